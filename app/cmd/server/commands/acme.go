@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"os"
+	"strings"
+
+	"wireport/internal/acme"
+	"wireport/internal/commands"
+
+	"github.com/spf13/cobra"
+)
+
+var acmeEmail string
+var acmeCADirectory string
+var acmeCARootFile string
+var acmeDNSProvider string
+var acmeDNSCredentials []string
+
+var ACMEGatewayCmd = &cobra.Command{
+	Use:   "acme",
+	Short: "Manage ACME settings of the gateway",
+	Long:  `Manage gateway-wide ACME settings used by Caddy to obtain certificates for https services: account email, CA directory and DNS-01 challenge provider.`,
+}
+
+var SetACMEGatewayCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Update ACME settings",
+	Long: `Update ACME settings of the gateway. Only the flags that are passed are changed.
+
+	A DNS provider enables the DNS-01 challenge: certificates are issued without pointing DNS to the gateway first,
+	and wildcard public hosts (e.g. https://*.preview.example.com) become available.
+	The provider name is a caddy-dns module built into wireport (cloudflare, digitalocean or route53), credentials are its Caddyfile options;
+	use {env.VAR} placeholders to read them from the environment of the gateway container instead of storing them.
+
+	For testing, point --ca to a local ACME server (e.g. pebble) and pass its root certificate with --ca-root-file.
+
+	Example:
+
+	wireport gateway acme set --email ops@example.com
+	wireport gateway acme set --dns-provider cloudflare --dns-credential api_token={env.CF_API_TOKEN}
+	wireport gateway acme set --ca https://pebble:14000/dir --ca-root-file ./pebble.minica.pem
+	wireport gateway acme set --dns-provider ""`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		update := acme.SettingsUpdate{}
+
+		if cmd.Flags().Changed("email") {
+			update.Email = &acmeEmail
+		}
+
+		if cmd.Flags().Changed("ca") {
+			update.CADirectory = &acmeCADirectory
+		}
+
+		if cmd.Flags().Changed("ca-root-file") {
+			caRootPEM := ""
+
+			if acmeCARootFile != "" {
+				contents, err := os.ReadFile(acmeCARootFile)
+
+				if err != nil {
+					cmd.PrintErrf("❌ Error: failed to read CA root certificate: %v\n", err)
+					setExitCode(commands.ExitCodeInvalidArgument)
+					return
+				}
+
+				caRootPEM = string(contents)
+			}
+
+			update.CARootPEM = &caRootPEM
+		}
+
+		if cmd.Flags().Changed("dns-provider") {
+			update.DNSProvider = &acmeDNSProvider
+		}
+
+		if cmd.Flags().Changed("dns-credential") {
+			update.DNSCredentials = map[string]string{}
+
+			for _, credential := range acmeDNSCredentials {
+				key, value, found := strings.Cut(credential, "=")
+
+				if !found || key == "" {
+					cmd.PrintErrf("❌ Error: DNS credential must be in key=value format: %s\n", credential)
+					setExitCode(commands.ExitCodeInvalidArgument)
+					return
+				}
+
+				update.DNSCredentials[key] = value
+			}
+		}
+
+		setExitCodeFromError(commandsService.ACMESet(cmd.OutOrStdout(), cmd.ErrOrStderr(), update))
+	},
+}
+
+var ShowACMEGatewayCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show ACME settings",
+	Long:  `Show ACME settings of the gateway (DNS credentials are masked).`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.ACMEShow(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var ResetACMEGatewayCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset ACME settings to Caddy defaults",
+	Long:  `Reset ACME settings of the gateway to Caddy defaults (HTTP-01/TLS-ALPN-01 challenges, Let's Encrypt and ZeroSSL).`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.ACMEReset(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	SetACMEGatewayCmd.Flags().StringVar(&acmeEmail, "email", "", "ACME account email (used for certificate expiry notices)")
+	SetACMEGatewayCmd.Flags().StringVar(&acmeCADirectory, "ca", "", "ACME directory URL (e.g. https://acme-staging-v02.api.letsencrypt.org/directory), empty for Caddy defaults")
+	SetACMEGatewayCmd.Flags().StringVar(&acmeCARootFile, "ca-root-file", "", "Path to a PEM root certificate to trust for the ACME directory (e.g. pebble), empty to remove")
+	SetACMEGatewayCmd.Flags().StringVar(&acmeDNSProvider, "dns-provider", "", "DNS provider for the DNS-01 challenge (cloudflare, digitalocean or route53), empty to disable")
+	SetACMEGatewayCmd.Flags().StringArrayVar(&acmeDNSCredentials, "dns-credential", []string{}, "DNS provider credential in key=value format (repeatable, replaces all credentials)")
+
+	ACMEGatewayCmd.AddCommand(SetACMEGatewayCmd)
+	ACMEGatewayCmd.AddCommand(ShowACMEGatewayCmd)
+	ACMEGatewayCmd.AddCommand(ResetACMEGatewayCmd)
+
+	GatewayCmd.AddCommand(ACMEGatewayCmd)
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"wireport/cmd/server/config"
+	"wireport/internal/commands"
+
+	"github.com/spf13/cobra"
+)
+
+var caDir string
+var caOutDir string
+var caRootName string
+var caIntermediateName string
+
+var CACmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Offline root CA for the mTLS certificates of the nodes",
+	Long: `Manage a root CA kept away from the gateway, e.g. on an encrypted USB drive or an air-gapped machine.
+
+The root signs a gateway intermediate, and the intermediate signs the mTLS certificates of the nodes. The nodes trust the root only,
+so a compromised gateway is recovered by revoking its intermediate and importing a new one, instead of joining every node again:
+
+  wireport ca init --ca-dir ./ca
+  wireport ca intermediate --ca-dir ./ca --out ./gateway-ca
+  wireport gateway up <user>@<host> --ca-dir ./gateway-ca
+
+An existing root is used by putting its certificate and key (PEM) into the CA directory as root-ca.crt and root-ca.key.`,
+}
+
+var InitCACmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate a root CA to keep offline",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.CAInit(caDir, caRootName, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var IntermediateCACmd = &cobra.Command{
+	Use:   "intermediate",
+	Short: "Issue a gateway intermediate signed by the root CA",
+	Long: `Issue a gateway intermediate signed by the root CA. The intermediate, the root certificate and the revocation list of the root
+are written to the output directory: pass it to 'wireport gateway up --ca-dir', or copy it to the ca directory next to the database
+of a running gateway and run 'wireport gateway ca import' there.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if caOutDir == "" {
+			cmd.PrintErrf("❌ Error: --out is required\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		setExitCodeFromError(commandsService.CAIntermediate(caDir, caOutDir, caIntermediateName, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RevokeCACmd = &cobra.Command{
+	Use:   "revoke <intermediate.crt>",
+	Short: "Revoke a gateway intermediate",
+	Long: `Add a gateway intermediate to the revocation list of the root CA, e.g. after the gateway holding it was compromised.
+
+The list reaches the gateway and the nodes with the next intermediate: issue one with 'wireport ca intermediate' and import it
+with 'wireport gateway ca import'. The nodes then renew their certificates on their next request and refuse a gateway
+presenting a certificate of the revoked intermediate.
+
+Revoke the previous intermediate when replacing it before it expires as well, the nodes only renew certificates of revoked intermediates.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.CARevoke(caDir, args[0], cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var CAGatewayCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Manage the CA the gateway signs node certificates with",
+}
+
+var ImportCAGatewayCmd = &cobra.Command{
+	Use:   "import [dir]",
+	Short: "Sign the gateway certificates with an intermediate issued by 'wireport ca intermediate'",
+	Long: `Sign the gateway certificates with an intermediate issued by 'wireport ca intermediate', taken from the ca directory next
+to the database by default (~/.wireport-docker/gateway/ca on the host of a gateway bootstrapped with 'gateway up').
+The gateway issues a new server certificate and serves it right away; the key of the intermediate is removed from the directory.
+
+An intermediate of another root is only accepted before any server or client has joined.
+
+Run it inside the gateway container: docker exec wireport-gateway wireport gateway ca import`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := config.Config.GatewayCADir
+
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		setExitCodeFromError(commandsService.GatewayCAImport(dir, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var StatusCAGatewayCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the CAs the gateway certificates are signed by",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.GatewayCAStatus(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	CACmd.PersistentFlags().StringVar(&caDir, "ca-dir", ".", "Directory of the root CA (root-ca.crt, root-ca.key, root-ca.crl)")
+
+	InitCACmd.Flags().StringVar(&caRootName, "name", "wireport offline Root CA", "Common name of the root CA")
+
+	IntermediateCACmd.Flags().StringVar(&caOutDir, "out", "", "Directory the intermediate is written to")
+	IntermediateCACmd.Flags().StringVar(&caIntermediateName, "name", "wireport gateway Intermediate CA", "Common name of the intermediate")
+
+	CACmd.AddCommand(InitCACmd)
+	CACmd.AddCommand(IntermediateCACmd)
+	CACmd.AddCommand(RevokeCACmd)
+
+	CAGatewayCmd.AddCommand(ImportCAGatewayCmd)
+	CAGatewayCmd.AddCommand(StatusCAGatewayCmd)
+
+	GatewayCmd.AddCommand(CAGatewayCmd)
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"os"
+	"wireport/internal/commands"
+
+	"github.com/spf13/cobra"
+)
+
+var certFile string
+var certKeyFile string
+var certHosts []string
+
+var CertCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Manage TLS certificates of public services",
+	Long:  `Manage user-provided TLS certificates (e.g. corporate CA or EV certificates) the gateway serves for https public services instead of obtaining them via ACME.`,
+}
+
+var AddCertCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a TLS certificate",
+	Long: `Add a PEM-encoded certificate (with its chain) and private key to the gateway and serve it for the given public hosts.
+If no --host is passed, the certificate is used for all of its DNS names. Wildcard hosts (*.example.com) cover all https services one level below.
+
+Example:
+
+wireport cert add --cert ./fullchain.pem --key ./privkey.pem
+wireport cert add --cert ./fullchain.pem --key ./privkey.pem --host app.example.com --host api.example.com`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if certFile == "" || certKeyFile == "" {
+			cmd.PrintErrf("❌ Error: both --cert and --key are required\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		certPEM, err := os.ReadFile(certFile)
+
+		if err != nil {
+			cmd.PrintErrf("❌ Error: failed to read certificate: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		keyPEM, err := os.ReadFile(certKeyFile)
+
+		if err != nil {
+			cmd.PrintErrf("❌ Error: failed to read private key: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		setExitCodeFromError(commandsService.CertAdd(cmd.OutOrStdout(), cmd.ErrOrStderr(), string(certPEM), string(keyPEM), certHosts))
+	},
+}
+
+var ListCertCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List TLS certificates",
+	Long:  `List user-provided TLS certificates with their hosts and expiry dates.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.CertList(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RemoveCertCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Remove a TLS certificate",
+	Long:  `Remove a user-provided TLS certificate. Its hosts are served with certificates obtained via ACME again.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.CertRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0]))
+	},
+}
+
+func init() {
+	CertCmd.AddCommand(AddCertCmd)
+	CertCmd.AddCommand(ListCertCmd)
+	CertCmd.AddCommand(RemoveCertCmd)
+
+	AddCertCmd.Flags().StringVar(&certFile, "cert", "", "Path to the PEM-encoded certificate (chain)")
+	AddCertCmd.Flags().StringVar(&certKeyFile, "key", "", "Path to the PEM-encoded private key")
+	AddCertCmd.Flags().StringArrayVar(&certHosts, "host", []string{}, "Public host to use the certificate for (repeatable, defaults to the DNS names of the certificate)")
+}
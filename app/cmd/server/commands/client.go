@@ -1,12 +1,19 @@
 package commands
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"wireport/internal/commands"
+
 	"github.com/spf13/cobra"
 )
 
 var joinRequestClientCreation = false
 var quietClientCreation = false
 var waitClientCreation = false
+var clientCertPassword = ""
+var clientCertOutput = ""
 
 var ClientCmd = &cobra.Command{
 	Use:   "client",
@@ -22,7 +29,7 @@ var NewClientCmd = &cobra.Command{
 The join-request will generate a token that can be used to join the network (see 'wireport join' command).
 If used without -j, the command will create a client directly and such client won't be able to manage the gateway, although it will have access to the network.`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		commandsService.ClientNew(cmd.OutOrStdout(), cmd.ErrOrStderr(), joinRequestClientCreation, quietClientCreation, waitClientCreation)
+		setExitCodeFromError(commandsService.ClientNew(cmd.OutOrStdout(), cmd.ErrOrStderr(), joinRequestClientCreation, quietClientCreation, waitClientCreation))
 	},
 }
 
@@ -31,7 +38,53 @@ var ListClientCmd = &cobra.Command{
 	Short: "List all clients",
 	Long:  `List all clients that are connected to the wireport network`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		commandsService.ClientList(nil, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.ClientList(nil, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var CertClientCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Manage client certificates for public services",
+	Long:  `Manage client certificates that grant browsers and devices access to public services with client authentication enabled (see 'wireport service client-auth').`,
+}
+
+var IssueCertClientCmd = &cobra.Command{
+	Use:   "issue <name>",
+	Short: "Issue a client certificate as a PKCS#12 bundle",
+	Long: `Issue a client certificate signed by the wireport public services client CA and save it as a password-protected PKCS#12 (.p12) bundle for browsers and OS keychains.
+
+If no password is passed, a random one is generated and printed.
+
+Example:
+
+wireport client cert issue alice-laptop
+wireport client cert issue alice-laptop --password 'secret' --output ./alice.p12`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		password := clientCertPassword
+
+		if password == "" {
+			randomBytes := make([]byte, 12)
+
+			if _, err := rand.Read(randomBytes); err != nil {
+				cmd.PrintErrf("❌ Error: failed to generate password: %v\n", err)
+				setExitCode(commands.ExitCodeFailure)
+				return
+			}
+
+			password = base64.RawURLEncoding.EncodeToString(randomBytes)
+
+			cmd.Printf("🔑 Bundle password: %s\n", password)
+		}
+
+		outputPath := clientCertOutput
+
+		if outputPath == "" {
+			outputPath = fmt.Sprintf("%s.p12", name)
+		}
+
+		setExitCodeFromError(commandsService.ClientCertIssue(cmd.OutOrStdout(), cmd.ErrOrStderr(), name, password, outputPath))
 	},
 }
 
@@ -42,4 +95,10 @@ func init() {
 
 	ClientCmd.AddCommand(NewClientCmd)
 	ClientCmd.AddCommand(ListClientCmd)
+
+	IssueCertClientCmd.Flags().StringVar(&clientCertPassword, "password", "", "Password of the PKCS#12 bundle (random if not set)")
+	IssueCertClientCmd.Flags().StringVarP(&clientCertOutput, "output", "o", "", "Path to save the PKCS#12 bundle to (defaults to <name>.p12)")
+
+	CertClientCmd.AddCommand(IssueCertClientCmd)
+	ClientCmd.AddCommand(CertClientCmd)
 }
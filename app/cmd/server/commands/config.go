@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"wireport/cmd/server/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "wireport configuration",
+	Long: `Settings are taken from, in this order of precedence: flags, environment variables, config files and the defaults.
+The ports, the Docker network, the container names and the logs have a flag of every command, e.g. --control-server-port.
+
+The config files are YAML files of settings (see 'wireport config show' for all of them), read in this order,
+the later taking precedence:
+
+  ` + config.SystemConfigFile + `          all profiles of this machine
+  ~/.wireport/<profile>/` + config.ConfigFileName + `     a profile, next to its database (config.yaml in the data
+                                     directory of the gateway and server containers)
+
+For example:
+
+  control_server_port: 4070
+  cert_expiry: 8760h
+  gateway_container_name: wireport-gateway-staging
+  log_level: debug
+
+The ports, the health server address, the Docker network, the container names and images and the data directory of the
+gateway are taken from the configuration of the CLI running 'wireport gateway up' and 'wireport gateway upgrade', keep
+them the same for both.`,
+}
+
+var ShowConfigCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and where every setting comes from",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		var changedFlags []string
+
+		cmd.Flags().Visit(func(flag *pflag.Flag) {
+			changedFlags = append(changedFlags, flag.Name)
+		})
+
+		setExitCodeFromError(commandsService.ConfigShow(changedFlags, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	ConfigCmd.AddCommand(ShowConfigCmd)
+}
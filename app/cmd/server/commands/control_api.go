@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"time"
+
+	"wireport/internal/controlapi"
+
+	"github.com/spf13/cobra"
+)
+
+var controlAPIOverlayOnly bool
+var controlAPIJoinRateLimit int
+var controlAPIJoinWindow time.Duration
+
+var APIGatewayCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Manage access to the control API of the gateway",
+	Long:  `Manage who may reach the control API of the gateway (port 4060) on its public address: all routes, or join requests only with the rest served over the WireGuard network.`,
+}
+
+var SetAPIGatewayCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Update control API settings",
+	Long: `Update control API settings of the gateway. Only the flags that are passed are changed, the settings apply to the next request.
+
+	In the overlay-only mode the public address of the gateway serves join requests only; all other commands are served
+	on the WireGuard address of the gateway (e.g. 10.0.0.1:4060). Clients and servers switch to it on their own once their tunnel is up,
+	so bring the tunnel up on the client you manage the network from before enabling the mode.
+
+	Join requests on the public address can be rate-limited per IP and time-boxed: a join token is only accepted there
+	for the given time after it was issued with 'wireport server new' or 'wireport client new'.
+
+	Example:
+
+	wireport gateway api set --overlay-only
+	wireport gateway api set --join-rate-limit 10 --join-window 30m
+	wireport gateway api set --overlay-only=false`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		update := controlapi.SettingsUpdate{}
+
+		if cmd.Flags().Changed("overlay-only") {
+			update.OverlayOnly = &controlAPIOverlayOnly
+		}
+
+		if cmd.Flags().Changed("join-rate-limit") {
+			update.JoinRateLimit = &controlAPIJoinRateLimit
+		}
+
+		if cmd.Flags().Changed("join-window") {
+			update.JoinWindow = &controlAPIJoinWindow
+		}
+
+		setExitCodeFromError(commandsService.ControlAPISet(cmd.OutOrStdout(), cmd.ErrOrStderr(), update))
+	},
+}
+
+var ShowAPIGatewayCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show control API settings",
+	Long:  `Show control API settings of the gateway and the addresses the control API is served on.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.ControlAPIShow(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var ResetAPIGatewayCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset control API settings to defaults",
+	Long:  `Reset control API settings of the gateway to defaults: all routes on all addresses, no limits on join requests.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.ControlAPIReset(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	SetAPIGatewayCmd.Flags().BoolVar(&controlAPIOverlayOnly, "overlay-only", true, "Serve join requests only on the public address, all other routes on the WireGuard address of the gateway")
+	SetAPIGatewayCmd.Flags().IntVar(&controlAPIJoinRateLimit, "join-rate-limit", 0, "Join requests per minute accepted from a single IP on the public address, 0 for no limit")
+	SetAPIGatewayCmd.Flags().DurationVar(&controlAPIJoinWindow, "join-window", 0, "How long after issuing a join token it is accepted on the public address (e.g. 30m), 0 for no limit")
+
+	APIGatewayCmd.AddCommand(SetAPIGatewayCmd)
+	APIGatewayCmd.AddCommand(ShowAPIGatewayCmd)
+	APIGatewayCmd.AddCommand(ResetAPIGatewayCmd)
+
+	GatewayCmd.AddCommand(APIGatewayCmd)
+}
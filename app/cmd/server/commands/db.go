@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var DBCmd = &cobra.Command{
+	Use:   "db",
+	Short: "wireport database commands",
+	Long: `Inspect and maintain the local database of the node.
+
+The private keys in the database (WireGuard keys, the mTLS certificate bundles and the keys of join requests) are encrypted
+with a master key, taken from the WIREPORT_MASTER_KEY environment variable (base64, 32 bytes), the key file
+(WIREPORT_MASTER_KEY_FILE, master.key next to the database by default) or the OS keyring, in this order.
+A new key is generated on the first start: in the OS keyring if there is one (macOS keychain, Secret Service on Linux desktops),
+in the key file otherwise. A key file in the directory of the database decrypts any copy of that directory,
+keep it elsewhere (WIREPORT_MASTER_KEY or a WIREPORT_MASTER_KEY_FILE outside of it).`,
+}
+
+var MigrateDBCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Schema migrations of the database",
+	Long: `wireport applies the pending schema migrations and table changes of its database on start, after copying the database into the backups directory.
+A database migrated by a newer wireport build is refused, upgrade wireport or restore a backup.`,
+}
+
+var StatusMigrateDBCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema version and the applied and pending migrations",
+	Long: `Show the schema version of the local database, every migration known to this build with the time it was applied,
+and the migrations applied by newer builds. The database is not migrated, a database migrated by a newer build is
+reported with a non-zero exit code.
+
+On the gateway, run it inside the container: docker exec wireport-gateway wireport db migrate status`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.DBMigrateStatus(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RekeyDBCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt the private keys in the database with a new master key",
+	Long: `Generate a new master key, re-encrypt the private keys in the local database with it and save it in place of the current key.
+The database is copied into the backups directory first; the copy needs the previous key.
+
+The previous key is saved next to the new one (the key file or the OS keyring entry, suffixed with its ID) and is used to
+read the backup when it is restored. A master key from WIREPORT_MASTER_KEY cannot be replaced by wireport: the new key
+is printed, set it before wireport starts again.
+
+The database is not re-encrypted while a wireport gateway or server runs on it. On the gateway, stop the gateway service
+inside the container first:
+
+docker exec wireport-gateway sh -c 'sv stop /etc/service/wireport-gateway && wireport db rekey; sv start /etc/service/wireport-gateway'`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.DBRekey(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	MigrateDBCmd.AddCommand(StatusMigrateDBCmd)
+	DBCmd.AddCommand(MigrateDBCmd)
+	DBCmd.AddCommand(RekeyDBCmd)
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"wireport/internal/dnsrecords"
+
+	"github.com/spf13/cobra"
+)
+
+var dnsRecordType string
+var dnsUpstreams []string
+var dnsTLSServerName string
+var dnsServerFanout bool
+var dnsServerZones bool
+
+var DNSCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Manage the wireport DNS zone",
+	Long: `Manage the wireport DNS zone (wp.internal) served by the gateway to all nodes of the network.
+Every node is resolvable as <name>.wp.internal (see 'wireport node rename'), user-defined records are added with 'wireport dns record'.`,
+}
+
+var RecordDNSCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Manage user-defined DNS records",
+	Long:  `Manage user-defined A and CNAME records of the wireport DNS zone, e.g. for services running outside of docker on a client or server.`,
+}
+
+var AddRecordDNSCmd = &cobra.Command{
+	Use:   "add <name> <value>",
+	Short: "Add a DNS record",
+	Long: `Add an A (IPv4 address) or CNAME (host name) record to the wireport DNS zone. The name is relative to the zone (db resolves as db.wp.internal).
+CNAME targets without dots are relative to the zone too (e.g. a node name).
+
+Example:
+
+wireport dns record add nas 10.0.0.4
+wireport dns record add grafana server-3 --type CNAME`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.DNSRecordAdd(cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0], dnsRecordType, args[1]))
+	},
+}
+
+var ListRecordDNSCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List DNS records",
+	Long:  `List all names of the wireport DNS zone: the names of the nodes and the user-defined records.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.DNSRecordList(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RemoveRecordDNSCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a DNS record",
+	Long:  `Remove a user-defined record from the wireport DNS zone.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.DNSRecordRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0]))
+	},
+}
+
+var ConfigDNSCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage DNS settings of the gateway",
+	Long:  `Manage gateway-wide name resolution settings: upstream resolvers (plain or DNS-over-TLS), the fanout to the docker DNS of the servers and per-server zones.`,
+}
+
+var SetConfigDNSCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Update DNS settings",
+	Long: `Update DNS settings of the gateway. Only the flags that are passed are changed, CoreDNS of the gateway is restarted to apply them.
+
+	Upstreams resolve the names outside of the network (8.8.8.8 and 1.1.1.1 by default). Prefix an upstream with tls:// to use DNS-over-TLS
+	and pass the name its certificate is issued for with --tls-servername. Passing --upstream replaces all upstreams, --upstream "" restores the defaults.
+
+	With the server fanout (enabled by default) names without a zone, e.g. docker container names, are asked from the docker DNS of all servers
+	one after another before the upstreams. Disable it when servers run containers with the same names.
+
+	Server zones make <name>.<server>.wp.internal resolve by the docker DNS of that server only (e.g. db.server-3.wp.internal).
+
+	Example:
+
+	wireport dns config set --upstream 10.0.0.53 --upstream 1.1.1.1
+	wireport dns config set --upstream tls://9.9.9.9 --upstream tls://149.112.112.112 --tls-servername dns.quad9.net
+	wireport dns config set --server-zones --server-fanout=false
+	wireport dns config set --upstream ""`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		update := dnsrecords.SettingsUpdate{}
+
+		if cmd.Flags().Changed("upstream") {
+			update.Upstreams = []string{}
+
+			for _, upstream := range dnsUpstreams {
+				if upstream != "" {
+					update.Upstreams = append(update.Upstreams, upstream)
+				}
+			}
+		}
+
+		if cmd.Flags().Changed("tls-servername") {
+			update.TLSServerName = &dnsTLSServerName
+		}
+
+		if cmd.Flags().Changed("server-fanout") {
+			update.ServerFanout = &dnsServerFanout
+		}
+
+		if cmd.Flags().Changed("server-zones") {
+			update.ServerZones = &dnsServerZones
+		}
+
+		setExitCodeFromError(commandsService.DNSConfigSet(cmd.OutOrStdout(), cmd.ErrOrStderr(), update))
+	},
+}
+
+var ShowConfigDNSCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show DNS settings",
+	Long:  `Show DNS settings of the gateway.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.DNSConfigShow(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var ResetConfigDNSCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset DNS settings to defaults",
+	Long:  `Reset DNS settings of the gateway to defaults: 8.8.8.8 and 1.1.1.1 upstreams, fanout to the servers, no server zones.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.DNSConfigReset(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	DNSCmd.AddCommand(RecordDNSCmd)
+	DNSCmd.AddCommand(ConfigDNSCmd)
+
+	RecordDNSCmd.AddCommand(AddRecordDNSCmd)
+	RecordDNSCmd.AddCommand(ListRecordDNSCmd)
+	RecordDNSCmd.AddCommand(RemoveRecordDNSCmd)
+
+	AddRecordDNSCmd.Flags().StringVarP(&dnsRecordType, "type", "t", "A", "Record type (A or CNAME)")
+
+	ConfigDNSCmd.AddCommand(SetConfigDNSCmd)
+	ConfigDNSCmd.AddCommand(ShowConfigDNSCmd)
+	ConfigDNSCmd.AddCommand(ResetConfigDNSCmd)
+
+	SetConfigDNSCmd.Flags().StringArrayVar(&dnsUpstreams, "upstream", []string{}, "Upstream resolver: IP address with an optional port, tls:// prefix for DNS-over-TLS (repeatable, replaces all upstreams, empty restores the defaults)")
+	SetConfigDNSCmd.Flags().StringVar(&dnsTLSServerName, "tls-servername", "", "Name the certificates of the DNS-over-TLS upstreams are verified against (e.g. dns.quad9.net), empty to remove")
+	SetConfigDNSCmd.Flags().BoolVar(&dnsServerFanout, "server-fanout", true, "Ask the docker DNS of all servers for names without a zone before the upstreams")
+	SetConfigDNSCmd.Flags().BoolVar(&dnsServerZones, "server-zones", false, "Resolve <name>.<server>.wp.internal by the docker DNS of that server only")
+}
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the connectivity of this node to the wireport network",
+	Long: `Run end-to-end connectivity checks from a client or server node and print actionable fixes for the failed ones:
+
+- the gateway control API is reachable
+- the WireGuard handshake with the gateway is fresh
+- the gateway is reachable over the WireGuard tunnel
+- container names of the services published by this node resolve through CoreDNS
+- the Caddy config of the gateway contains the services published by this node
+- the gateway reaches the upstreams of these services
+
+On a server node, run it inside the wireport container: docker exec wireport-server wireport doctor`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.Doctor(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
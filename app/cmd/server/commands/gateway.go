@@ -1,8 +1,13 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"os/signal"
+	"syscall"
 	"wireport/cmd/server/config"
+	"wireport/internal/commands"
+	"wireport/internal/database"
 	"wireport/internal/routes"
 	"wireport/internal/ssh"
 	"wireport/internal/utils"
@@ -15,6 +20,7 @@ var GatewayStartConfigureOnly = false
 var GatewaySSHKeyPassEmpty = false
 var GatewayDockerImage = config.Config.WireportGatewayContainerImage
 var GatewayDockerImageTag = version.Version
+var GatewayCADir = ""
 var forceGatewayTeardown = false
 
 var GatewayCmd = &cobra.Command{
@@ -32,12 +38,28 @@ var StartGatewayCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.PrintErrf("Error: %v\n", err)
+			setExitCode(commands.ExitCodeFailure)
 			return
 		}
 
+		// 'db rekey' is refused while this process writes to the database
+		release, err := database.HoldDatabase()
+
+		if err != nil {
+			cmd.PrintErrf("Error: %v\n", err)
+			setExitCodeFromError(err)
+			return
+		}
+
+		defer release()
+
 		router := routes.Router(dbInstance)
 
-		commandsService.GatewayStart(*gatewayPublicIP, cmd.OutOrStdout(), cmd.ErrOrStderr(), GatewayStartConfigureOnly, router)
+		// SIGTERM is sent by runit when the service is stopped and by docker when the container is stopped
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		setExitCodeFromError(commandsService.GatewayStart(ctx, *gatewayPublicIP, cmd.OutOrStdout(), cmd.ErrOrStderr(), GatewayStartConfigureOnly, router))
 	},
 }
 
@@ -54,27 +76,32 @@ If no username@hostname[:port] is provided, the command will use the IP address
 
 		if err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.GatewayStatus(creds, cmd.OutOrStdout())
+		setExitCodeFromError(commandsService.GatewayStatus(creds, cmd.OutOrStdout()))
 	},
 }
 
 var UpGatewayCmd = &cobra.Command{
 	Use:   "up username@hostname[:port]",
 	Short: "Bootstrap wireport gateway node",
-	Long:  `Bootstrap wireport gateway node: install and configure wireport software in gateway mode on it.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Bootstrap wireport gateway node: install and configure wireport software in gateway mode on it.
+
+By default the gateway generates a root CA of its own for the mTLS certificates of the nodes. With --ca-dir, it signs them
+with an intermediate issued by 'wireport ca intermediate' instead, and the key of the root never reaches the gateway.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		creds, err := buildSSHCredentials(cmd, args, true, true, GatewaySSHKeyPassEmpty)
 
 		if err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.GatewayUp(creds, GatewayDockerImage, GatewayDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.GatewayUp(creds, GatewayDockerImage, GatewayDockerImageTag, GatewayCADir, cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
@@ -95,11 +122,13 @@ var DownGatewayCmd = &cobra.Command{
 
 			if err != nil {
 				cmd.PrintErrf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeFailure)
 				return
 			}
 
 			if confirm != "y" {
 				cmd.PrintErrf("❌ Aborted\n")
+				setExitCode(commands.ExitCodeFailure)
 				return
 			}
 		}
@@ -109,11 +138,12 @@ var DownGatewayCmd = &cobra.Command{
 
 			if err != nil {
 				cmd.PrintErrf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
 				return
 			}
 		}
 
-		commandsService.GatewayDown(creds, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.GatewayDown(creds, cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
@@ -127,10 +157,11 @@ var UpgradeGatewayCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.GatewayUpgrade(creds, GatewayDockerImage, GatewayDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.GatewayUpgrade(creds, GatewayDockerImage, GatewayDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
@@ -150,6 +181,7 @@ func init() {
 	UpGatewayCmd.Flags().BoolVar(&GatewaySSHKeyPassEmpty, "ssh-key-pass-empty", false, "Skip SSH key passphrase prompt (for passwordless SSH keys)")
 	UpGatewayCmd.Flags().StringVar(&GatewayDockerImage, "image", config.Config.WireportGatewayContainerImage, "Docker image to use for the wireport gateway container")
 	UpGatewayCmd.Flags().StringVar(&GatewayDockerImageTag, "image-tag", version.Version, "Image tag to use for the wireport gateway container")
+	UpGatewayCmd.Flags().StringVar(&GatewayCADir, "ca-dir", "", "Directory with a gateway intermediate issued by 'wireport ca intermediate', signed by an offline root CA")
 
 	DownGatewayCmd.Flags().String("ssh-key-path", "", "Path to SSH private key file (for passwordless authentication)")
 	DownGatewayCmd.Flags().BoolVar(&GatewaySSHKeyPassEmpty, "ssh-key-pass-empty", false, "Skip SSH key passphrase prompt (for passwordless SSH keys)")
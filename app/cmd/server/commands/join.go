@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"wireport/internal/commands"
+
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +15,7 @@ var JoinCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 1 {
 			cmd.PrintErrf("Provide a join token\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
@@ -20,6 +23,7 @@ var JoinCmd = &cobra.Command{
 
 		if joinToken == "" {
 			cmd.PrintErrf("Provide a join token\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
@@ -28,12 +32,13 @@ var JoinCmd = &cobra.Command{
 
 			if err != nil {
 				cmd.PrintErrf("Failed to create join token: %v\n", err)
+				setExitCode(commands.ExitCodeFailure)
 				return
 			}
 
 			cmd.Printf("Join token has been saved and will be applied on the next server start\n")
 		} else {
-			commandsService.Join(cmd.OutOrStdout(), cmd.ErrOrStderr(), joinToken)
+			setExitCodeFromError(commandsService.Join(cmd.OutOrStdout(), cmd.ErrOrStderr(), joinToken))
 		}
 	},
 }
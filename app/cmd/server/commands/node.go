@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var NodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "wireport node commands",
+	Long:  `Inspect the nodes (gateway, servers and clients) of the wireport network.`,
+}
+
+var StatusNodeCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show all nodes with their live WireGuard connection state",
+	Long: `Show all nodes of the wireport network with their role, private IP, labels and the live state of their WireGuard peer on the gateway:
+last handshake, endpoint, received / sent bytes and whether the node is online, stale (no handshake recently) or never connected.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.NodeStatus(nil, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RenameNodeCmd = &cobra.Command{
+	Use:   "rename <node-ip> <name>",
+	Short: "Rename a node in the wireport DNS zone",
+	Long: `Set the name the node is resolvable as on all nodes of the network (<name>.wp.internal).
+Nodes are named after their role and the last octet of their private IP by default (e.g. server-3), pass an empty name to restore it.
+
+Example:
+
+wireport node rename 10.0.0.4 alice-laptop`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.NodeRename(cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0], args[1]))
+	},
+}
+
+func init() {
+	NodeCmd.AddCommand(StatusNodeCmd)
+	NodeCmd.AddCommand(RenameNodeCmd)
+}
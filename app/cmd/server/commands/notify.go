@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"wireport/internal/commands"
+
+	"github.com/spf13/cobra"
+)
+
+var notifyURL string
+var notifySecret string
+var notifyEvents []string
+
+var NotifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage webhook notifications",
+	Long: `Manage webhooks the gateway notifies about node and service lifecycle events:
+
+server.joined, server.left, service.published, service.unpublished, peer.stale, certificate.expiring
+
+Events are posted as JSON, signed with HMAC-SHA256 of the body in the X-Wireport-Signature header (sha256=<hex>) if a secret is set,
+and retried with exponential backoff until delivered.`,
+}
+
+var AddNotifyCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a webhook",
+	Long: `Add a webhook the gateway posts events to. If no --event is passed, the webhook receives all events.
+
+Example:
+
+wireport notify add --url https://hooks.example.com/wireport --secret "$WEBHOOK_SECRET"
+wireport notify add --url https://hooks.example.com/wireport --event server.joined --event server.left --event peer.stale`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if notifyURL == "" {
+			cmd.PrintErrf("❌ Error: --url is required\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		setExitCodeFromError(commandsService.NotifyAdd(cmd.OutOrStdout(), cmd.ErrOrStderr(), notifyURL, notifySecret, notifyEvents))
+	},
+}
+
+var ListNotifyCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List webhooks",
+	Long:  `List webhooks with their event filters and the number of pending and failed deliveries.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.NotifyList(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RemoveNotifyCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Remove a webhook",
+	Long:  `Remove a webhook together with its undelivered events.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.NotifyRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0]))
+	},
+}
+
+var TestNotifyCmd = &cobra.Command{
+	Use:   "test <id>",
+	Short: "Send a test event to a webhook",
+	Long:  `Send a test event to a webhook right away and report whether it was accepted (any 2xx response).`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.NotifyTest(cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0]))
+	},
+}
+
+func init() {
+	NotifyCmd.AddCommand(AddNotifyCmd)
+	NotifyCmd.AddCommand(ListNotifyCmd)
+	NotifyCmd.AddCommand(RemoveNotifyCmd)
+	NotifyCmd.AddCommand(TestNotifyCmd)
+
+	AddNotifyCmd.Flags().StringVar(&notifyURL, "url", "", "URL the events are posted to")
+	AddNotifyCmd.Flags().StringVar(&notifySecret, "secret", "", "Secret for the HMAC-SHA256 signature of the events (X-Wireport-Signature header)")
+	AddNotifyCmd.Flags().StringArrayVar(&notifyEvents, "event", []string{}, "Event to send (repeatable, defaults to all events)")
+}
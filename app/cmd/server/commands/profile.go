@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"fmt"
+	"wireport/internal/commands"
+
+	"github.com/spf13/cobra"
+)
+
+var forceProfileDeletion = false
+
+var ProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage the wireport networks of this machine",
+	Long: `Every profile is a wireport network managed from this machine, with its own database in ~/.wireport/<profile>
+(e.g. staging and production gateways managed from the same laptop).
+
+The profile of a command is selected by the --profile flag, the WIREPORT_PROFILE environment variable or
+'wireport profile use', in this order; the profile named default is used otherwise.`,
+}
+
+var ListProfileCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles with the gateway each of them joined",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.ProfileList(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var ShowProfileCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show a profile, the current one by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		setExitCodeFromError(commandsService.ProfileShow(name, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var UseProfileCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a profile the current one",
+	Long: `Make a profile the current one, used by the commands unless --profile or WIREPORT_PROFILE select another.
+A new profile gets its database with the first command run in it, e.g. 'wireport join' or 'wireport gateway up'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.ProfileUse(args[0], cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var RenameProfileCmd = &cobra.Command{
+	Use:   "rename <name> <new-name>",
+	Short: "Rename a profile",
+	Long:  `Rename a profile, together with its master key in the OS keyring. The current profile selection follows the renamed profile.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setExitCodeFromError(commandsService.ProfileRename(args[0], args[1], cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+var DeleteProfileCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a profile",
+	Long: `Delete a profile: its database, the backups of the database and its master key. The node of the profile stays
+registered at its gateway. The current profile cannot be deleted, switch to another one with 'wireport profile use' first.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !forceProfileDeletion {
+			cmd.Printf("🔴 WARNING: This command will destroy the database and the keys of profile %s.\nAre you sure you want to continue? (y/n): ", args[0])
+
+			var confirm string
+			_, err := fmt.Scanln(&confirm)
+
+			if err != nil {
+				cmd.PrintErrf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeFailure)
+				return
+			}
+
+			if confirm != "y" {
+				cmd.PrintErrf("❌ Aborted\n")
+				setExitCode(commands.ExitCodeFailure)
+				return
+			}
+		}
+
+		setExitCodeFromError(commandsService.ProfileDelete(args[0], cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+func init() {
+	DeleteProfileCmd.Flags().BoolVarP(&forceProfileDeletion, "force", "f", false, "Delete the profile without the confirmation prompt")
+
+	ProfileCmd.AddCommand(ListProfileCmd)
+	ProfileCmd.AddCommand(ShowProfileCmd)
+	ProfileCmd.AddCommand(UseProfileCmd)
+	ProfileCmd.AddCommand(RenameProfileCmd)
+	ProfileCmd.AddCommand(DeleteProfileCmd)
+}
@@ -1,11 +1,18 @@
 package commands
 
 import (
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
 	"wireport/internal/commands"
+	"wireport/internal/controlapi"
+	"wireport/internal/database"
+	"wireport/internal/dnsrecords"
 	"wireport/internal/joinrequests"
 	"wireport/internal/jointokens"
 	"wireport/internal/nodes"
+	"wireport/internal/notifications"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
 
 	"github.com/spf13/cobra"
 	"gorm.io/gorm"
@@ -17,30 +24,107 @@ var (
 	joinRequestsRepository   *joinrequests.Repository
 	publicServicesRepository *publicservices.Repository
 	joinTokensRepository     *jointokens.Repository
+	acmeRepository           *acme.Repository
+	certificatesRepository   *certificates.Repository
+	notificationsRepository  *notifications.Repository
+	dnsRecordsRepository     *dnsrecords.Repository
+	controlAPIRepository     *controlapi.Repository
+	rolloutsRepository       *rollouts.Repository
+	schemaRepository         *database.SchemaRepository
 	commandsService          *commands.Service
 )
 
-func RegisterCommands(rootCmd *cobra.Command, db *gorm.DB) {
+// exitCode is the exit code of the executed command, see commands.ExitCode
+var exitCode = commands.ExitCodeOK
+
+// ExitCode returns the exit code of the executed command
+func ExitCode() int {
+	return exitCode
+}
+
+// setExitCode records the exit code of the executed command, the first failure wins
+func setExitCode(code int) {
+	if exitCode == commands.ExitCodeOK {
+		exitCode = code
+	}
+}
+
+func setExitCodeFromError(err error) {
+	setExitCode(commands.ExitCode(err))
+}
+
+// RegisterServices creates the services the commands run with, db is nil for commands that do not require one
+func RegisterServices(db *gorm.DB) {
 	dbInstance = db
 	nodesRepository = nodes.NewRepository(db)
 	joinRequestsRepository = joinrequests.NewRepository(db)
 	publicServicesRepository = publicservices.NewRepository(db)
 	joinTokensRepository = jointokens.NewRepository(db)
+	acmeRepository = acme.NewRepository(db)
+	certificatesRepository = certificates.NewRepository(db)
+	notificationsRepository = notifications.NewRepository(db)
+	dnsRecordsRepository = dnsrecords.NewRepository(db)
+	controlAPIRepository = controlapi.NewRepository(db)
+	rolloutsRepository = rollouts.NewRepository(db)
+	schemaRepository = database.NewSchemaRepository(db)
 	commandsService = &commands.Service{
 		LocalCommandsService: commands.LocalCommandsService{
 			NodesRepository:          nodesRepository,
 			PublicServicesRepository: publicServicesRepository,
 			JoinRequestsRepository:   joinRequestsRepository,
 			JoinTokensRepository:     joinTokensRepository,
+			ACMERepository:           acmeRepository,
+			CertificatesRepository:   certificatesRepository,
+			NotificationsRepository:  notificationsRepository,
+			DNSRecordsRepository:     dnsRecordsRepository,
+			ControlAPIRepository:     controlAPIRepository,
+			RolloutsRepository:       rolloutsRepository,
+			SchemaRepository:         schemaRepository,
 		},
 		NodesRepository:          nodesRepository,
 		PublicServicesRepository: publicServicesRepository,
 		JoinRequestsRepository:   joinRequestsRepository,
 	}
+}
 
+// RegisterCommands adds the commands to the root command, before the database is opened, see RequiresDatabase
+func RegisterCommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(GatewayCmd)
 	rootCmd.AddCommand(ServerCmd)
 	rootCmd.AddCommand(ClientCmd)
 	rootCmd.AddCommand(JoinCmd)
 	rootCmd.AddCommand(ServiceCmd)
+	rootCmd.AddCommand(CertCmd)
+	rootCmd.AddCommand(NodeCmd)
+	rootCmd.AddCommand(DoctorCmd)
+	rootCmd.AddCommand(NotifyCmd)
+	rootCmd.AddCommand(DNSCmd)
+	rootCmd.AddCommand(DBCmd)
+	rootCmd.AddCommand(CACmd)
+	rootCmd.AddCommand(ProfileCmd)
+	rootCmd.AddCommand(ConfigCmd)
+}
+
+// RequiresDatabase reports whether the command invoked by the arguments works on the database of the profile. The profile
+// commands manage the databases of all profiles and run without opening one, e.g. to rename the profile in use; so does
+// 'config show', to help with a configuration the database cannot be opened with, and 'db migrate status', which opens
+// the database without migrating it
+func RequiresDatabase(rootCmd *cobra.Command, args []string) bool {
+	cmd, _, err := rootCmd.Find(args)
+
+	if err != nil {
+		return true
+	}
+
+	if cmd == StatusMigrateDBCmd {
+		return false
+	}
+
+	for ; cmd != nil; cmd = cmd.Parent() {
+		if cmd == ProfileCmd || cmd == ConfigCmd {
+			return false
+		}
+	}
+
+	return true
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"wireport/cmd/server/config"
+	"wireport/internal/commands"
+	"wireport/internal/database"
 	"wireport/internal/nodes/types"
 	"wireport/internal/ssh"
 	"wireport/version"
@@ -18,6 +20,14 @@ var ServerSSHKeyPassEmpty = false
 var ServerDockerImage = config.Config.WireportServerContainerImage
 var ServerDockerImageTag = version.Version
 var forceServerTeardown = false
+var upgradeAllServers = false
+var upgradeServersLabel = ""
+var upgradeServersDetach = false
+var upgradeServersStatus = false
+var upgradeServersCancel = false
+var replaceServerContainer = ""
+var replaceServerImage = ""
+var replaceServerRolloutID = ""
 
 var ServerCmd = &cobra.Command{
 	Use:   "server",
@@ -30,7 +40,7 @@ var NewServerCmd = &cobra.Command{
 	Short: "Create a new join-request for connecting a server to wireport network",
 	Long:  `Create a new join-request for connecting a server to wireport network. The join-request will generate a token that can be used to join the network (see 'wireport join' command help)`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		commandsService.ServerNew(cmd.OutOrStdout(), cmd.ErrOrStderr(), forceServerCreation, quietServerCreation, dockerSubnet)
+		setExitCodeFromError(commandsService.ServerNew(cmd.OutOrStdout(), cmd.ErrOrStderr(), forceServerCreation, quietServerCreation, dockerSubnet))
 	},
 }
 
@@ -39,7 +49,18 @@ var StartServerCmd = &cobra.Command{
 	Short: "Start wireport in server mode",
 	Long:  `Start wireport in server mode. This command is only relevant for server nodes after they joined the network.`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		commandsService.ServerStart(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		// 'db rekey' is refused while this process writes to the database
+		release, err := database.HoldDatabase()
+
+		if err != nil {
+			cmd.PrintErrf("Error: %v\n", err)
+			setExitCodeFromError(err)
+			return
+		}
+
+		defer release()
+
+		setExitCodeFromError(commandsService.ServerStart(cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
@@ -54,10 +75,11 @@ var StatusServerCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.ServerStatus(creds, cmd.OutOrStdout())
+		setExitCodeFromError(commandsService.ServerStatus(creds, cmd.OutOrStdout()))
 	},
 }
 
@@ -71,6 +93,7 @@ var UpServerCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
@@ -80,11 +103,12 @@ var UpServerCmd = &cobra.Command{
 
 			if err != nil {
 				cmd.PrintErrf("❌ Failed to parse Docker subnet: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
 				return
 			}
 		}
 
-		commandsService.ServerUp(creds, ServerDockerImage, ServerDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr(), dockerSubnet)
+		setExitCodeFromError(commandsService.ServerUp(creds, ServerDockerImage, ServerDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr(), dockerSubnet))
 	},
 }
 
@@ -105,11 +129,13 @@ var DownServerCmd = &cobra.Command{
 
 			if err != nil {
 				cmd.PrintErrf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeFailure)
 				return
 			}
 
 			if confirm != "y" {
 				cmd.PrintErrf("❌ Aborted\n")
+				setExitCode(commands.ExitCodeFailure)
 				return
 			}
 		}
@@ -120,11 +146,12 @@ var DownServerCmd = &cobra.Command{
 
 			if err != nil {
 				cmd.PrintErrf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
 				return
 			}
 		}
 
-		commandsService.ServerDown(creds, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.ServerDown(creds, cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
@@ -133,24 +160,59 @@ var ListServerCmd = &cobra.Command{
 	Short: "List all servers",
 	Long:  `List all servers that are connected to the wireport network`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		commandsService.ServerList(nil, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.ServerList(nil, cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
 var UpgradeServerCmd = &cobra.Command{
-	Use:   "upgrade username@hostname[:port]",
-	Short: "Upgrade a server",
-	Long:  `Upgrade a server. This command will upgrade the wireport server software to the latest version. This command is only relevant for server nodes after they joined the network.`,
-	Args:  cobra.ExactArgs(1),
+	Use:   "upgrade [username@hostname[:port]]",
+	Short: "Upgrade a server, or all servers one at a time",
+	Long: `Upgrade a server. This command will upgrade the wireport server software to the latest version. This command is only relevant for server nodes after they joined the network.
+
+With --all, no SSH access to the servers is needed: the gateway orchestrates a rolling upgrade of all servers (or the ones with --label). Each server pulls the image and replaces its own container, one server at a time, and reports back over the control API. The upgrade halts on the first failure, the server that failed is restored to its previous container. A server that does not pick up its turn (e.g. it is offline) fails too; --cancel stops a rollout that is stuck.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if upgradeAllServers || upgradeServersStatus || upgradeServersCancel {
+			return cobra.NoArgs(cmd, args)
+		}
+
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if upgradeServersStatus {
+			setExitCodeFromError(commandsService.ServerUpgradeStatus(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+			return
+		}
+
+		if upgradeServersCancel {
+			setExitCodeFromError(commandsService.ServerUpgradeCancel(cmd.OutOrStdout(), cmd.ErrOrStderr()))
+			return
+		}
+
+		if upgradeAllServers {
+			setExitCodeFromError(commandsService.ServerUpgradeAll(cmd.OutOrStdout(), cmd.ErrOrStderr(), ServerDockerImage, ServerDockerImageTag, upgradeServersLabel, upgradeServersDetach))
+			return
+		}
+
 		creds, err := buildSSHCredentials(cmd, args, false, false, ServerSSHKeyPassEmpty)
 
 		if err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.ServerUpgrade(creds, ServerDockerImage, ServerDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.ServerUpgrade(creds, ServerDockerImage, ServerDockerImageTag, cmd.OutOrStdout(), cmd.ErrOrStderr()))
+	},
+}
+
+// ReplaceServerCmd is run by the one-off container replacing a server container during 'server upgrade --all'
+var ReplaceServerCmd = &cobra.Command{
+	Use:    "replace",
+	Short:  "Replace the wireport server container with a container of a new image",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		setExitCodeFromError(commandsService.ServerReplace(cmd.OutOrStdout(), cmd.ErrOrStderr(), replaceServerContainer, replaceServerImage, replaceServerRolloutID))
 	},
 }
 
@@ -182,10 +244,11 @@ var ServerLabelAddCmd = &cobra.Command{
 
 		if err := validateNodeIPAndLabel(nodeIP, label); err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.NodeLabelAdd(cmd.OutOrStdout(), cmd.ErrOrStderr(), nodeIP, label)
+		setExitCodeFromError(commandsService.NodeLabelAdd(cmd.OutOrStdout(), cmd.ErrOrStderr(), nodeIP, label))
 	},
 }
 
@@ -199,10 +262,11 @@ var ServerLabelRemoveCmd = &cobra.Command{
 
 		if err := validateNodeIPAndLabel(nodeIP, label); err != nil {
 			cmd.PrintErrf("❌ Error: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.NodeLabelRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), nodeIP, label)
+		setExitCodeFromError(commandsService.NodeLabelRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), nodeIP, label))
 	},
 }
 
@@ -218,6 +282,7 @@ func init() {
 	ServerCmd.AddCommand(DownServerCmd)
 	ServerCmd.AddCommand(ListServerCmd)
 	ServerCmd.AddCommand(UpgradeServerCmd)
+	ServerCmd.AddCommand(ReplaceServerCmd)
 	ServerLabelCmd.AddCommand(ServerLabelAddCmd)
 	ServerLabelCmd.AddCommand(ServerLabelRemoveCmd)
 	ServerCmd.AddCommand(ServerLabelCmd)
@@ -239,4 +304,13 @@ func init() {
 	UpgradeServerCmd.Flags().BoolVar(&ServerSSHKeyPassEmpty, "ssh-key-pass-empty", false, "Skip SSH key passphrase prompt (for passwordless SSH keys)")
 	UpgradeServerCmd.Flags().StringVar(&ServerDockerImage, "image", config.Config.WireportServerContainerImage, "Docker image to use for the wireport server container")
 	UpgradeServerCmd.Flags().StringVar(&ServerDockerImageTag, "image-tag", version.Version, "Image tag to use for the wireport server container")
+	UpgradeServerCmd.Flags().BoolVar(&upgradeAllServers, "all", false, "Upgrade all servers one at a time through the gateway, without SSH access to them")
+	UpgradeServerCmd.Flags().StringVar(&upgradeServersLabel, "label", "", "With --all, upgrade only the servers with this label")
+	UpgradeServerCmd.Flags().BoolVar(&upgradeServersDetach, "detach", false, "With --all, start the upgrade without following its progress")
+	UpgradeServerCmd.Flags().BoolVar(&upgradeServersStatus, "status", false, "Show the progress of the last 'server upgrade --all'")
+	UpgradeServerCmd.Flags().BoolVar(&upgradeServersCancel, "cancel", false, "Cancel the running 'server upgrade --all', e.g. when it waits for a server that is gone")
+
+	ReplaceServerCmd.Flags().StringVar(&replaceServerContainer, "container", config.Config.WireportServerContainerName, "Name of the server container to replace")
+	ReplaceServerCmd.Flags().StringVar(&replaceServerImage, "image", "", "Image of the new server container, with tag")
+	ReplaceServerCmd.Flags().StringVar(&replaceServerRolloutID, "rollout", "", "ID of the server upgrade")
 }
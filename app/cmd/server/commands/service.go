@@ -1,16 +1,34 @@
 package commands
 
 import (
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"wireport/cmd/server/config"
+	"wireport/internal/commands"
 	"wireport/internal/publicservices"
 	"wireport/internal/utils"
 )
 
+// lbPolicyResetValue of --lb-policy restores the default load balancing policy of Caddy
+const lbPolicyResetValue = "default"
+
 var local string
+var locals []string
 var public string
 var paramValue string
+var lbPolicy string
+var healthPath string
+var healthInterval string
+var healthTimeout string
+var noHealthCheck bool
+var routePath string
+var routeStripPrefix bool
+var routeHeader string
+var logsLines int
+var logsFollow bool
 
 var ServiceCmd = &cobra.Command{
 	Use:   "service",
@@ -33,16 +51,28 @@ var PublishServiceCmd = &cobra.Command{
 	Supported public ports: 80, 443, 32420-32421 (tcp+udp; should be open in the firewall on the gateway node)
 	Supported local ports: any
 	Supported local hosts: private IP addresses (e.g. 10.0.0.2) of CLIENT and SERVER nodes on wireport network
+
+	Multiple --local addresses (or publishing the same public address again) build an upstream set,
+	the traffic is load balanced across it. Supported load balancing policies: round_robin, least_conn, ip_hash
+	Options that are not passed keep their values; --lb-policy default and --no-health-check clear them.
+	SERVER nodes can not publish to services with upstreams of CLIENT nodes, nor change the settings of
+	services backed by other nodes
+
+	For http/https services, --path and --header add the local address to a route of the public address:
+	matching requests go to the route upstreams, the rest go to the default upstreams
 	
 	Example:
 
 	wireport service publish --local http://10.0.0.2:4000 --public https://demo.server.com:443
-	wireport service publish --local tcp://10.0.0.2:4000 --public tcp://140.120.10.10:32420`,
+	wireport service publish --local tcp://10.0.0.2:4000 --public tcp://140.120.10.10:32420
+	wireport service publish --local http://10.0.0.2:4000 --local http://10.0.0.3:4000 --public https://demo.server.com:443 --lb-policy least_conn --health-path /healthz
+	wireport service publish --local http://10.0.0.2:4000 --public https://demo.server.com:443 --lb-policy default --no-health-check
+	wireport service publish --local http://10.0.0.4:5000 --public https://demo.server.com:443 --path /v2 --strip-prefix
+	wireport service publish --local http://10.0.0.5:5000 --public https://demo.server.com:443 --header "X-Canary: 1"`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		localProtocol, localHost, localPort, err := utils.ParseAddress(local)
-
-		if err != nil {
-			cmd.Printf("❌ Error: local address parsing failed: %v\n", err)
+		if len(locals) == 0 {
+			cmd.Printf("❌ Error: at least one local address is required\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
@@ -50,28 +80,102 @@ var PublishServiceCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
 		if *publicPort == config.Config.ControlServerPort {
 			cmd.Printf("❌ Error: port %d is reserved for wireport control plane and cannot be used for publishing services\n", config.Config.ControlServerPort)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		if (*localProtocol == "tcp" && *publicProtocol != "tcp") ||
-			(*localProtocol == "udp" && *publicProtocol != "udp") {
-			cmd.Printf("❌ Error: local protocol and public protocol must be the same for layer 4 services (tcp -> tcp or udp -> udp)\n")
+		options := publicservices.PublishOptions{
+			ResetLBPolicy:     lbPolicy == lbPolicyResetValue,
+			RemoveHealthCheck: noHealthCheck,
+		}
+
+		if !options.ResetLBPolicy {
+			options.LBPolicy, err = publicservices.ParseLBPolicy(lbPolicy)
+
+			if err != nil {
+				cmd.Printf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
+				return
+			}
+		}
+
+		if noHealthCheck && (healthPath != "" || healthInterval != "" || healthTimeout != "") {
+			cmd.Printf("❌ Error: --no-health-check can not be combined with --health-path, --health-interval or --health-timeout\n")
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
+		if healthPath != "" || healthInterval != "" || healthTimeout != "" {
+			options.HealthCheck = &publicservices.PublicServiceHealthCheck{
+				Path:     healthPath,
+				Interval: healthInterval,
+				Timeout:  healthTimeout,
+			}
+		}
+
+		if routePath != "" || routeHeader != "" || routeStripPrefix {
+			options.Route = &publicservices.PublicServiceRouteMatcher{
+				PathPrefix:  routePath,
+				StripPrefix: routeStripPrefix,
+			}
+
+			if routeHeader != "" {
+				headerName, headerValue, found := strings.Cut(routeHeader, ":")
+
+				if !found {
+					cmd.Printf("❌ Error: header matcher must be in 'Name: value' format\n")
+					setExitCode(commands.ExitCodeInvalidArgument)
+					return
+				}
+
+				options.Route.HeaderName = strings.TrimSpace(headerName)
+				options.Route.HeaderValue = strings.TrimSpace(headerValue)
+			}
+
+			if err = options.Route.Validate(); err != nil {
+				cmd.Printf("❌ Error: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
+				return
+			}
+		}
+
+		upstreams := []publicservices.PublicServiceUpstream{}
+
+		for _, localAddress := range locals {
+			localProtocol, localHost, localPort, err := utils.ParseAddress(localAddress)
+
+			if err != nil {
+				cmd.Printf("❌ Error: local address parsing failed: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
+				return
+			}
+
+			if (*localProtocol == "tcp" && *publicProtocol != "tcp") ||
+				(*localProtocol == "udp" && *publicProtocol != "udp") {
+				cmd.Printf("❌ Error: local protocol and public protocol must be the same for layer 4 services (tcp -> tcp or udp -> udp)\n")
+				setExitCode(commands.ExitCodeInvalidArgument)
+				return
+			}
+
+			upstreams = append(upstreams, publicservices.PublicServiceUpstream{LocalProtocol: *localProtocol, LocalHost: *localHost, LocalPort: *localPort})
+		}
+
 		currentNode, err := nodesRepository.GetCurrentNode()
 
 		if err != nil {
 			cmd.Printf("❌ Error: failed to get current node: %v\n", err)
+			setExitCode(commands.ExitCodeFailure)
 			return
 		}
 
-		commandsService.ServicePublish(cmd.OutOrStdout(), cmd.ErrOrStderr(), &currentNode.ID, *localProtocol, *localHost, *localPort, *publicProtocol, *publicHost, *publicPort)
+		// every local address is added to the upstream set of the same public service, all at once
+		setExitCodeFromError(commandsService.ServicePublish(cmd.OutOrStdout(), cmd.ErrOrStderr(), &currentNode.ID, upstreams, *publicProtocol, *publicHost, *publicPort, options))
 	},
 }
 
@@ -79,19 +183,36 @@ var UnpublishServiceCmd = &cobra.Command{
 	Use:   "unpublish",
 	Short: "Unpublish a public service",
 	Long: `Unpublish a public service and make it no longer accessible from the Internet.
+	If --local is set, only the given upstream is removed from the service (the service is unpublished once its last upstream is removed).
 	
 	Example:
 
-	wireport service unpublish --public tcp://140.120.10.10:443`,
+	wireport service unpublish --public tcp://140.120.10.10:443
+	wireport service unpublish --public https://demo.server.com:443 --local http://10.0.0.3:4000`,
 	Run: func(cmd *cobra.Command, _ []string) {
 		publicProtocol, publicHost, publicPort, err := utils.ParseAddress(public)
 
 		if err != nil {
 			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.ServiceUnpublish(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort)
+		localProtocol, localHost, localPort := "", "", uint16(0)
+
+		if local != "" {
+			parsedProtocol, parsedHost, parsedPort, err := utils.ParseAddress(local)
+
+			if err != nil {
+				cmd.Printf("❌ Error: local address parsing failed: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
+				return
+			}
+
+			localProtocol, localHost, localPort = *parsedProtocol, *parsedHost, *parsedPort
+		}
+
+		setExitCodeFromError(commandsService.ServiceUnpublish(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, localProtocol, localHost, localPort))
 	},
 }
 
@@ -100,7 +221,7 @@ var ListServiceCmd = &cobra.Command{
 	Short: "List all published services",
 	Long:  `List all published services.`,
 	Run: func(cmd *cobra.Command, _ []string) {
-		commandsService.ServiceList(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		setExitCodeFromError(commandsService.ServiceList(cmd.OutOrStdout(), cmd.ErrOrStderr()))
 	},
 }
 
@@ -113,10 +234,11 @@ var NewParamsServiceCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.ServiceParamNew(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, publicservices.PublicServiceParamTypeCaddyFreeText, paramValue)
+		setExitCodeFromError(commandsService.ServiceParamNew(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, publicservices.PublicServiceParamTypeCaddyFreeText, paramValue))
 	},
 }
 
@@ -129,10 +251,11 @@ var RemoveParamsServiceCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.Printf("Error parsing public address: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.ServiceParamRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, publicservices.PublicServiceParamTypeCaddyFreeText, paramValue)
+		setExitCodeFromError(commandsService.ServiceParamRemove(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, publicservices.PublicServiceParamTypeCaddyFreeText, paramValue))
 	},
 }
 
@@ -145,18 +268,134 @@ var ListParamsServiceCmd = &cobra.Command{
 
 		if err != nil {
 			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		setExitCodeFromError(commandsService.ServiceParamList(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort))
+	},
+}
+
+var clientAuthCAFile string
+
+var ClientAuthServiceCmd = &cobra.Command{
+	Use:   "client-auth",
+	Short: "Require client certificates for a public service",
+	Long:  `Require visitors of an https public service to present a client certificate (mTLS) at the gateway.`,
+}
+
+var EnableClientAuthServiceCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Require client certificates for a public service",
+	Long: `Require visitors of an https public service to present a client certificate.
+
+By default, certificates issued with 'wireport client cert issue' are accepted; pass --ca-file to accept certificates signed by your own CA instead.
+
+Example:
+
+wireport service client-auth enable -p https://grafana.example.com:443
+wireport service client-auth enable -p https://grafana.example.com:443 --ca-file ./corporate-ca.pem`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		publicProtocol, publicHost, publicPort, err := utils.ParseAddress(public)
+
+		if err != nil {
+			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		trustedCAPEM := ""
+
+		if clientAuthCAFile != "" {
+			contents, err := os.ReadFile(clientAuthCAFile)
+
+			if err != nil {
+				cmd.PrintErrf("❌ Error: failed to read CA certificate: %v\n", err)
+				setExitCode(commands.ExitCodeInvalidArgument)
+				return
+			}
+
+			trustedCAPEM = string(contents)
+		}
+
+		setExitCodeFromError(commandsService.ServiceClientAuthEnable(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, trustedCAPEM))
+	},
+}
+
+var DisableClientAuthServiceCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop requiring client certificates for a public service",
+	Long:  `Stop requiring client certificates for a public service.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		publicProtocol, publicHost, publicPort, err := utils.ParseAddress(public)
+
+		if err != nil {
+			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		setExitCodeFromError(commandsService.ServiceClientAuthDisable(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort))
+	},
+}
+
+var StatsServiceCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show traffic statistics of a public service",
+	Long: `Show traffic statistics of a public service aggregated from its access logs on the gateway: requests (connections for tcp/udp services), status codes, bytes sent and received and top client IPs.
+
+	Example:
+
+	wireport service stats --public https://demo.example.com`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		publicProtocol, publicHost, publicPort, err := utils.ParseAddress(public)
+
+		if err != nil {
+			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
 			return
 		}
 
-		commandsService.ServiceParamList(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort)
+		setExitCodeFromError(commandsService.ServiceStats(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort))
+	},
+}
+
+var LogsServiceCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show access logs of a public service",
+	Long: `Show the last access log entries of a public service, logged by the gateway.
+	With --follow, new entries are printed as they are logged until interrupted.
+
+	Example:
+
+	wireport service logs --public https://demo.example.com --follow`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		publicProtocol, publicHost, publicPort, err := utils.ParseAddress(public)
+
+		if err != nil {
+			cmd.Printf("❌ Error: public address parsing failed: %v\n", err)
+			setExitCode(commands.ExitCodeInvalidArgument)
+			return
+		}
+
+		setExitCodeFromError(commandsService.ServiceLogs(cmd.OutOrStdout(), cmd.ErrOrStderr(), *publicProtocol, *publicHost, *publicPort, logsLines, logsFollow))
 	},
 }
 
 func init() {
-	PublishServiceCmd.Flags().StringVarP(&local, "local", "l", "", "Local address of the service (e.g. tcp://localhost:4000)")
+	PublishServiceCmd.Flags().StringArrayVarP(&locals, "local", "l", []string{}, "Local address of the service (e.g. tcp://localhost:4000); repeat to load balance across several upstreams")
 	PublishServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. tcp://140.120.10.10:32420)")
+	PublishServiceCmd.Flags().StringVar(&lbPolicy, "lb-policy", "", "Load balancing policy across the upstreams (round_robin, least_conn, ip_hash; default restores Caddy's default)")
+	PublishServiceCmd.Flags().StringVar(&healthPath, "health-path", "", "Path for active health checks of the upstreams (http/https only, e.g. /healthz; / if not set)")
+	PublishServiceCmd.Flags().StringVar(&healthInterval, "health-interval", "", "Interval of active health checks (e.g. 10s)")
+	PublishServiceCmd.Flags().StringVar(&healthTimeout, "health-timeout", "", "Timeout of active health checks (e.g. 2s)")
+	PublishServiceCmd.Flags().BoolVar(&noHealthCheck, "no-health-check", false, "Remove the active health checks of the service")
+	PublishServiceCmd.Flags().StringVar(&routePath, "path", "", "Route the requests with this path prefix to the local address (http/https only, e.g. /v1)")
+	PublishServiceCmd.Flags().BoolVar(&routeStripPrefix, "strip-prefix", false, "Strip the route path prefix before proxying the request")
+	PublishServiceCmd.Flags().StringVar(&routeHeader, "header", "", "Route the requests with this header to the local address (http/https only, e.g. 'X-Api-Version: 2')")
 
 	UnpublishServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. tcp://140.120.10.10:32420)")
+	UnpublishServiceCmd.Flags().StringVarP(&local, "local", "l", "", "Local address of a single upstream to remove (e.g. http://10.0.0.3:4000)")
 
 	NewParamsServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. tcp://140.120.10.10:32420)")
 	NewParamsServiceCmd.Flags().StringVar(&paramValue, "param-value", "", "Value of the parameter to add (e.g. 'header_up X-Tenant-Hostname {http.request.host}', 'dial_timeout 5s' and other valid caddy directives for reverse proxy and/or layer 4 Caddyfile directives)")
@@ -166,6 +405,20 @@ func init() {
 
 	ListParamsServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. tcp://140.120.10.10:32420)")
 
+	EnableClientAuthServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. https://grafana.example.com:443)")
+	EnableClientAuthServiceCmd.Flags().StringVar(&clientAuthCAFile, "ca-file", "", "PEM file with the CA certificates client certificates must be signed by (defaults to the wireport public services client CA)")
+
+	DisableClientAuthServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. https://grafana.example.com:443)")
+
+	StatsServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. https://demo.example.com:443)")
+
+	LogsServiceCmd.Flags().StringVarP(&public, "public", "p", "", "Public address of the service (e.g. https://demo.example.com:443)")
+	LogsServiceCmd.Flags().IntVarP(&logsLines, "lines", "n", 20, "Number of the last entries to show")
+	LogsServiceCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Print new entries as they are logged")
+
+	ClientAuthServiceCmd.AddCommand(EnableClientAuthServiceCmd)
+	ClientAuthServiceCmd.AddCommand(DisableClientAuthServiceCmd)
+
 	ParamsServiceCmd.AddCommand(NewParamsServiceCmd)
 	ParamsServiceCmd.AddCommand(RemoveParamsServiceCmd)
 	ParamsServiceCmd.AddCommand(ListParamsServiceCmd)
@@ -174,4 +427,7 @@ func init() {
 	ServiceCmd.AddCommand(UnpublishServiceCmd)
 	ServiceCmd.AddCommand(ParamsServiceCmd)
 	ServiceCmd.AddCommand(ListServiceCmd)
+	ServiceCmd.AddCommand(ClientAuthServiceCmd)
+	ServiceCmd.AddCommand(StatsServiceCmd)
+	ServiceCmd.AddCommand(LogsServiceCmd)
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"time"
@@ -22,6 +23,9 @@ func init() {
 			}
 		}
 	}
+
+	// the defaults of Config are overridden by the environment and the config files, see Settings
+	loadErr = errors.Join(profileErr, loadSettings(Settings, ConfigFiles()))
 }
 
 func GetEnv(key string, defaultValue string) string {
@@ -44,11 +48,10 @@ func getHomeDir() string {
 }
 
 func getDefaultDatabasePath(fallback string, profile string) string {
-	homeDir := getHomeDir()
-	if homeDir == "" {
+	if ProfilesDir == "" {
 		return fallback
 	}
-	return filepath.Join(homeDir, ".wireport", profile, "wireport.db")
+	return ProfileDatabasePath(profile)
 }
 
 type Configuration struct {
@@ -56,18 +59,55 @@ type Configuration struct {
 	DatabasePath      string
 	WGPublicPort      uint16
 
-	WireportProfile string
+	DatabaseBackupsDir  string
+	DatabaseBackupsKeep int
+
+	MasterKey        string
+	MasterKeyEnvName string
+	MasterKeyFile    string
+
+	GatewayCADir         string
+	RootCAExpiry         time.Duration
+	IntermediateCAExpiry time.Duration
+
+	ControlServerReadHeaderTimeout time.Duration
+	ControlServerReadTimeout       time.Duration
+	ControlServerWriteTimeout      time.Duration
+	ControlServerIdleTimeout       time.Duration
+	ControlServerMaxHeaderBytes    int
+	ControlServerMaxBodyBytes      int64
+	ControlServerShutdownTimeout   time.Duration
+
+	HealthServerAddress string
+
+	WireportProfile       string
+	WireportProfileSource ProfileSource
+	ProfilesDir           string
+
+	WireguardInterfaceName  string
+	WireguardPeerStaleAfter time.Duration
 
 	WireguardConfigPath string
 	ResolvConfigPath    string
 	CaddyConfigPath     string
 	CoreDNSConfigPath   string
 
+	DNSZone                 string
+	CoreDNSZoneFilePath     string
+	CoreDNSUpstreamsAddress string
+
+	CaddyACMECARootPath   string
+	CaddyCertificatesDir  string
+	CaddyClientCAsDir     string
+	CaddyAccessLogsDir    string
+	CertificateExpiryWarn time.Duration
+
 	ResolvConfigTemplatePath  string
 	CaddyConfigTemplatePath   string
 	CoreDNSConfigTemplatePath string
 
 	UpGatewayScriptTemplatePath      string
+	UpGatewayCAScriptTemplatePath    string
 	UpServerScriptTemplatePath       string
 	DownServerScriptTemplatePath     string
 	DownGatewayScriptTemplatePath    string
@@ -75,6 +115,9 @@ type Configuration struct {
 	UpgradeServerScriptTemplatePath  string
 	NewClientScriptTemplatePath      string
 
+	BackupGatewayScriptTemplatePath   string
+	RollbackGatewayScriptTemplatePath string
+
 	PublishDockerSocketScriptTemplatePath   string
 	UnpublishDockerSocketScriptTemplatePath string
 
@@ -94,15 +137,48 @@ type Configuration struct {
 
 	DocumentationURL string
 
+	MetricsPort string
+
+	LogLevel  string
+	LogFormat string
+
+	ServiceHealthProbeInterval time.Duration
+	ServiceHealthProbeTimeout  time.Duration
+
+	ServiceStatsTopClients    int
+	ServiceLogsFollowInterval time.Duration
+
+	NotificationsDispatchInterval time.Duration
+	NotificationsWatchInterval    time.Duration
+	NotificationsDeliveryTimeout  time.Duration
+	NotificationsMaxAttempts      int
+	NotificationsBaseBackoff      time.Duration
+	NotificationsMaxBackoff       time.Duration
+	NotificationsRetention        time.Duration
+
 	WireportGatewayContainerName  string
 	WireportGatewayContainerImage string
 	WireportServerContainerName   string
 	WireportServerContainerImage  string
 
-	CertExpiry time.Duration
+	WireportGatewayDataDir         string
+	WireportGatewayDatabaseName    string
+	WireportGatewayBackupsDir      string
+	WireportGatewayBackupsKeep     int
+	GatewayUpgradeReadinessTimeout time.Duration
+	GatewayUpgradeReadinessPoll    time.Duration
+
+	ServerUpgradeStepTimeout    time.Duration
+	ServerUpgradeProbation      time.Duration
+	ServerUpgradeDataDir        string
+	ServerUpgradeResultFile     string
+	ServerUpgradeFollowInterval time.Duration
+
+	CertExpiry               time.Duration
+	ServicesClientCertExpiry time.Duration
 }
 
-var WireportProfile = GetEnv("WIREPORT_PROFILE", "default")
+var WireportProfile, WireportProfileSource, profileErr = resolveProfile(os.Args[1:])
 var DatabasePath = GetEnv("DATABASE_PATH", getDefaultDatabasePath("/app/wireport/wireport.db", WireportProfile))
 
 var Config = &Configuration{
@@ -110,18 +186,66 @@ var Config = &Configuration{
 	DatabasePath:      DatabasePath,
 	WGPublicPort:      51820,
 
-	WireportProfile: WireportProfile,
-
-	ResolvConfigPath:    GetEnv("RESOLV_CONFIG_PATH", "/etc/resolv.conf"),
-	WireguardConfigPath: GetEnv("WIREGUARD_CONFIG_PATH", "/etc/wireguard/wg0.conf"),
-	CaddyConfigPath:     GetEnv("CADDY_CONFIG_PATH", "/etc/caddy/Caddyfile"),
-	CoreDNSConfigPath:   GetEnv("COREDNS_CONFIG_PATH", "/etc/coredns/Corefile"),
+	// the database is copied here before pending schema migrations are applied
+	DatabaseBackupsDir:  filepath.Join(filepath.Dir(DatabasePath), "backups"),
+	DatabaseBackupsKeep: 5,
+
+	// private keys in the database are encrypted with the master key: the base64 key from WIREPORT_MASTER_KEY, the key
+	// file, or the OS keyring of CLIENT laptops; generated on first start, see atrest.LoadKey
+	MasterKey:        GetEnv("WIREPORT_MASTER_KEY", ""),
+	MasterKeyEnvName: "WIREPORT_MASTER_KEY",
+	MasterKeyFile:    filepath.Join(filepath.Dir(DatabasePath), "master.key"),
+
+	// the gateway imports an intermediate signed by an offline root from here, see 'wireport ca'
+	GatewayCADir:         filepath.Join(filepath.Dir(DatabasePath), "ca"),
+	RootCAExpiry:         time.Hour * 24 * 365 * 20, // 20 years
+	IntermediateCAExpiry: time.Hour * 24 * 365 * 5,  // 5 years
+
+	// the client gives up on a request after 60 seconds, the gateway lets it finish a bit longer
+	ControlServerReadHeaderTimeout: 10 * time.Second,
+	ControlServerReadTimeout:       30 * time.Second,
+	ControlServerWriteTimeout:      90 * time.Second,
+	ControlServerIdleTimeout:       2 * time.Minute,
+	ControlServerMaxHeaderBytes:    64 << 10, // 64 KiB
+	ControlServerMaxBodyBytes:      1 << 20,  // 1 MiB, certificates and CA roots included
+	// requests in progress are drained on SIGTERM for this long, below the stop timeout of the gateway container (30s)
+	ControlServerShutdownTimeout: 20 * time.Second,
+
+	// plain HTTP /healthz and /readyz for the docker HEALTHCHECK and runit, on the loopback address of the container only
+	HealthServerAddress: "127.0.0.1:4061",
+
+	// --profile, WIREPORT_PROFILE or 'wireport profile use' select the network the CLI manages, see 'wireport profile'
+	WireportProfile:       WireportProfile,
+	WireportProfileSource: WireportProfileSource,
+	ProfilesDir:           ProfilesDir,
+
+	WireguardInterfaceName: "wg0",
+	// handshakes are renewed every 2 minutes while a peer is connected (persistent keepalive keeps the tunnels busy)
+	WireguardPeerStaleAfter: 3 * time.Minute,
+
+	ResolvConfigPath:    "/etc/resolv.conf",
+	WireguardConfigPath: "/etc/wireguard/wg0.conf",
+	CaddyConfigPath:     "/etc/caddy/Caddyfile",
+	CoreDNSConfigPath:   "/etc/coredns/Corefile",
+
+	// nodes and user-defined records are resolvable as <name>.wp.internal on all nodes, the zone is served by the gateway
+	DNSZone:             "wp.internal",
+	CoreDNSZoneFilePath: "/etc/coredns/wp.internal.db",
+	// local CoreDNS server of the gateway forwarding to the upstream resolvers (see 'dns config'), the fanout ends with it
+	CoreDNSUpstreamsAddress: "127.0.0.1:5300",
+
+	CaddyACMECARootPath:   "/etc/caddy/acme-ca-root.pem",
+	CaddyCertificatesDir:  "/etc/caddy/certificates",
+	CaddyClientCAsDir:     "/etc/caddy/client-cas",
+	CaddyAccessLogsDir:    "/app/wireport/caddy/logs", // on the volume, so that restarts keep the history
+	CertificateExpiryWarn: 30 * 24 * time.Hour,
 
 	ResolvConfigTemplatePath:  "configs/resolv/resolv.hbs",
 	CaddyConfigTemplatePath:   "configs/caddy/caddyfile.hbs",
 	CoreDNSConfigTemplatePath: "configs/coredns/corefile.hbs",
 
 	UpGatewayScriptTemplatePath:      "scripts/up/gateway.hbs",
+	UpGatewayCAScriptTemplatePath:    "scripts/up/gateway-ca.hbs",
 	UpServerScriptTemplatePath:       "scripts/up/server.hbs",
 	DownGatewayScriptTemplatePath:    "scripts/down/gateway.hbs",
 	DownServerScriptTemplatePath:     "scripts/down/server.hbs",
@@ -129,6 +253,9 @@ var Config = &Configuration{
 	UpgradeServerScriptTemplatePath:  "scripts/upgrade/server.hbs",
 	NewClientScriptTemplatePath:      "scripts/new/client.hbs",
 
+	BackupGatewayScriptTemplatePath:   "scripts/upgrade/gateway-backup.hbs",
+	RollbackGatewayScriptTemplatePath: "scripts/upgrade/gateway-rollback.hbs",
+
 	PublishDockerSocketScriptTemplatePath:   "scripts/publish/docker-socket.hbs",
 	UnpublishDockerSocketScriptTemplatePath: "scripts/unpublish/docker-socket.hbs",
 
@@ -148,12 +275,57 @@ var Config = &Configuration{
 	CoreDNSRestartCommand:            "/usr/bin/pkill -TERM coredns 2>/dev/null || true",
 	ServerJoinVerificationCommandFmt: "docker exec %s sh -c 'test -f %s && test -f %s'",
 
-	DocumentationURL: GetEnv("WIREPORT_DOCUMENTATION_URL", "https://github.com/MultionLabs/wireport"),
+	DocumentationURL: "https://github.com/MultionLabs/wireport",
+
+	// opt-in: /metrics is served on the WireGuard address of the node only when the port is set (e.g. 9586)
+	MetricsPort: "",
+
+	// debug, info, warn or error; text or json (e.g. for shipping the gateway logs into a log stack), overridden by --log-level and --log-format
+	LogLevel:  "info",
+	LogFormat: "text",
+
+	// upstreams of public services are probed from the gateway, results are shown in 'service list'
+	ServiceHealthProbeInterval: 30 * time.Second,
+	ServiceHealthProbeTimeout:  5 * time.Second, // overridden by --health-timeout of the service
+
+	// access logs of public services, see 'service stats' and 'service logs'
+	ServiceStatsTopClients:    10,
+	ServiceLogsFollowInterval: 2 * time.Second,
+
+	// webhook deliveries are retried with exponential backoff: 10s, 20s, 40s, ... up to 1h between attempts (~3.5h in total)
+	NotificationsDispatchInterval: 5 * time.Second,
+	NotificationsWatchInterval:    time.Minute, // peer handshakes and certificate expiry
+	NotificationsDeliveryTimeout:  10 * time.Second,
+	NotificationsMaxAttempts:      12,
+	NotificationsBaseBackoff:      10 * time.Second,
+	NotificationsMaxBackoff:       time.Hour,
+	NotificationsRetention:        7 * 24 * time.Hour, // delivered and failed messages are kept in the outbox for inspection
 
 	WireportGatewayContainerName:  "wireport-gateway",
 	WireportGatewayContainerImage: "ghcr.io/multionlabs/wireport",
 	WireportServerContainerName:   "wireport-server",
 	WireportServerContainerImage:  "ghcr.io/multionlabs/wireport",
 
-	CertExpiry: time.Hour * 24 * 365 * 5, // 5 years
+	// host side of the gateway volume (mounted at /app/wireport), the database is snapshotted there before every upgrade
+	WireportGatewayDataDir:      "~/.wireport-docker/gateway",
+	WireportGatewayDatabaseName: "wireport.db",
+	WireportGatewayBackupsDir:   "~/.wireport-docker/backups",
+	WireportGatewayBackupsKeep:  5,
+	// the upgraded gateway must answer /readyz within this time, otherwise the previous container and database are restored
+	GatewayUpgradeReadinessTimeout: 2 * time.Minute,
+	GatewayUpgradeReadinessPoll:    3 * time.Second,
+
+	// 'server upgrade --all': a server has this long to replace its container and report back from the new one,
+	// otherwise the gateway fails its step and halts the rollout
+	ServerUpgradeStepTimeout: 10 * time.Minute,
+	// the replaced server container must keep running without restarts for this long, otherwise the previous one is restored
+	ServerUpgradeProbation: time.Minute,
+	// data directory of the server as mounted into the one-off replacement container, the result of a failed replacement
+	// is written there for the restored server to report it
+	ServerUpgradeDataDir:        "/app/upgrade",
+	ServerUpgradeResultFile:     "upgrade-result.json",
+	ServerUpgradeFollowInterval: 5 * time.Second,
+
+	CertExpiry:               time.Hour * 24 * 365 * 5, // 5 years
+	ServicesClientCertExpiry: time.Hour * 24 * 365,     // 1 year
 }
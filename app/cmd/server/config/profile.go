@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"wireport/internal/logger"
+	"wireport/internal/profiles"
+)
+
+const DefaultProfile = "default"
+
+// ProfileSource tells how the profile of the invocation was selected, see resolveProfile
+type ProfileSource string
+
+const (
+	ProfileSourceFlag    ProfileSource = "--profile"
+	ProfileSourceEnv     ProfileSource = "WIREPORT_PROFILE"
+	ProfileSourceCurrent ProfileSource = "wireport profile use"
+	ProfileSourceDefault ProfileSource = "default"
+)
+
+// ProfilesDir holds a directory per profile (~/.wireport/<profile>), each with the database of the node in that network
+var ProfilesDir = getProfilesDir()
+
+func getProfilesDir() string {
+	homeDir := getHomeDir()
+
+	if homeDir == "" {
+		return ""
+	}
+
+	return filepath.Join(homeDir, ".wireport")
+}
+
+// ProfileDatabasePath returns the database of the profile, ~/.wireport/<profile>/wireport.db
+func ProfileDatabasePath(profile string) string {
+	return profiles.DatabasePath(ProfilesDir, profile)
+}
+
+// resolveProfile returns the profile selected by the --profile flag, WIREPORT_PROFILE or 'wireport profile use', in this
+// order. The flag is looked up in the arguments directly: the database of the profile is opened before cobra parses them.
+// An invalid name is returned as an error with the default profile, it must never become a path (e.g. '../other')
+func resolveProfile(args []string) (string, ProfileSource, error) {
+	profile, source := selectedProfile(args)
+
+	if err := profiles.ValidateName(profile); err != nil {
+		return DefaultProfile, source, fmt.Errorf("%s: %w", source, err)
+	}
+
+	return profile, source, nil
+}
+
+func selectedProfile(args []string) (string, ProfileSource) {
+	if profile, ok := profileFlag(args); ok {
+		return profile, ProfileSourceFlag
+	}
+
+	if profile := os.Getenv("WIREPORT_PROFILE"); profile != "" {
+		return profile, ProfileSourceEnv
+	}
+
+	if ProfilesDir != "" {
+		profile, err := profiles.ReadCurrent(ProfilesDir)
+
+		if err != nil {
+			logger.Warn("Error reading the current profile: %v", err)
+		}
+
+		if profile != "" {
+			return profile, ProfileSourceCurrent
+		}
+	}
+
+	return DefaultProfile, ProfileSourceDefault
+}
+
+// profileFlag returns the value of --profile, an empty value is returned too to be refused
+func profileFlag(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		if value, ok := strings.CutPrefix(arg, "--profile="); ok {
+			return value, true
+		}
+
+		if arg == "--profile" && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"wireport/internal/profiles"
+)
+
+func TestResolveProfile(t *testing.T) {
+	t.Setenv("WIREPORT_PROFILE", "staging")
+
+	if profile, source, err := resolveProfile([]string{"status", "--profile", "production"}); err != nil || profile != "production" || source != ProfileSourceFlag {
+		t.Errorf("expected the profile of the flag, got %q from %s, %v", profile, source, err)
+	}
+
+	if profile, source, err := resolveProfile([]string{"status"}); err != nil || profile != "staging" || source != ProfileSourceEnv {
+		t.Errorf("expected the profile of the environment, got %q from %s, %v", profile, source, err)
+	}
+}
+
+func TestResolveProfile_InvalidName(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		env    string
+		source ProfileSource
+	}{
+		{[]string{"--profile", "../other"}, "", ProfileSourceFlag},
+		{[]string{"--profile="}, "", ProfileSourceFlag},
+		{nil, "../../etc", ProfileSourceEnv},
+	} {
+		t.Setenv("WIREPORT_PROFILE", test.env)
+
+		profile, source, err := resolveProfile(test.args)
+
+		if !errors.Is(err, profiles.ErrInvalidProfile) || source != test.source {
+			t.Errorf("%v %q: expected ErrInvalidProfile from %s, got %v from %s", test.args, test.env, test.source, err, source)
+		}
+
+		if profile != DefaultProfile {
+			t.Errorf("%v %q: expected the default profile instead of the invalid name, got %q", test.args, test.env, profile)
+		}
+	}
+}
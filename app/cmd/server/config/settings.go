@@ -0,0 +1,294 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"wireport/internal/logger"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// ConfigFileName is the config file of a node, next to its database: ~/.wireport/<profile>/config.yaml for the CLI,
+// config.yaml in the data directory of the gateway and server containers
+const ConfigFileName = "config.yaml"
+
+// SystemConfigFile holds the settings of all profiles, the config file of a node takes precedence over it
+const SystemConfigFile = "/etc/wireport/config.yaml"
+
+// Setting is a part of the configuration that can be changed: by a flag (for some), an environment variable or a config
+// file, in this order of precedence, see loadSettings
+type Setting struct {
+	Key      string // in the config file
+	Env      string
+	Flag     string // command line flag taking precedence over the other sources, if any
+	Usage    string // of the flag
+	Optional bool   // may be set to an empty string
+	Allowed  []string
+	Pattern  *regexp.Regexp // the value must match, e.g. for names inserted into the shell commands run on the hosts
+
+	value  any // *string, *int, *uint16 or *time.Duration in Config
+	source string
+}
+
+// Value returns the effective value of the setting as written in a config file
+func (s *Setting) Value() string {
+	switch value := s.value.(type) {
+	case *string:
+		return *value
+	case *int:
+		return strconv.Itoa(*value)
+	case *uint16:
+		return strconv.Itoa(int(*value))
+	case *time.Duration:
+		return value.String()
+	}
+
+	return ""
+}
+
+// Source tells where the value of the setting comes from: the default, an environment variable or a config file
+func (s *Setting) Source() string {
+	if s.source == "" {
+		return "default"
+	}
+
+	return s.source
+}
+
+func (s *Setting) set(value string, source string) error {
+	value = strings.TrimSpace(value)
+
+	if value == "" && !s.Optional {
+		return fmt.Errorf("%w: %s from %s must not be empty", ErrInvalidConfig, s.Key, source)
+	}
+
+	if len(s.Allowed) > 0 && !slices.Contains(s.Allowed, strings.ToLower(value)) {
+		return fmt.Errorf("%w: %s from %s must be one of %s, got %q", ErrInvalidConfig, s.Key, source, strings.Join(s.Allowed, ", "), value)
+	}
+
+	if s.Pattern != nil && value != "" && !s.Pattern.MatchString(value) {
+		return fmt.Errorf("%w: %s from %s must match %s, got %q", ErrInvalidConfig, s.Key, source, s.Pattern, value)
+	}
+
+	switch target := s.value.(type) {
+	case *string:
+		*target = value
+	case *int:
+		parsed, err := strconv.Atoi(value)
+
+		if err != nil || parsed < 1 {
+			return fmt.Errorf("%w: %s from %s must be a positive number, got %q", ErrInvalidConfig, s.Key, source, value)
+		}
+
+		*target = parsed
+	case *uint16:
+		parsed, err := strconv.ParseUint(value, 10, 16)
+
+		if err != nil || parsed == 0 {
+			return fmt.Errorf("%w: %s from %s must be a port number (1-65535), got %q", ErrInvalidConfig, s.Key, source, value)
+		}
+
+		*target = uint16(parsed)
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("%w: %s from %s must be a positive duration (e.g. 30s, 5m, 8760h), got %q", ErrInvalidConfig, s.Key, source, value)
+		}
+
+		*target = parsed
+	}
+
+	s.source = source
+
+	return nil
+}
+
+// namePattern is the pattern of the Docker container and network names
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// addressPattern is the pattern of a host:port address
+var addressPattern = regexp.MustCompile(`^[a-zA-Z0-9.\-]+:[0-9]{1,5}$`)
+
+// flagValue sets a setting from its flag, the value is checked as one from the other sources
+type flagValue struct {
+	setting *Setting
+}
+
+func (f flagValue) String() string {
+	return f.setting.Value()
+}
+
+func (f flagValue) Set(value string) error {
+	return f.setting.set(value, "--"+f.setting.Flag)
+}
+
+func (f flagValue) Type() string {
+	switch f.setting.value.(type) {
+	case *int:
+		return "int"
+	case *uint16:
+		return "port"
+	case *time.Duration:
+		return "duration"
+	}
+
+	return "string"
+}
+
+// AddFlags declares the flags of the settings that have one, e.g. on the root command for all commands
+func AddFlags(flags *pflag.FlagSet) {
+	addFlags(flags, Settings)
+}
+
+func addFlags(flags *pflag.FlagSet, settings []*Setting) {
+	for _, setting := range settings {
+		if setting.Flag != "" {
+			flags.Var(flagValue{setting: setting}, setting.Flag, fmt.Sprintf("%s (%s)", setting.Usage, setting.Env))
+		}
+	}
+}
+
+// Settings are the parts of the configuration that can be changed, see 'wireport config show'. The ports, the container
+// names and images and the data directory of the gateway are taken from the configuration of the CLI that runs
+// 'gateway up' and 'gateway upgrade'
+var Settings = []*Setting{
+	{Key: "control_server_port", Env: "CONTROL_SERVER_PORT", Flag: "control-server-port", Usage: "Port of the control API of the gateway", value: &Config.ControlServerPort},
+	{Key: "wg_public_port", Env: "WG_PUBLIC_PORT", Flag: "wg-public-port", Usage: "Public WireGuard port of the gateway", value: &Config.WGPublicPort},
+	{Key: "health_server_address", Env: "HEALTH_SERVER_ADDRESS", Flag: "health-server-address", Usage: "Address of the health endpoints inside the gateway container", Pattern: addressPattern, value: &Config.HealthServerAddress},
+	{Key: "metrics_port", Env: "METRICS_PORT", Flag: "metrics-port", Usage: "Port of the metrics endpoint, none if empty", Optional: true, value: &Config.MetricsPort},
+
+	{Key: "log_level", Env: "LOG_LEVEL", Flag: "log-level", Usage: "Minimum level of the logs: debug, info, warn or error", Allowed: []string{"debug", "info", "warn", "error"}, value: &Config.LogLevel},
+	{Key: "log_format", Env: "LOG_FORMAT", Flag: "log-format", Usage: "Format of the logs: text or json", Allowed: []string{logger.FormatText, logger.FormatJSON}, value: &Config.LogFormat},
+
+	{Key: "database_backups_keep", Env: "DATABASE_BACKUPS_KEEP", value: &Config.DatabaseBackupsKeep},
+	{Key: "master_key_file", Env: "WIREPORT_MASTER_KEY_FILE", value: &Config.MasterKeyFile},
+
+	{Key: "cert_expiry", Env: "CERT_EXPIRY", value: &Config.CertExpiry},
+	{Key: "services_client_cert_expiry", Env: "SERVICES_CLIENT_CERT_EXPIRY", value: &Config.ServicesClientCertExpiry},
+	{Key: "root_ca_expiry", Env: "ROOT_CA_EXPIRY", value: &Config.RootCAExpiry},
+	{Key: "intermediate_ca_expiry", Env: "INTERMEDIATE_CA_EXPIRY", value: &Config.IntermediateCAExpiry},
+	{Key: "certificate_expiry_warn", Env: "CERTIFICATE_EXPIRY_WARN", value: &Config.CertificateExpiryWarn},
+
+	{Key: "docker_network_name", Env: "DOCKER_NETWORK_NAME", Flag: "docker-network-name", Usage: "Docker network of the wireport containers", Pattern: namePattern, value: &Config.DockerNetworkName},
+	{Key: "docker_network_driver", Env: "DOCKER_NETWORK_DRIVER", Flag: "docker-network-driver", Usage: "Driver of the Docker network of the wireport containers", value: &Config.DockerNetworkDriver},
+	{Key: "gateway_container_name", Env: "WIREPORT_GATEWAY_CONTAINER_NAME", Flag: "gateway-container-name", Usage: "Name of the gateway container", Pattern: namePattern, value: &Config.WireportGatewayContainerName},
+	{Key: "gateway_container_image", Env: "WIREPORT_GATEWAY_CONTAINER_IMAGE", value: &Config.WireportGatewayContainerImage},
+	{Key: "server_container_name", Env: "WIREPORT_SERVER_CONTAINER_NAME", Flag: "server-container-name", Usage: "Name of the server containers", Pattern: namePattern, value: &Config.WireportServerContainerName},
+	{Key: "server_container_image", Env: "WIREPORT_SERVER_CONTAINER_IMAGE", value: &Config.WireportServerContainerImage},
+	{Key: "gateway_data_dir", Env: "WIREPORT_GATEWAY_DATA_DIR", value: &Config.WireportGatewayDataDir},
+	{Key: "gateway_backups_dir", Env: "WIREPORT_GATEWAY_BACKUPS_DIR", value: &Config.WireportGatewayBackupsDir},
+	{Key: "gateway_backups_keep", Env: "WIREPORT_GATEWAY_BACKUPS_KEEP", value: &Config.WireportGatewayBackupsKeep},
+
+	{Key: "wireguard_config_path", Env: "WIREGUARD_CONFIG_PATH", value: &Config.WireguardConfigPath},
+	{Key: "resolv_config_path", Env: "RESOLV_CONFIG_PATH", value: &Config.ResolvConfigPath},
+	{Key: "caddy_config_path", Env: "CADDY_CONFIG_PATH", value: &Config.CaddyConfigPath},
+	{Key: "coredns_config_path", Env: "COREDNS_CONFIG_PATH", value: &Config.CoreDNSConfigPath},
+	{Key: "coredns_zone_file_path", Env: "COREDNS_ZONE_FILE_PATH", value: &Config.CoreDNSZoneFilePath},
+	{Key: "caddy_acme_ca_root_path", Env: "CADDY_ACME_CA_ROOT_PATH", value: &Config.CaddyACMECARootPath},
+	{Key: "caddy_certificates_dir", Env: "CADDY_CERTIFICATES_DIR", value: &Config.CaddyCertificatesDir},
+	{Key: "caddy_client_cas_dir", Env: "CADDY_CLIENT_CAS_DIR", value: &Config.CaddyClientCAsDir},
+	{Key: "caddy_access_logs_dir", Env: "CADDY_ACCESS_LOGS_DIR", value: &Config.CaddyAccessLogsDir},
+
+	{Key: "wireguard_peer_stale_after", Env: "WIREGUARD_PEER_STALE_AFTER", value: &Config.WireguardPeerStaleAfter},
+	{Key: "service_health_probe_interval", Env: "SERVICE_HEALTH_PROBE_INTERVAL", value: &Config.ServiceHealthProbeInterval},
+	{Key: "service_health_probe_timeout", Env: "SERVICE_HEALTH_PROBE_TIMEOUT", value: &Config.ServiceHealthProbeTimeout},
+	{Key: "control_server_shutdown_timeout", Env: "CONTROL_SERVER_SHUTDOWN_TIMEOUT", value: &Config.ControlServerShutdownTimeout},
+	{Key: "gateway_upgrade_readiness_timeout", Env: "GATEWAY_UPGRADE_READINESS_TIMEOUT", value: &Config.GatewayUpgradeReadinessTimeout},
+	{Key: "server_upgrade_step_timeout", Env: "SERVER_UPGRADE_STEP_TIMEOUT", value: &Config.ServerUpgradeStepTimeout},
+
+	{Key: "documentation_url", Env: "WIREPORT_DOCUMENTATION_URL", value: &Config.DocumentationURL},
+}
+
+// ConfigFiles returns the config files read on start, the later ones take precedence
+func ConfigFiles() []string {
+	return []string{SystemConfigFile, filepath.Join(filepath.Dir(DatabasePath), ConfigFileName)}
+}
+
+// loadErr is the error of loading the settings, see Err
+var loadErr error
+
+// Err returns the error of loading the settings: an unreadable config file, an unknown key or an invalid value
+func Err() error {
+	return loadErr
+}
+
+// loadSettings applies the environment variables and the config files to the settings, the environment taking precedence;
+// flags are applied by cobra afterwards. All errors are returned, so that they can be fixed at once
+func loadSettings(settings []*Setting, files []string) error {
+	fileValues := map[string]string{}
+	fileSources := map[string]string{}
+
+	var errs []error
+
+	for _, file := range files {
+		values, err := readConfigFile(file)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for key, value := range values {
+			if !slices.ContainsFunc(settings, func(s *Setting) bool { return s.Key == key }) {
+				errs = append(errs, fmt.Errorf("%w: unknown setting %s in %s", ErrInvalidConfig, key, file))
+				continue
+			}
+
+			fileValues[key] = value
+			fileSources[key] = file
+		}
+	}
+
+	for _, setting := range settings {
+		if value := os.Getenv(setting.Env); value != "" {
+			errs = append(errs, setting.set(value, setting.Env))
+		} else if value, ok := fileValues[setting.Key]; ok {
+			errs = append(errs, setting.set(value, fileSources[setting.Key]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// readConfigFile returns the settings of the YAML config file, none if there is no such file
+func readConfigFile(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidConfig, file, err)
+	}
+
+	var document map[string]yaml.Node
+
+	if err = yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse %s: %v", ErrInvalidConfig, file, err)
+	}
+
+	values := map[string]string{}
+
+	for key, node := range document {
+		if node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%w: %s in %s (line %d) must be a single value", ErrInvalidConfig, key, file, node.Line)
+		}
+
+		values[key] = node.Value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type testConfig struct {
+	port     uint16
+	expiry   time.Duration
+	name     string
+	keep     int
+	logLevel string
+}
+
+func newTestSettings(c *testConfig) []*Setting {
+	return []*Setting{
+		{Key: "control_server_port", Env: "WIREPORT_TEST_PORT", value: &c.port},
+		{Key: "cert_expiry", Env: "WIREPORT_TEST_EXPIRY", value: &c.expiry},
+		{Key: "gateway_container_name", Env: "WIREPORT_TEST_NAME", value: &c.name},
+		{Key: "gateway_backups_keep", Env: "WIREPORT_TEST_KEEP", value: &c.keep},
+		{Key: "log_level", Env: "WIREPORT_TEST_LOG_LEVEL", Allowed: []string{"debug", "info"}, value: &c.logLevel},
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), ConfigFileName)
+
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	return file
+}
+
+func TestLoadSettings_Precedence(t *testing.T) {
+	c := &testConfig{port: 4060, expiry: time.Hour, name: "wireport-gateway", keep: 5, logLevel: "info"}
+	settings := newTestSettings(c)
+
+	system := writeConfigFile(t, "control_server_port: 4070\ngateway_container_name: system\ngateway_backups_keep: 3\n")
+	profile := writeConfigFile(t, "gateway_container_name: profile\ncert_expiry: 720h\n")
+
+	t.Setenv("WIREPORT_TEST_PORT", "4080")
+
+	if err := loadSettings(settings, []string{system, profile, filepath.Join(t.TempDir(), "missing.yaml")}); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	if c.port != 4080 || settings[0].Source() != "WIREPORT_TEST_PORT" {
+		t.Errorf("expected the environment to take precedence over the files, got %d from %s", c.port, settings[0].Source())
+	}
+
+	if c.expiry != 720*time.Hour || settings[1].Value() != "720h0m0s" {
+		t.Errorf("expected the expiry of the profile file, got %s", c.expiry)
+	}
+
+	if c.name != "profile" || settings[2].Source() != profile {
+		t.Errorf("expected the profile file to take precedence over the system file, got %s from %s", c.name, settings[2].Source())
+	}
+
+	if c.keep != 3 || settings[3].Source() != system {
+		t.Errorf("expected the value of the system file, got %d from %s", c.keep, settings[3].Source())
+	}
+
+	if c.logLevel != "info" || settings[4].Source() != "default" {
+		t.Errorf("expected the default, got %s from %s", c.logLevel, settings[4].Source())
+	}
+}
+
+func TestLoadSettings_InvalidValues(t *testing.T) {
+	c := &testConfig{port: 4060, expiry: time.Hour, name: "wireport-gateway", keep: 5, logLevel: "info"}
+
+	file := writeConfigFile(t, "control_server_port: 70000\ncert_expiry: 5 years\ngateway_container_name: ''\ngateway_backups_keep: 0\nlog_level: verbose\ncontrol_port: 4070\n")
+
+	err := loadSettings(newTestSettings(c), []string{file})
+
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+
+	for _, expected := range []string{"control_server_port", "cert_expiry", "gateway_container_name", "gateway_backups_keep", "log_level", "unknown setting control_port"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error about %s, got %v", expected, err)
+		}
+	}
+
+	if c.port != 4060 || c.expiry != time.Hour || c.name != "wireport-gateway" {
+		t.Errorf("expected invalid values to leave the defaults, got %+v", c)
+	}
+}
+
+func TestLoadSettings_InvalidFile(t *testing.T) {
+	c := &testConfig{}
+
+	for _, content := range []string{"control_server_port: [4060, 4070]\n", "control_server_port 4060\n"} {
+		if err := loadSettings(newTestSettings(c), []string{writeConfigFile(t, content)}); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %q, got %v", content, err)
+		}
+	}
+}
+
+func TestAddFlags(t *testing.T) {
+	c := &testConfig{port: 4060, expiry: time.Hour, name: "wireport-gateway", keep: 5, logLevel: "info"}
+	settings := newTestSettings(c)
+	settings[0].Flag = "control-server-port"
+	settings[2].Flag = "gateway-container-name"
+	settings[2].Pattern = namePattern
+
+	flags := pflag.NewFlagSet("wireport", pflag.ContinueOnError)
+	addFlags(flags, settings)
+
+	if err := flags.Parse([]string{"--control-server-port", "4070", "--gateway-container-name=wireport-gateway-staging"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if c.port != 4070 || settings[0].Source() != "--control-server-port" {
+		t.Errorf("expected the port of the flag, got %d from %s", c.port, settings[0].Source())
+	}
+
+	if c.name != "wireport-gateway-staging" || settings[2].Source() != "--gateway-container-name" {
+		t.Errorf("expected the name of the flag, got %s from %s", c.name, settings[2].Source())
+	}
+
+	for _, args := range [][]string{{"--control-server-port", "0"}, {"--gateway-container-name="}, {"--gateway-container-name", "wireport; rm -rf /"}, {"--gateway-container-name", "wireport gateway"}} {
+		if err := flags.Parse(args); err == nil || !strings.Contains(err.Error(), ErrInvalidConfig.Error()) {
+			t.Errorf("expected %v to be refused as an invalid value, got %v", args, err)
+		}
+	}
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"wireport/cmd/server/commands"
 	"wireport/cmd/server/config"
+	internalcommands "wireport/internal/commands"
 	"wireport/internal/database"
+	"wireport/internal/logger"
 	"wireport/version"
 
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 )
 
 var rootCmd = &cobra.Command{
@@ -54,25 +59,60 @@ If the installation process fails, or the service is not accessible over the Int
 
 If this does not help, check the logs of the wireport docker container on gateway.
 `,
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		return logger.Configure(config.Config.LogLevel, config.Config.LogFormat)
+	},
 	Version: fmt.Sprintf("%s (commit: %s, date: %s, arch: %s, os: %s, package: %s); db path: %s; profile: %s", version.Version, version.Commit, version.Date, version.Arch, version.OS, version.Package, config.DatabasePath, config.WireportProfile),
 }
 
+func init() {
+	// the profile is selected before the flags are parsed (see config.WireportProfile), the flag is declared for cobra to accept it
+	rootCmd.PersistentFlags().StringVar(&config.Config.WireportProfile, "profile", config.Config.WireportProfile, "Profile (wireport network) to manage, overrides WIREPORT_PROFILE and 'wireport profile use'")
+	// --log-level, --control-server-port, --gateway-container-name, ... see config.Settings
+	config.AddFlags(rootCmd.PersistentFlags())
+}
+
 func main() {
-	db, err := database.InitDB()
+	// settings from the environment and the config files, see config.Settings
+	if err := config.Err(); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			rootCmd.PrintErrf("❌ %s\n", line)
+		}
 
-	if err != nil {
-		rootCmd.PrintErrf("Failed to initialize database at %s: %v\n", config.Config.DatabasePath, err)
-	} else {
-		commands.RegisterCommands(rootCmd, db)
+		os.Exit(internalcommands.ExitCodeInvalidArgument)
+	}
+
+	commands.RegisterCommands(rootCmd)
+
+	var db *gorm.DB
+	var err error
+
+	if commands.RequiresDatabase(rootCmd, os.Args[1:]) {
+		db, err = database.InitDB()
 
-		if err := rootCmd.Execute(); err != nil {
-			rootCmd.PrintErrf("%v\n", err)
+		if err != nil {
+			rootCmd.PrintErrf("Failed to initialize database at %s: %v\n", config.Config.DatabasePath, err)
+			os.Exit(internalcommands.ExitCodeFailure)
 		}
 	}
 
-	defer func() {
+	commands.RegisterServices(db)
+
+	err = rootCmd.Execute()
+	exitCode := commands.ExitCode()
+
+	if err != nil {
+		// unknown command, invalid flags or arguments
+		rootCmd.PrintErrf("%v\n", err)
+		exitCode = internalcommands.ExitCodeInvalidArgument
+	}
+
+	// closed explicitly, os.Exit does not run deferred functions
+	if db != nil {
 		if err := database.CloseDB(db); err != nil {
 			rootCmd.PrintErrf("Failed to close database: %v\n", err)
 		}
-	}()
+	}
+
+	os.Exit(exitCode)
 }
@@ -0,0 +1,158 @@
+package accesslogs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// caddy log lines are short, but request URIs and headers are not limited
+const maxLineSize = 1024 * 1024
+
+// Cursor is the position in the log file up to which the lines were returned by Tail
+type Cursor struct {
+	Offset int64 `json:"offset"`
+}
+
+// Files returns the rotated backups of the log file (oldest first, gzip-compressed by caddy by default)
+// followed by the log file itself; files that do not exist (yet) are not returned
+func Files(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+
+	// caddy names the backups <name>-<timestamp><ext>[.gz], timestamps sort lexicographically
+	backups, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext + "*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(backups)
+
+	if _, err := os.Stat(path); err == nil {
+		backups = append(backups, path)
+	}
+
+	return backups, nil
+}
+
+// Read calls visit with every line of the log file and its rotated backups, oldest first
+func Read(path string, visit func(line []byte)) error {
+	files, err := Files(path)
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := readFile(file, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readFile(path string, visit func(line []byte)) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// rotated away in the meantime
+			return nil
+		}
+
+		return err
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+
+		if err != nil {
+			return err
+		}
+
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	return scanLines(reader, visit)
+}
+
+func scanLines(reader io.Reader, visit func(line []byte)) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			visit(scanner.Bytes())
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Tail calls visit with the complete lines appended to the log file since the cursor (all lines of the file
+// if the cursor is nil or the file was rotated) and returns the cursor to continue from
+func Tail(path string, cursor *Cursor, visit func(line []byte)) (*Cursor, error) {
+	next := &Cursor{}
+
+	if cursor != nil {
+		next.Offset = cursor.Offset
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// no traffic yet
+			return &Cursor{}, nil
+		}
+
+		return nil, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() < next.Offset {
+		// rotated: the file was moved to a backup and started over
+		next.Offset = 0
+	}
+
+	if _, err = file.Seek(next.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, info.Size()-next.Offset))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a partially written line is returned by the next call
+	if lastNewline := bytes.LastIndexByte(data, '\n'); lastNewline >= 0 {
+		data = data[:lastNewline+1]
+	} else {
+		data = nil
+	}
+
+	next.Offset += int64(len(data))
+
+	return next, scanLines(bytes.NewReader(data), visit)
+}
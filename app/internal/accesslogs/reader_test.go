@@ -0,0 +1,114 @@
+package accesslogs
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func collectLines(t *testing.T, read func(visit func(line []byte)) error) []string {
+	t.Helper()
+
+	lines := []string{}
+
+	if err := read(func(line []byte) { lines = append(lines, string(line)) }); err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+
+	return lines
+}
+
+func TestRead_RotatedBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "https_demo.example.com_443.log")
+
+	backupFile, err := os.Create(filepath.Join(dir, "https_demo.example.com_443-2025-10-01T12-00-00.000.log.gz"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzipWriter := gzip.NewWriter(backupFile)
+	_, _ = gzipWriter.Write([]byte("first\nsecond\n"))
+	_ = gzipWriter.Close()
+	_ = backupFile.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "https_demo.example.com_443-2025-10-02T12-00-00.000.log"), []byte("third\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("fourth\n\nfifth\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the log of another service must not be read
+	if err := os.WriteFile(filepath.Join(dir, "https_other.example.com_443.log"), []byte("other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := collectLines(t, func(visit func(line []byte)) error { return Read(path, visit) })
+	expected := []string{"first", "second", "third", "fourth", "fifth"}
+
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layer4.log")
+
+	cursor, err := Tail(path, nil, func([]byte) { t.Errorf("unexpected line of a missing file") })
+
+	if err != nil || cursor.Offset != 0 {
+		t.Fatalf("expected an empty cursor for a missing file, got %v, %v", cursor, err)
+	}
+
+	if err := os.WriteFile(path, []byte("first\nsecond\npart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+
+	tail := func() {
+		t.Helper()
+
+		lines = collectLines(t, func(visit func(line []byte)) error {
+			cursor, err = Tail(path, cursor, visit)
+			return err
+		})
+	}
+
+	tail()
+
+	if !slices.Equal(lines, []string{"first", "second"}) {
+		t.Errorf("expected the complete lines only, got %v", lines)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = file.WriteString("ial\nthird\n")
+	_ = file.Close()
+
+	tail()
+
+	if !slices.Equal(lines, []string{"partial", "third"}) {
+		t.Errorf("expected the appended lines, got %v", lines)
+	}
+
+	// rotated: the new file is shorter than the cursor
+	if err := os.WriteFile(path, []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tail()
+
+	if !slices.Equal(lines, []string{"new"}) {
+		t.Errorf("expected the lines of the new file, got %v", lines)
+	}
+}
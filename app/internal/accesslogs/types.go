@@ -0,0 +1,267 @@
+package accesslogs
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"sort"
+	"time"
+)
+
+var ErrNotAnAccessLogEntry = errors.New("not an access log entry")
+
+// Entry is a request to a layer 7 service or a connection to a layer 4 one
+type Entry struct {
+	Time          time.Time     `json:"time"`
+	ClientIP      string        `json:"clientIp"`
+	Method        string        `json:"method,omitempty"` // layer 7 only
+	Host          string        `json:"host,omitempty"`
+	URI           string        `json:"uri,omitempty"`
+	Status        int           `json:"status,omitempty"`
+	Upstream      string        `json:"upstream,omitempty"` // layer 4 only, the upstream the connection was proxied to
+	Closed        bool          `json:"closed,omitempty"`   // layer 4 only, bytes and duration are known once the connection is closed
+	BytesSent     uint64        `json:"bytesSent"`
+	BytesReceived uint64        `json:"bytesReceived"`
+	Duration      time.Duration `json:"duration"`
+}
+
+// caddyTime is the ts field of caddy logs: unix time in seconds (default) or a RFC 3339 string
+type caddyTime time.Time
+
+func (t *caddyTime) UnmarshalJSON(data []byte) error {
+	var seconds float64
+
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*t = caddyTime(time.Unix(0, int64(seconds*float64(time.Second))))
+		return nil
+	}
+
+	var text string
+
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+
+	if err != nil {
+		return err
+	}
+
+	*t = caddyTime(parsed)
+
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// caddyRecord holds the fields of the caddy log records read by wireport
+type caddyRecord struct {
+	Time    caddyTime `json:"ts"`
+	Logger  string    `json:"logger"`
+	Message string    `json:"msg"`
+
+	// http access logs
+	Request *struct {
+		RemoteIP string `json:"remote_ip"`
+		ClientIP string `json:"client_ip"`
+		Method   string `json:"method"`
+		Host     string `json:"host"`
+		URI      string `json:"uri"`
+	} `json:"request"`
+	Status    int     `json:"status"`
+	Size      uint64  `json:"size"`
+	BytesRead uint64  `json:"bytes_read"`
+	Duration  float64 `json:"duration"` // seconds
+
+	// layer4 connection logs
+	Remote   string `json:"remote"`
+	Upstream string `json:"upstream"`
+	Read     uint64 `json:"read"`
+	Written  uint64 `json:"written"`
+}
+
+// ParseLayer7Line parses a record of the access log of a caddy site
+func ParseLayer7Line(line []byte) (*Entry, error) {
+	var record caddyRecord
+
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, err
+	}
+
+	if record.Request == nil {
+		return nil, ErrNotAnAccessLogEntry
+	}
+
+	clientIP := record.Request.ClientIP
+
+	if clientIP == "" {
+		clientIP = record.Request.RemoteIP
+	}
+
+	return &Entry{
+		Time:          time.Time(record.Time),
+		ClientIP:      clientIP,
+		Method:        record.Request.Method,
+		Host:          record.Request.Host,
+		URI:           record.Request.URI,
+		Status:        record.Status,
+		BytesSent:     record.Size,
+		BytesReceived: record.BytesRead,
+		Duration:      secondsToDuration(record.Duration),
+	}, nil
+}
+
+// Layer4Parser turns the debug logs of caddy-l4 into connections: the proxy logs the upstream a connection
+// is dialed to ("dial upstream") and the server logs the traffic of the connection once it is closed
+// ("connection stats"); both records carry the remote address of the client
+type Layer4Parser struct {
+	// upstreams of the connections to report (host:port as in the caddy config), all if empty
+	Upstreams []string
+
+	// called with the connection once it is closed, optional
+	OnClose func(entry *Entry)
+
+	open map[string]*Entry
+}
+
+// Parse returns the connection opened by the record; connections are updated in place once they are closed
+func (p *Layer4Parser) Parse(line []byte) (*Entry, error) {
+	var record caddyRecord
+
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, err
+	}
+
+	if record.Remote == "" {
+		return nil, ErrNotAnAccessLogEntry
+	}
+
+	if p.open == nil {
+		p.open = map[string]*Entry{}
+	}
+
+	switch record.Message {
+	case "dial upstream":
+		if len(p.Upstreams) > 0 && !slices.Contains(p.Upstreams, record.Upstream) {
+			return nil, ErrNotAnAccessLogEntry
+		}
+
+		clientIP, _, err := net.SplitHostPort(record.Remote)
+
+		if err != nil {
+			clientIP = record.Remote
+		}
+
+		entry := &Entry{
+			Time:     time.Time(record.Time),
+			ClientIP: clientIP,
+			Upstream: record.Upstream,
+		}
+
+		p.open[record.Remote] = entry
+
+		return entry, nil
+	case "connection stats":
+		if entry, ok := p.open[record.Remote]; ok {
+			entry.Closed = true
+			entry.BytesSent = record.Written
+			entry.BytesReceived = record.Read
+			entry.Duration = secondsToDuration(record.Duration)
+
+			delete(p.open, record.Remote)
+
+			if p.OnClose != nil {
+				p.OnClose(entry)
+			}
+		}
+	}
+
+	return nil, ErrNotAnAccessLogEntry
+}
+
+// ClientCount is the number of requests (connections) of a client IP
+type ClientCount struct {
+	ClientIP string `json:"clientIp"`
+	Count    int    `json:"count"`
+}
+
+// Stats aggregates the access log entries of a service
+type Stats struct {
+	Requests      int           `json:"requests"` // connections for layer 4 services
+	StatusCodes   map[int]int   `json:"statusCodes,omitempty"`
+	BytesSent     uint64        `json:"bytesSent"`
+	BytesReceived uint64        `json:"bytesReceived"`
+	TopClients    []ClientCount `json:"topClients"`
+	From          time.Time     `json:"from"`
+	To            time.Time     `json:"to"`
+
+	clients map[string]int
+}
+
+func (s *Stats) Add(entry *Entry) {
+	if s.clients == nil {
+		s.clients = map[string]int{}
+		s.StatusCodes = map[int]int{}
+	}
+
+	s.Requests++
+	s.BytesSent += entry.BytesSent
+	s.BytesReceived += entry.BytesReceived
+	s.clients[entry.ClientIP]++
+
+	if entry.Status != 0 {
+		s.StatusCodes[entry.Status]++
+	}
+
+	if s.From.IsZero() || entry.Time.Before(s.From) {
+		s.From = entry.Time
+	}
+
+	if entry.Time.After(s.To) {
+		s.To = entry.Time
+	}
+}
+
+// AddTraffic adds the traffic of a layer 4 connection closed after it was added
+func (s *Stats) AddTraffic(entry *Entry) {
+	s.BytesSent += entry.BytesSent
+	s.BytesReceived += entry.BytesReceived
+}
+
+// Finish computes the top clients, limited to the given number of client IPs
+func (s *Stats) Finish(topClients int) {
+	s.TopClients = []ClientCount{}
+
+	for clientIP, count := range s.clients {
+		s.TopClients = append(s.TopClients, ClientCount{ClientIP: clientIP, Count: count})
+	}
+
+	sort.Slice(s.TopClients, func(i, j int) bool {
+		if s.TopClients[i].Count != s.TopClients[j].Count {
+			return s.TopClients[i].Count > s.TopClients[j].Count
+		}
+
+		return s.TopClients[i].ClientIP < s.TopClients[j].ClientIP
+	})
+
+	if len(s.TopClients) > topClients {
+		s.TopClients = s.TopClients[:topClients]
+	}
+}
+
+// StatusCodesSorted returns the status codes in ascending order
+func (s *Stats) StatusCodesSorted() []int {
+	codes := []int{}
+
+	for code := range s.StatusCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+
+	return codes
+}
@@ -0,0 +1,142 @@
+package accesslogs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLayer7Line(t *testing.T) {
+	line := []byte(`{"level":"info","ts":1760000000.5,"logger":"http.log.access.log0","msg":"handled request","request":{"remote_ip":"10.0.0.1","client_ip":"203.0.113.7","method":"GET","host":"demo.example.com","uri":"/health"},"bytes_read":12,"duration":0.25,"size":512,"status":200}`)
+
+	entry, err := ParseLayer7Line(line)
+
+	if err != nil {
+		t.Fatalf("ParseLayer7Line() error = %v", err)
+	}
+
+	expected := Entry{
+		Time:          time.Unix(1760000000, 500000000),
+		ClientIP:      "203.0.113.7",
+		Method:        "GET",
+		Host:          "demo.example.com",
+		URI:           "/health",
+		Status:        200,
+		BytesSent:     512,
+		BytesReceived: 12,
+		Duration:      250 * time.Millisecond,
+	}
+
+	if !entry.Time.Equal(expected.Time) {
+		t.Errorf("expected time %v, got %v", expected.Time, entry.Time)
+	}
+
+	entry.Time = expected.Time
+
+	if *entry != expected {
+		t.Errorf("expected %+v, got %+v", expected, *entry)
+	}
+
+	if _, err := ParseLayer7Line([]byte(`{"level":"info","ts":1760000000,"msg":"server running"}`)); !errors.Is(err, ErrNotAnAccessLogEntry) {
+		t.Errorf("expected ErrNotAnAccessLogEntry for a non-request record, got %v", err)
+	}
+}
+
+func TestParseLayer7Line_RemoteIPFallback(t *testing.T) {
+	entry, err := ParseLayer7Line([]byte(`{"ts":"2025-10-01T12:00:00Z","request":{"remote_ip":"10.0.0.1","method":"POST","host":"demo.example.com","uri":"/"},"status":502}`))
+
+	if err != nil {
+		t.Fatalf("ParseLayer7Line() error = %v", err)
+	}
+
+	if entry.ClientIP != "10.0.0.1" {
+		t.Errorf("expected the remote IP without a client IP, got %q", entry.ClientIP)
+	}
+
+	if !entry.Time.Equal(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the RFC 3339 timestamp to be parsed, got %v", entry.Time)
+	}
+}
+
+func TestLayer4Parser(t *testing.T) {
+	closed := []*Entry{}
+	parser := &Layer4Parser{Upstreams: []string{"10.0.0.2:5432"}, OnClose: func(entry *Entry) { closed = append(closed, entry) }}
+
+	lines := []string{
+		`{"level":"debug","ts":1760000000,"logger":"layer4.handlers.proxy","msg":"dial upstream","remote":"198.51.100.4:50000","upstream":"10.0.0.2:5432"}`,
+		`{"level":"debug","ts":1760000001,"logger":"layer4.handlers.proxy","msg":"dial upstream","remote":"198.51.100.5:50001","upstream":"10.0.0.3:6379"}`,
+		`{"level":"debug","ts":1760000002,"logger":"layer4","msg":"connection stats","remote":"198.51.100.5:50001","read":1,"written":2,"duration":1}`,
+		`{"level":"debug","ts":1760000003,"logger":"layer4","msg":"connection stats","remote":"198.51.100.4:50000","read":100,"written":2048,"duration":3}`,
+	}
+
+	entries := []*Entry{}
+
+	for _, line := range lines {
+		if entry, err := parser.Parse([]byte(line)); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 connection to the upstreams of the service, got %d", len(entries))
+	}
+
+	expected := Entry{
+		Time:          time.Unix(1760000000, 0),
+		ClientIP:      "198.51.100.4",
+		Upstream:      "10.0.0.2:5432",
+		Closed:        true,
+		BytesSent:     2048,
+		BytesReceived: 100,
+		Duration:      3 * time.Second,
+	}
+
+	if *entries[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, *entries[0])
+	}
+
+	if len(closed) != 1 || closed[0] != entries[0] {
+		t.Errorf("expected OnClose to be called with the closed connection, got %v", closed)
+	}
+}
+
+func TestStats(t *testing.T) {
+	start := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	stats := &Stats{}
+
+	for i, clientIP := range []string{"10.0.0.2", "10.0.0.1", "10.0.0.1", "10.0.0.3", "10.0.0.2", "10.0.0.1"} {
+		status := 200
+
+		if i%3 == 2 {
+			status = 404
+		}
+
+		stats.Add(&Entry{Time: start.Add(time.Duration(i) * time.Minute), ClientIP: clientIP, Status: status, BytesSent: 10, BytesReceived: 1})
+	}
+
+	stats.Finish(2)
+
+	if stats.Requests != 6 || stats.BytesSent != 60 || stats.BytesReceived != 6 {
+		t.Errorf("unexpected totals: %+v", stats)
+	}
+
+	if stats.StatusCodes[200] != 4 || stats.StatusCodes[404] != 2 {
+		t.Errorf("unexpected status codes: %v", stats.StatusCodes)
+	}
+
+	if !stats.From.Equal(start) || !stats.To.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("unexpected period: %v - %v", stats.From, stats.To)
+	}
+
+	expectedTopClients := []ClientCount{{ClientIP: "10.0.0.1", Count: 3}, {ClientIP: "10.0.0.2", Count: 2}}
+
+	if len(stats.TopClients) != len(expectedTopClients) {
+		t.Fatalf("expected %v, got %v", expectedTopClients, stats.TopClients)
+	}
+
+	for i := range expectedTopClients {
+		if stats.TopClients[i] != expectedTopClients[i] {
+			t.Errorf("expected %v, got %v", expectedTopClients, stats.TopClients)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package acme
+
+import "errors"
+
+var (
+	ErrInvalidSettings = errors.New("invalid ACME settings")
+)
@@ -0,0 +1,51 @@
+package acme
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Get returns the ACME settings, or empty settings (Caddy defaults) if they were never set
+func (r *Repository) Get() (*Settings, error) {
+	var settings Settings
+
+	err := r.db.Where("id = ?", SettingsID).First(&settings).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Settings{ID: SettingsID, DNSCredentials: map[string]string{}}, nil
+		}
+
+		return nil, err
+	}
+
+	if settings.DNSCredentials == nil {
+		settings.DNSCredentials = map[string]string{}
+	}
+
+	return &settings, nil
+}
+
+func (r *Repository) Save(settings *Settings) error {
+	settings.ID = SettingsID
+
+	if settings.CreatedAt.IsZero() {
+		settings.CreatedAt = time.Now()
+	}
+
+	return r.db.Save(settings).Error
+}
+
+func (r *Repository) Reset() error {
+	return r.db.Where("id = ?", SettingsID).Delete(&Settings{}).Error
+}
@@ -0,0 +1,173 @@
+package acme
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	_ "wireport/internal/encryption/atrest" // the encrypted serializer of the DNS credentials
+)
+
+// DNSProviders are the caddy-dns modules built into the wireport image, keep in sync with the Dockerfile
+var DNSProviders = []string{"cloudflare", "digitalocean", "route53"}
+
+// SettingsID is the primary key of the single row of gateway-wide ACME settings
+const SettingsID = "default"
+
+// envPlaceholder is a Caddyfile environment variable placeholder, the only credential value allowed to contain braces
+var envPlaceholder = regexp.MustCompile(`^\{env\.[A-Za-z_][A-Za-z0-9_]*\}$`)
+
+// Settings are gateway-wide ACME settings used by Caddy to obtain certificates
+type Settings struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	Email       string `gorm:"type:text;not null;default:''"` // account email, used for expiry notices
+	CADirectory string `gorm:"type:text;not null;default:''"` // ACME directory URL, empty means Caddy defaults (Let's Encrypt, ZeroSSL)
+	CARootPEM   string `gorm:"type:text;not null;default:''"` // root certificate to trust for the ACME directory (e.g. pebble)
+
+	// DNS-01 challenge provider (caddy-dns module name, e.g. cloudflare, route53, digitalocean)
+	DNSProvider    string            `gorm:"type:text;not null;default:''"`
+	DNSCredentials map[string]string `gorm:"type:text;serializer:encrypted;not null;default:'{}'"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (s *Settings) IsDNSChallengeEnabled() bool {
+	return s.DNSProvider != ""
+}
+
+func (s *Settings) Validate() error {
+	if s.Email != "" && !strings.Contains(s.Email, "@") {
+		return fmt.Errorf("%w: invalid email %s", ErrInvalidSettings, s.Email)
+	}
+
+	if s.CADirectory != "" && !strings.HasPrefix(s.CADirectory, "https://") {
+		return fmt.Errorf("%w: CA directory must be an https:// URL", ErrInvalidSettings)
+	}
+
+	if s.DNSProvider != "" && !slices.Contains(DNSProviders, s.DNSProvider) {
+		return fmt.Errorf("%w: unknown DNS provider %q, the supported ones are %s", ErrInvalidSettings, s.DNSProvider, strings.Join(DNSProviders, ", "))
+	}
+
+	if s.DNSProvider == "" && len(s.DNSCredentials) > 0 {
+		return fmt.Errorf("%w: DNS credentials are set but DNS provider is not", ErrInvalidSettings)
+	}
+
+	for _, value := range append(s.credentialKeys(), s.Email, s.CADirectory, s.DNSProvider) {
+		if strings.ContainsAny(value, " \t\n{}") {
+			return fmt.Errorf("%w: %q must not contain spaces or braces", ErrInvalidSettings, value)
+		}
+	}
+
+	// the values are written into the Caddyfile as they are, anything else than a single token would change its structure
+	for _, key := range s.credentialKeys() {
+		value := s.DNSCredentials[key]
+
+		if value == "" || (strings.ContainsAny(value, " \t\r\n{}#") && !envPlaceholder.MatchString(value)) {
+			return fmt.Errorf("%w: DNS credential %s must be a single value without spaces, braces or '#', or an {env.VAR} placeholder", ErrInvalidSettings, key)
+		}
+	}
+
+	return nil
+}
+
+func (s *Settings) credentialKeys() []string {
+	keys := make([]string, 0, len(s.DNSCredentials))
+
+	for key := range s.DNSCredentials {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// MaskedCredentials returns the DNS credential keys with their values hidden, for display purposes
+func (s *Settings) MaskedCredentials() []string {
+	masked := []string{}
+
+	for _, key := range s.credentialKeys() {
+		value := s.DNSCredentials[key]
+
+		if len(value) > 4 && !strings.HasPrefix(value, "{env.") {
+			value = strings.Repeat("*", 8) + value[len(value)-4:]
+		}
+
+		masked = append(masked, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return masked
+}
+
+// AsCaddyGlobalOptions renders the settings as Caddyfile global options; caRootPath is the path
+// the CA root certificate is written to (only used if CARootPEM is set)
+func (s *Settings) AsCaddyGlobalOptions(caRootPath string) string {
+	lines := []string{}
+
+	if s.Email != "" {
+		lines = append(lines, fmt.Sprintf("email %s", s.Email))
+	}
+
+	if s.CADirectory != "" {
+		lines = append(lines, fmt.Sprintf("acme_ca %s", s.CADirectory))
+	}
+
+	if s.CARootPEM != "" {
+		lines = append(lines, fmt.Sprintf("acme_ca_root %s", caRootPath))
+	}
+
+	if s.DNSProvider != "" {
+		if len(s.DNSCredentials) == 0 {
+			lines = append(lines, fmt.Sprintf("acme_dns %s", s.DNSProvider))
+		} else {
+			credentials := []string{}
+
+			for _, key := range s.credentialKeys() {
+				credentials = append(credentials, fmt.Sprintf("        %s %s", key, s.DNSCredentials[key]))
+			}
+
+			lines = append(lines, fmt.Sprintf("acme_dns %s {\n%s\n    }", s.DNSProvider, strings.Join(credentials, "\n")))
+		}
+	}
+
+	return strings.Join(lines, "\n    ")
+}
+
+// SettingsUpdate describes a partial update of the settings, nil fields are left unchanged
+type SettingsUpdate struct {
+	Email          *string           `json:"email,omitempty"`
+	CADirectory    *string           `json:"caDirectory,omitempty"`
+	CARootPEM      *string           `json:"caRootPEM,omitempty"`
+	DNSProvider    *string           `json:"dnsProvider,omitempty"`
+	DNSCredentials map[string]string `json:"dnsCredentials,omitempty"` // replaces all credentials if set
+}
+
+func (u *SettingsUpdate) Apply(settings *Settings) {
+	if u.Email != nil {
+		settings.Email = *u.Email
+	}
+
+	if u.CADirectory != nil {
+		settings.CADirectory = *u.CADirectory
+	}
+
+	if u.CARootPEM != nil {
+		settings.CARootPEM = *u.CARootPEM
+	}
+
+	if u.DNSProvider != nil {
+		settings.DNSProvider = *u.DNSProvider
+
+		if settings.DNSProvider == "" {
+			settings.DNSCredentials = map[string]string{}
+		}
+	}
+
+	if u.DNSCredentials != nil {
+		settings.DNSCredentials = u.DNSCredentials
+	}
+}
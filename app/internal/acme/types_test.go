@@ -0,0 +1,91 @@
+package acme
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func removeSpaces(s string) string {
+	s = strings.ReplaceAll(s, "\t", "")
+	s = strings.ReplaceAll(s, "\n", "")
+	s = strings.ReplaceAll(s, " ", "")
+	return s
+}
+
+func TestSettings_AsCaddyGlobalOptions_Empty(t *testing.T) {
+	settings := Settings{}
+
+	if got := settings.AsCaddyGlobalOptions("/etc/caddy/acme-ca-root.pem"); got != "" {
+		t.Errorf("expected empty global options, got %s", got)
+	}
+}
+
+func TestSettings_AsCaddyGlobalOptions_All(t *testing.T) {
+	settings := Settings{
+		Email:          "ops@example.com",
+		CADirectory:    "https://pebble:14000/dir",
+		CARootPEM:      "-----BEGIN CERTIFICATE-----",
+		DNSProvider:    "route53",
+		DNSCredentials: map[string]string{"region": "eu-west-1", "access_key_id": "{env.AWS_ACCESS_KEY_ID}"},
+	}
+
+	expected := `
+email ops@example.com
+acme_ca https://pebble:14000/dir
+acme_ca_root /etc/caddy/acme-ca-root.pem
+acme_dns route53 {
+    access_key_id {env.AWS_ACCESS_KEY_ID}
+    region eu-west-1
+}
+`
+
+	got := settings.AsCaddyGlobalOptions("/etc/caddy/acme-ca-root.pem")
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSettings_Validate(t *testing.T) {
+	invalid := []Settings{
+		{Email: "not-an-email"},
+		{CADirectory: "http://insecure/dir"},
+		{DNSCredentials: map[string]string{"api_token": "x"}},
+		{DNSProvider: "cloud flare"},
+		{DNSProvider: "cloudfare"},
+		{DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": "abc def"}},
+		{DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": "abc}\n}\nimport evil"}},
+		{DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": "abc#comment"}},
+		{DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": "{env.CF_API_TOKEN} extra"}},
+		{DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": ""}},
+	}
+
+	for _, settings := range invalid {
+		if err := settings.Validate(); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("expected invalid settings error for %+v, got %v", settings, err)
+		}
+	}
+
+	valid := Settings{Email: "ops@example.com", DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": "{env.CF_API_TOKEN}"}}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestSettingsUpdate_Apply(t *testing.T) {
+	settings := Settings{Email: "ops@example.com", DNSProvider: "cloudflare", DNSCredentials: map[string]string{"api_token": "x"}}
+
+	disabled := ""
+	update := SettingsUpdate{DNSProvider: &disabled}
+	update.Apply(&settings)
+
+	if settings.Email != "ops@example.com" {
+		t.Errorf("expected email to be unchanged, got %s", settings.Email)
+	}
+
+	if settings.DNSProvider != "" || len(settings.DNSCredentials) != 0 {
+		t.Errorf("expected DNS provider and credentials to be cleared, got %s %v", settings.DNSProvider, settings.DNSCredentials)
+	}
+}
@@ -0,0 +1,9 @@
+package certificates
+
+import "errors"
+
+var (
+	ErrCertificateNotFound = errors.New("certificate not found")
+	ErrInvalidCertificate  = errors.New("invalid certificate")
+	ErrHostHasCertificate  = errors.New("host already has a certificate")
+)
@@ -0,0 +1,72 @@
+package certificates
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create stores the certificate, refusing hosts that are already associated with another certificate
+func (r *Repository) Create(certificate *Certificate) error {
+	existing, err := r.GetAll()
+
+	if err != nil {
+		return err
+	}
+
+	for _, other := range existing {
+		for _, host := range certificate.Hosts {
+			if slices.Contains(other.Hosts, host) {
+				return fmt.Errorf("%w: %s (certificate %s)", ErrHostHasCertificate, host, other.ID)
+			}
+		}
+	}
+
+	return r.db.Create(certificate).Error
+}
+
+func (r *Repository) GetAll() ([]*Certificate, error) {
+	var certificates []*Certificate
+
+	if err := r.db.Order("created_at ASC").Find(&certificates).Error; err != nil {
+		return nil, err
+	}
+
+	return certificates, nil
+}
+
+func (r *Repository) Get(id string) (*Certificate, error) {
+	var certificate Certificate
+
+	err := r.db.Where("id = ?", id).First(&certificate).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCertificateNotFound
+		}
+
+		return nil, err
+	}
+
+	return &certificate, nil
+}
+
+func (r *Repository) Delete(id string) bool {
+	result := r.db.Delete(&Certificate{}, "id = ?", id)
+
+	if result.Error != nil {
+		return false
+	}
+
+	return result.RowsAffected > 0
+}
@@ -0,0 +1,154 @@
+package certificates
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Certificate is a user-provided TLS certificate served by the gateway for its hosts instead of an ACME one
+type Certificate struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	Hosts []string `gorm:"type:text;serializer:json;not null;default:'[]'"` // public hosts the certificate is used for
+
+	CertPEM string `gorm:"type:text;not null" json:"-"`                      // certificate chain
+	KeyPEM  string `gorm:"type:text;serializer:encrypted;not null" json:"-"` // private key, never leaves the gateway
+
+	Subject  string    `gorm:"type:text;not null"`
+	Issuer   string    `gorm:"type:text;not null"`
+	NotAfter time.Time `gorm:"type:timestamp;not null"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (c *Certificate) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+
+	return nil
+}
+
+// NewCertificate validates the PEM cert/key pair and builds a certificate for the given hosts;
+// if no hosts are given, the DNS names of the certificate are used
+func NewCertificate(certPEM string, keyPEM string, hosts []string) (*Certificate, error) {
+	keyPair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	leaf, err := x509.ParseCertificate(keyPair.Certificate[0])
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	if len(hosts) == 0 {
+		hosts = leaf.DNSNames
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("%w: certificate has no DNS names, pass the hosts explicitly", ErrInvalidCertificate)
+	}
+
+	for _, host := range hosts {
+		if err = verifyHost(leaf, host); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Certificate{
+		Hosts:    hosts,
+		CertPEM:  certPEM,
+		KeyPEM:   keyPEM,
+		Subject:  leaf.Subject.String(),
+		Issuer:   leaf.Issuer.String(),
+		NotAfter: leaf.NotAfter,
+	}, nil
+}
+
+// verifyHost checks that the certificate is valid for the host. A wildcard host (*.example.com) needs the same wildcard
+// DNS name in the certificate, a wildcard covers a single label only
+func verifyHost(leaf *x509.Certificate, host string) error {
+	if !strings.Contains(host, "*") {
+		if err := leaf.VerifyHostname(host); err != nil {
+			return fmt.Errorf("%w: certificate is not valid for host %s", ErrInvalidCertificate, host)
+		}
+
+		return nil
+	}
+
+	suffix, isWildcard := strings.CutPrefix(host, "*.")
+
+	if !isWildcard || strings.Contains(suffix, "*") || !strings.Contains(suffix, ".") || slices.Contains(strings.Split(suffix, "."), "") {
+		return fmt.Errorf("%w: invalid wildcard host %s, only the whole leftmost label can be a wildcard (e.g. *.example.com)", ErrInvalidCertificate, host)
+	}
+
+	if !slices.ContainsFunc(leaf.DNSNames, func(name string) bool { return strings.EqualFold(name, host) }) {
+		return fmt.Errorf("%w: certificate is not valid for host %s", ErrInvalidCertificate, host)
+	}
+
+	return nil
+}
+
+// CoversHost reports whether the certificate is associated with the public host (exactly or via a wildcard host)
+func (c *Certificate) CoversHost(host string) bool {
+	for _, certHost := range c.Hosts {
+		if certHost == host {
+			return true
+		}
+
+		if strings.HasPrefix(certHost, "*.") {
+			label, rest, found := strings.Cut(host, ".")
+
+			if found && label != "" && label != "*" && "*."+rest == certHost {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *Certificate) ExpiresWithin(duration time.Duration) bool {
+	return time.Until(c.NotAfter) < duration
+}
+
+func (c *Certificate) IsExpired() bool {
+	return time.Now().After(c.NotAfter)
+}
+
+func (c *Certificate) CertFilePath(dir string) string {
+	return filepath.Join(dir, c.ID+".crt")
+}
+
+func (c *Certificate) KeyFilePath(dir string) string {
+	return filepath.Join(dir, c.ID+".key")
+}
+
+// FindForHost returns the certificate associated with the host, exact matches take precedence over wildcard ones
+func FindForHost(certificates []*Certificate, host string) *Certificate {
+	for _, certificate := range certificates {
+		if slices.Contains(certificate.Hosts, host) {
+			return certificate
+		}
+	}
+
+	for _, certificate := range certificates {
+		if certificate.CoversHost(host) {
+			return certificate
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func generateCertificate(t *testing.T, dnsNames []string, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return string(certPEM), string(keyPEM)
+}
+
+func TestNewCertificate_DefaultsToDNSNames(t *testing.T) {
+	certPEM, keyPEM := generateCertificate(t, []string{"app.example.com", "*.preview.example.com"}, time.Now().Add(90*24*time.Hour))
+
+	certificate, err := NewCertificate(certPEM, keyPEM, nil)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(certificate.Hosts) != 2 || certificate.Hosts[0] != "app.example.com" || certificate.Hosts[1] != "*.preview.example.com" {
+		t.Errorf("expected hosts from DNS names, got %v", certificate.Hosts)
+	}
+
+	if certificate.ExpiresWithin(30 * 24 * time.Hour) {
+		t.Errorf("expected certificate not to expire within 30 days")
+	}
+}
+
+func TestNewCertificate_Errors(t *testing.T) {
+	certPEM, keyPEM := generateCertificate(t, []string{"app.example.com"}, time.Now().Add(90*24*time.Hour))
+	_, otherKeyPEM := generateCertificate(t, []string{"app.example.com"}, time.Now().Add(90*24*time.Hour))
+
+	if _, err := NewCertificate(certPEM, otherKeyPEM, nil); !errors.Is(err, ErrInvalidCertificate) {
+		t.Errorf("expected ErrInvalidCertificate for mismatched key, got %v", err)
+	}
+
+	if _, err := NewCertificate(certPEM, keyPEM, []string{"api.example.com"}); !errors.Is(err, ErrInvalidCertificate) {
+		t.Errorf("expected ErrInvalidCertificate for uncovered host, got %v", err)
+	}
+}
+
+func TestNewCertificate_WildcardHosts(t *testing.T) {
+	certPEM, keyPEM := generateCertificate(t, []string{"*.example.com", "wildcard.preview.example.com"}, time.Now().Add(90*24*time.Hour))
+
+	if _, err := NewCertificate(certPEM, keyPEM, []string{"*.example.com", "api.example.com"}); err != nil {
+		t.Errorf("expected the wildcard host and a host it covers to be valid, got %v", err)
+	}
+
+	// a wildcard host needs a wildcard DNS name, not a host literally named "wildcard"
+	invalid := []string{"*.preview.example.com", "*.api.example.com", "*", "*.com", "**.example.com", "a*.example.com", "*.*.example.com", "*.example..com", "api.*.example.com"}
+
+	for _, host := range invalid {
+		if _, err := NewCertificate(certPEM, keyPEM, []string{host}); !errors.Is(err, ErrInvalidCertificate) {
+			t.Errorf("expected ErrInvalidCertificate for %s, got %v", host, err)
+		}
+	}
+}
+
+func TestFindForHost(t *testing.T) {
+	wildcard := &Certificate{ID: "wildcard", Hosts: []string{"*.example.com"}}
+	exact := &Certificate{ID: "exact", Hosts: []string{"app.example.com"}}
+	certificates := []*Certificate{wildcard, exact}
+
+	cases := map[string]string{
+		"app.example.com":      "exact",
+		"api.example.com":      "wildcard",
+		"*.example.com":        "wildcard",
+		"a.api.example.com":    "",
+		"example.com":          "",
+		"app.example.com.evil": "",
+	}
+
+	for host, expectedID := range cases {
+		got := FindForHost(certificates, host)
+
+		if expectedID == "" {
+			if got != nil {
+				t.Errorf("%s: expected no certificate, got %s", host, got.ID)
+			}
+
+			continue
+		}
+
+		if got == nil || got.ID != expectedID {
+			t.Errorf("%s: expected certificate %s, got %v", host, expectedID, got)
+		}
+	}
+}
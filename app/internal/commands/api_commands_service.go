@@ -3,24 +3,132 @@ package commands
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"time"
+	"wireport/internal/accesslogs"
+	"wireport/internal/acme"
 	"wireport/internal/commands/types"
+	"wireport/internal/controlapi"
+	"wireport/internal/dnsrecords"
 	"wireport/internal/encryption/mtls"
 	"wireport/internal/logger"
+	node_types "wireport/internal/nodes/types"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
+	"wireport/internal/wg"
+	"wireport/version"
+
+	"github.com/google/uuid"
 )
 
 type APICommandsService struct {
 	Host             string
 	Port             uint16
+	ServerName       string // name the certificate of the gateway is verified against if it differs from Host
 	ClientCertBundle *mtls.FullClientBundle
+
+	// SaveClientCertBundle keeps the certificate the gateway renewed after refusing the previous one as signed by a
+	// revoked intermediate; without it, the certificate is not renewed
+	SaveClientCertBundle func(bundle *mtls.FullClientBundle) error
+}
+
+// newAPICommandsService returns the client of the control API of the gateway; once the WireGuard tunnel of the node
+// is up, the gateway is reached over it, as the control API may be restricted to the WireGuard network
+func newAPICommandsService(node *node_types.Node) *APICommandsService {
+	api := &APICommandsService{
+		Host:             node.GatewayPublicIP,
+		Port:             node.GatewayPublicPort,
+		ClientCertBundle: node.ClientCertBundle,
+	}
+
+	if gatewayWGIP := node.GatewayWGIP(); gatewayWGIP != "" && wg.IsAddressAssigned(node.WGConfig.Interface.Address.IP) {
+		api.Host = gatewayWGIP
+		// the certificate of the gateway is issued for its public IP
+		api.ServerName = node.GatewayPublicIP
+	}
+
+	return api
 }
 
 func makeSecureRequestWithResponse[RequestType any, ResponseType any](api *APICommandsService, method, endpoint string, request RequestType) (ResponseType, error) {
+	response, err := sendSecureRequest[RequestType, ResponseType](api, method, endpoint, request)
+
+	if errors.Is(err, mtls.ErrCertificateRevoked) && endpoint != NodeCertRenewPath && api.SaveClientCertBundle != nil {
+		if renewErr := api.renewClientCertBundle(); renewErr != nil {
+			return response, errors.Join(err, fmt.Errorf("failed to renew the certificate: %w", renewErr))
+		}
+
+		return sendSecureRequest[RequestType, ResponseType](api, method, endpoint, request)
+	}
+
+	return response, err
+}
+
+// renewClientCertBundle replaces the certificate of the node with one signed by the current intermediate of the gateway
+func (a *APICommandsService) renewClientCertBundle() error {
+	response, err := sendSecureRequest[types.NodeCertRenewRequestDTO, types.NodeCertRenewResponseDTO](
+		a, "POST", NodeCertRenewPath,
+		types.NodeCertRenewRequestDTO{},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if response.Bundle == nil {
+		return errors.New("the gateway returned no certificate")
+	}
+
+	if err = a.SaveClientCertBundle(response.Bundle); err != nil {
+		return err
+	}
+
+	a.ClientCertBundle = response.Bundle
+
+	logger.Component(logger.ComponentControlAPI).Info("Certificate renewed, the previous one was signed by a revoked intermediate")
+
+	return nil
+}
+
+// updateRevocationList keeps a newer revocation list sent by the gateway, the gateway certificates signed by the
+// intermediates it revokes are refused from the next request on
+func (a *APICommandsService) updateRevocationList(header http.Header) {
+	value := header.Get(mtls.RevocationListHeader)
+
+	if value == "" {
+		return
+	}
+
+	log := logger.Component(logger.ComponentControlAPI)
+
+	revocationList, err := mtls.DecodeRevocationListHeader(value)
+
+	if err != nil {
+		log.Warn("Ignored the revocation list sent by the gateway", logger.Err(err))
+		return
+	}
+
+	updated, err := a.ClientCertBundle.UpdateRevocationList(revocationList)
+
+	if err != nil {
+		log.Warn("Ignored the revocation list sent by the gateway", logger.Err(err))
+		return
+	}
+
+	if !updated || a.SaveClientCertBundle == nil {
+		return
+	}
+
+	if err = a.SaveClientCertBundle(a.ClientCertBundle); err != nil {
+		log.Warn("Failed to save the revocation list sent by the gateway", logger.Err(err))
+	}
+}
+
+func sendSecureRequest[RequestType any, ResponseType any](api *APICommandsService, method, endpoint string, request RequestType) (ResponseType, error) {
 	var response ResponseType
 
 	requestBody, err := json.Marshal(request)
@@ -33,13 +141,22 @@ func makeSecureRequestWithResponse[RequestType any, ResponseType any](api *APICo
 		return response, fmt.Errorf("failed to get client TLS config: %v", err)
 	}
 
+	if api.ServerName != "" {
+		tlsConfig.ServerName = api.ServerName
+	}
+
 	url := fmt.Sprintf("https://%s:%d%s", api.Host, api.Port, endpoint)
 	httpRequest, err := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return response, fmt.Errorf("failed to create request: %v", err)
 	}
 
+	// the same ID is in the logs of the gateway
+	requestID := uuid.NewString()
+
 	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set(RequestIDHeader, requestID)
+	version.SetHeaders(httpRequest.Header)
 
 	// Create a dialer with timeout
 	dialer := &net.Dialer{
@@ -63,7 +180,7 @@ func makeSecureRequestWithResponse[RequestType any, ResponseType any](api *APICo
 
 	httpResponse, err := client.Do(httpRequest)
 	if err != nil {
-		return response, fmt.Errorf("failed to execute request: %v", err)
+		return response, fmt.Errorf("%w: %v", ErrGatewayUnreachable, err)
 	}
 	defer httpResponse.Body.Close()
 
@@ -72,8 +189,16 @@ func makeSecureRequestWithResponse[RequestType any, ResponseType any](api *APICo
 		return response, fmt.Errorf("failed to read response body: %v", err)
 	}
 
+	warnAboutGatewayVersion(httpResponse.Header)
+	api.updateRevocationList(httpResponse.Header)
+
 	if httpResponse.StatusCode < http.StatusOK || httpResponse.StatusCode >= http.StatusMultipleChoices {
-		return response, fmt.Errorf("request failed with status %d: %s", httpResponse.StatusCode, string(responseBody))
+		err = parseErrorResponse(httpResponse.StatusCode, responseBody)
+
+		logger.Component(logger.ComponentControlAPI).Debug("Control API request failed",
+			logger.KeyRequestID, requestID, logger.KeyPath, endpoint, logger.KeyStatus, httpResponse.StatusCode, logger.Err(err))
+
+		return response, err
 	}
 
 	if len(responseBody) == 0 {
@@ -121,6 +246,48 @@ func (a *APICommandsService) ServerRemove(nodeID string) (types.ExecResponseDTO,
 	return serverRemoveResponseDTO, nil
 }
 
+func (a *APICommandsService) ServerUpgradeRollout(image string, label string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServerUpgradeRolloutRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/server/upgrade/rollout",
+		types.ServerUpgradeRolloutRequestDTO{
+			Image: image,
+			Label: label,
+		},
+	)
+}
+
+func (a *APICommandsService) ServerUpgradeCancel() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServerUpgradeCancelRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/server/upgrade/cancel",
+		types.ServerUpgradeCancelRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) ServerUpgradeStatus() (types.ServerUpgradeStatusResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServerUpgradeStatusRequestDTO, types.ServerUpgradeStatusResponseDTO](
+		a, "POST", "/commands/server/upgrade/status",
+		types.ServerUpgradeStatusRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) ServerUpgradeAssignment() (types.ServerUpgradeAssignmentResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServerUpgradeAssignmentRequestDTO, types.ServerUpgradeAssignmentResponseDTO](
+		a, "POST", "/commands/server/upgrade/assignment",
+		types.ServerUpgradeAssignmentRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) ServerUpgradeReport(rolloutID string, status rollouts.StepStatus, message string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServerUpgradeReportRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/server/upgrade/report",
+		types.ServerUpgradeReportRequestDTO{
+			RolloutID: rolloutID,
+			Status:    status,
+			Message:   message,
+		},
+	)
+}
+
 func (a *APICommandsService) ClientNew(force bool, quiet bool, wait bool) (types.ExecResponseDTO, error) {
 	clientNewResponseDTO, err := makeSecureRequestWithResponse[types.ClientNewRequestDTO, types.ExecResponseDTO](
 		a, "POST", "/commands/client/new",
@@ -152,6 +319,27 @@ func (a *APICommandsService) ClientList() (types.ExecResponseDTO, error) {
 	return clientListResponseDTO, nil
 }
 
+func (a *APICommandsService) NodeStatus() (types.ExecResponseDTO, error) {
+	nodeStatusResponseDTO, err := makeSecureRequestWithResponse[types.NodeStatusRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/node/status",
+		types.NodeStatusRequestDTO{},
+	)
+
+	if err != nil {
+		logger.Error("Request to node/status failed: %v", err)
+		return types.ExecResponseDTO{}, err
+	}
+
+	return nodeStatusResponseDTO, nil
+}
+
+func (a *APICommandsService) Doctor() (types.DoctorResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DoctorRequestDTO, types.DoctorResponseDTO](
+		a, "POST", "/commands/doctor",
+		types.DoctorRequestDTO{},
+	)
+}
+
 func (a *APICommandsService) ServerList() (types.ServerListResponseDTO, error) {
 	serverListResponseDTO, err := makeSecureRequestWithResponse[types.ServerListRequestDTO, types.ServerListResponseDTO](
 		a, "POST", "/commands/server/list",
@@ -200,16 +388,15 @@ func (a *APICommandsService) NodeConfig() (types.NodeConfigResponseDTO, error) {
 	return nodeConfigResponseDTO, nil
 }
 
-func (a *APICommandsService) ServicePublish(localProtocol string, localHost string, localPort uint16, publicProtocol string, publicHost string, publicPort uint16) (types.ExecResponseDTO, error) {
+func (a *APICommandsService) ServicePublish(upstreams []publicservices.PublicServiceUpstream, publicProtocol string, publicHost string, publicPort uint16, options publicservices.PublishOptions) (types.ExecResponseDTO, error) {
 	servicePublishResponseDTO, err := makeSecureRequestWithResponse[types.ServicePublishRequestDTO, types.ExecResponseDTO](
 		a, "POST", "/commands/service/publish",
 		types.ServicePublishRequestDTO{
-			LocalProtocol:  localProtocol,
-			LocalHost:      localHost,
-			LocalPort:      localPort,
+			Upstreams:      upstreams,
 			PublicProtocol: publicProtocol,
 			PublicHost:     publicHost,
 			PublicPort:     publicPort,
+			Options:        options,
 		})
 
 	if err != nil {
@@ -220,13 +407,16 @@ func (a *APICommandsService) ServicePublish(localProtocol string, localHost stri
 	return servicePublishResponseDTO, nil
 }
 
-func (a *APICommandsService) ServiceUnpublish(publicProtocol string, publicHost string, publicPort uint16) (types.ExecResponseDTO, error) {
+func (a *APICommandsService) ServiceUnpublish(publicProtocol string, publicHost string, publicPort uint16, localProtocol string, localHost string, localPort uint16) (types.ExecResponseDTO, error) {
 	serviceUnpublishResponseDTO, err := makeSecureRequestWithResponse[types.ServiceUnpublishRequestDTO, types.ExecResponseDTO](
 		a, "POST", "/commands/service/unpublish",
 		types.ServiceUnpublishRequestDTO{
 			PublicProtocol: publicProtocol,
 			PublicHost:     publicHost,
 			PublicPort:     publicPort,
+			LocalProtocol:  localProtocol,
+			LocalHost:      localHost,
+			LocalPort:      localPort,
 		})
 
 	if err != nil {
@@ -251,6 +441,30 @@ func (a *APICommandsService) ServiceList() (types.ServiceListRequestDTO, error)
 	return serviceListResponseDTO, nil
 }
 
+func (a *APICommandsService) ServiceStats(publicProtocol string, publicHost string, publicPort uint16) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServiceStatsRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/service/stats",
+		types.ServiceStatsRequestDTO{
+			PublicProtocol: publicProtocol,
+			PublicHost:     publicHost,
+			PublicPort:     publicPort,
+		},
+	)
+}
+
+func (a *APICommandsService) ServiceLogs(publicProtocol string, publicHost string, publicPort uint16, lines int, cursor *accesslogs.Cursor) (types.ServiceLogsResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServiceLogsRequestDTO, types.ServiceLogsResponseDTO](
+		a, "POST", "/commands/service/logs",
+		types.ServiceLogsRequestDTO{
+			PublicProtocol: publicProtocol,
+			PublicHost:     publicHost,
+			PublicPort:     publicPort,
+			Lines:          lines,
+			Cursor:         cursor,
+		},
+	)
+}
+
 func (a *APICommandsService) ServiceParamNew(publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) (types.ExecResponseDTO, error) {
 	serviceParamNewResponseDTO, err := makeSecureRequestWithResponse[types.ServiceParamNewRequestDTO, types.ExecResponseDTO](
 		a, "POST", "/commands/service/params/new",
@@ -305,3 +519,212 @@ func (a *APICommandsService) ServiceParamList(publicProtocol string, publicHost
 
 	return serviceParamListResponseDTO, nil
 }
+
+func (a *APICommandsService) ACMESet(update acme.SettingsUpdate) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ACMESetRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/acme/set",
+		types.ACMESetRequestDTO{
+			Update: update,
+		},
+	)
+}
+
+func (a *APICommandsService) ACMEShow() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ACMEShowRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/acme/show",
+		types.ACMEShowRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) ACMEReset() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ACMEResetRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/acme/reset",
+		types.ACMEResetRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) CertAdd(certPEM string, keyPEM string, hosts []string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.CertAddRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/cert/add",
+		types.CertAddRequestDTO{
+			CertPEM: certPEM,
+			KeyPEM:  keyPEM,
+			Hosts:   hosts,
+		},
+	)
+}
+
+func (a *APICommandsService) CertList() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.CertListRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/cert/list",
+		types.CertListRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) CertRemove(id string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.CertRemoveRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/cert/remove",
+		types.CertRemoveRequestDTO{
+			ID: id,
+		},
+	)
+}
+
+func (a *APICommandsService) CertStatus() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.CertStatusRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/cert/status",
+		types.CertStatusRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) NotifyAdd(url string, secret string, events []string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.NotifyAddRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/notify/add",
+		types.NotifyAddRequestDTO{
+			URL:    url,
+			Secret: secret,
+			Events: events,
+		},
+	)
+}
+
+func (a *APICommandsService) NotifyList() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.NotifyListRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/notify/list",
+		types.NotifyListRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) NotifyRemove(id string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.NotifyRemoveRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/notify/remove",
+		types.NotifyRemoveRequestDTO{
+			ID: id,
+		},
+	)
+}
+
+func (a *APICommandsService) NotifyTest(id string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.NotifyTestRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/notify/test",
+		types.NotifyTestRequestDTO{
+			ID: id,
+		},
+	)
+}
+
+func (a *APICommandsService) DNSRecordAdd(name string, recordType string, value string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DNSRecordAddRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/dns/record/add",
+		types.DNSRecordAddRequestDTO{
+			Name:  name,
+			Type:  recordType,
+			Value: value,
+		},
+	)
+}
+
+func (a *APICommandsService) DNSRecordList() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DNSRecordListRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/dns/record/list",
+		types.DNSRecordListRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) DNSRecordRemove(name string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DNSRecordRemoveRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/dns/record/remove",
+		types.DNSRecordRemoveRequestDTO{
+			Name: name,
+		},
+	)
+}
+
+func (a *APICommandsService) ControlAPISet(update controlapi.SettingsUpdate) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ControlAPISetRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/gateway/api/set",
+		types.ControlAPISetRequestDTO{
+			Update: update,
+		},
+	)
+}
+
+func (a *APICommandsService) ControlAPIShow() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ControlAPIShowRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/gateway/api/show",
+		types.ControlAPIShowRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) ControlAPIReset() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ControlAPIResetRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/gateway/api/reset",
+		types.ControlAPIResetRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) DNSConfigSet(update dnsrecords.SettingsUpdate) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DNSConfigSetRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/dns/config/set",
+		types.DNSConfigSetRequestDTO{
+			Update: update,
+		},
+	)
+}
+
+func (a *APICommandsService) DNSConfigShow() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DNSConfigShowRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/dns/config/show",
+		types.DNSConfigShowRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) DNSConfigReset() (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.DNSConfigResetRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/dns/config/reset",
+		types.DNSConfigResetRequestDTO{},
+	)
+}
+
+func (a *APICommandsService) NodeRename(nodeIP string, name string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.NodeRenameRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/node/rename",
+		types.NodeRenameRequestDTO{
+			NodeIP: nodeIP,
+			Name:   name,
+		},
+	)
+}
+
+func (a *APICommandsService) ClientCertIssue(name string, password string) (types.ClientCertIssueResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ClientCertIssueRequestDTO, types.ClientCertIssueResponseDTO](
+		a, "POST", "/commands/client/cert/issue",
+		types.ClientCertIssueRequestDTO{
+			Name:     name,
+			Password: password,
+		},
+	)
+}
+
+func (a *APICommandsService) ServiceClientAuthEnable(publicProtocol string, publicHost string, publicPort uint16, trustedCAPEM string) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServiceClientAuthEnableRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/service/client-auth/enable",
+		types.ServiceClientAuthEnableRequestDTO{
+			PublicProtocol: publicProtocol,
+			PublicHost:     publicHost,
+			PublicPort:     publicPort,
+			TrustedCAPEM:   trustedCAPEM,
+		},
+	)
+}
+
+func (a *APICommandsService) ServiceClientAuthDisable(publicProtocol string, publicHost string, publicPort uint16) (types.ExecResponseDTO, error) {
+	return makeSecureRequestWithResponse[types.ServiceClientAuthDisableRequestDTO, types.ExecResponseDTO](
+		a, "POST", "/commands/service/client-auth/disable",
+		types.ServiceClientAuthDisableRequestDTO{
+			PublicProtocol: publicProtocol,
+			PublicHost:     publicHost,
+			PublicPort:     publicPort,
+		},
+	)
+}
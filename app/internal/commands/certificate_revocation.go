@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"net/http"
+	"wireport/internal/encryption/mtls"
+	"wireport/internal/logger"
+	"wireport/internal/nodes"
+
+	"gorm.io/gorm"
+)
+
+// NodeCertRenewPath is the only route served to a node whose certificate is signed by a revoked intermediate
+const NodeCertRenewPath = "/commands/node/cert/renew"
+
+// RefuseRevokedCertificates refuses the control API requests of nodes whose certificate is signed by an intermediate the
+// offline root has revoked. Such a node may only renew its certificate, and only with the last one the gateway issued to
+// it, so that certificates minted with the revoked intermediate are of no use. The revocation list is sent with every
+// response, see mtls.RevocationListHeader
+func RefuseRevokedCertificates(next http.Handler, db *gorm.DB) http.Handler {
+	nodesRepository := nodes.NewRepository(db)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := requestLogger(r, logger.ComponentControlAPI)
+
+		certificates, err := gatewayCertificatesInMemory.get(nodesRepository)
+
+		if err != nil {
+			log.Error("Failed to read the certificates of the gateway", logger.Err(err))
+			writeErrorResponse(w, err)
+			return
+		}
+
+		if certificates == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the nodes learn about the intermediates revoked since they joined, and refuse the gateway certificates they sign
+		if certificates.revocationListHeader != "" {
+			w.Header().Set(mtls.RevocationListHeader, certificates.revocationListHeader)
+		}
+
+		if !certificates.revocations.IsRevoked(r.TLS.VerifiedChains) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cert := r.TLS.PeerCertificates[0]
+
+		if r.URL.Path == NodeCertRenewPath && certificates.bundle.IsCurrentClient(cert.Subject.CommonName, cert) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log.Warn("Request refused, the certificate is signed by a revoked intermediate", logger.KeyNodeID, cert.Subject.CommonName)
+		writeErrorResponse(w, mtls.ErrCertificateRevoked)
+	})
+}
@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/commands/types"
+	"wireport/internal/controlapi"
+	"wireport/internal/database"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/encryption/mtls"
+	"wireport/internal/nodes"
+	"wireport/internal/notifications"
+	"wireport/internal/profiles"
+	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
+	"wireport/version"
+)
+
+// CLI exit codes, stable across releases so that scripts can rely on them
+const (
+	ExitCodeOK              = 0
+	ExitCodeFailure         = 1 // unexpected/internal failure
+	ExitCodeInvalidArgument = 2 // invalid flags, addresses, settings
+	ExitCodeNotFound        = 3 // service, upstream, certificate, ... not found
+	ExitCodeConflict        = 4 // already exists, conflicting configuration
+	ExitCodeForbidden       = 5 // not allowed for the current node (role) or the requesting node
+	ExitCodeUnavailable     = 6 // gateway is unreachable
+)
+
+const (
+	ErrorCodeInvalidRequest = "invalid_request"
+	ErrorCodeForbidden      = "forbidden"
+	ErrorCodeUnavailable    = "unavailable"
+	ErrorCodeInternal       = "internal"
+)
+
+type errorKind struct {
+	code       string
+	err        error // sentinel error
+	httpStatus int
+	exitCode   int
+}
+
+// errorKinds maps sentinel errors to the codes they are sent over the control API with;
+// the client maps the codes back to the same sentinels, so errors.Is works on both sides
+var errorKinds = []errorKind{
+	{"service_not_found", publicservices.ErrServiceNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"param_not_found", publicservices.ErrParamNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"param_exists", publicservices.ErrParamExists, http.StatusConflict, ExitCodeConflict},
+	{"upstream_not_found", publicservices.ErrUpstreamNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"invalid_upstream", publicservices.ErrInvalidUpstream, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"invalid_lb_policy", publicservices.ErrInvalidLBPolicy, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"invalid_health_check", publicservices.ErrInvalidHealthCheck, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"invalid_route", publicservices.ErrInvalidRoute, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"route_conflict", publicservices.ErrRouteConflict, http.StatusConflict, ExitCodeConflict},
+	{"invalid_client_auth", publicservices.ErrInvalidClientAuth, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"certificate_not_found", certificates.ErrCertificateNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"invalid_certificate", certificates.ErrInvalidCertificate, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"host_has_certificate", certificates.ErrHostHasCertificate, http.StatusConflict, ExitCodeConflict},
+	{"invalid_acme_settings", acme.ErrInvalidSettings, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"webhook_not_found", notifications.ErrWebhookNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"invalid_webhook", notifications.ErrInvalidWebhook, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"webhook_delivery_failed", notifications.ErrDeliveryFailed, http.StatusBadGateway, ExitCodeUnavailable},
+	{"dns_record_not_found", dnsrecords.ErrRecordNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"invalid_dns_record", dnsrecords.ErrInvalidRecord, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"dns_name_in_use", dnsrecords.ErrNameInUse, http.StatusConflict, ExitCodeConflict},
+	{"invalid_dns_settings", dnsrecords.ErrInvalidSettings, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"invalid_control_api_settings", controlapi.ErrInvalidSettings, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"overlay_only", controlapi.ErrOverlayOnly, http.StatusForbidden, ExitCodeForbidden},
+	{"join_rate_limited", controlapi.ErrJoinRateLimited, http.StatusTooManyRequests, ExitCodeUnavailable},
+	{"join_window_closed", controlapi.ErrJoinWindowClosed, http.StatusForbidden, ExitCodeForbidden},
+	{"rollout_not_found", rollouts.ErrRolloutNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"rollout_in_progress", rollouts.ErrRolloutInProgress, http.StatusConflict, ExitCodeConflict},
+	{"no_servers_to_upgrade", rollouts.ErrNoServersToUpgrade, http.StatusNotFound, ExitCodeNotFound},
+	{"invalid_upgrade_report", rollouts.ErrInvalidReport, http.StatusConflict, ExitCodeConflict},
+	{"rollout_not_running", rollouts.ErrRolloutNotRunning, http.StatusNotFound, ExitCodeNotFound},
+	{"schema_too_new", database.ErrSchemaTooNew, http.StatusConflict, ExitCodeConflict},
+	{"database_in_use", database.ErrDatabaseInUse, http.StatusConflict, ExitCodeConflict},
+	{"gateway_not_found", nodes.ErrGatewayNodeNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"server_not_found", nodes.ErrServerNodeNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"node_not_found", nodes.ErrNodeNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"no_available_docker_subnets", nodes.ErrNoAvailableDockerSubnets, http.StatusConflict, ExitCodeConflict},
+	{"no_available_wireguard_ips", nodes.ErrNoAvailableWGPrivateIPs, http.StatusConflict, ExitCodeConflict},
+	{"no_available_wireguard_client_slots", ErrNoWireguardClientSlots, http.StatusConflict, ExitCodeConflict},
+	{"invalid_join_request", ErrInvalidJoinRequest, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"invalid_join_request_role", ErrInvalidJoinRequestRole, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"invalid_join_token", ErrFailedToParseJoinToken, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"incompatible_version", version.ErrIncompatibleVersion, http.StatusConflict, ExitCodeConflict},
+	{"invalid_ca", mtls.ErrInvalidCA, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"certificate_revoked", mtls.ErrCertificateRevoked, http.StatusForbidden, ExitCodeForbidden},
+	{"root_ca_in_use", ErrRootCAInUse, http.StatusConflict, ExitCodeConflict},
+	{"profile_not_found", profiles.ErrProfileNotFound, http.StatusNotFound, ExitCodeNotFound},
+	{"invalid_profile", profiles.ErrInvalidProfile, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{"profile_exists", profiles.ErrProfileExists, http.StatusConflict, ExitCodeConflict},
+	{"profile_in_use", profiles.ErrProfileInUse, http.StatusConflict, ExitCodeConflict},
+	{"request_too_large", ErrRequestTooLarge, http.StatusRequestEntityTooLarge, ExitCodeInvalidArgument},
+	{ErrorCodeInvalidRequest, ErrInvalidRequest, http.StatusBadRequest, ExitCodeInvalidArgument},
+	{ErrorCodeForbidden, ErrForbidden, http.StatusForbidden, ExitCodeForbidden},
+	{"role_not_allowed", ErrRoleNotAllowed, http.StatusForbidden, ExitCodeForbidden},
+	{ErrorCodeUnavailable, ErrGatewayUnreachable, http.StatusServiceUnavailable, ExitCodeUnavailable},
+	{"node_unhealthy", ErrNodeUnhealthy, http.StatusServiceUnavailable, ExitCodeUnavailable},
+}
+
+var internalErrorKind = errorKind{ErrorCodeInternal, nil, http.StatusInternalServerError, ExitCodeFailure}
+
+func findErrorKind(err error) errorKind {
+	var commandError *CommandError
+
+	if errors.As(err, &commandError) {
+		return findErrorKindByCode(commandError.Code)
+	}
+
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			return kind
+		}
+	}
+
+	return internalErrorKind
+}
+
+func findErrorKindByCode(code string) errorKind {
+	for _, kind := range errorKinds {
+		if kind.code == code {
+			return kind
+		}
+	}
+
+	return internalErrorKind
+}
+
+// CommandError is a command failure reported by the control API
+type CommandError struct {
+	Code    string
+	Message string
+	Details []string
+}
+
+func (e *CommandError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the sentinel error of the code, so that errors.Is(err, publicservices.ErrServiceNotFound) works on the client
+func (e *CommandError) Unwrap() error {
+	return findErrorKindByCode(e.Code).err
+}
+
+// newErrorResponse packs the error into the control API error response
+func newErrorResponse(err error) (int, types.ErrorResponseDTO) {
+	kind := findErrorKind(err)
+
+	response := types.ErrorResponseDTO{
+		Code:    kind.code,
+		Message: err.Error(),
+	}
+
+	var commandError *CommandError
+
+	if errors.As(err, &commandError) {
+		response.Details = commandError.Details
+	} else if joinedErrors, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, joinedError := range joinedErrors.Unwrap() {
+			response.Details = append(response.Details, joinedError.Error())
+		}
+	}
+
+	return kind.httpStatus, response
+}
+
+// writeErrorResponse answers the control API request with the error response
+func writeErrorResponse(w http.ResponseWriter, err error) {
+	status, response := newErrorResponse(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// parseErrorResponse turns a non-2xx control API response into a CommandError
+func parseErrorResponse(statusCode int, responseBody []byte) error {
+	var response types.ErrorResponseDTO
+
+	if err := json.Unmarshal(responseBody, &response); err != nil || response.Code == "" {
+		// e.g. a proxy in between or an older gateway
+		code := ErrorCodeInternal
+
+		switch statusCode {
+		case http.StatusBadRequest:
+			code = ErrorCodeInvalidRequest
+		case http.StatusUnauthorized, http.StatusForbidden:
+			code = ErrorCodeForbidden
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			code = ErrorCodeUnavailable
+		}
+
+		return &CommandError{
+			Code:    code,
+			Message: fmt.Sprintf("request failed with status %d: %s", statusCode, string(responseBody)),
+		}
+	}
+
+	return &CommandError{
+		Code:    response.Code,
+		Message: response.Message,
+		Details: response.Details,
+	}
+}
+
+// ExitCode returns the CLI exit code for the error of a command
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeOK
+	}
+
+	return findErrorKind(err).exitCode
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"wireport/internal/controlapi"
+	"wireport/internal/logger"
+	"wireport/internal/nodes"
+
+	"gorm.io/gorm"
+)
+
+type publicJoinWindowContextKey struct{}
+
+// gatewayOverlayIP is the WireGuard address of the gateway, read from the database until the gateway node exists;
+// it does not change afterwards
+type gatewayOverlayIP struct {
+	mu sync.Mutex
+	ip net.IP
+}
+
+func (g *gatewayOverlayIP) get(nodesRepository *nodes.Repository) net.IP {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.ip == nil {
+		if gatewayNode, err := nodesRepository.GetGatewayNode(); err == nil && gatewayNode != nil {
+			g.ip = gatewayNode.WGConfig.Interface.Address.IP
+		}
+	}
+
+	return g.ip
+}
+
+// ControlAPIAccess restricts the routes served on the public address of the gateway: in the overlay-only mode
+// it serves join requests only, which are rate-limited and time-boxed by the control API settings in any mode
+func ControlAPIAccess(next http.Handler, db *gorm.DB) http.Handler {
+	nodesRepository := nodes.NewRepository(db)
+	controlAPIRepository := controlapi.NewRepository(db)
+	joinLimiter := controlapi.NewJoinLimiter()
+	gatewayWGIP := &gatewayOverlayIP{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := requestLogger(r, logger.ComponentControlAPI)
+
+		localAddr, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+
+		if controlapi.IsOverlayConnection(localAddr, r.RemoteAddr, gatewayWGIP.get(nodesRepository)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// read on every request on the public address, 'gateway api set' runs in another process
+		settings, err := controlAPIRepository.Get()
+
+		if err != nil {
+			log.Error("Failed to get control API settings", logger.Err(err))
+			writeErrorResponse(w, fmt.Errorf("failed to get control API settings: %v", err))
+			return
+		}
+
+		if r.URL.Path != controlapi.JoinPath {
+			if settings.OverlayOnly {
+				log.Warn("Request on the public address refused, the control API is overlay-only", logger.KeyRemoteAddr, r.RemoteAddr)
+				writeErrorResponse(w, controlapi.ErrOverlayOnly)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+
+		if !joinLimiter.Allow(remoteIP, settings.JoinRateLimit, time.Now()) {
+			log.Warn("Join request refused, rate limit exceeded", logger.KeyRemoteAddr, r.RemoteAddr)
+			writeErrorResponse(w, controlapi.ErrJoinRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), publicJoinWindowContextKey{}, settings.JoinWindow)))
+	})
+}
+
+// publicJoinWindow returns how long after issuing a join token it is accepted, 0 for requests
+// over the WireGuard network and for no limit
+func publicJoinWindow(r *http.Request) time.Duration {
+	window, _ := r.Context().Value(publicJoinWindowContextKey{}).(time.Duration)
+
+	return window
+}
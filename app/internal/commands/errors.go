@@ -17,4 +17,14 @@ var (
 	ErrFailedToCreateClientNode   = errors.New("failed to create client node")
 	ErrFailedToRestartServices    = errors.New("failed to restart services")
 	ErrFailedToListServices       = errors.New("failed to list services")
+	ErrInvalidRequest             = errors.New("invalid request")
+	ErrRequestTooLarge            = errors.New("request body is too large")
+	ErrForbidden                  = errors.New("operation is not allowed for the requesting node")
+	ErrRoleNotAllowed             = errors.New("command is not allowed for the current node role")
+	ErrGatewayUnreachable         = errors.New("gateway is unreachable")
+	ErrNoWireguardClientSlots     = errors.New("no available wireguard client slots")
+	ErrFailedToJoinNetwork        = errors.New("failed to join the wireport network")
+	ErrDoctorChecksFailed         = errors.New("some connectivity checks failed")
+	ErrNodeUnhealthy              = errors.New("node is not healthy")
+	ErrRootCAInUse                = errors.New("nodes trust the current root CA")
 )
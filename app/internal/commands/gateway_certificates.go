@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+	"wireport/internal/encryption/mtls"
+	"wireport/internal/nodes"
+)
+
+// gatewayCertificates are the certificates of the gateway as the control server uses them, parsed once
+type gatewayCertificates struct {
+	updatedAt time.Time // of the gateway node they were read from
+
+	bundle               *mtls.FullGatewayBundle
+	tlsConfig            *tls.Config
+	revocations          *mtls.Revocations
+	revocationListHeader string // value of mtls.RevocationListHeader, empty without a revocation list
+}
+
+// gatewayCertificatesCache keeps the certificates of the gateway in memory for the TLS handshakes of the control server
+// and RefuseRevokedCertificates. The copy is read again once the gateway node has been saved since, e.g. by 'gateway ca
+// import' running in another process; renewals and imports served by this process drop it right away (see invalidate)
+type gatewayCertificatesCache struct {
+	mu      sync.Mutex
+	current *gatewayCertificates
+}
+
+var gatewayCertificatesInMemory = &gatewayCertificatesCache{}
+
+// get returns the certificates of the gateway, nil if there is no gateway node or it has no certificates
+func (c *gatewayCertificatesCache) get(nodesRepository *nodes.Repository) (*gatewayCertificates, error) {
+	updatedAt, err := nodesRepository.GetGatewayNodeUpdatedAt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil && c.current.updatedAt.Equal(updatedAt) {
+		return c.current, nil
+	}
+
+	gatewayNode, err := nodesRepository.GetGatewayNode()
+
+	if err != nil || gatewayNode == nil || gatewayNode.GatewayCertBundle == nil {
+		c.current = nil
+		return nil, err
+	}
+
+	bundle := gatewayNode.GatewayCertBundle
+
+	tlsConfig, err := bundle.GetServerTLSConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	revocations, err := bundle.Revocations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := &gatewayCertificates{
+		updatedAt:   gatewayNode.UpdatedAt,
+		bundle:      bundle,
+		tlsConfig:   tlsConfig,
+		revocations: revocations,
+	}
+
+	if bundle.RevocationList != "" {
+		certificates.revocationListHeader = mtls.EncodeRevocationListHeader(bundle.RevocationList)
+	}
+
+	c.current = certificates
+
+	return certificates, nil
+}
+
+// invalidate drops the copy, e.g. after the certificates of the gateway node have been changed
+func (c *gatewayCertificatesCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = nil
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"wireport/cmd/server/config"
+	"wireport/internal/nodes/types"
+)
+
+// loadGatewayConfigs collects everything the gateway configs are rendered from
+func (s *LocalCommandsService) loadGatewayConfigs() (*types.GatewayConfigs, error) {
+	publicServices, err := s.PublicServicesRepository.GetAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	acmeSettings, err := s.ACMERepository.Get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayCertificates, err := s.CertificatesRepository.GetAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	allNodes, err := s.NodesRepository.GetAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	dnsRecords, err := s.DNSRecordsRepository.GetAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	dnsSettings, err := s.DNSRecordsRepository.GetSettings()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.GatewayConfigs{
+		PublicServices: publicServices,
+		ACME:           acmeSettings,
+		Certificates:   gatewayCertificates,
+		AccessLogsDir:  config.Config.CaddyAccessLogsDir,
+		Nodes:          allNodes,
+		DNSRecords:     dnsRecords,
+		DNSSettings:    dnsSettings,
+	}, nil
+}
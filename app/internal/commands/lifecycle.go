@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// runPeriodically calls fn every interval until the context is done; the wait group is done once the last call
+// returns, so that shutdown waits for the writes in progress instead of cutting them off
+func runPeriodically(ctx context.Context, waitGroup *sync.WaitGroup, interval time.Duration, fn func()) {
+	waitGroup.Add(1)
+
+	go func() {
+		defer waitGroup.Done()
+
+		for {
+			fn()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// waitWithTimeout waits for the wait group until the context is done, false if it gave up
+func waitWithTimeout(ctx context.Context, waitGroup *sync.WaitGroup) bool {
+	done := make(chan struct{})
+
+	go func() {
+		waitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// LimitRequestBodies caps the size of the bodies of all control API requests
+func LimitRequestBodies(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestBodyError turns a failure to decode a request body into the error sent back to the client
+func requestBodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Errorf("%w: the limit is %d bytes", ErrRequestTooLarge, maxBytesErr.Limit)
+	}
+
+	return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+}
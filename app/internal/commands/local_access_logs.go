@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/accesslogs"
+	commandstypes "wireport/internal/commands/types"
+	"wireport/internal/publicservices"
+)
+
+// serviceAccessLog returns the access log file of the service and the parser of its lines; layer 4 services
+// share a single log, their connections are told apart by the upstreams. onClose is called with the layer 4
+// connections once they are closed, it may be nil
+func (s *LocalCommandsService) serviceAccessLog(publicProtocol string, publicHost string, publicPort uint16, onClose func(entry *accesslogs.Entry)) (*publicservices.PublicService, string, func([]byte) (*accesslogs.Entry, error), error) {
+	service, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort)
+
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if !service.IsLayer4() {
+		return service, filepath.Join(config.Config.CaddyAccessLogsDir, service.AccessLogFileName()), accesslogs.ParseLayer7Line, nil
+	}
+
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to get gateway node: %w", err)
+	}
+
+	parser := &accesslogs.Layer4Parser{Upstreams: service.CaddyUpstreamAddresses(gatewayNode.GatewayPublicIP), OnClose: onClose}
+
+	return service, filepath.Join(config.Config.CaddyAccessLogsDir, publicservices.Layer4AccessLogFileName), parser.Parse, nil
+}
+
+// ServiceStats prints the traffic statistics of the service aggregated from its access logs
+func (s *LocalCommandsService) ServiceStats(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16) error {
+	// aggregated while the lines are read, the logs are not kept in memory: layer 4 connections are counted when
+	// they are opened and get their traffic when they are closed
+	stats := &accesslogs.Stats{}
+
+	service, path, parse, err := s.serviceAccessLog(publicProtocol, publicHost, publicPort, stats.AddTraffic)
+
+	if err != nil {
+		return err
+	}
+
+	err = accesslogs.Read(path, func(line []byte) {
+		if entry, err := parse(line); err == nil {
+			stats.Add(entry)
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to read access log %s: %w", path, err)
+	}
+
+	stats.Finish(config.Config.ServiceStatsTopClients)
+
+	fmt.Fprintf(stdOut, "Traffic of %s://%s:%d\n", service.PublicProtocol, service.PublicHost, service.PublicPort)
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+
+	if stats.Requests == 0 {
+		fmt.Fprintf(stdOut, "No requests logged yet\n")
+		return nil
+	}
+
+	requestsLabel := "Requests"
+
+	if service.IsLayer4() {
+		requestsLabel = "Connections"
+	}
+
+	fmt.Fprintf(stdOut, "%-16s %s - %s\n", "Period", stats.From.UTC().Format(time.RFC3339), stats.To.UTC().Format(time.RFC3339))
+	fmt.Fprintf(stdOut, "%-16s %d\n", requestsLabel, stats.Requests)
+	fmt.Fprintf(stdOut, "%-16s %s\n", "Sent", formatBytes(stats.BytesSent))
+	fmt.Fprintf(stdOut, "%-16s %s\n", "Received", formatBytes(stats.BytesReceived))
+
+	if len(stats.StatusCodes) > 0 {
+		statusCodes := []string{}
+
+		for _, code := range stats.StatusCodesSorted() {
+			statusCodes = append(statusCodes, fmt.Sprintf("%d: %d", code, stats.StatusCodes[code]))
+		}
+
+		fmt.Fprintf(stdOut, "%-16s %s\n", "Status codes", strings.Join(statusCodes, ", "))
+	}
+
+	fmt.Fprintf(stdOut, "\n%-40s %s\n", "TOP CLIENT IPS", strings.ToUpper(requestsLabel))
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+
+	for _, client := range stats.TopClients {
+		fmt.Fprintf(stdOut, "%-40s %d\n", client.ClientIP, client.Count)
+	}
+
+	return nil
+}
+
+// ServiceLogs returns the access log entries of the service logged since the cursor, or the last lines without a cursor
+func (s *LocalCommandsService) ServiceLogs(publicProtocol string, publicHost string, publicPort uint16, lines int, cursor *accesslogs.Cursor) (*commandstypes.ServiceLogsResponseDTO, error) {
+	_, path, parse, err := s.serviceAccessLog(publicProtocol, publicHost, publicPort, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*accesslogs.Entry{}
+
+	next, err := accesslogs.Tail(path, cursor, func(line []byte) {
+		if entry, err := parse(line); err == nil {
+			entries = append(entries, entry)
+		}
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access log %s: %w", path, err)
+	}
+
+	if cursor == nil && lines > 0 && len(entries) > lines {
+		entries = entries[len(entries)-lines:]
+	}
+
+	return &commandstypes.ServiceLogsResponseDTO{Entries: entries, Cursor: next}, nil
+}
+
+// printServiceLogs prints the access log entries returned by fetch; with follow, new entries are polled until interrupted
+func printServiceLogs(stdOut io.Writer, follow bool, fetch func(cursor *accesslogs.Cursor) (*commandstypes.ServiceLogsResponseDTO, error)) error {
+	var cursor *accesslogs.Cursor
+
+	for {
+		response, err := fetch(cursor)
+
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range response.Entries {
+			fmt.Fprintln(stdOut, formatAccessLogEntry(entry))
+		}
+
+		if !follow {
+			return nil
+		}
+
+		cursor = response.Cursor
+
+		time.Sleep(config.Config.ServiceLogsFollowInterval)
+	}
+}
+
+func formatAccessLogEntry(entry *accesslogs.Entry) string {
+	timestamp := entry.Time.UTC().Format(time.RFC3339)
+
+	if entry.Upstream == "" {
+		return fmt.Sprintf("%s %s %s %s%s %d %s %s", timestamp, entry.ClientIP, entry.Method, entry.Host, entry.URI, entry.Status, formatBytes(entry.BytesSent), entry.Duration.Round(time.Millisecond))
+	}
+
+	if !entry.Closed {
+		// the connection was still open when the log was read
+		return fmt.Sprintf("%s %s -> %s", timestamp, entry.ClientIP, entry.Upstream)
+	}
+
+	return fmt.Sprintf("%s %s -> %s sent %s, received %s in %s", timestamp, entry.ClientIP, entry.Upstream, formatBytes(entry.BytesSent), formatBytes(entry.BytesReceived), entry.Duration.Round(time.Millisecond))
+}
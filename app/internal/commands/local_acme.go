@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"wireport/internal/acme"
+)
+
+func (s *LocalCommandsService) ACMESet(stdOut io.Writer, _ io.Writer, update acme.SettingsUpdate) error {
+	settings, err := s.ACMERepository.Get()
+
+	if err != nil {
+		return fmt.Errorf("error getting ACME settings: %w", err)
+	}
+
+	update.Apply(settings)
+
+	if err = settings.Validate(); err != nil {
+		return fmt.Errorf("error updating ACME settings: %w", err)
+	}
+
+	if err = s.ensureWildcardServicesAreCovered(settings); err != nil {
+		return fmt.Errorf("error updating ACME settings: %w", err)
+	}
+
+	if err = s.ACMERepository.Save(settings); err != nil {
+		return fmt.Errorf("error saving ACME settings: %w", err)
+	}
+
+	if err = s.applyGatewayConfigs(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ ACME settings updated\n\n")
+
+	s.printACMESettings(stdOut, settings)
+
+	return nil
+}
+
+func (s *LocalCommandsService) ACMEShow(stdOut io.Writer, _ io.Writer) error {
+	settings, err := s.ACMERepository.Get()
+
+	if err != nil {
+		return fmt.Errorf("error getting ACME settings: %w", err)
+	}
+
+	s.printACMESettings(stdOut, settings)
+
+	return nil
+}
+
+func (s *LocalCommandsService) ACMEReset(stdOut io.Writer, _ io.Writer) error {
+	if err := s.ensureWildcardServicesAreCovered(&acme.Settings{}); err != nil {
+		return fmt.Errorf("error resetting ACME settings: %w", err)
+	}
+
+	if err := s.ACMERepository.Reset(); err != nil {
+		return fmt.Errorf("error resetting ACME settings: %w", err)
+	}
+
+	if err := s.applyGatewayConfigs(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ ACME settings reset to Caddy defaults (HTTP-01/TLS-ALPN-01 challenges, Let's Encrypt and ZeroSSL)\n")
+
+	return nil
+}
+
+// ensureWildcardServicesAreCovered refuses settings that would leave published https wildcard hosts without the DNS-01 challenge
+func (s *LocalCommandsService) ensureWildcardServicesAreCovered(settings *acme.Settings) error {
+	if settings.IsDNSChallengeEnabled() {
+		return nil
+	}
+
+	services, err := s.PublicServicesRepository.GetAll()
+
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		if service.IsWildcardHTTPS() {
+			return fmt.Errorf("%w: https wildcard service %s://%s:%d requires a DNS provider, unpublish it first", acme.ErrInvalidSettings, service.PublicProtocol, service.PublicHost, service.PublicPort)
+		}
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) printACMESettings(stdOut io.Writer, settings *acme.Settings) {
+	valueOrDefault := func(value string, defaultValue string) string {
+		if value == "" {
+			return defaultValue
+		}
+
+		return value
+	}
+
+	caRoot := "(system trust store)"
+
+	if settings.CARootPEM != "" {
+		caRoot = "custom root certificate"
+	}
+
+	challenge := "HTTP-01 / TLS-ALPN-01"
+
+	if settings.IsDNSChallengeEnabled() {
+		challenge = fmt.Sprintf("DNS-01 (%s)", settings.DNSProvider)
+	}
+
+	fmt.Fprintf(stdOut, "ACME SETTINGS\n")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+	fmt.Fprintf(stdOut, "Email:\t\t%s\n", valueOrDefault(settings.Email, "(not set)"))
+	fmt.Fprintf(stdOut, "CA directory:\t%s\n", valueOrDefault(settings.CADirectory, "(Caddy defaults: Let's Encrypt, ZeroSSL)"))
+	fmt.Fprintf(stdOut, "CA root:\t%s\n", caRoot)
+	fmt.Fprintf(stdOut, "Challenge:\t%s\n", challenge)
+
+	if credentials := settings.MaskedCredentials(); len(credentials) > 0 {
+		fmt.Fprintf(stdOut, "Credentials:\t%s\n", strings.Join(credentials, ", "))
+	}
+
+	fmt.Fprintf(stdOut, "\n")
+}
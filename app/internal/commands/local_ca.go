@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/encryption/mtls"
+	"wireport/internal/nodes"
+	"wireport/internal/nodes/types"
+
+	"gorm.io/gorm"
+)
+
+// CAInit generates a root CA to be kept offline, it signs the intermediates of gateways bootstrapped with 'gateway up --ca-dir'
+func (s *LocalCommandsService) CAInit(dir string, commonName string, stdOut io.Writer, _ io.Writer) error {
+	if _, err := os.Stat(filepath.Join(dir, mtls.RootKeyFile)); err == nil {
+		return fmt.Errorf("%w: %s already holds a root CA", ErrInvalidRequest, dir)
+	}
+
+	root, err := mtls.GenerateRootCA(commonName, config.Config.RootCAExpiry)
+
+	if err != nil {
+		return fmt.Errorf("failed to generate the root CA: %w", err)
+	}
+
+	if err = mtls.WriteCAFile(dir, mtls.RootKeyFile, root.KeyPEM, true); err != nil {
+		return err
+	}
+
+	if err = mtls.WriteCAFile(dir, mtls.RootCertFile, root.CertPEM, false); err != nil {
+		return err
+	}
+
+	rootCert, err := mtls.ParseCertificate(root.CertPEM)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Root CA %s generated in %s (expires %s)\n", commonName, dir, rootCert.NotAfter.Format(time.DateOnly))
+	fmt.Fprintf(stdOut, "   💡 Keep %s offline, e.g. on an encrypted USB drive; it is only needed to issue and revoke gateway intermediates\n", mtls.RootKeyFile)
+	fmt.Fprintf(stdOut, "   💡 Issue the intermediate of a gateway with 'wireport ca intermediate --ca-dir %s --out <dir>'\n", dir)
+
+	return nil
+}
+
+// CAIntermediate signs a gateway intermediate with the offline root and writes it to outDir, with the root certificate
+// and the revocation list, ready for 'gateway up --ca-dir' or 'gateway ca import'
+func (s *LocalCommandsService) CAIntermediate(dir string, outDir string, commonName string, stdOut io.Writer, _ io.Writer) error {
+	root, revocationList, err := mtls.ReadRootDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	intermediate, err := mtls.IssueIntermediate(*root, commonName, config.Config.IntermediateCAExpiry)
+
+	if err != nil {
+		return fmt.Errorf("failed to issue the intermediate: %w", err)
+	}
+
+	err = mtls.WriteIntermediateDir(outDir, &mtls.Intermediate{
+		RootCertPEM:    root.CertPEM,
+		CA:             *intermediate,
+		RevocationList: revocationList,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cert, err := mtls.ParseCertificate(intermediate.CertPEM)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Intermediate %s issued to %s\n", describeCACertificate(cert), outDir)
+	fmt.Fprintf(stdOut, "   💡 Bootstrap a gateway with it: 'wireport gateway up <user>@<host> --ca-dir %s'\n", outDir)
+	fmt.Fprintf(stdOut, "   💡 Or replace the intermediate of a running gateway: copy %s to %s on the gateway and run 'wireport gateway ca import'\n", outDir, config.Config.GatewayCADir)
+
+	return nil
+}
+
+// CARevoke adds the intermediate to the revocation list of the offline root, e.g. after the gateway holding it was
+// compromised; the list reaches the nodes with the next intermediate imported by the gateway
+func (s *LocalCommandsService) CARevoke(dir string, certPath string, stdOut io.Writer, _ io.Writer) error {
+	root, revocationList, err := mtls.ReadRootDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+
+	if err != nil {
+		return fmt.Errorf("failed to read the certificate to revoke: %w", err)
+	}
+
+	revocationList, err = mtls.RevokeCertificate(*root, revocationList, string(certPEM))
+
+	if err != nil {
+		return err
+	}
+
+	if err = mtls.WriteCAFile(dir, mtls.RevocationListFile, revocationList, false); err != nil {
+		return err
+	}
+
+	cert, err := mtls.ParseCertificate(string(certPEM))
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Intermediate %s revoked in %s\n", describeCACertificate(cert), filepath.Join(dir, mtls.RevocationListFile))
+	fmt.Fprintf(stdOut, "   💡 Issue a new intermediate with 'wireport ca intermediate' and import it on the gateway with 'wireport gateway ca import':\n")
+	fmt.Fprintf(stdOut, "      the nodes renew their certificates on their next request and refuse the revoked intermediate from then on\n")
+
+	return nil
+}
+
+// GatewayCAImport makes the intermediate in the directory sign the certificates of the gateway. An intermediate of the
+// root the nodes already trust replaces the current one without re-enrolling them; a new root is only accepted before
+// any server or client has joined
+func (s *LocalCommandsService) GatewayCAImport(dir string, stdOut io.Writer, _ io.Writer) error {
+	intermediate, err := mtls.ReadIntermediateDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	if intermediate == nil {
+		return fmt.Errorf("%w: no %s in %s, issue one with 'wireport ca intermediate'", ErrInvalidRequest, mtls.IntermediateCertFile, dir)
+	}
+
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil || gatewayNode == nil || gatewayNode.GatewayCertBundle == nil {
+		return errors.Join(ErrFailedToGetGatewayNode, err)
+	}
+
+	bundle := gatewayNode.GatewayCertBundle
+	rootChanged := !bundle.HasRoot(intermediate.RootCertPEM)
+
+	if rootChanged {
+		joinedNodes := 0
+
+		for _, role := range []types.NodeRole{types.NodeRoleServer, types.NodeRoleClient} {
+			count, err := s.NodesRepository.CountNodesByRole(role)
+
+			if err != nil {
+				return err
+			}
+
+			joinedNodes += count
+		}
+
+		if joinedNodes > 0 {
+			return fmt.Errorf("%w: %d nodes have joined, an intermediate of another root would make them join again", ErrRootCAInUse, joinedNodes)
+		}
+	}
+
+	serverOptions, err := bundle.ServerOptions(config.Config.CertExpiry)
+
+	if err != nil {
+		return err
+	}
+
+	if err = bundle.ImportIntermediate(intermediate, serverOptions); err != nil {
+		return err
+	}
+
+	if err = s.NodesRepository.SaveNode(gatewayNode); err != nil {
+		return fmt.Errorf("failed to save gateway node: %w", err)
+	}
+
+	gatewayCertificatesInMemory.invalidate()
+
+	// the key is kept encrypted in the database from now on
+	if err = os.Remove(filepath.Join(dir, mtls.IntermediateKeyFile)); err != nil {
+		fmt.Fprintf(stdOut, "⚠️  Failed to remove %s, remove it by hand: %v\n", filepath.Join(dir, mtls.IntermediateKeyFile), err)
+	}
+
+	status, err := bundle.CAStatus()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Intermediate %s imported, the gateway certificates are signed by it\n", describeCACertificate(status.Intermediate))
+
+	if rootChanged {
+		fmt.Fprintf(stdOut, "   💡 Join tokens issued before the import are signed by the previous root, issue them again\n")
+	} else if status.RevokedCount > 0 {
+		fmt.Fprintf(stdOut, "   💡 Nodes holding a certificate of a revoked intermediate renew it on their next request\n")
+	}
+
+	return nil
+}
+
+// GatewayCAStatus prints the CAs the certificates of the gateway are signed by
+func (s *LocalCommandsService) GatewayCAStatus(stdOut io.Writer, _ io.Writer) error {
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil || gatewayNode == nil || gatewayNode.GatewayCertBundle == nil {
+		return errors.Join(ErrFailedToGetGatewayNode, err)
+	}
+
+	status, err := gatewayNode.GatewayCertBundle.CAStatus()
+
+	if err != nil {
+		return err
+	}
+
+	rootMode := "held by the gateway"
+
+	if status.RootOffline {
+		rootMode = "offline"
+	}
+
+	fmt.Fprintf(stdOut, "Root CA:      %s, %s\n", describeCACertificate(status.Root), rootMode)
+
+	if status.Intermediate != nil {
+		fmt.Fprintf(stdOut, "Intermediate: %s\n", describeCACertificate(status.Intermediate))
+	} else {
+		fmt.Fprintf(stdOut, "Intermediate: - (the root signs the certificates, see 'wireport ca')\n")
+	}
+
+	fmt.Fprintf(stdOut, "Revoked:      %d intermediates\n", status.RevokedCount)
+
+	return nil
+}
+
+// NodeCertRenew issues a new certificate to the node, signed by the current intermediate of the gateway
+func (s *LocalCommandsService) NodeCertRenew(nodeID string) (*mtls.FullClientBundle, error) {
+	node, err := s.NodesRepository.GetByID(nodeID)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", nodes.ErrNodeNotFound, nodeID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil || gatewayNode == nil || gatewayNode.GatewayCertBundle == nil {
+		return nil, errors.Join(ErrFailedToGetGatewayNode, err)
+	}
+
+	bundle, err := gatewayNode.GatewayCertBundle.RenewClient(node.ID, config.Config.CertExpiry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.NodesRepository.SaveNode(gatewayNode); err != nil {
+		return nil, fmt.Errorf("failed to save gateway node: %w", err)
+	}
+
+	gatewayCertificatesInMemory.invalidate()
+
+	if err = s.NodesRepository.UpdateClientCertBundle(node.ID, bundle); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+func describeCACertificate(cert *x509.Certificate) string {
+	return fmt.Sprintf("%s (serial %s, expires %s)", cert.Subject.CommonName, cert.SerialNumber.Text(16), cert.NotAfter.Format(time.DateOnly))
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/certificates"
+)
+
+func (s *LocalCommandsService) CertAdd(stdOut io.Writer, _ io.Writer, certPEM string, keyPEM string, hosts []string) error {
+	certificate, err := certificates.NewCertificate(certPEM, keyPEM, hosts)
+
+	if err != nil {
+		return fmt.Errorf("error adding certificate: %w", err)
+	}
+
+	if certificate.IsExpired() {
+		return fmt.Errorf("error adding certificate: %w: certificate expired on %s", certificates.ErrInvalidCertificate, certificate.NotAfter.Format(time.DateOnly))
+	}
+
+	if err = s.CertificatesRepository.Create(certificate); err != nil {
+		return fmt.Errorf("error adding certificate: %w", err)
+	}
+
+	if err = s.applyGatewayConfigs(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Certificate %s added for %s (expires %s)\n", certificate.ID, strings.Join(certificate.Hosts, ", "), certificate.NotAfter.Format(time.DateOnly))
+
+	if certificate.ExpiresWithin(config.Config.CertificateExpiryWarn) {
+		fmt.Fprintf(stdOut, "⚠️  Certificate expires in %s, remember to replace it in time\n", formatTimeUntil(certificate.NotAfter))
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) CertList(stdOut io.Writer, _ io.Writer) error {
+	gatewayCertificates, err := s.CertificatesRepository.GetAll()
+
+	if err != nil {
+		return fmt.Errorf("error getting certificates: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "ID\t\t\t\t\tEXPIRES\t\tHOSTS\n")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+
+	if len(gatewayCertificates) == 0 {
+		fmt.Fprintf(stdOut, "No certificates are added to this gateway\n")
+		return nil
+	}
+
+	for _, certificate := range gatewayCertificates {
+		expiryMark := ""
+
+		if certificate.IsExpired() {
+			expiryMark = " ❌"
+		} else if certificate.ExpiresWithin(config.Config.CertificateExpiryWarn) {
+			expiryMark = " ⚠️"
+		}
+
+		fmt.Fprintf(stdOut, "%s\t%s%s\t%s\n", certificate.ID, certificate.NotAfter.Format(time.DateOnly), expiryMark, strings.Join(certificate.Hosts, ", "))
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) CertRemove(stdOut io.Writer, _ io.Writer, id string) error {
+	if !s.CertificatesRepository.Delete(id) {
+		return fmt.Errorf("error removing certificate: %w: %s", certificates.ErrCertificateNotFound, id)
+	}
+
+	if err := s.applyGatewayConfigs(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Certificate %s removed, its hosts are served with ACME certificates again\n", id)
+
+	return nil
+}
+
+// CertStatus prints the certificates section of the gateway status with expiry warnings
+func (s *LocalCommandsService) CertStatus(stdOut io.Writer, _ io.Writer) error {
+	gatewayCertificates, err := s.CertificatesRepository.GetAll()
+
+	if err != nil {
+		return fmt.Errorf("error getting certificates: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "🔐 TLS Certificates\n")
+
+	if len(gatewayCertificates) == 0 {
+		fmt.Fprintf(stdOut, "   Status: ✅ All hosts use ACME certificates\n\n")
+		return nil
+	}
+
+	warnings := 0
+
+	for _, certificate := range gatewayCertificates {
+		hosts := strings.Join(certificate.Hosts, ", ")
+
+		if certificate.IsExpired() {
+			fmt.Fprintf(stdOut, "   ❌ %s: expired on %s\n", hosts, certificate.NotAfter.Format(time.DateOnly))
+			warnings++
+		} else if certificate.ExpiresWithin(config.Config.CertificateExpiryWarn) {
+			fmt.Fprintf(stdOut, "   ⚠️  %s: expires in %s (%s)\n", hosts, formatTimeUntil(certificate.NotAfter), certificate.NotAfter.Format(time.DateOnly))
+			warnings++
+		}
+	}
+
+	if warnings == 0 {
+		fmt.Fprintf(stdOut, "   Status: ✅ %d certificate(s), none expiring soon\n\n", len(gatewayCertificates))
+		return nil
+	}
+
+	fmt.Fprintf(stdOut, "   💡 Run 'wireport cert add' with a renewed certificate after removing the old one with 'wireport cert remove'.\n\n")
+
+	return nil
+}
+
+func formatTimeUntil(t time.Time) string {
+	days := int(time.Until(t).Hours() / 24)
+
+	if days < 1 {
+		return "less than a day"
+	}
+
+	if days == 1 {
+		return "1 day"
+	}
+
+	return fmt.Sprintf("%d days", days)
+}
@@ -14,13 +14,12 @@ import (
 	"github.com/google/uuid"
 )
 
-func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joinRequestClientCreation bool, quietClientCreation bool, waitClientCreation bool) {
+func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joinRequestClientCreation bool, quietClientCreation bool, waitClientCreation bool) error {
 	currentNode, err := s.NodesRepository.GetCurrentNode()
 
 	if err != nil || currentNode == nil {
 		if !waitClientCreation {
-			fmt.Fprintf(errOut, "Current node not found, skipping client creation\n")
-			return
+			return fmt.Errorf("current node not found, skipping client creation")
 		}
 
 		// wait for 10 seconds with retries every 1 second
@@ -39,8 +38,7 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 		}
 
 		if err != nil || currentNode == nil {
-			fmt.Fprintf(errOut, "Failed to get current node after waiting and multiple retries: %v\n", err)
-			return
+			return fmt.Errorf("failed to get current node after waiting and multiple retries: %w", err)
 		}
 	}
 
@@ -49,15 +47,13 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 		totalWireguardClients, availableWireguardClients, err := s.NodesRepository.TotalAvailableWireguardClients()
 
 		if err != nil {
-			fmt.Fprintf(errOut, "Failed to count available wireguard clients: %v\n", err)
-			return
+			return fmt.Errorf("failed to count available wireguard clients: %w", err)
 		}
 
 		totalJoinRequests := s.JoinRequestsRepository.CountAll()
 
 		if availableWireguardClients <= 0 || totalJoinRequests >= availableWireguardClients {
-			fmt.Fprintf(errOut, "No available wireguard client slots. Please delete some client/server nodes (total used: %d) or client/server join-requests (total used: %d) to free up some wireguard client slots.\n", totalWireguardClients, totalJoinRequests)
-			return
+			return fmt.Errorf("%w: please delete some client/server nodes (total used: %d) or client/server join-requests (total used: %d) to free up some wireguard client slots", ErrNoWireguardClientSlots, totalWireguardClients, totalJoinRequests)
 		}
 
 		if joinRequestClientCreation {
@@ -70,23 +66,20 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 			})
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to add client to gateway cert bundle: %v\n", err)
-				return
+				return fmt.Errorf("failed to add client to gateway cert bundle: %w", err)
 			}
 
 			err = s.NodesRepository.SaveNode(currentNode)
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to save gateway node: %v\n", err)
-				return
+				return fmt.Errorf("failed to save gateway node: %w", err)
 			}
 
 			var clientCertBundle *mtls.FullClientBundle
 			clientCertBundle, err = currentNode.GatewayCertBundle.GetClientBundlePublic(joinRequestID)
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to get client cert bundle: %v\n", err)
-				return
+				return fmt.Errorf("failed to get client cert bundle: %w", err)
 			}
 
 			var joinRequest *joinrequeststypes.JoinRequest
@@ -94,8 +87,7 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 			joinRequest, err = s.JoinRequestsRepository.Create(joinRequestID, *currentNode.WGPublicIP, config.Config.ControlServerPort, nil, types.NodeRoleClient, clientCertBundle)
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to create join request: %v\n", err)
-				return
+				return fmt.Errorf("failed to create join request: %w", err)
 			}
 
 			var joinRequestBase64 *string
@@ -103,8 +95,7 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 			joinRequestBase64, err = joinRequest.ToBase64()
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to encode join request: %v\n", err)
-				return
+				return fmt.Errorf("failed to encode join request: %w", err)
 			}
 
 			if !quietClientCreation {
@@ -118,30 +109,26 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 			clientNode, err = s.NodesRepository.CreateClient()
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to create client: %v\n", err)
-				return
+				return fmt.Errorf("failed to create client: %w", err)
 			}
 
 			// save configs & restart services
-			publicServices, err := s.PublicServicesRepository.GetAll()
+			gatewayConfigs, err := s.loadGatewayConfigs()
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
-				return
+				return fmt.Errorf("failed to load gateway configs: %w", err)
 			}
 
 			currentNode, err = s.NodesRepository.GetCurrentNode()
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to get a fresh current node instance after creating client: %v\n", err)
-				return
+				return fmt.Errorf("failed to get a fresh current node instance after creating client: %w", err)
 			}
 
-			err = currentNode.SaveConfigs(publicServices, false)
+			err = currentNode.SaveConfigs(gatewayConfigs, false)
 
 			if err != nil {
-				fmt.Fprintf(errOut, "Failed to save gateway configs: %v\n", err)
-				return
+				return fmt.Errorf("failed to save gateway configs: %w", err)
 			}
 
 			err = networkapps.RestartNetworkApps(true, false, false)
@@ -161,28 +148,82 @@ func (s *LocalCommandsService) ClientNew(stdOut io.Writer, errOut io.Writer, joi
 			}
 		}
 	}
+
+	return nil
 }
 
-func (s *LocalCommandsService) ClientList(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) {
+func (s *LocalCommandsService) ClientList(requestFromNodeID *string, stdOut io.Writer, _ io.Writer) error {
 	clientNodes, err := s.NodesRepository.GetNodesByRole(types.NodeRoleClient)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Error getting nodes: %v\n", err)
-		return
+		return fmt.Errorf("error getting nodes: %w", err)
 	}
 
-	fmt.Fprintf(stdOut, "CLIENT PRIVATE IP\n")
+	fmt.Fprintf(stdOut, "CLIENT PRIVATE IP       VERSION\n")
 	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
 
 	if len(clientNodes) > 0 {
 		for _, clientNode := range clientNodes {
+			ip := clientNode.WGConfig.Interface.Address.String()
 			if requestFromNodeID != nil && clientNode.ID == *requestFromNodeID {
-				fmt.Fprintf(stdOut, "%s*\n", clientNode.WGConfig.Interface.Address.String())
-			} else {
-				fmt.Fprintf(stdOut, "%s\n", clientNode.WGConfig.Interface.Address.String())
+				ip += "*"
 			}
+
+			fmt.Fprintf(stdOut, "%-24s %s\n", ip, nodeVersion(&clientNode))
 		}
 	} else {
 		fmt.Fprintf(stdOut, "No clients are registered on this gateway\n")
 	}
+
+	return nil
+}
+
+// ClientCertIssue issues a client certificate for public services with client authentication enabled
+// and returns it as a password-protected PKCS#12 bundle
+func (s *LocalCommandsService) ClientCertIssue(stdOut io.Writer, _ io.Writer, name string, password string) ([]byte, error) {
+	if err := s.ensureServicesClientCA(); err != nil {
+		return nil, fmt.Errorf("failed to generate public services client CA: %w", err)
+	}
+
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gateway node: %w", err)
+	}
+
+	clientCert, err := gatewayNode.GatewayCertBundle.IssueServicesClient(mtls.Options{
+		CommonName: name,
+		Expiry:     config.Config.ServicesClientCertExpiry,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue client certificate: %w", err)
+	}
+
+	bundle, err := mtls.EncodePKCS12(clientCert, gatewayNode.GatewayCertBundle.ServicesClientCA.CertPEM, password)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode client certificate: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "✅ Client certificate '%s' issued (expires %s)\n", name, time.Now().Add(config.Config.ServicesClientCertExpiry).Format(time.DateOnly))
+
+	return bundle, nil
+}
+
+// ensureServicesClientCA generates the CA signing client certificates for public services on first use
+func (s *LocalCommandsService) ensureServicesClientCA() error {
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return err
+	}
+
+	changed, err := gatewayNode.GatewayCertBundle.EnsureServicesClientCA(config.Config.CertExpiry)
+
+	if err != nil || !changed {
+		return err
+	}
+
+	return s.NodesRepository.SaveNode(gatewayNode)
 }
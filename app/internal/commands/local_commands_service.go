@@ -1,10 +1,17 @@
 package commands
 
 import (
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/controlapi"
+	"wireport/internal/database"
+	"wireport/internal/dnsrecords"
 	"wireport/internal/joinrequests"
 	"wireport/internal/jointokens"
 	nodes "wireport/internal/nodes"
+	"wireport/internal/notifications"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
 )
 
 type LocalCommandsService struct {
@@ -12,4 +19,11 @@ type LocalCommandsService struct {
 	PublicServicesRepository *publicservices.Repository
 	JoinRequestsRepository   *joinrequests.Repository
 	JoinTokensRepository     *jointokens.Repository
+	ACMERepository           *acme.Repository
+	CertificatesRepository   *certificates.Repository
+	NotificationsRepository  *notifications.Repository
+	DNSRecordsRepository     *dnsrecords.Repository
+	ControlAPIRepository     *controlapi.Repository
+	RolloutsRepository       *rollouts.Repository
+	SchemaRepository         *database.SchemaRepository
 }
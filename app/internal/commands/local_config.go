@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"wireport/cmd/server/config"
+)
+
+// ConfigShow prints the effective configuration, with the source of every setting: a flag, an environment variable, a
+// config file or the default
+func (s *LocalCommandsService) ConfigShow(changedFlags []string, stdOut io.Writer, _ io.Writer) error {
+	fmt.Fprintf(stdOut, "Profile:  %s (selected by %s)\n", config.Config.WireportProfile, config.Config.WireportProfileSource)
+	fmt.Fprintf(stdOut, "Database: %s\n", config.Config.DatabasePath)
+
+	for i, file := range config.ConfigFiles() {
+		label := "Config:"
+
+		if i > 0 {
+			label = ""
+		}
+
+		status := ""
+
+		if _, err := os.Stat(file); err != nil {
+			status = " (not found)"
+		}
+
+		fmt.Fprintf(stdOut, "%-9s %s%s\n", label, file, status)
+	}
+
+	fmt.Fprintf(stdOut, "\n%-34s %-40s %s\n", "SETTING", "VALUE", "SOURCE")
+
+	for _, setting := range config.Settings {
+		source := setting.Source()
+
+		if setting.Flag != "" && slices.Contains(changedFlags, setting.Flag) {
+			source = "--" + setting.Flag
+		}
+
+		value := setting.Value()
+
+		if value == "" {
+			value = "-"
+		}
+
+		fmt.Fprintf(stdOut, "%-34s %-40s %s\n", setting.Key, value, source)
+	}
+
+	return nil
+}
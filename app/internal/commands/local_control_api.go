@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"wireport/cmd/server/config"
+	"wireport/internal/controlapi"
+	"wireport/internal/nodes/types"
+)
+
+func (s *LocalCommandsService) ControlAPISet(stdOut io.Writer, _ io.Writer, update controlapi.SettingsUpdate) error {
+	settings, err := s.ControlAPIRepository.Get()
+
+	if err != nil {
+		return fmt.Errorf("error getting control API settings: %w", err)
+	}
+
+	update.Apply(settings)
+
+	if err = settings.Validate(); err != nil {
+		return fmt.Errorf("error updating control API settings: %w", err)
+	}
+
+	if err = s.ControlAPIRepository.Save(settings); err != nil {
+		return fmt.Errorf("error saving control API settings: %w", err)
+	}
+
+	// applied to the next request, the control server reads the settings on every request
+	fmt.Fprintf(stdOut, "✅ Control API settings updated\n\n")
+
+	return s.printControlAPISettings(stdOut, settings)
+}
+
+func (s *LocalCommandsService) ControlAPIShow(stdOut io.Writer, _ io.Writer) error {
+	settings, err := s.ControlAPIRepository.Get()
+
+	if err != nil {
+		return fmt.Errorf("error getting control API settings: %w", err)
+	}
+
+	return s.printControlAPISettings(stdOut, settings)
+}
+
+func (s *LocalCommandsService) ControlAPIReset(stdOut io.Writer, _ io.Writer) error {
+	if err := s.ControlAPIRepository.Reset(); err != nil {
+		return fmt.Errorf("error resetting control API settings: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "✅ Control API settings reset to defaults (all routes on all addresses, no join limits)\n")
+
+	return nil
+}
+
+func (s *LocalCommandsService) printControlAPISettings(stdOut io.Writer, settings *controlapi.Settings) error {
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return fmt.Errorf("error getting gateway node: %w", err)
+	}
+
+	publicRoutes := "all routes"
+
+	if settings.OverlayOnly {
+		publicRoutes = "join requests only"
+	}
+
+	joinRateLimit := "(no limit)"
+
+	if settings.JoinRateLimit > 0 {
+		joinRateLimit = fmt.Sprintf("%d per minute per IP", settings.JoinRateLimit)
+	}
+
+	joinWindow := "(no limit)"
+
+	if settings.JoinWindow > 0 {
+		joinWindow = fmt.Sprintf("%s after the join token is issued", settings.JoinWindow)
+	}
+
+	fmt.Fprintf(stdOut, "CONTROL API SETTINGS\n")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+	fmt.Fprintf(stdOut, "Public address:\t\t%s:%d (%s)\n", gatewayNode.GatewayPublicIP, config.Config.ControlServerPort, publicRoutes)
+	fmt.Fprintf(stdOut, "WireGuard address:\t%s:%d (all routes)\n", types.IPToString(gatewayNode.WGConfig.Interface.Address.IP), config.Config.ControlServerPort)
+	fmt.Fprintf(stdOut, "Join rate limit:\t%s\n", joinRateLimit)
+	fmt.Fprintf(stdOut, "Join window:\t\t%s\n", joinWindow)
+
+	if settings.OverlayOnly {
+		fmt.Fprintf(stdOut, "\nClients and servers reach the control API over their WireGuard tunnel only, wireport switches to it once the tunnel is up.\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/database"
+	"wireport/internal/encryption/atrest"
+)
+
+// DBMigrateStatus prints the migrations of the local database. The database is opened without migrating it, a database
+// migrated by a newer build is reported with ErrSchemaTooNew
+func (s *LocalCommandsService) DBMigrateStatus(stdOut io.Writer, _ io.Writer) error {
+	db, err := database.OpenDBWithoutMigrating()
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = database.CloseDB(db) }()
+
+	schemaRepository := database.NewSchemaRepository(db)
+	statuses, err := schemaRepository.Status()
+
+	if err != nil {
+		return fmt.Errorf("failed to get the migrations: %w", err)
+	}
+
+	schemaVersion := 0
+
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			schemaVersion = status.Version
+		}
+	}
+
+	fmt.Fprintf(stdOut, "Database:       %s\n", config.Config.DatabasePath)
+	fmt.Fprintf(stdOut, "Schema version: %d (this build: %d)\n", schemaVersion, schemaRepository.LatestVersion())
+	fmt.Fprintf(stdOut, "Backups:        %s\n\n", config.Config.DatabaseBackupsDir)
+
+	fmt.Fprintf(stdOut, "%-8s %-22s %s\n", "VERSION", "APPLIED", "DESCRIPTION")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+
+	for _, status := range statuses {
+		applied := "pending"
+
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Local().Format(time.DateTime)
+		}
+
+		description := status.Description
+
+		if status.Unknown {
+			description += " (applied by a newer wireport build)"
+		}
+
+		fmt.Fprintf(stdOut, "%-8d %-22s %s\n", status.Version, applied, description)
+	}
+
+	return schemaRepository.Check()
+}
+
+// DBRekey re-encrypts the private keys in the local database with a new master key
+func (s *LocalCommandsService) DBRekey(stdOut io.Writer, _ io.Writer) error {
+	result, err := s.SchemaRepository.Rekey()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "🔑 The database has been re-encrypted with the master key %s (previous key: %s)\n", result.Key.ID, result.PreviousKeyID)
+	fmt.Fprintf(stdOut, "   Backup (encrypted with the previous key): %s\n", result.BackupPath)
+
+	if result.ArchivePath != "" {
+		fmt.Fprintf(stdOut, "   The previous key has been saved to %s, it is used to read the backup when restored\n", result.ArchivePath)
+	}
+
+	switch result.Key.Source {
+	case atrest.KeySourceFile:
+		fmt.Fprintf(stdOut, "   The new key has been saved to %s\n", config.Config.MasterKeyFile)
+	case atrest.KeySourceKeyring:
+		fmt.Fprintf(stdOut, "   The new key has been saved to the OS keyring\n")
+	case atrest.KeySourceEnv:
+		fmt.Fprintf(stdOut, "\n⚠️  The master key is taken from %s, set it to the new key before wireport starts again (keep the previous key to read the backup):\n\n%s\n", config.Config.MasterKeyEnvName, result.Key)
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"wireport/cmd/server/config"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/networkapps"
+	"wireport/internal/nodes"
+	"wireport/internal/nodes/types"
+
+	"gorm.io/gorm"
+)
+
+func (s *LocalCommandsService) DNSRecordAdd(stdOut io.Writer, _ io.Writer, name string, recordType string, value string) error {
+	record, err := dnsrecords.NewRecord(config.Config.DNSZone, name, recordType, value)
+
+	if err != nil {
+		return fmt.Errorf("error adding dns record: %w", err)
+	}
+
+	if err = s.ensureDNSNameIsFree(record.Name, ""); err != nil {
+		return fmt.Errorf("error adding dns record: %w", err)
+	}
+
+	if err = s.DNSRecordsRepository.Create(record); err != nil {
+		return fmt.Errorf("error adding dns record: %w", err)
+	}
+
+	if err = s.applyDNSConfigs(false); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ DNS record %s %s %s added\n", record.FQDN(config.Config.DNSZone), record.Type, record.Value)
+
+	return nil
+}
+
+func (s *LocalCommandsService) DNSRecordList(stdOut io.Writer, _ io.Writer) error {
+	allNodes, err := s.NodesRepository.GetAll()
+
+	if err != nil {
+		return fmt.Errorf("error getting nodes: %w", err)
+	}
+
+	records, err := s.DNSRecordsRepository.GetAll()
+
+	if err != nil {
+		return fmt.Errorf("error getting dns records: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "%-40s %-6s %-30s %s\n", "NAME", "TYPE", "VALUE", "SOURCE")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 90))
+
+	for _, node := range allNodes {
+		fmt.Fprintf(stdOut, "%-40s %-6s %-30s %s\n", node.Hostname()+"."+config.Config.DNSZone, dnsrecords.RecordTypeA, types.IPToString(node.WGConfig.Interface.Address.IP), node.Role+" node")
+	}
+
+	for _, record := range records {
+		fmt.Fprintf(stdOut, "%-40s %-6s %-30s %s\n", record.FQDN(config.Config.DNSZone), record.Type, record.Value, "user")
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) DNSRecordRemove(stdOut io.Writer, _ io.Writer, name string) error {
+	normalizedName, err := dnsrecords.NormalizeName(config.Config.DNSZone, name)
+
+	if err != nil {
+		return fmt.Errorf("error removing dns record: %w", err)
+	}
+
+	if err = s.DNSRecordsRepository.Delete(normalizedName); err != nil {
+		return fmt.Errorf("error removing dns record: %w", err)
+	}
+
+	if err = s.applyDNSConfigs(false); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ DNS record %s.%s removed\n", normalizedName, config.Config.DNSZone)
+
+	return nil
+}
+
+// NodeRename sets the name of the node in the wireport DNS zone; an empty name restores the default one (e.g. server-3)
+func (s *LocalCommandsService) NodeRename(stdOut io.Writer, _ io.Writer, nodeIP string, name string) error {
+	node, err := s.NodesRepository.GetByWGPrivateIP(nodeIP)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: no node found with IP %q", nodes.ErrNodeNotFound, nodeIP)
+		}
+
+		return fmt.Errorf("failed to resolve node: %w", err)
+	}
+
+	if name != "" {
+		name, err = dnsrecords.NormalizeName(config.Config.DNSZone, name)
+
+		if err != nil {
+			return fmt.Errorf("error renaming node: %w", err)
+		}
+
+		if strings.Contains(name, ".") {
+			return fmt.Errorf("error renaming node: %w: node names are single labels, got %q", dnsrecords.ErrInvalidRecord, name)
+		}
+
+		if err = s.ensureDNSNameIsFree(name, node.ID); err != nil {
+			return fmt.Errorf("error renaming node: %w", err)
+		}
+	}
+
+	if err = s.NodesRepository.Rename(node.ID, name); err != nil {
+		return fmt.Errorf("error renaming node: %w", err)
+	}
+
+	// the zone of the server is renamed too
+	if err = s.applyDNSConfigs(node.Role == types.NodeRoleServer); err != nil {
+		return err
+	}
+
+	node.Name = name
+
+	fmt.Fprintf(stdOut, "✅ Node %s is now resolvable as %s.%s\n", types.IPToString(node.WGConfig.Interface.Address.IP), node.Hostname(), config.Config.DNSZone)
+
+	return nil
+}
+
+// ensureDNSNameIsFree refuses names of other nodes, of user-defined records and the default node names,
+// which are reserved for nodes joining later
+func (s *LocalCommandsService) ensureDNSNameIsFree(name string, exceptNodeID string) error {
+	if types.IsDefaultHostname(name) {
+		return fmt.Errorf("%w: %s is reserved for the default node names", dnsrecords.ErrNameInUse, name)
+	}
+
+	allNodes, err := s.NodesRepository.GetAll()
+
+	if err != nil {
+		return err
+	}
+
+	for _, node := range allNodes {
+		if node.ID != exceptNodeID && node.Hostname() == name {
+			return fmt.Errorf("%w: %s is the name of the %s node %s", dnsrecords.ErrNameInUse, name, node.Role, types.IPToString(node.WGConfig.Interface.Address.IP))
+		}
+	}
+
+	if _, err = s.DNSRecordsRepository.Get(name); err == nil {
+		return fmt.Errorf("%w: %s has a dns record", dnsrecords.ErrNameInUse, name)
+	} else if !errors.Is(err, dnsrecords.ErrRecordNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// applyDNSConfigs rewrites the gateway configs including the DNS zone; CoreDNS reloads the zone file on its own,
+// other changes of the Corefile (upstreams, zones of the servers) need a restart
+func (s *LocalCommandsService) applyDNSConfigs(restartCoreDNS bool) error {
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return fmt.Errorf("error getting gateway node: %w", err)
+	}
+
+	gatewayConfigs, err := s.loadGatewayConfigs()
+
+	if err != nil {
+		return fmt.Errorf("failed to load gateway configs: %w", err)
+	}
+
+	if err = gatewayNode.SaveConfigs(gatewayConfigs, false); err != nil {
+		return fmt.Errorf("error saving gateway node configs: %w", err)
+	}
+
+	if !restartCoreDNS {
+		return nil
+	}
+
+	if err = networkapps.RestartNetworkApps(false, true, false); err != nil {
+		return fmt.Errorf("error restarting CoreDNS: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) DNSConfigSet(stdOut io.Writer, _ io.Writer, update dnsrecords.SettingsUpdate) error {
+	settings, err := s.DNSRecordsRepository.GetSettings()
+
+	if err != nil {
+		return fmt.Errorf("error getting DNS settings: %w", err)
+	}
+
+	update.Apply(settings)
+
+	if err = settings.Validate(); err != nil {
+		return fmt.Errorf("error updating DNS settings: %w", err)
+	}
+
+	if err = s.DNSRecordsRepository.SaveSettings(settings); err != nil {
+		return fmt.Errorf("error saving DNS settings: %w", err)
+	}
+
+	if err = s.applyDNSConfigs(true); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ DNS settings updated\n\n")
+
+	return s.printDNSSettings(stdOut, settings)
+}
+
+func (s *LocalCommandsService) DNSConfigShow(stdOut io.Writer, _ io.Writer) error {
+	settings, err := s.DNSRecordsRepository.GetSettings()
+
+	if err != nil {
+		return fmt.Errorf("error getting DNS settings: %w", err)
+	}
+
+	return s.printDNSSettings(stdOut, settings)
+}
+
+func (s *LocalCommandsService) DNSConfigReset(stdOut io.Writer, _ io.Writer) error {
+	if err := s.DNSRecordsRepository.ResetSettings(); err != nil {
+		return fmt.Errorf("error resetting DNS settings: %w", err)
+	}
+
+	if err := s.applyDNSConfigs(true); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ DNS settings reset to defaults (upstreams %s, fanout to all servers)\n", strings.Join(dnsrecords.DefaultUpstreams, ", "))
+
+	return nil
+}
+
+func (s *LocalCommandsService) printDNSSettings(stdOut io.Writer, settings *dnsrecords.Settings) error {
+	upstreams := strings.Join(settings.EffectiveUpstreams(), ", ")
+
+	if len(settings.Upstreams) == 0 {
+		upstreams += " (defaults)"
+	}
+
+	tlsServerName := settings.TLSServerName
+
+	if tlsServerName == "" {
+		tlsServerName = "(not set)"
+	}
+
+	fanout := "❌ disabled, names without a zone are resolved by the upstreams only"
+
+	if settings.ServerFanout {
+		fanout = "✅ names without a zone are asked from the docker DNS of all servers first"
+	}
+
+	serverZones := "❌ disabled"
+
+	if settings.ServerZones {
+		servers, err := s.NodesRepository.GetNodesByRole(types.NodeRoleServer)
+
+		if err != nil {
+			return fmt.Errorf("error getting server nodes: %w", err)
+		}
+
+		zones := []string{}
+
+		for _, server := range servers {
+			zones = append(zones, fmt.Sprintf("*.%s.%s", server.Hostname(), config.Config.DNSZone))
+		}
+
+		serverZones = "✅ enabled"
+
+		if len(zones) > 0 {
+			serverZones += ": " + strings.Join(zones, ", ")
+		}
+	}
+
+	fmt.Fprintf(stdOut, "DNS SETTINGS\n")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+	fmt.Fprintf(stdOut, "Zone:\t\t\t%s\n", config.Config.DNSZone)
+	fmt.Fprintf(stdOut, "Upstreams:\t\t%s\n", upstreams)
+	fmt.Fprintf(stdOut, "TLS server name:\t%s\n", tlsServerName)
+	fmt.Fprintf(stdOut, "Server fanout:\t\t%s\n", fanout)
+	fmt.Fprintf(stdOut, "Server zones:\t\t%s\n", serverZones)
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+	commandstypes "wireport/internal/commands/types"
+	"wireport/internal/joinrequests"
+	"wireport/internal/nodes/types"
+	"wireport/internal/wg"
+)
+
+const doctorCheckTimeout = 5 * time.Second
+
+func doctorCheck(name string, err error, fix string) commandstypes.DoctorCheckDTO {
+	if err == nil {
+		return commandstypes.DoctorCheckDTO{Name: name, Status: commandstypes.DoctorCheckStatusOK}
+	}
+
+	return commandstypes.DoctorCheckDTO{Name: name, Status: commandstypes.DoctorCheckStatusFailed, Fix: fmt.Sprintf("%v\n%s", err, fix)}
+}
+
+// Doctor checks the connectivity of the current client or server node end to end: control API, WireGuard tunnel,
+// CoreDNS resolution of the node's upstreams and, on the gateway side, caddy config and upstream reachability
+func (s *LocalCommandsService) Doctor(currentNode *types.Node, api *APICommandsService, stdOut io.Writer, _ io.Writer) error {
+	checks := []commandstypes.DoctorCheckDTO{}
+
+	// 1. control API (over the WireGuard tunnel once it is up, the public address of the gateway otherwise)
+
+	controlAPICheckName := fmt.Sprintf("Control API is reachable at %s:%d", api.Host, api.Port)
+	gatewayResponse, apiErr := api.Doctor()
+
+	if apiErr != nil {
+		checks = append(checks, doctorCheck(controlAPICheckName, apiErr,
+			strings.TrimSpace(joinrequests.DiagnoseGatewayConnectionError(apiErr)+joinrequests.ConnectivityRequirementsText())))
+	} else {
+		checks = append(checks, doctorCheck(controlAPICheckName, nil, ""))
+	}
+
+	// 2. WireGuard handshake with the gateway
+
+	if len(currentNode.WGConfig.Peers) == 0 || len(currentNode.WGConfig.Interface.DNS) == 0 {
+		checks = append(checks, doctorCheck("WireGuard config contains the gateway", fmt.Errorf("the gateway peer is missing"),
+			"The node config is incomplete, join the network again with a new join token"))
+	} else {
+		// servers and clients always have the gateway as the only peer and its WireGuard address as the first DNS server
+		gatewayPeer := currentNode.WGConfig.Peers[0]
+		gatewayOverlayIP := types.IPToString(currentNode.WGConfig.Interface.DNS[0].IP)
+
+		checks = append(checks, checkGatewayHandshake(gatewayPeer.PublicKey))
+
+		// 3. traffic over the tunnel
+
+		connection, err := net.DialTimeout("tcp", net.JoinHostPort(gatewayOverlayIP, fmt.Sprint(config.Config.ControlServerPort)), doctorCheckTimeout)
+
+		if err == nil {
+			connection.Close()
+		}
+
+		checks = append(checks, doctorCheck(
+			fmt.Sprintf("Gateway is reachable over the WireGuard tunnel at %s", gatewayOverlayIP),
+			err,
+			fmt.Sprintf("Make sure the WireGuard tunnel is up on this node and UDP %d is open from this node to the gateway (and inbound on the gateway)", config.Config.WGPublicPort),
+		))
+
+		// 4. container names through CoreDNS
+
+		for _, host := range gatewayResponse.UpstreamHosts {
+			checks = append(checks, checkCoreDNSResolution(gatewayOverlayIP, host))
+		}
+	}
+
+	// 5. caddy config & upstreams, checked on the gateway (none if the control API is unreachable)
+
+	checks = append(checks, gatewayResponse.Checks...)
+
+	return printDoctorChecks(stdOut, checks)
+}
+
+func checkGatewayHandshake(gatewayPublicKey string) commandstypes.DoctorCheckDTO {
+	name := "WireGuard handshake with the gateway is fresh"
+
+	peers, err := wg.GetPeerStats()
+
+	if err != nil {
+		// e.g. clients using the WireGuard app without the wg tool
+		return commandstypes.DoctorCheckDTO{
+			Name:   name,
+			Status: commandstypes.DoctorCheckStatusWarning,
+			Fix:    fmt.Sprintf("Could not read the WireGuard state: %v\nCheck the last handshake of the wireport tunnel in your WireGuard app", err),
+		}
+	}
+
+	for _, peer := range peers {
+		if peer.PublicKey != gatewayPublicKey {
+			continue
+		}
+
+		switch peer.State(time.Now(), config.Config.WireguardPeerStaleAfter) {
+		case wg.PeerStateNeverConnected:
+			return doctorCheck(name, fmt.Errorf("no handshake with the gateway yet"),
+				fmt.Sprintf("Make sure UDP %d is open from this node to the gateway and inbound on the gateway (check cloud security groups too)", config.Config.WGPublicPort))
+		case wg.PeerStateStale:
+			return doctorCheck(name, fmt.Errorf("last handshake was %s", formatAge(time.Since(peer.LatestHandshake))),
+				fmt.Sprintf("The tunnel is not active anymore: check that the gateway is running and UDP %d is still open, then restart the WireGuard tunnel", config.Config.WGPublicPort))
+		}
+
+		return doctorCheck(name, nil, "")
+	}
+
+	return doctorCheck(name, fmt.Errorf("the gateway is not a peer of any WireGuard interface"),
+		"Bring the wireport WireGuard tunnel up (e.g. import the config printed by 'wireport join' into your WireGuard app)")
+}
+
+func checkCoreDNSResolution(dnsServerIP string, host string) commandstypes.DoctorCheckDTO {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: doctorCheckTimeout}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(dnsServerIP, "53"))
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorCheckTimeout)
+	defer cancel()
+
+	_, err := resolver.LookupHost(ctx, host)
+
+	return doctorCheck(
+		fmt.Sprintf("%s resolves through CoreDNS at %s", host, dnsServerIP),
+		err,
+		fmt.Sprintf("Make sure the container %s is running and attached to the %s Docker network on its server", host, config.Config.DockerNetworkName),
+	)
+}
+
+func printDoctorChecks(stdOut io.Writer, checks []commandstypes.DoctorCheckDTO) error {
+	failed := 0
+
+	for _, check := range checks {
+		switch check.Status {
+		case commandstypes.DoctorCheckStatusOK:
+			fmt.Fprintf(stdOut, "✅ %s\n", check.Name)
+		case commandstypes.DoctorCheckStatusWarning:
+			fmt.Fprintf(stdOut, "⚠️  %s\n", check.Name)
+		default:
+			failed++
+			fmt.Fprintf(stdOut, "❌ %s\n", check.Name)
+		}
+
+		for _, line := range strings.Split(check.Fix, "\n") {
+			if strings.TrimSpace(line) != "" {
+				fmt.Fprintf(stdOut, "   %s\n", line)
+			}
+		}
+	}
+
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrDoctorChecksFailed, failed, len(checks))
+	}
+
+	fmt.Fprintf(stdOut, "All %d checks passed\n", len(checks))
+
+	return nil
+}
+
+// DoctorGateway runs the gateway side checks of 'wireport doctor' for the services published by the requesting node
+func (s *LocalCommandsService) DoctorGateway(requestFromNodeID string) (*commandstypes.DoctorResponseDTO, error) {
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gateway node: %w", err)
+	}
+
+	publicServices, err := s.PublicServicesRepository.GetAll()
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFailedToListServices, err)
+	}
+
+	response := &commandstypes.DoctorResponseDTO{
+		Checks:        []commandstypes.DoctorCheckDTO{},
+		UpstreamHosts: []string{},
+	}
+
+	caddyConfig, caddyConfigErr := os.ReadFile(config.Config.CaddyConfigPath)
+
+	for _, service := range publicServices {
+		upstreams := service.GetUpstreamsPublishedByNode(requestFromNodeID)
+
+		if len(upstreams) == 0 {
+			continue
+		}
+
+		publicAddress := fmt.Sprintf("%s://%s:%d", service.PublicProtocol, service.PublicHost, service.PublicPort)
+		siteAddress, err := service.CaddySiteAddress(gatewayNode.GatewayPublicIP)
+
+		if err == nil && caddyConfigErr != nil {
+			err = caddyConfigErr
+		} else if err == nil && !strings.Contains(string(caddyConfig), siteAddress+" {") {
+			err = fmt.Errorf("%s is missing in %s", siteAddress, config.Config.CaddyConfigPath)
+		}
+
+		response.Checks = append(response.Checks, doctorCheck(
+			fmt.Sprintf("Caddy config of the gateway contains %s", publicAddress),
+			err,
+			"Publish the service again ('wireport service publish ...') and check 'docker logs wireport-gateway' for caddy reload errors",
+		))
+
+		for _, upstream := range upstreams {
+			if upstream.LocalProtocol == "udp" {
+				// there is no handshake to check udp upstreams with
+				continue
+			}
+
+			upstreamAddress := net.JoinHostPort(upstream.LocalHost, fmt.Sprint(upstream.LocalPort))
+			dialAddress := upstreamAddress
+
+			if upstream.LocalHost == gatewayNode.GatewayPublicIP {
+				// same as caddy: the gateway public IP is not visible from inside the gateway container
+				dialAddress = net.JoinHostPort("0.0.0.0", fmt.Sprint(upstream.LocalPort))
+			}
+
+			connection, err := net.DialTimeout("tcp", dialAddress, doctorCheckTimeout)
+
+			if err == nil {
+				connection.Close()
+			}
+
+			response.Checks = append(response.Checks, doctorCheck(
+				fmt.Sprintf("Gateway reaches upstream %s://%s of %s", upstream.LocalProtocol, upstreamAddress, publicAddress),
+				err,
+				fmt.Sprintf("Make sure the container is running, listens on port %d on all interfaces (not only 127.0.0.1) and is attached to the %s Docker network", upstream.LocalPort, config.Config.DockerNetworkName),
+			))
+
+			if net.ParseIP(upstream.LocalHost) == nil && !slices.Contains(response.UpstreamHosts, upstream.LocalHost) {
+				response.UpstreamHosts = append(response.UpstreamHosts, upstream.LocalHost)
+			}
+		}
+	}
+
+	return response, nil
+}
@@ -1,17 +1,39 @@
 package commands
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sync"
 	"wireport/cmd/server/config"
+	"wireport/internal/encryption/mtls"
+	"wireport/internal/health"
 	"wireport/internal/nodes/types"
 	"wireport/internal/ssh"
 )
 
-func (s *LocalCommandsService) GatewayStart(gatewayPublicIP string, stdOut io.Writer, errOut io.Writer, gatewayStartConfigureOnly bool, router http.Handler) {
+// newControlServer returns the mTLS control server of the gateway; the timeouts keep slow or stuck clients
+// from holding connections forever
+func newControlServer(handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", config.Config.ControlServerPort),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: config.Config.ControlServerReadHeaderTimeout,
+		ReadTimeout:       config.Config.ControlServerReadTimeout,
+		WriteTimeout:      config.Config.ControlServerWriteTimeout,
+		IdleTimeout:       config.Config.ControlServerIdleTimeout,
+		MaxHeaderBytes:    config.Config.ControlServerMaxHeaderBytes,
+	}
+}
+
+// GatewayStart configures the gateway and serves the control API until the context is done (SIGTERM from runit or docker),
+// then drains the requests in progress and waits for the background tasks, so that no write to the database is cut off
+func (s *LocalCommandsService) GatewayStart(ctx context.Context, gatewayPublicIP string, stdOut io.Writer, errOut io.Writer, gatewayStartConfigureOnly bool, router http.Handler) {
 	gatewayNode, err := s.NodesRepository.EnsureGatewayNode(types.IPMarshable{
 		IP: net.ParseIP(gatewayPublicIP),
 	}, config.Config.WGPublicPort, gatewayPublicIP, config.Config.ControlServerPort)
@@ -26,60 +48,98 @@ func (s *LocalCommandsService) GatewayStart(gatewayPublicIP string, stdOut io.Wr
 		return
 	}
 
-	serverError := make(chan error, 1)
-
-	if !gatewayStartConfigureOnly {
-		go func() {
-			var tlsConfig *tls.Config
-
-			tlsConfig, err = gatewayNode.GatewayCertBundle.GetServerTLSConfig()
+	gatewayConfigs, err := s.loadGatewayConfigs()
 
-			if err != nil {
-				serverError <- fmt.Errorf("Failed to get TLS config for the gateway control server: %v", err)
-				return
-			}
-
-			server := &http.Server{
-				Addr:      fmt.Sprintf(":%d", config.Config.ControlServerPort),
-				Handler:   router,
-				TLSConfig: tlsConfig,
-			}
-
-			if err = server.ListenAndServeTLS("", ""); err != nil {
-				serverError <- err
-			}
-		}()
+	if err != nil {
+		fmt.Fprintf(errOut, "Failed to load gateway configs: %v\n", err)
+		return
 	}
 
-	publicServices, err := s.PublicServicesRepository.GetAll()
+	err = gatewayNode.SaveConfigs(gatewayConfigs, true)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
+		fmt.Fprintf(errOut, "Failed to save configs: %v\n", err)
 		return
 	}
 
-	err = gatewayNode.SaveConfigs(publicServices, true)
+	if gatewayStartConfigureOnly {
+		fmt.Fprintf(stdOut, "wireport has been configured on the gateway: %s\n", gatewayPublicIP)
+		return
+	}
+
+	tlsConfig, err := gatewayNode.GatewayCertBundle.GetServerTLSConfig()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to save configs: %v\n", err)
+		fmt.Fprintf(errOut, "Failed to get TLS config for the gateway control server: %v\n", err)
 		return
 	}
 
-	if !gatewayStartConfigureOnly {
-		fmt.Fprintf(stdOut, "wireport server has started with mTLS on gateway: %s\n", gatewayPublicIP)
-	} else {
-		fmt.Fprintf(stdOut, "wireport has been configured on the gateway: %s\n", gatewayPublicIP)
+	// a certificate issued by 'gateway ca import' is served without a restart
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		certificates, err := gatewayCertificatesInMemory.get(s.NodesRepository)
+
+		if err != nil || certificates == nil {
+			return nil, nil
+		}
+
+		return certificates.tlsConfig, nil
 	}
 
-	if !gatewayStartConfigureOnly {
-		// Block on the server error channel
-		if err := <-serverError; err != nil {
-			fmt.Fprintf(errOut, "Server error: %v\n", err)
+	// the control API is served once the gateway is configured, nothing returns before the shutdown below
+	server := newControlServer(router, tlsConfig)
+	serverError := make(chan error, 1)
+
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverError <- err
 		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var background sync.WaitGroup
+
+	s.startMetrics(gatewayNode)
+	s.startServiceHealthProber(ctx, &background, gatewayNode.GatewayPublicIP)
+	s.startNotifications(ctx, &background)
+
+	checker := health.NewChecker()
+	checker.AddCheck("database", s.NodesRepository.Ping)
+
+	if config.Config.HealthServerAddress != "" {
+		go health.Serve(ctx, config.Config.HealthServerAddress, checker)
+	}
+
+	checker.SetReady(true)
+
+	fmt.Fprintf(stdOut, "wireport server has started with mTLS on gateway: %s\n", gatewayPublicIP)
+
+	select {
+	case err := <-serverError:
+		fmt.Fprintf(errOut, "Server error: %v\n", err)
+	case <-ctx.Done():
+		fmt.Fprintf(stdOut, "Shutting down wireport gateway, draining requests in progress\n")
+	}
+
+	checker.SetReady(false)
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), config.Config.ControlServerShutdownTimeout)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintf(errOut, "Failed to drain the control server: %v\n", err)
 	}
+
+	if !waitWithTimeout(shutdownCtx, &background) {
+		fmt.Fprintf(errOut, "Background tasks did not stop within %s\n", config.Config.ControlServerShutdownTimeout)
+	}
+
+	fmt.Fprintf(stdOut, "wireport gateway has stopped\n")
 }
 
-func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.Writer) {
+func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.Writer) error {
 	sshService := ssh.NewService()
 
 	fmt.Fprintf(stdOut, "🔍 Checking wireport Gateway Status\n")
@@ -93,7 +153,7 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: SSH connection failed", ErrNodeUnhealthy)
 	}
 
 	defer sshService.Close()
@@ -106,7 +166,7 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	var dockerVersion string
@@ -122,7 +182,7 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	} else {
 		fmt.Fprintf(stdOut, "   Status: ❌ Not Installed\n\n")
 		fmt.Fprintf(stdOut, "💡 Install Docker to continue with wireport setup.\n\n")
-		return
+		return fmt.Errorf("%w: docker is not installed", ErrNodeUnhealthy)
 	}
 
 	// Docker Permissions Check
@@ -132,7 +192,7 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	if err != nil {
 		fmt.Fprintf(stdOut, "❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	if dockerAccessible {
@@ -140,7 +200,7 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	} else {
 		fmt.Fprintf(stdOut, "❌ User lacks permissions\n")
 		fmt.Fprintf(stdOut, "💡 Add user to docker group.\n\n")
-		return
+		return fmt.Errorf("%w: the user has no access to docker", ErrNodeUnhealthy)
 	}
 	fmt.Fprintf(stdOut, "\n")
 
@@ -150,7 +210,7 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	var containerStatus string
@@ -176,10 +236,16 @@ func (s *LocalCommandsService) GatewayStatus(creds *ssh.Credentials, stdOut io.W
 	}
 	fmt.Fprintf(stdOut, "\n")
 
+	if !isRunning {
+		return fmt.Errorf("%w: the wireport gateway container is not running", ErrNodeUnhealthy)
+	}
+
 	fmt.Fprintf(stdOut, "✨ Gateway Status check completed successfully!\n")
+
+	return nil
 }
 
-func (s *LocalCommandsService) GatewayUp(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer) {
+func (s *LocalCommandsService) GatewayUp(creds *ssh.Credentials, image string, imageTag string, caDir string, stdOut io.Writer, errOut io.Writer) {
 	sshService := ssh.NewService()
 
 	fmt.Fprintf(errOut, "🚀 wireport Gateway Bootstrapping\n")
@@ -217,6 +283,19 @@ func (s *LocalCommandsService) GatewayUp(creds *ssh.Credentials, image string, i
 
 	fmt.Fprintf(errOut, "   Status: ❌ Not Running\n")
 	fmt.Fprintf(errOut, "   💡 Proceeding with installation...\n\n")
+
+	if caDir != "" {
+		fmt.Fprintf(errOut, "🔐 Uploading the gateway intermediate CA...\n")
+		fmt.Fprintf(errOut, "   Directory: %s\n", caDir)
+
+		if err = uploadGatewayCA(sshService, caDir); err != nil {
+			fmt.Fprintf(errOut, "   Status: ❌ Upload Failed\n")
+			fmt.Fprintf(errOut, "   Error:  %v\n\n", err)
+			return
+		}
+
+		fmt.Fprintf(errOut, "   Status: ✅ Uploaded, the root CA stays offline\n\n")
+	}
 	fmt.Fprintf(errOut, "📦 Installing wireport gateway...\n")
 	fmt.Fprintf(errOut, "   Gateway: %s@%s:%d\n", creds.Username, creds.Host, creds.Port)
 
@@ -257,6 +336,25 @@ func (s *LocalCommandsService) GatewayUp(creds *ssh.Credentials, image string, i
 	fmt.Fprintf(errOut, "✨ Gateway Bootstrapping completed successfully!\n")
 }
 
+// uploadGatewayCA checks the intermediate issued by 'wireport ca intermediate' before the gateway is installed with it
+func uploadGatewayCA(sshService *ssh.Service, caDir string) error {
+	intermediate, err := mtls.ReadIntermediateDir(caDir)
+
+	if err != nil {
+		return err
+	}
+
+	if intermediate == nil {
+		return fmt.Errorf("%w: no %s in %s, issue one with 'wireport ca intermediate'", ErrInvalidRequest, mtls.IntermediateCertFile, caDir)
+	}
+
+	if err = intermediate.Verify(); err != nil {
+		return err
+	}
+
+	return sshService.UploadGatewayCA(intermediate)
+}
+
 func (s *LocalCommandsService) GatewayDown(creds *ssh.Credentials, stdOut io.Writer, errOut io.Writer) {
 	// First, try to determine if we are executing on a gateway node or locally
 	currentNode, err := s.NodesRepository.GetCurrentNode()
@@ -334,7 +432,7 @@ func (s *LocalCommandsService) GatewayDown(creds *ssh.Credentials, stdOut io.Wri
 	fmt.Fprintf(stdOut, "✨ Gateway Teardown completed successfully!\n")
 }
 
-func (s *LocalCommandsService) GatewayUpgrade(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, _ io.Writer) {
+func (s *LocalCommandsService) GatewayUpgrade(creds *ssh.Credentials, image string, imageTag string, verify func() error, stdOut io.Writer, _ io.Writer) error {
 	sshService := ssh.NewService()
 
 	fmt.Fprintf(stdOut, "🔄 wireport Gateway Upgrade\n")
@@ -349,7 +447,7 @@ func (s *LocalCommandsService) GatewayUpgrade(creds *ssh.Credentials, image stri
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return err
 	}
 
 	defer sshService.Close()
@@ -358,22 +456,64 @@ func (s *LocalCommandsService) GatewayUpgrade(creds *ssh.Credentials, image stri
 	// Upgrade wireport gateway
 	fmt.Fprintf(stdOut, "🔄 Upgrading wireport gateway...\n")
 	fmt.Fprintf(stdOut, "   Gateway: %s@%s:%d\n", creds.Username, creds.Host, creds.Port)
+	fmt.Fprintf(stdOut, "   Image:   %s:%s\n\n", image, imageTag)
 
-	success, err := sshService.UpgradeWireportGateway(image, imageTag)
+	result, err := sshService.UpgradeWireportGateway(image, imageTag, verify, func(step ssh.GatewayUpgradeStep, details string, stepErr error) {
+		printGatewayUpgradeStep(stdOut, step, details, stepErr)
+	})
 
-	if err != nil {
-		fmt.Fprintf(stdOut, "   Status: ❌ Failed\n")
-		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
-	}
+	fmt.Fprintf(stdOut, "\n")
 
-	if success {
+	switch {
+	case err == nil:
 		fmt.Fprintf(stdOut, "   Status: ✅ Upgraded Successfully\n")
-		fmt.Fprintf(stdOut, "   🎉 wireport gateway node has been successfully upgraded!\n\n")
-	} else {
+		fmt.Fprintf(stdOut, "   🎉 wireport gateway node has been upgraded from %s to %s!\n", result.PreviousImage, result.Image)
+		fmt.Fprintf(stdOut, "   💡 The database snapshot taken before the upgrade is kept in %s\n\n", result.BackupDir)
+	case result.RolledBack && result.RollbackError == nil:
+		fmt.Fprintf(stdOut, "   Status: ↩️  Rolled Back\n")
+		fmt.Fprintf(stdOut, "   Error:  %v\n", err)
+		fmt.Fprintf(stdOut, "   💡 wireport gateway is running %s again, with the database as it was before the upgrade.\n\n", result.PreviousImage)
+	case result.RolledBack:
+		fmt.Fprintf(stdOut, "   Status: ❌ Rollback Failed\n")
+		fmt.Fprintf(stdOut, "   Error:  %v\n", err)
+		fmt.Fprintf(stdOut, "   💡 Restore the gateway manually: the previous container is kept as %s-previous (image %s),\n", config.Config.WireportGatewayContainerName, result.PreviousImage)
+		fmt.Fprintf(stdOut, "      the database snapshot is in %s on the gateway host.\n\n", result.BackupDir)
+	default:
 		fmt.Fprintf(stdOut, "   Status: ❌ Failed\n")
-		fmt.Fprintf(stdOut, "   💡 Upgrade process completed but may not have been successful.\n\n")
+		fmt.Fprintf(stdOut, "   Error:  %v\n", err)
+		fmt.Fprintf(stdOut, "   💡 The running gateway has not been touched.\n\n")
+	}
+
+	if err != nil {
+		return err
 	}
 
 	fmt.Fprintf(stdOut, "✨ Gateway Upgrade completed!\n")
+
+	return nil
+}
+
+func printGatewayUpgradeStep(stdOut io.Writer, step ssh.GatewayUpgradeStep, details string, err error) {
+	titles := map[ssh.GatewayUpgradeStep]string{
+		ssh.GatewayUpgradeStepPreflight: "Pre-flight checks",
+		ssh.GatewayUpgradeStepPull:      "Pulling the new image",
+		ssh.GatewayUpgradeStepBackup:    "Stopping the gateway and backing up the database",
+		ssh.GatewayUpgradeStepStart:     "Starting the upgraded gateway",
+		ssh.GatewayUpgradeStepReadiness: "Waiting for the readiness check",
+		ssh.GatewayUpgradeStepVerify:    "mTLS round trip with the upgraded gateway",
+		ssh.GatewayUpgradeStepCleanup:   "Removing the previous container",
+		ssh.GatewayUpgradeStepRollback:  "Rolling back to the previous gateway",
+	}
+
+	if err != nil {
+		fmt.Fprintf(stdOut, "   ❌ %s: %v\n", titles[step], err)
+		return
+	}
+
+	if details != "" {
+		fmt.Fprintf(stdOut, "   ✅ %s (%s)\n", titles[step], details)
+		return
+	}
+
+	fmt.Fprintf(stdOut, "   ✅ %s\n", titles[step])
 }
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"wireport/cmd/server/config"
+	"wireport/internal/logger"
+	"wireport/internal/publicservices"
+)
+
+// startServiceHealthProber probes the upstreams of all public services periodically and stores the results for 'service list'
+func (s *LocalCommandsService) startServiceHealthProber(ctx context.Context, waitGroup *sync.WaitGroup, gatewayPublicIP string) {
+	runPeriodically(ctx, waitGroup, config.Config.ServiceHealthProbeInterval, func() {
+		s.probeServices(gatewayPublicIP)
+	})
+}
+
+func (s *LocalCommandsService) probeServices(gatewayPublicIP string) {
+	publicServices, err := s.PublicServicesRepository.GetAll()
+
+	if err != nil {
+		logger.Error("Failed to list public services for health probes: %v", err)
+		return
+	}
+
+	previousHealth, err := s.PublicServicesRepository.GetAllHealth()
+
+	if err != nil {
+		logger.Error("Failed to get upstream health: %v", err)
+	}
+
+	var mutex sync.Mutex
+	var waitGroup sync.WaitGroup
+
+	health := []*publicservices.UpstreamHealth{}
+
+	for _, service := range publicServices {
+		probed := []publicservices.PublicServiceUpstream{}
+
+		for _, upstream := range service.GetAllUpstreams() {
+			// udp has no handshake to probe with; the same upstream may be used by several routes
+			if upstream.LocalProtocol == "udp" || slices.ContainsFunc(probed, func(u publicservices.PublicServiceUpstream) bool {
+				return u.Matches(upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort)
+			}) {
+				continue
+			}
+
+			probed = append(probed, upstream)
+
+			waitGroup.Go(func() {
+				upstreamHealth := publicservices.ProbeUpstream(service, upstream, gatewayPublicIP, config.Config.ServiceHealthProbeTimeout)
+				previous := publicservices.FindUpstreamHealth(previousHealth, service, upstream)
+
+				if upstreamHealth.Status == publicservices.UpstreamHealthStatusUnhealthy && (previous == nil || previous.Status != upstreamHealth.Status) {
+					logger.Warn("Upstream %s://%s:%d of %s://%s:%d is unhealthy: %s", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort, service.PublicProtocol, service.PublicHost, service.PublicPort, upstreamHealth.Error)
+				} else if upstreamHealth.Status == publicservices.UpstreamHealthStatusHealthy && previous != nil && previous.Status != upstreamHealth.Status {
+					logger.Info("Upstream %s://%s:%d of %s://%s:%d is healthy again", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort, service.PublicProtocol, service.PublicHost, service.PublicPort)
+				}
+
+				mutex.Lock()
+				health = append(health, upstreamHealth)
+				mutex.Unlock()
+			})
+		}
+	}
+
+	waitGroup.Wait()
+
+	if err := s.PublicServicesRepository.ReplaceHealth(health); err != nil {
+		logger.Error("Failed to save upstream health: %v", err)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	joinrequeststypes "wireport/internal/joinrequests/types"
 	"wireport/internal/networkapps"
 	"wireport/internal/nodes/types"
-	"wireport/internal/publicservices"
 )
 
 func (s *LocalCommandsService) Join(stdOut io.Writer, errOut io.Writer, joinToken string) bool {
@@ -71,9 +70,7 @@ func (s *LocalCommandsService) Join(stdOut io.Writer, errOut io.Writer, joinToke
 			return false
 		}
 
-		publicServices := []*publicservices.PublicService{}
-
-		err = currentNode.SaveConfigs(publicServices, true)
+		err = currentNode.SaveConfigs(&types.GatewayConfigs{}, true)
 
 		if err != nil {
 			fmt.Fprintf(errOut, "Failed to save node configs: %v\n", err)
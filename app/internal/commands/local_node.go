@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/nodes/types"
+	"wireport/internal/wg"
+	"wireport/version"
+)
+
+// NodeStatus prints every node of the network with the live state of its WireGuard peer on the gateway
+func (s *LocalCommandsService) NodeStatus(requestFromNodeID *string, stdOut io.Writer, _ io.Writer) error {
+	peers, err := wg.GetInterfacePeerStats(config.Config.WireguardInterfaceName)
+
+	if err != nil {
+		return fmt.Errorf("failed to get WireGuard peer stats: %w", err)
+	}
+
+	peersByPublicKey := map[string]*wg.PeerStats{}
+
+	for i := range peers {
+		peersByPublicKey[peers[i].PublicKey] = &peers[i]
+	}
+
+	nodesList := []types.Node{}
+
+	for _, role := range []types.NodeRole{types.NodeRoleGateway, types.NodeRoleServer, types.NodeRoleClient} {
+		roleNodes, err := s.NodesRepository.GetNodesByRole(role)
+
+		if err != nil {
+			return fmt.Errorf("error getting %s nodes: %w", role, err)
+		}
+
+		nodesList = append(nodesList, roleNodes...)
+	}
+
+	now := time.Now()
+
+	fmt.Fprintf(stdOut, "%-8s %-20s %-12s %-16s %-14s %-22s %-20s %s\n", "ROLE", "NAME", "OVERLAY IP", "STATE", "HANDSHAKE", "ENDPOINT", "RX / TX", "LABELS")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 130))
+
+	for _, node := range nodesList {
+		overlayIP := types.IPToString(node.WGConfig.Interface.Address.IP)
+
+		if requestFromNodeID != nil && node.ID == *requestFromNodeID {
+			overlayIP += "*"
+		}
+
+		labels := strings.Join(node.Labels, ", ")
+
+		if labels == "" {
+			labels = "-"
+		}
+
+		state, handshake, endpoint, transfer := "-", "-", "-", "-"
+
+		if node.Role == types.NodeRoleGateway {
+			state = "self"
+		} else if peer, ok := peersByPublicKey[node.WGPublicKey]; ok {
+			state = string(peer.State(now, config.Config.WireguardPeerStaleAfter))
+
+			if !peer.LatestHandshake.IsZero() {
+				handshake = formatAge(now.Sub(peer.LatestHandshake))
+			}
+
+			if peer.Endpoint != "(none)" {
+				endpoint = peer.Endpoint
+			}
+
+			transfer = fmt.Sprintf("%s / %s", formatBytes(peer.ReceiveBytes), formatBytes(peer.TransmitBytes))
+		} else {
+			// not in the WireGuard config yet (e.g. the config was not reloaded after the node joined)
+			state = "unknown peer"
+		}
+
+		fmt.Fprintf(stdOut, "%-8s %-20s %-12s %-16s %-14s %-22s %-20s %s\n", node.Role, node.Hostname(), overlayIP, state, handshake, endpoint, transfer, labels)
+	}
+
+	return nil
+}
+
+// nodeVersion returns the wireport build the node has last reported to the gateway, "-" if it has not reported one
+func nodeVersion(node *types.Node) string {
+	if node.Role == types.NodeRoleGateway {
+		return version.Version
+	}
+
+	if node.Version == "" {
+		return "-"
+	}
+
+	return node.Version
+}
+
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds ago", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	}
+
+	return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+}
+
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/logger"
+	"wireport/internal/nodes/types"
+	"wireport/internal/notifications"
+	"wireport/internal/wg"
+)
+
+// notify writes the event to the outbox; failing to notify never fails the command that caused the event
+func (s *LocalCommandsService) notify(event *notifications.Event) {
+	if s.NotificationsRepository == nil {
+		return
+	}
+
+	if err := s.NotificationsRepository.Enqueue(event); err != nil {
+		logger.Error("Failed to enqueue %s notification: %v", event.Type, err)
+	}
+}
+
+func newNotificationsHTTPClient() *http.Client {
+	return &http.Client{Timeout: config.Config.NotificationsDeliveryTimeout}
+}
+
+// startNotifications delivers the outbox to the webhooks and watches peers and certificates for events
+func (s *LocalCommandsService) startNotifications(ctx context.Context, waitGroup *sync.WaitGroup) {
+	dispatcher := &notifications.Dispatcher{
+		Repository:  s.NotificationsRepository,
+		Client:      newNotificationsHTTPClient(),
+		MaxAttempts: config.Config.NotificationsMaxAttempts,
+		BaseBackoff: config.Config.NotificationsBaseBackoff,
+		MaxBackoff:  config.Config.NotificationsMaxBackoff,
+	}
+
+	runPeriodically(ctx, waitGroup, config.Config.NotificationsDispatchInterval, func() {
+		if err := dispatcher.DeliverDue(time.Now()); err != nil {
+			logger.Error("Failed to deliver notifications: %v", err)
+		}
+	})
+
+	peerStates := map[string]wg.PeerState{}
+	certificatesNotifiedAt := map[string]time.Time{}
+
+	runPeriodically(ctx, waitGroup, config.Config.NotificationsWatchInterval, func() {
+		s.watchPeers(peerStates)
+		s.watchCertificates(certificatesNotifiedAt)
+
+		if err := s.NotificationsRepository.PruneMessages(time.Now().Add(-config.Config.NotificationsRetention)); err != nil {
+			logger.Error("Failed to prune notifications outbox: %v", err)
+		}
+	})
+}
+
+// watchPeers notifies when a server or client that was online stops handshaking with the gateway
+func (s *LocalCommandsService) watchPeers(peerStates map[string]wg.PeerState) {
+	peers, err := wg.GetInterfacePeerStats(config.Config.WireguardInterfaceName)
+
+	if err != nil {
+		logger.Error("Failed to get WireGuard peer stats: %v", err)
+		return
+	}
+
+	nodesByPublicKey := map[string]types.Node{}
+
+	for _, role := range []types.NodeRole{types.NodeRoleServer, types.NodeRoleClient} {
+		roleNodes, err := s.NodesRepository.GetNodesByRole(role)
+
+		if err != nil {
+			logger.Error("Failed to get %s nodes: %v", role, err)
+			return
+		}
+
+		for _, node := range roleNodes {
+			nodesByPublicKey[node.WGPublicKey] = node
+		}
+	}
+
+	now := time.Now()
+
+	for _, peer := range peers {
+		node, ok := nodesByPublicKey[peer.PublicKey]
+
+		if !ok {
+			continue
+		}
+
+		state := peer.State(now, config.Config.WireguardPeerStaleAfter)
+		previousState, seen := peerStates[peer.PublicKey]
+		peerStates[peer.PublicKey] = state
+
+		if seen && previousState == wg.PeerStateOnline && state == wg.PeerStateStale {
+			overlayIP := types.IPToString(node.WGConfig.Interface.Address.IP)
+
+			s.notify(notifications.NewEvent(notifications.EventPeerStale, map[string]string{
+				"nodeId":        node.ID,
+				"role":          string(node.Role),
+				"overlayIp":     overlayIP,
+				"lastHandshake": peer.LatestHandshake.UTC().Format(time.RFC3339),
+			}, "%s %s stopped handshaking with the gateway (last handshake %s)", node.Role, overlayIP, formatAge(now.Sub(peer.LatestHandshake))))
+		}
+	}
+}
+
+// watchCertificates notifies about user-provided certificates nearing expiry, at most once a day per certificate
+func (s *LocalCommandsService) watchCertificates(notifiedAt map[string]time.Time) {
+	gatewayCertificates, err := s.CertificatesRepository.GetAll()
+
+	if err != nil {
+		logger.Error("Failed to get certificates: %v", err)
+		return
+	}
+
+	for _, certificate := range gatewayCertificates {
+		if !certificate.ExpiresWithin(config.Config.CertificateExpiryWarn) || time.Since(notifiedAt[certificate.ID]) < 24*time.Hour {
+			continue
+		}
+
+		notifiedAt[certificate.ID] = time.Now()
+
+		s.notify(notifications.NewEvent(notifications.EventCertificateExpiring, map[string]string{
+			"certificateId": certificate.ID,
+			"hosts":         strings.Join(certificate.Hosts, ","),
+			"notAfter":      certificate.NotAfter.UTC().Format(time.RFC3339),
+		}, "certificate of %s expires on %s", strings.Join(certificate.Hosts, ", "), certificate.NotAfter.Format(time.DateOnly)))
+	}
+}
+
+func (s *LocalCommandsService) NotifyAdd(stdOut io.Writer, _ io.Writer, url string, secret string, events []string) error {
+	webhook, err := notifications.NewWebhook(url, secret, events)
+
+	if err != nil {
+		return fmt.Errorf("error adding webhook: %w", err)
+	}
+
+	if err = s.NotificationsRepository.CreateWebhook(webhook); err != nil {
+		return fmt.Errorf("error adding webhook: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "✅ Webhook %s added for %s events\n", webhook.ID, webhook.EventsString())
+
+	if webhook.Secret == "" {
+		fmt.Fprintf(stdOut, "⚠️  No secret set, deliveries are not signed (see --secret)\n")
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) NotifyList(stdOut io.Writer, _ io.Writer) error {
+	webhooks, err := s.NotificationsRepository.GetAllWebhooks()
+
+	if err != nil {
+		return fmt.Errorf("error getting webhooks: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "ID\t\t\t\t\tSIGNED\tPENDING\tFAILED\tURL\tEVENTS\n")
+	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
+
+	if len(webhooks) == 0 {
+		fmt.Fprintf(stdOut, "No webhooks are added to this gateway\n")
+		return nil
+	}
+
+	for _, webhook := range webhooks {
+		pending, err := s.NotificationsRepository.CountMessages(webhook.ID, notifications.OutboxStatusPending)
+
+		if err != nil {
+			return fmt.Errorf("error counting pending notifications: %w", err)
+		}
+
+		failed, err := s.NotificationsRepository.CountMessages(webhook.ID, notifications.OutboxStatusFailed)
+
+		if err != nil {
+			return fmt.Errorf("error counting failed notifications: %w", err)
+		}
+
+		signed := "no"
+
+		if webhook.Secret != "" {
+			signed = "yes"
+		}
+
+		fmt.Fprintf(stdOut, "%s\t%s\t%d\t%d\t%s\t%s\n", webhook.ID, signed, pending, failed, webhook.URL, webhook.EventsString())
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) NotifyRemove(stdOut io.Writer, _ io.Writer, id string) error {
+	if !s.NotificationsRepository.DeleteWebhook(id) {
+		return fmt.Errorf("error removing webhook: %w: %s", notifications.ErrWebhookNotFound, id)
+	}
+
+	fmt.Fprintf(stdOut, "✅ Webhook %s removed\n", id)
+
+	return nil
+}
+
+// NotifyTest sends a test event to the webhook right away, bypassing the outbox
+func (s *LocalCommandsService) NotifyTest(stdOut io.Writer, _ io.Writer, id string) error {
+	webhook, err := s.NotificationsRepository.GetWebhook(id)
+
+	if err != nil {
+		return fmt.Errorf("error getting webhook %s: %w", id, err)
+	}
+
+	event := notifications.NewEvent(notifications.EventTest, nil, "test notification from the wireport gateway")
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return fmt.Errorf("error encoding test event: %w", err)
+	}
+
+	if err = notifications.Deliver(newNotificationsHTTPClient(), webhook, event.Type, payload); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Test event delivered to %s\n", webhook.URL)
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"wireport/cmd/server/config"
+	"wireport/internal/profiles"
+)
+
+// ProfileList prints the profiles of this machine with the gateway each of them joined
+func (s *LocalCommandsService) ProfileList(stdOut io.Writer) error {
+	dir, err := profilesDir()
+
+	if err != nil {
+		return err
+	}
+
+	names, err := profiles.List(dir)
+
+	if err != nil {
+		return err
+	}
+
+	current := config.Config.WireportProfile
+
+	// the current profile gets its database with the first command that needs one
+	if !slices.Contains(names, current) {
+		names = append(names, current)
+	}
+
+	fmt.Fprintf(stdOut, "  %-24s %-8s %s\n", "PROFILE", "ROLE", "GATEWAY")
+
+	for _, name := range names {
+		marker := " "
+
+		if name == current {
+			marker = "*"
+		}
+
+		role, gateway := describeProfile(dir, name)
+
+		fmt.Fprintf(stdOut, "%s %-24s %-8s %s\n", marker, name, role, gateway)
+	}
+
+	fmt.Fprintf(stdOut, "\n* current profile, selected by %s\n", config.Config.WireportProfileSource)
+	printDatabasePathOverride(stdOut)
+
+	return nil
+}
+
+// ProfileShow prints the profile (the current one if name is empty): its database and the gateway it joined
+func (s *LocalCommandsService) ProfileShow(name string, stdOut io.Writer) error {
+	dir, err := profilesDir()
+
+	if err != nil {
+		return err
+	}
+
+	current := config.Config.WireportProfile
+
+	if name == "" {
+		name = current
+	}
+
+	if name != current {
+		if err = profiles.ValidateName(name); err != nil {
+			return err
+		}
+
+		if !profiles.Exists(dir, name) {
+			return fmt.Errorf("%w: %s", profiles.ErrProfileNotFound, name)
+		}
+	}
+
+	selection := ""
+
+	if name == current {
+		selection = fmt.Sprintf(" (current, selected by %s)", config.Config.WireportProfileSource)
+	}
+
+	databasePath := profiles.DatabasePath(dir, name)
+
+	if !profiles.Exists(dir, name) {
+		databasePath += " (created by the first command)"
+	}
+
+	role, gateway := describeProfile(dir, name)
+
+	fmt.Fprintf(stdOut, "Profile:  %s%s\n", name, selection)
+	fmt.Fprintf(stdOut, "Database: %s\n", databasePath)
+	fmt.Fprintf(stdOut, "Role:     %s\n", role)
+	fmt.Fprintf(stdOut, "Gateway:  %s\n", gateway)
+
+	if name == current {
+		printDatabasePathOverride(stdOut)
+	}
+
+	return nil
+}
+
+// ProfileUse makes the profile the current one, used unless --profile or WIREPORT_PROFILE select another
+func (s *LocalCommandsService) ProfileUse(name string, stdOut io.Writer) error {
+	dir, err := profilesDir()
+
+	if err != nil {
+		return err
+	}
+
+	if err = profiles.WriteCurrent(dir, name); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Switched to profile %s\n", name)
+
+	if !profiles.Exists(dir, name) {
+		fmt.Fprintf(stdOut, "   💡 Profile %s is new, its database is created by the next command (e.g. 'wireport join' or 'wireport gateway up')\n", name)
+	}
+
+	if envProfile := config.GetEnv("WIREPORT_PROFILE", ""); envProfile != "" && envProfile != name {
+		fmt.Fprintf(stdOut, "   ⚠️  WIREPORT_PROFILE=%s takes precedence in this shell, unset it to use %s\n", envProfile, name)
+	}
+
+	return nil
+}
+
+// ProfileRename renames the profile, the current profile selection follows it
+func (s *LocalCommandsService) ProfileRename(from string, to string, stdOut io.Writer) error {
+	dir, err := profilesDir()
+
+	if err != nil {
+		return err
+	}
+
+	if err = profiles.Rename(dir, from, to); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Profile %s renamed to %s\n", from, to)
+
+	if envProfile := config.GetEnv("WIREPORT_PROFILE", ""); envProfile == from {
+		fmt.Fprintf(stdOut, "   ⚠️  WIREPORT_PROFILE still selects %s, set it to %s\n", from, to)
+	}
+
+	return nil
+}
+
+// ProfileDelete deletes the profile with its database, the backups of the database and its master key
+func (s *LocalCommandsService) ProfileDelete(name string, stdOut io.Writer) error {
+	dir, err := profilesDir()
+
+	if err != nil {
+		return err
+	}
+
+	if name == config.Config.WireportProfile {
+		return fmt.Errorf("%w: %s is the current profile, switch to another one with 'wireport profile use' first", profiles.ErrProfileInUse, name)
+	}
+
+	if err = profiles.Delete(dir, name); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Profile %s deleted\n", name)
+
+	return nil
+}
+
+func profilesDir() (string, error) {
+	if config.Config.ProfilesDir == "" {
+		return "", fmt.Errorf("%w: the home directory is unknown, profiles are kept in ~/.wireport", ErrInvalidRequest)
+	}
+
+	return config.Config.ProfilesDir, nil
+}
+
+// describeProfile returns the role of the node of the profile and the gateway it joined, '-' for a profile without a
+// database or a node yet
+func describeProfile(dir string, name string) (string, string) {
+	if !profiles.Exists(dir, name) {
+		return "-", "-"
+	}
+
+	profile, err := profiles.Inspect(dir, name)
+
+	if err != nil {
+		return "?", fmt.Sprintf("⚠️  %v", err)
+	}
+
+	role, gateway := profile.Role, profile.Gateway()
+
+	if role == "" {
+		role = "-"
+	}
+
+	if gateway == "" {
+		gateway = "-"
+	}
+
+	return role, gateway
+}
+
+// printDatabasePathOverride warns that DATABASE_PATH replaces the database of the current profile
+func printDatabasePathOverride(stdOut io.Writer) {
+	if config.Config.DatabasePath != config.ProfileDatabasePath(config.Config.WireportProfile) {
+		fmt.Fprintf(stdOut, "⚠️  DATABASE_PATH is set, the commands use %s instead of the current profile\n", config.Config.DatabasePath)
+	}
+}
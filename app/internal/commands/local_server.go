@@ -12,6 +12,9 @@ import (
 	"wireport/internal/dockerutils"
 	"wireport/internal/encryption/mtls"
 	"wireport/internal/joinrequests"
+	"wireport/internal/logger"
+	"wireport/internal/networkapps"
+	"wireport/internal/nodes"
 	"wireport/internal/publicservices"
 	"wireport/internal/ssh"
 	"wireport/internal/utils"
@@ -19,43 +22,44 @@ import (
 	"github.com/google/uuid"
 
 	"wireport/internal/nodes/types"
+	"wireport/internal/notifications"
 
 	"gorm.io/gorm"
 )
 
 // Label keys expected in Docker Compose (or equivalent) to declare a wireport publication.
 const (
-	wireportServiceLocalLabel  = "wireport.service.local"
-	wireportServicePublicLabel = "wireport.service.public"
+	wireportServiceLocalLabel       = "wireport.service.local"
+	wireportServicePublicLabel      = "wireport.service.public"
+	wireportServiceLBPolicyLabel    = "wireport.service.lb_policy"
+	wireportServiceHealthPathLabel  = "wireport.service.health_path"
+	wireportServicePathLabel        = "wireport.service.path"
+	wireportServiceStripPrefixLabel = "wireport.service.strip_prefix"
 )
 
-func (s *LocalCommandsService) ServerNew(forceServerCreation bool, quietServerCreation bool, dockerSubnet string, stdOut io.Writer, errOut io.Writer) {
+func (s *LocalCommandsService) ServerNew(forceServerCreation bool, quietServerCreation bool, dockerSubnet string, stdOut io.Writer, _ io.Writer) error {
 	totalDockerSubnets, availableDockerSubnets, err := s.NodesRepository.TotalAndAvailableDockerSubnets()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to count available Docker subnets: %v\n", err)
-		return
+		return fmt.Errorf("failed to count available Docker subnets: %w", err)
 	}
 
 	totalServerRoleJoinRequests := s.JoinRequestsRepository.CountServerJoinRequests()
 
 	if availableDockerSubnets <= 0 || totalServerRoleJoinRequests >= availableDockerSubnets {
-		fmt.Fprintf(errOut, "No Docker subnets available. Please delete some server nodes (total used: %d) or server join-requests (total used: %d) to free up some subnets.\n", totalDockerSubnets, totalServerRoleJoinRequests)
-		return
+		return fmt.Errorf("%w: please delete some server nodes (total used: %d) or server join-requests (total used: %d) to free up some subnets", nodes.ErrNoAvailableDockerSubnets, totalDockerSubnets, totalServerRoleJoinRequests)
 	}
 
 	totalWireguardClients, availableWireguardClients, err := s.NodesRepository.TotalAvailableWireguardClients()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to count available WireGuard clients: %v\n", err)
-		return
+		return fmt.Errorf("failed to count available WireGuard clients: %w", err)
 	}
 
 	totalJoinRequests := s.JoinRequestsRepository.CountAll()
 
 	if availableWireguardClients <= 0 || totalJoinRequests >= availableWireguardClients {
-		fmt.Fprintf(errOut, "No WireGuard clients available. Please delete some client/server nodes (total used: %d) or client/server join-requests (total used: %d) to free up some clients.\n", totalWireguardClients, totalJoinRequests)
-		return
+		return fmt.Errorf("%w: please delete some client/server nodes (total used: %d) or client/server join-requests (total used: %d) to free up some clients", ErrNoWireguardClientSlots, totalWireguardClients, totalJoinRequests)
 	}
 
 	var dockerSubnetPtr *string
@@ -66,13 +70,11 @@ func (s *LocalCommandsService) ServerNew(forceServerCreation bool, quietServerCr
 		parsedDockerSubnet, err = types.ParseIPNetMarshable(dockerSubnet, true)
 
 		if err != nil {
-			fmt.Fprintf(errOut, "Failed to parse Docker subnet: %v\n", err)
-			return
+			return fmt.Errorf("%w: failed to parse Docker subnet: %v", ErrInvalidRequest, err)
 		}
 
 		if !s.NodesRepository.IsDockerSubnetAvailable(parsedDockerSubnet) {
-			fmt.Fprintf(errOut, "Docker subnet %s is already in use\n", dockerSubnet)
-			return
+			return fmt.Errorf("%w: docker subnet %s is already in use", nodes.ErrNoAvailableDockerSubnets, dockerSubnet)
 		}
 
 		dockerSubnetPtr = &dockerSubnet
@@ -86,22 +88,20 @@ func (s *LocalCommandsService) ServerNew(forceServerCreation bool, quietServerCr
 		_, err = s.NodesRepository.CreateServer(dockerSubnetPtr)
 
 		if err != nil {
-			fmt.Fprintf(errOut, "Failed to create server node: %v\n", err)
-			return
+			return fmt.Errorf("failed to create server node: %w", err)
 		}
 
 		if !quietServerCreation {
 			fmt.Fprintf(stdOut, "Server node created\n")
 		}
 
-		return
+		return nil
 	}
 
 	gatewayNode, err := s.NodesRepository.GetGatewayNode()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to get gateway node: %v\n", err)
-		return
+		return fmt.Errorf("failed to get gateway node: %w", err)
 	}
 
 	joinRequestID := uuid.New().String()
@@ -112,36 +112,31 @@ func (s *LocalCommandsService) ServerNew(forceServerCreation bool, quietServerCr
 	})
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to add client to gateway cert bundle: %v\n", err)
-		return
+		return fmt.Errorf("failed to add client to gateway cert bundle: %w", err)
 	}
 
 	err = s.NodesRepository.SaveNode(gatewayNode)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to save gateway node: %v\n", err)
-		return
+		return fmt.Errorf("failed to save gateway node: %w", err)
 	}
 
 	clientCertBundle, err := gatewayNode.GatewayCertBundle.GetClientBundlePublic(joinRequestID)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to get client cert bundle: %v\n", err)
-		return
+		return fmt.Errorf("failed to get client cert bundle: %w", err)
 	}
 
 	joinRequest, err := s.JoinRequestsRepository.Create(joinRequestID, *gatewayNode.WGPublicIP, config.Config.ControlServerPort, dockerSubnetPtr, types.NodeRoleServer, clientCertBundle)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to create join request: %v\n", err)
-		return
+		return fmt.Errorf("failed to create join request: %w", err)
 	}
 
 	joinRequestBase64, err := joinRequest.ToBase64()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to encode join request: %v\n", err)
-		return
+		return fmt.Errorf("failed to encode join request: %w", err)
 	}
 
 	if !quietServerCreation {
@@ -149,19 +144,45 @@ func (s *LocalCommandsService) ServerNew(forceServerCreation bool, quietServerCr
 	} else {
 		fmt.Fprintf(stdOut, "%s\n", *joinRequestBase64)
 	}
+
+	return nil
 }
 
-func (s *LocalCommandsService) ServerRemove(stdOut io.Writer, errOut io.Writer, serverNodeID string) {
+func (s *LocalCommandsService) ServerRemove(stdOut io.Writer, _ io.Writer, serverNodeID string) error {
+	// looked up before the removal for the notification only
+	serverNode, lookupErr := s.NodesRepository.GetByID(serverNodeID)
+
 	err := s.NodesRepository.DeleteServer(serverNodeID)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to remove server node '%s': %v\n", serverNodeID, err)
-		return
+		return fmt.Errorf("failed to remove server node '%s': %w", serverNodeID, err)
+	}
+
+	// the name, the zone and the peer of the server are gone from the gateway configs
+	if err = s.applyDNSConfigs(true); err != nil {
+		return err
+	}
+
+	if err = networkapps.RestartNetworkApps(true, false, false); err != nil {
+		return fmt.Errorf("error restarting WireGuard: %w", err)
+	}
+
+	if lookupErr == nil && serverNode.Role == types.NodeRoleServer {
+		overlayIP := types.IPToString(serverNode.WGConfig.Interface.Address.IP)
+
+		s.notify(notifications.NewEvent(notifications.EventServerLeft, map[string]string{
+			"nodeId":    serverNodeID,
+			"overlayIp": overlayIP,
+		}, "server %s left the network", overlayIP))
 	}
 
 	fmt.Fprintf(stdOut, "Server node '%s' removed successfully\n", serverNodeID)
+
+	return nil
 }
 
+var reconcileLog = logger.Component(logger.ComponentReconcile)
+
 func (s *LocalCommandsService) ServerStart(apiCommandsService *APICommandsService, stdOut io.Writer, errOut io.Writer) {
 	fmt.Fprintf(stdOut, "Starting wireport server\n")
 
@@ -172,9 +193,7 @@ func (s *LocalCommandsService) ServerStart(apiCommandsService *APICommandsServic
 		return
 	}
 
-	publicServices := []*publicservices.PublicService{}
-
-	err = currentNode.SaveConfigs(publicServices, true)
+	err = currentNode.SaveConfigs(&types.GatewayConfigs{}, true)
 
 	if err != nil {
 		fmt.Fprintf(errOut, "Failed to save server node configs: %v\n", err)
@@ -183,41 +202,57 @@ func (s *LocalCommandsService) ServerStart(apiCommandsService *APICommandsServic
 
 	fmt.Fprintf(stdOut, "Server node configs saved to the disk successfully\n")
 
+	s.startMetrics(currentNode)
+
 	for {
 		currentNode, err = s.NodesRepository.GetCurrentNode()
 
 		if err != nil || currentNode == nil {
-			fmt.Fprintf(errOut, "Failed to get current node: %v\n", err)
+			reconcileLog.Error("Failed to get current node", logger.Err(err))
 			time.Sleep(time.Second * 30)
 			continue
 		}
 
-		ensureDockerNetworkIsAttachedToAllContainers(stdOut, errOut)
-		reconcileGatewayServicesWithDockerLabels(apiCommandsService, currentNode, stdOut, errOut)
-		refreshNodeConfig(s, apiCommandsService, currentNode, stdOut, errOut)
+		startedAt := time.Now()
+		ok := ensureDockerNetworkIsAttachedToAllContainers()
+		observeReconcileStep("docker_network", startedAt, ok)
+
+		startedAt = time.Now()
+		ok = reconcileGatewayServicesWithDockerLabels(apiCommandsService, currentNode)
+		observeReconcileStep("services", startedAt, ok)
+
+		startedAt = time.Now()
+		ok = refreshNodeConfig(s, apiCommandsService, currentNode, stdOut, errOut)
+		observeReconcileStep("node_config", startedAt, ok)
+
+		startedAt = time.Now()
+		ok = reconcileServerUpgrade(apiCommandsService)
+		observeReconcileStep("upgrade", startedAt, ok)
 
 		time.Sleep(time.Second * 30)
 	}
 }
 
-func refreshNodeConfig(localCommandsService *LocalCommandsService, apiCommandsService *APICommandsService, currentNode *types.Node, stdOut io.Writer, errOut io.Writer) {
+func refreshNodeConfig(localCommandsService *LocalCommandsService, apiCommandsService *APICommandsService, currentNode *types.Node, stdOut io.Writer, errOut io.Writer) bool {
+	log := reconcileLog.With(logger.KeyStep, "node_config")
+
 	nodeCommandResponse, err := apiCommandsService.NodeConfig()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to get node config: %v\n", err)
-		return
+		log.Error("Failed to get node config", logger.Err(err))
+		return false
 	}
 
 	nodeConfig := nodeCommandResponse.NodeConfig
 
 	if nodeConfig == nil {
-		fmt.Fprintf(errOut, "Failed to get node config: %v\n", err)
-		return
+		log.Error("Failed to get node config: the gateway returned no config")
+		return false
 	}
 
 	if nodeConfig.ID != currentNode.ID {
-		fmt.Fprintf(errOut, "Node config mismatch: %s != %s\n", nodeConfig.ID, currentNode.ID)
-		return
+		log.Error("Node config mismatch", "config_node_id", nodeConfig.ID, logger.KeyNodeID, currentNode.ID)
+		return false
 	}
 
 	// for now we only update the labels
@@ -225,33 +260,50 @@ func refreshNodeConfig(localCommandsService *LocalCommandsService, apiCommandsSe
 	err = localCommandsService.NodesRepository.UpdateLabels(nodeConfig.ID, nodeConfig.Labels)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to update node config: %v\n", err)
-		return
+		log.Error("Failed to update node config", logger.Err(err))
+		return false
 	}
 
-	fmt.Fprintf(stdOut, "Node config updated successfully\n")
+	log.Debug("Node config updated")
 
 	dockersocket.ReconcileWithLabels(nodeConfig.Labels, stdOut, errOut)
+
+	return true
 }
 
-func ensureDockerNetworkIsAttachedToAllContainers(stdOut io.Writer, errOut io.Writer) {
-	fmt.Fprintf(stdOut, "Ensuring docker network is attached to all containers\n")
+func ensureDockerNetworkIsAttachedToAllContainers() bool {
+	log := reconcileLog.With(logger.KeyStep, "docker_network")
+
+	log.Debug("Ensuring docker network is attached to all containers")
 
 	err := dockerutils.EnsureDockerNetworkIsAttachedToAllContainers()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to ensure docker network is attached to all containers: %v\n", err)
-		return
+		log.Error("Failed to ensure docker network is attached to all containers", logger.Err(err))
+		return false
 	}
 
-	fmt.Fprintf(stdOut, "Docker network is attached to all containers\n")
+	log.Debug("Docker network is attached to all containers")
+
+	return true
 }
 
+// filterServicesPublishedByNode returns one entry per upstream published by the node;
+// Local* fields of each entry describe the upstream, so that services load balanced across
+// several nodes are reconciled per upstream
 func filterServicesPublishedByNode(all []*publicservices.PublicService, nodeID string) []*publicservices.PublicService {
 	out := make([]*publicservices.PublicService, 0)
 	for _, svc := range all {
-		if svc.PublishedByNodeID != nil && *svc.PublishedByNodeID == nodeID {
-			out = append(out, svc)
+		for _, upstream := range svc.GetUpstreamsPublishedByNode(nodeID) {
+			out = append(out, &publicservices.PublicService{
+				PublishedByNodeID: upstream.PublishedByNodeID,
+				LocalProtocol:     upstream.LocalProtocol,
+				LocalHost:         upstream.LocalHost,
+				LocalPort:         upstream.LocalPort,
+				PublicProtocol:    svc.PublicProtocol,
+				PublicHost:        svc.PublicHost,
+				PublicPort:        svc.PublicPort,
+			})
 		}
 	}
 	return out
@@ -259,25 +311,25 @@ func filterServicesPublishedByNode(all []*publicservices.PublicService, nodeID s
 
 // reconcileGatewayServicesWithDockerLabels syncs gateway publications for this node with
 // Docker container labels. Unpublish/publish calls are batched at the end.
-func reconcileGatewayServicesWithDockerLabels(api *APICommandsService, currentNode *types.Node, stdOut, errOut io.Writer) {
+// Returns false if any of the services could not be reconciled.
+func reconcileGatewayServicesWithDockerLabels(api *APICommandsService, currentNode *types.Node) bool {
+	log := reconcileLog.With(logger.KeyStep, "services")
+	ok := true
+
 	serviceList, err := api.ServiceList()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to get services: %v\n", err)
-		return
+		log.Error("Failed to get services", logger.Err(err))
+		return false
 	}
 
-	fmt.Fprintf(stdOut, "Retrieved %d services from gateway node\n", len(serviceList.Services))
-
 	labelsByContainerName, err := dockerutils.ListAllContainerLabels()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to list all container labels: %v\n", err)
-		return
+		log.Error("Failed to list all container labels", logger.Err(err))
+		return false
 	}
 
-	fmt.Fprintf(stdOut, "Retrieved labels for %d containers\n", len(labelsByContainerName))
-
 	// Some labels define services published on the gateway node.
 	// Here we compare the list of actually published services with the list of services defined by labels:
 	// if there are services defined by labels but not published, we publish them;
@@ -287,7 +339,7 @@ func reconcileGatewayServicesWithDockerLabels(api *APICommandsService, currentNo
 	// All services published by the current server node on the gateway — we only reconcile these.
 	publishedHere := filterServicesPublishedByNode(serviceList.Services, currentNode.ID)
 
-	fmt.Fprintf(stdOut, "Retrieved %d services published by current node\n", len(publishedHere))
+	log.Debug("Retrieved services and container labels", "services", len(serviceList.Services), "published_by_node", len(publishedHere), "containers", len(labelsByContainerName))
 
 	// Services still published by this node but no longer backed by valid labels → unpublish.
 	servicesToUnpublish := make([]*publicservices.PublicService, 0, len(publishedHere))
@@ -299,12 +351,17 @@ func reconcileGatewayServicesWithDockerLabels(api *APICommandsService, currentNo
 			continue
 		}
 
-		fmt.Fprintf(stdOut, "Service %s://%s:%d is published by the node, but labels are not set anymore - to be unpublished\n", service.LocalProtocol, service.LocalHost, service.LocalPort)
+		log.Info("Service is published by the node, but labels are not set anymore - to be unpublished", "local", fmt.Sprintf("%s://%s:%d", service.LocalProtocol, service.LocalHost, service.LocalPort))
 		servicesToUnpublish = append(servicesToUnpublish, service)
 	}
 
 	// Labels on containers that are not yet published by this node → candidates to publish.
-	servicesToPublish := make([]*publicservices.PublicService, 0)
+	type servicePublication struct {
+		service *publicservices.PublicService
+		options publicservices.PublishOptions
+	}
+
+	servicesToPublish := make([]servicePublication, 0)
 
 	for containerName, containerLabels := range labelsByContainerName {
 		// If local host already matches a service published by this node, skip.
@@ -328,55 +385,97 @@ func reconcileGatewayServicesWithDockerLabels(api *APICommandsService, currentNo
 
 		localProtocol, localHost, localPort, err := utils.ParseAddress(localAddress)
 		if err != nil {
-			fmt.Fprintf(errOut, "Can not publish service %s: failed to parse local address: %v\n", containerName, err)
+			ok = false
+			log.Error("Can not publish service: failed to parse local address", "container", containerName, logger.Err(err))
 			continue
 		}
 
 		if *localHost != containerName {
-			fmt.Fprintf(stdOut, "Can not publish service %s: local host %s does not match container name %s - the service definition is not valid, skipping\n", containerName, *localHost, containerName)
+			log.Warn("Can not publish service: local host does not match container name - the service definition is not valid, skipping", "container", containerName, "local_host", *localHost)
 			continue
 		}
 
 		publicProtocol, publicHost, publicPort, err := utils.ParseAddress(publicAddress)
 		if err != nil {
-			fmt.Fprintf(errOut, "Can not publish service %s: failed to parse public address: %v\n", containerName, err)
+			ok = false
+			log.Error("Can not publish service: failed to parse public address", "container", containerName, logger.Err(err))
 			continue
 		}
 
-		servicesToPublish = append(servicesToPublish, &publicservices.PublicService{
-			PublishedByNodeID: &currentNode.ID,
-			LocalHost:         *localHost,
-			LocalProtocol:     *localProtocol,
-			LocalPort:         *localPort,
-			PublicProtocol:    *publicProtocol,
-			PublicHost:        *publicHost,
-			PublicPort:        *publicPort,
+		lbPolicy, err := publicservices.ParseLBPolicy(containerLabels[wireportServiceLBPolicyLabel])
+		if err != nil {
+			ok = false
+			log.Error("Can not publish service", "container", containerName, logger.Err(err))
+			continue
+		}
+
+		var healthCheck *publicservices.PublicServiceHealthCheck
+
+		if healthPath := containerLabels[wireportServiceHealthPathLabel]; healthPath != "" {
+			healthCheck = &publicservices.PublicServiceHealthCheck{Path: healthPath}
+		}
+
+		options := publicservices.PublishOptions{
+			LBPolicy:    lbPolicy,
+			HealthCheck: healthCheck,
+		}
+
+		if routePath := containerLabels[wireportServicePathLabel]; routePath != "" {
+			options.Route = &publicservices.PublicServiceRouteMatcher{
+				PathPrefix:  routePath,
+				StripPrefix: containerLabels[wireportServiceStripPrefixLabel] == "true",
+			}
+
+			if err = options.Route.Validate(); err != nil {
+				ok = false
+				log.Error("Can not publish service", "container", containerName, logger.Err(err))
+				continue
+			}
+		}
+
+		servicesToPublish = append(servicesToPublish, servicePublication{
+			service: &publicservices.PublicService{
+				PublishedByNodeID: &currentNode.ID,
+				LocalHost:         *localHost,
+				LocalProtocol:     *localProtocol,
+				LocalPort:         *localPort,
+				PublicProtocol:    *publicProtocol,
+				PublicHost:        *publicHost,
+				PublicPort:        *publicPort,
+			},
+			options: options,
 		})
 	}
 
 	// Apply unpublish then publish on the gateway.
 	for _, service := range servicesToUnpublish {
-		_, err := api.ServiceUnpublish(service.PublicProtocol, service.PublicHost, service.PublicPort)
+		// only the upstream owned by this node is removed, other nodes may still serve the same public address
+		_, err := api.ServiceUnpublish(service.PublicProtocol, service.PublicHost, service.PublicPort, service.LocalProtocol, service.LocalHost, service.LocalPort)
 		if err != nil {
-			fmt.Fprintf(errOut, "Failed to unpublish service %s://%s:%d: %v\n", service.PublicProtocol, service.PublicHost, service.PublicPort, err)
+			ok = false
+			log.Error("Failed to unpublish service", "public", fmt.Sprintf("%s://%s:%d", service.PublicProtocol, service.PublicHost, service.PublicPort), logger.Err(err))
 			continue
 		}
 
-		fmt.Fprintf(stdOut, "Unpublished service %s://%s:%d\n", service.PublicProtocol, service.PublicHost, service.PublicPort)
+		log.Info("Unpublished service", "local", fmt.Sprintf("%s://%s:%d", service.LocalProtocol, service.LocalHost, service.LocalPort), "public", fmt.Sprintf("%s://%s:%d", service.PublicProtocol, service.PublicHost, service.PublicPort))
 	}
 
-	for _, service := range servicesToPublish {
-		publicationResult, err := api.ServicePublish(service.LocalProtocol, service.LocalHost, service.LocalPort, service.PublicProtocol, service.PublicHost, service.PublicPort)
+	for _, publication := range servicesToPublish {
+		service := publication.service
+		publicationResult, err := api.ServicePublish([]publicservices.PublicServiceUpstream{{LocalProtocol: service.LocalProtocol, LocalHost: service.LocalHost, LocalPort: service.LocalPort}}, service.PublicProtocol, service.PublicHost, service.PublicPort, publication.options)
 		if err != nil || publicationResult.Stderr != "" {
-			fmt.Fprintf(errOut, "Failed to publish service %s://%s:%d: -> %s://%s:%d: %v\n", service.LocalProtocol, service.LocalHost, service.LocalPort, service.PublicProtocol, service.PublicHost, service.PublicPort, err)
+			ok = false
+			log.Error("Failed to publish service", "local", fmt.Sprintf("%s://%s:%d", service.LocalProtocol, service.LocalHost, service.LocalPort), "public", fmt.Sprintf("%s://%s:%d", service.PublicProtocol, service.PublicHost, service.PublicPort), logger.Err(err), "stderr", publicationResult.Stderr)
 			continue
 		}
 
-		fmt.Fprintf(stdOut, "Published service %s://%s:%d -> %s://%s:%d\n", service.LocalProtocol, service.LocalHost, service.LocalPort, service.PublicProtocol, service.PublicHost, service.PublicPort)
+		log.Info("Published service", "local", fmt.Sprintf("%s://%s:%d", service.LocalProtocol, service.LocalHost, service.LocalPort), "public", fmt.Sprintf("%s://%s:%d", service.PublicProtocol, service.PublicHost, service.PublicPort))
 	}
+
+	return ok
 }
 
-func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Writer) {
+func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Writer) error {
 	sshService := ssh.NewService()
 
 	fmt.Fprintf(stdOut, "🔍 Checking wireport Server Status\n")
@@ -390,7 +489,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: SSH connection failed", ErrNodeUnhealthy)
 	}
 
 	defer sshService.Close()
@@ -403,7 +502,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	var dockerVersion string
@@ -419,7 +518,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	} else {
 		fmt.Fprintf(stdOut, "   Status: ❌ Not Installed\n\n")
 		fmt.Fprintf(stdOut, "💡 Install Docker to continue with wireport setup.\n\n")
-		return
+		return fmt.Errorf("%w: docker is not installed", ErrNodeUnhealthy)
 	}
 
 	// Docker Permissions Check
@@ -429,7 +528,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	if err != nil {
 		fmt.Fprintf(stdOut, "❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	if dockerAccessible {
@@ -437,7 +536,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	} else {
 		fmt.Fprintf(stdOut, "❌ User lacks permissions\n")
 		fmt.Fprintf(stdOut, "💡 Add user to docker group.\n\n")
-		return
+		return fmt.Errorf("%w: the user has no access to docker", ErrNodeUnhealthy)
 	}
 	fmt.Fprintf(stdOut, "\n")
 
@@ -447,7 +546,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	var containerStatus string
@@ -479,7 +578,7 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	if err != nil {
 		fmt.Fprintf(stdOut, "   Status: ❌ Check Failed\n")
 		fmt.Fprintf(stdOut, "   Error:  %v\n\n", err)
-		return
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
 	if networkStatus != "" {
@@ -490,7 +589,13 @@ func (s *LocalCommandsService) ServerStatus(creds *ssh.Credentials, stdOut io.Wr
 	}
 	fmt.Fprintf(stdOut, "\n")
 
+	if !isRunning {
+		return fmt.Errorf("%w: the wireport server container is not running", ErrNodeUnhealthy)
+	}
+
 	fmt.Fprintf(stdOut, "✨ Server Status check completed successfully!\n")
+
+	return nil
 }
 
 func (s *LocalCommandsService) ServerUp(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer, dockerSubnet string, commandsService *Service) {
@@ -517,9 +622,9 @@ func (s *LocalCommandsService) ServerUp(creds *ssh.Credentials, image string, im
 	stdOutWriter := bytes.NewBufferString("")
 	errOutWriter := bytes.NewBufferString("")
 
-	commandsService.ServerNew(stdOutWriter, errOutWriter, false, true, dockerSubnet)
+	err = commandsService.ServerNew(stdOutWriter, errOutWriter, false, true, dockerSubnet)
 
-	if len(errOutWriter.String()) > 0 || len(stdOutWriter.String()) == 0 {
+	if err != nil || len(stdOutWriter.String()) == 0 {
 		fmt.Fprintf(errOut, "%s\n", errOutWriter.String())
 		fmt.Fprintf(stdOut, "%s\n", stdOutWriter.String())
 		fmt.Fprintf(stdOut, "❌ Failed to connect server node to the wireport network\n")
@@ -670,15 +775,14 @@ func (s *LocalCommandsService) ServerDown(creds *ssh.Credentials, stdOut io.Writ
 	fmt.Fprintf(stdOut, "✨ Server Teardown completed successfully!\n")
 }
 
-func (s *LocalCommandsService) ServerList(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) {
+func (s *LocalCommandsService) ServerList(requestFromNodeID *string, stdOut io.Writer, _ io.Writer) error {
 	serverNodes, err := s.NodesRepository.GetNodesByRole(types.NodeRoleServer)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Error getting nodes: %v\n", err)
-		return
+		return fmt.Errorf("error getting nodes: %w", err)
 	}
 
-	fmt.Fprintf(stdOut, "SERVER PRIVATE IP       LABELS\n")
+	fmt.Fprintf(stdOut, "SERVER PRIVATE IP       VERSION      LABELS\n")
 	fmt.Fprintf(stdOut, "%s\n", strings.Repeat("=", 80))
 
 	if len(serverNodes) > 0 {
@@ -693,11 +797,13 @@ func (s *LocalCommandsService) ServerList(requestFromNodeID *string, stdOut io.W
 				labels = "-"
 			}
 
-			fmt.Fprintf(stdOut, "%-24s %s\n", ip, labels)
+			fmt.Fprintf(stdOut, "%-24s %-12s %s\n", ip, nodeVersion(&serverNode), labels)
 		}
 	} else {
 		fmt.Fprintf(stdOut, "No servers are registered on this gateway.\nUse 'wireport server new' command to create a new server node join request.\n")
 	}
+
+	return nil
 }
 
 func (s *LocalCommandsService) ServerUpgrade(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, _ io.Writer) {
@@ -744,42 +850,40 @@ func (s *LocalCommandsService) ServerUpgrade(creds *ssh.Credentials, image strin
 	fmt.Fprintf(stdOut, "✨ Server Upgrade completed!\n")
 }
 
-func (s *LocalCommandsService) NodeLabelAdd(nodeIP string, label string, stdOut io.Writer, errOut io.Writer) {
+func (s *LocalCommandsService) NodeLabelAdd(nodeIP string, label string, stdOut io.Writer, _ io.Writer) error {
 	node, err := s.NodesRepository.GetServerByWGPrivateIP(nodeIP)
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			fmt.Fprintf(errOut, "No server node found with IP %q\n", nodeIP)
-			return
+			return fmt.Errorf("%w: no server node found with IP %q", nodes.ErrServerNodeNotFound, nodeIP)
 		}
-		fmt.Fprintf(errOut, "failed to resolve server node: %v\n", err)
-		return
+		return fmt.Errorf("failed to resolve server node: %w", err)
 	}
 
 	if err := s.NodesRepository.AddLabelToNode(node.ID, label); err != nil {
-		fmt.Fprintf(errOut, "failed to add label: %v\n", err)
-		return
+		return fmt.Errorf("failed to add label: %w", err)
 	}
 
 	fmt.Fprintf(stdOut, "Added label %q to server node %q\n", label, types.IPToString(node.WGConfig.Interface.Address.IP))
+
+	return nil
 }
 
-func (s *LocalCommandsService) NodeLabelRemove(nodeIP string, label string, stdOut io.Writer, errOut io.Writer) {
+func (s *LocalCommandsService) NodeLabelRemove(nodeIP string, label string, stdOut io.Writer, _ io.Writer) error {
 	node, err := s.NodesRepository.GetServerByWGPrivateIP(nodeIP)
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			fmt.Fprintf(errOut, "No server node found with IP %q\n", nodeIP)
-			return
+			return fmt.Errorf("%w: no server node found with IP %q", nodes.ErrServerNodeNotFound, nodeIP)
 		}
-		fmt.Fprintf(errOut, "failed to resolve server node: %v\n", err)
-		return
+		return fmt.Errorf("failed to resolve server node: %w", err)
 	}
 
 	if err := s.NodesRepository.RemoveLabelFromNode(node.ID, label); err != nil {
-		fmt.Fprintf(errOut, "failed to remove label: %v\n", err)
-		return
+		return fmt.Errorf("failed to remove label: %w", err)
 	}
 
 	fmt.Fprintf(stdOut, "Removed label %q from server node %q\n", label, types.IPToString(node.WGConfig.Interface.Address.IP))
+
+	return nil
 }
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wireport/cmd/server/config"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/database/testutil"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/encryption/atrest"
+	"wireport/internal/nodes"
+	"wireport/internal/nodes/types"
+	"wireport/internal/publicservices"
+
+	"github.com/google/uuid"
+)
+
+// newTestGateway returns the commands service of a gateway whose configs are written to a temporary directory and
+// whose network apps restart commands do nothing
+func newTestGateway(t *testing.T) *LocalCommandsService {
+	dir := t.TempDir()
+	previousConfig := config.Config
+
+	config.Config.GatewayCADir = filepath.Join(dir, "ca")
+	config.Config.WireguardConfigPath = filepath.Join(dir, "wg0.conf")
+	config.Config.ResolvConfigPath = filepath.Join(dir, "resolv.conf")
+	config.Config.CaddyConfigPath = filepath.Join(dir, "Caddyfile")
+	config.Config.CoreDNSConfigPath = filepath.Join(dir, "Corefile")
+	config.Config.CoreDNSZoneFilePath = filepath.Join(dir, "wp.internal.db")
+	config.Config.CaddyACMECARootPath = filepath.Join(dir, "acme-ca-root.pem")
+	config.Config.CaddyCertificatesDir = filepath.Join(dir, "certificates")
+	config.Config.CaddyClientCAsDir = filepath.Join(dir, "client-cas")
+	config.Config.CaddyAccessLogsDir = ""
+	config.Config.WireguardRestartCommand = "true"
+	config.Config.CoreDNSRestartCommand = "true"
+
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+
+	t.Cleanup(func() {
+		config.Config = previousConfig
+		atrest.SetKeys(nil)
+	})
+
+	db := testutil.NewInMemoryDB(t, &types.Node{}, &publicservices.PublicService{}, &acme.Settings{}, &certificates.Certificate{},
+		&dnsrecords.Record{}, &dnsrecords.Settings{})
+
+	service := &LocalCommandsService{
+		NodesRepository:          nodes.NewRepository(db),
+		PublicServicesRepository: publicservices.NewRepository(db),
+		ACMERepository:           acme.NewRepository(db),
+		CertificatesRepository:   certificates.NewRepository(db),
+		DNSRecordsRepository:     dnsrecords.NewRepository(db),
+	}
+
+	return service
+}
+
+// saveTestNode stores a node of the role at the WireGuard address, the gateway has the other nodes as its peers
+func saveTestNode(t *testing.T, service *LocalCommandsService, role types.NodeRole, address string, name string, peers ...*types.Node) *types.Node {
+	t.Helper()
+
+	ip, network, _ := net.ParseCIDR(address)
+	wgPublicIP := "140.120.110.10"
+	wgPublicPort := uint16(51820)
+
+	node := &types.Node{
+		ID:           uuid.New().String(),
+		Role:         role,
+		Name:         name,
+		WGPrivateKey: string(role) + "-private-key",
+		WGPublicKey:  string(role) + "-public-key",
+		WGConfig: types.WGConfig{
+			Interface: types.WGConfigInterface{
+				Address:    types.IPNetMarshable{IPNet: net.IPNet{IP: ip, Mask: network.Mask}},
+				PrivateKey: string(role) + "-private-key",
+				DNS:        []types.IPNetMarshable{},
+			},
+			Peers: []types.WGConfigPeer{},
+		},
+		Labels: []string{},
+	}
+
+	if role == types.NodeRoleGateway {
+		node.WGPublicIP = &wgPublicIP
+		node.WGPublicPort = &wgPublicPort
+		node.WGConfig.Interface.ListenPort = &wgPublicPort
+		node.IsCurrentNode = true
+	}
+
+	if role == types.NodeRoleServer {
+		node.DockerSubnet = &types.IPNetMarshable{IPNet: net.IPNet{IP: net.ParseIP("172.20.0.0").To4(), Mask: net.CIDRMask(16, 32)}}
+	}
+
+	for _, peer := range peers {
+		node.WGConfig.Peers = append(node.WGConfig.Peers, types.WGConfigPeer{
+			PublicKey:  peer.WGPublicKey,
+			AllowedIPs: []types.IPNetMarshable{peer.WGConfig.Interface.Address},
+		})
+	}
+
+	if err := service.NodesRepository.SaveNode(node); err != nil {
+		t.Fatalf("failed to save %s node: %v", role, err)
+	}
+
+	return node
+}
+
+func TestServerRemove_RemovesTheServerFromTheGatewayConfigs(t *testing.T) {
+	service := newTestGateway(t)
+
+	serverNode := saveTestNode(t, service, types.NodeRoleServer, "10.0.0.2/24", "billing")
+	saveTestNode(t, service, types.NodeRoleGateway, "10.0.0.1/24", "", serverNode)
+
+	if err := service.applyDNSConfigs(false); err != nil {
+		t.Fatalf("failed to apply the gateway configs: %v", err)
+	}
+
+	if zone := readTestFile(t, config.Config.CoreDNSZoneFilePath); !strings.Contains(zone, "billing") {
+		t.Fatalf("expected the record of the server in the zone, got %s", zone)
+	}
+
+	if err := service.ServerRemove(io.Discard, io.Discard, serverNode.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if zone := readTestFile(t, config.Config.CoreDNSZoneFilePath); strings.Contains(zone, "billing") {
+		t.Errorf("expected the record of the server to be gone from the zone, got %s", zone)
+	}
+
+	if corefile := readTestFile(t, config.Config.CoreDNSConfigPath); strings.Contains(corefile, "billing."+config.Config.DNSZone) {
+		t.Errorf("expected the zone of the server to be gone from the Corefile, got %s", corefile)
+	}
+
+	if wireguardConfig := readTestFile(t, config.Config.WireguardConfigPath); strings.Contains(wireguardConfig, serverNode.WGPublicKey) {
+		t.Errorf("expected the peer of the server to be gone from the WireGuard config, got %s", wireguardConfig)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+
+	contents, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+
+	return string(contents)
+}
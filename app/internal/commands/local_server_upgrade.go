@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/dockerutils"
+	"wireport/internal/logger"
+	"wireport/internal/nodes/types"
+	"wireport/internal/rollouts"
+)
+
+// serverUpgradeResult is written by a failed container replacement into the data directory of the server, the restored
+// server reports the failure to the gateway
+type serverUpgradeResult struct {
+	RolloutID string `json:"rolloutId"`
+	Image     string `json:"image"`
+	Error     string `json:"error"`
+}
+
+func (s *LocalCommandsService) ServerUpgradeRollout(image string, label string, stdOut io.Writer, _ io.Writer) error {
+	servers, err := s.NodesRepository.GetNodesByRole(types.NodeRoleServer)
+
+	if err != nil {
+		return fmt.Errorf("failed to get server nodes: %w", err)
+	}
+
+	rollout, err := rollouts.NewRollout(image, label, servers)
+
+	if err != nil {
+		return err
+	}
+
+	if err = s.RolloutsRepository.Create(rollout); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "🔄 Upgrading %d server(s) to %s, one at a time:\n", len(rollout.Steps), rollout.Image)
+
+	for _, step := range rollout.Steps {
+		fmt.Fprintf(stdOut, "   %d. %s\n", step.Position, step.NodeName)
+	}
+
+	return nil
+}
+
+// ServerUpgradeCancel stops the running rollout, e.g. when it waits for a server that has been removed
+func (s *LocalCommandsService) ServerUpgradeCancel(stdOut io.Writer, _ io.Writer) error {
+	rollout, err := s.RolloutsRepository.GetRunning()
+
+	if err != nil {
+		return err
+	}
+
+	if rollout == nil {
+		return rollouts.ErrRolloutNotRunning
+	}
+
+	if err = rollout.Cancel(time.Now()); err != nil {
+		return err
+	}
+
+	if err = s.RolloutsRepository.Save(rollout); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "🛑 The upgrade to %s has been cancelled, the remaining servers have not been touched\n", rollout.Image)
+
+	return nil
+}
+
+// ServerUpgradeStatus returns the latest rollout, the current step fails first if its server has not reported back in time
+func (s *LocalCommandsService) ServerUpgradeStatus() (*rollouts.Rollout, error) {
+	rollout, err := s.RolloutsRepository.GetLatest()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rollout, s.expireRollout(rollout)
+}
+
+// ServerUpgradeAssignment returns the step of the server in the running rollout if it is the server's turn, nil otherwise
+func (s *LocalCommandsService) ServerUpgradeAssignment(nodeID string) (*rollouts.Assignment, error) {
+	rollout, err := s.RolloutsRepository.GetRunning()
+
+	if err != nil || rollout == nil {
+		return nil, err
+	}
+
+	if err = s.expireRollout(rollout); err != nil {
+		return nil, err
+	}
+
+	return rollout.AssignmentFor(nodeID), nil
+}
+
+func (s *LocalCommandsService) ServerUpgradeReport(nodeID string, rolloutID string, status rollouts.StepStatus, message string) error {
+	rollout, err := s.RolloutsRepository.GetRunning()
+
+	if err != nil {
+		return err
+	}
+
+	if rollout == nil || rollout.ID != rolloutID {
+		return fmt.Errorf("%w: rollout %s is not running", rollouts.ErrInvalidReport, rolloutID)
+	}
+
+	if err = rollout.Report(nodeID, status, message, time.Now()); err != nil {
+		return err
+	}
+
+	return s.RolloutsRepository.Save(rollout)
+}
+
+func (s *LocalCommandsService) expireRollout(rollout *rollouts.Rollout) error {
+	if !rollout.Expire(config.Config.ServerUpgradeStepTimeout, time.Now()) {
+		return nil
+	}
+
+	return s.RolloutsRepository.Save(rollout)
+}
+
+// ServerReplace runs in the one-off container started by the server picking up its upgrade step, see
+// dockerutils.StartServerReplacement
+func (s *LocalCommandsService) ServerReplace(containerName string, image string, rolloutID string, stdOut io.Writer, _ io.Writer) error {
+	fmt.Fprintf(stdOut, "Replacing container %s with image %s\n", containerName, image)
+
+	err := dockerutils.ReplaceServerContainer(containerName, image)
+
+	if err == nil {
+		fmt.Fprintf(stdOut, "Container %s has been replaced\n", containerName)
+		return nil
+	}
+
+	result, marshalErr := json.Marshal(serverUpgradeResult{
+		RolloutID: rolloutID,
+		Image:     image,
+		Error:     err.Error(),
+	})
+
+	if marshalErr == nil {
+		marshalErr = os.WriteFile(filepath.Join(config.Config.ServerUpgradeDataDir, config.Config.ServerUpgradeResultFile), result, 0600)
+	}
+
+	if marshalErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to save the upgrade result: %w", marshalErr))
+	}
+
+	return err
+}
+
+// reconcileServerUpgrade carries out the step of this server in a rollout started with 'server upgrade --all': the image
+// is pulled, the container is replaced by a one-off container of the new image, and the new server reports the success.
+// A failed replacement restores this container, which reports the failure
+func reconcileServerUpgrade(api *APICommandsService) bool {
+	log := reconcileLog.With(logger.KeyStep, "upgrade")
+
+	assignmentResponse, err := api.ServerUpgradeAssignment()
+
+	if err != nil {
+		log.Error("Failed to get the upgrade assignment", logger.Err(err))
+		return false
+	}
+
+	resultPath := filepath.Join(filepath.Dir(config.Config.DatabasePath), config.Config.ServerUpgradeResultFile)
+	assignment := assignmentResponse.Assignment
+
+	if assignment == nil {
+		_ = os.Remove(resultPath)
+		return true
+	}
+
+	log = log.With("rollout_id", assignment.RolloutID, "image", assignment.Image)
+
+	report := func(status rollouts.StepStatus, message string) bool {
+		if _, err := api.ServerUpgradeReport(assignment.RolloutID, status, message); err != nil {
+			log.Error("Failed to report the upgrade", "status", status, logger.Err(err))
+			return false
+		}
+
+		log.Info("Upgrade reported", "status", status, "message", message)
+
+		return true
+	}
+
+	image, err := dockerutils.GetRunningContainerImage()
+
+	if err != nil {
+		log.Error("Failed to get the image of the server container", logger.Err(err))
+		return false
+	}
+
+	if image == assignment.Image {
+		_ = os.Remove(resultPath)
+		return report(rollouts.StepStatusSucceeded, "")
+	}
+
+	if result, ok := readServerUpgradeResult(resultPath, assignment.RolloutID); ok {
+		if !report(rollouts.StepStatusFailed, result.Error) {
+			return false
+		}
+
+		_ = os.Remove(resultPath)
+
+		return true
+	}
+
+	if assignment.Status == rollouts.StepStatusUpgrading {
+		// the replacement is in progress, the gateway fails the step if it never finishes
+		return true
+	}
+
+	if err = dockerutils.PullImage(assignment.Image); err != nil {
+		return report(rollouts.StepStatusFailed, fmt.Sprintf("failed to pull %s: %v", assignment.Image, err))
+	}
+
+	if !report(rollouts.StepStatusUpgrading, "") {
+		return false
+	}
+
+	if err = dockerutils.StartServerReplacement(assignment.Image, assignment.RolloutID); err != nil {
+		return report(rollouts.StepStatusFailed, fmt.Sprintf("failed to start the container replacement: %v", err))
+	}
+
+	return true
+}
+
+func readServerUpgradeResult(path string, rolloutID string) (*serverUpgradeResult, bool) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, false
+	}
+
+	var result serverUpgradeResult
+
+	if err = json.Unmarshal(data, &result); err != nil || result.RolloutID != rolloutID {
+		return nil, false
+	}
+
+	return &result, true
+}
+
+// followRollout prints the progress of the rollout until it is finished, an error is returned if it has been halted
+func followRollout(stdOut io.Writer, getRollout func() (*rollouts.Rollout, error)) error {
+	printed := map[uint]rollouts.StepStatus{}
+
+	for {
+		rollout, err := getRollout()
+
+		if err != nil {
+			return err
+		}
+
+		for _, step := range rollout.Steps {
+			if step.Status == rollouts.StepStatusPending || printed[step.ID] == step.Status {
+				continue
+			}
+
+			printed[step.ID] = step.Status
+			printRolloutStep(stdOut, step)
+		}
+
+		if rollout.Finished() {
+			printRolloutSummary(stdOut, rollout)
+
+			switch rollout.Status {
+			case rollouts.StatusHalted:
+				return rollouts.ErrRolloutHalted
+			case rollouts.StatusCancelled:
+				return rollouts.ErrRolloutCancelled
+			}
+
+			return nil
+		}
+
+		time.Sleep(config.Config.ServerUpgradeFollowInterval)
+	}
+}
+
+func printRollout(stdOut io.Writer, rollout *rollouts.Rollout) {
+	fmt.Fprintf(stdOut, "SERVER UPGRADE %s\n", rollout.ID)
+	fmt.Fprintf(stdOut, "   Image:   %s\n", rollout.Image)
+
+	if rollout.Label != "" {
+		fmt.Fprintf(stdOut, "   Label:   %s\n", rollout.Label)
+	}
+
+	fmt.Fprintf(stdOut, "   Started: %s\n\n", rollout.CreatedAt.Format(time.RFC3339))
+
+	for _, step := range rollout.Steps {
+		printRolloutStep(stdOut, step)
+	}
+
+	printRolloutSummary(stdOut, rollout)
+}
+
+func printRolloutStep(stdOut io.Writer, step *rollouts.Step) {
+	switch step.Status {
+	case rollouts.StepStatusPending:
+		fmt.Fprintf(stdOut, "   ⏸️  %d. %s: waiting\n", step.Position, step.NodeName)
+	case rollouts.StepStatusUpgrading:
+		fmt.Fprintf(stdOut, "   ⏳ %d. %s: upgrading\n", step.Position, step.NodeName)
+	case rollouts.StepStatusSucceeded:
+		fmt.Fprintf(stdOut, "   ✅ %d. %s: upgraded\n", step.Position, step.NodeName)
+	case rollouts.StepStatusFailed:
+		fmt.Fprintf(stdOut, "   ❌ %d. %s: failed: %s\n", step.Position, step.NodeName, step.Message)
+	}
+}
+
+func printRolloutSummary(stdOut io.Writer, rollout *rollouts.Rollout) {
+	switch rollout.Status {
+	case rollouts.StatusCompleted:
+		fmt.Fprintf(stdOut, "\n🎉 All %d server(s) have been upgraded to %s\n", len(rollout.Steps), rollout.Image)
+	case rollouts.StatusHalted:
+		fmt.Fprintf(stdOut, "\n🛑 The upgrade has been halted, the remaining servers have not been touched\n")
+	case rollouts.StatusCancelled:
+		fmt.Fprintf(stdOut, "\n🛑 The upgrade has been cancelled, the remaining servers have not been touched\n")
+	default:
+		fmt.Fprintf(stdOut, "\n⏳ The upgrade is in progress\n")
+	}
+}
@@ -1,105 +1,236 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strings"
+	"wireport/internal/acme"
 	"wireport/internal/networkapps"
+	"wireport/internal/notifications"
 	"wireport/internal/publicservices"
 )
 
-func (s *LocalCommandsService) ServicePublish(stdOut io.Writer, errOut io.Writer, requestFromNodeID *string,
-	localProtocol string, localHost string, localPort uint16, publicProtocol string, publicHost string, publicPort uint16) {
-	err := s.PublicServicesRepository.Save(&publicservices.PublicService{
-		PublishedByNodeID: requestFromNodeID,
-		LocalProtocol:     localProtocol,
-		LocalHost:         localHost,
-		LocalPort:         localPort,
-		PublicProtocol:    publicProtocol,
-		PublicHost:        publicHost,
-		PublicPort:        publicPort,
-	})
+// ServicePublish adds the upstreams to the service on the public address, creating it if needed. The upstreams are saved
+// at once: if any of them is invalid, the service is left as it was
+func (s *LocalCommandsService) ServicePublish(stdOut io.Writer, _ io.Writer, requestFromNodeID *string,
+	upstreams []publicservices.PublicServiceUpstream, publicProtocol string, publicHost string, publicPort uint16, options publicservices.PublishOptions) error {
+	if len(upstreams) == 0 {
+		return fmt.Errorf("error creating public service: %w: at least one upstream is required", publicservices.ErrInvalidUpstream)
+	}
 
-	if err != nil {
-		fmt.Fprintf(errOut, "Error creating public service: %v\n", err)
-		return
+	service, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort)
+
+	if err != nil && !errors.Is(err, publicservices.ErrServiceNotFound) {
+		return fmt.Errorf("error getting public service: %w", err)
 	}
 
-	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+	if service == nil {
+		service = &publicservices.PublicService{
+			PublicProtocol: publicProtocol,
+			PublicHost:     publicHost,
+			PublicPort:     publicPort,
+		}
+	}
 
-	if err != nil {
-		fmt.Fprintf(errOut, "Error getting gateway node: %v\n", err)
-		return
+	// publishing an already published public address extends its upstream set (or the upstream set of the route)
+	addedUpstreams := []publicservices.PublicServiceUpstream{}
+
+	for _, upstream := range upstreams {
+		upstream.PublishedByNodeID = requestFromNodeID
+
+		upstreamAdded := false
+
+		if options.Route != nil {
+			upstreamAdded, err = service.AddRouteUpstream(*options.Route, upstream)
+
+			if err != nil {
+				return fmt.Errorf("error creating public service: %w", err)
+			}
+		} else {
+			upstreamAdded = service.AddUpstream(upstream)
+		}
+
+		if upstreamAdded {
+			addedUpstreams = append(addedUpstreams, upstream)
+		}
 	}
 
-	publicServices, err := s.PublicServicesRepository.GetAll()
+	options.Apply(service)
 
-	if err != nil {
-		fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
-		return
+	if service.IsWildcardHTTPS() {
+		acmeSettings, err := s.ACMERepository.Get()
+
+		if err != nil {
+			return fmt.Errorf("error getting ACME settings: %w", err)
+		}
+
+		if !acmeSettings.IsDNSChallengeEnabled() {
+			return fmt.Errorf("error creating public service: %w: wildcard certificates require the DNS-01 challenge, configure a DNS provider first with 'wireport gateway acme set --dns-provider <provider>'", acme.ErrInvalidSettings)
+		}
 	}
 
-	err = gatewayNode.SaveConfigs(publicServices, false)
+	if err = service.Validate(); err != nil {
+		return fmt.Errorf("error creating public service: %w", err)
+	}
+
+	err = s.PublicServicesRepository.Save(service)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Error saving gateway node configs: %v\n", err)
-		return
+		return fmt.Errorf("error creating public service: %w", err)
 	}
 
-	err = networkapps.RestartNetworkApps(false, false, true)
+	err = s.applyGatewayConfigs()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Error restarting services: %v\n", err)
-		return
+		return err
 	}
 
-	fmt.Fprintf(stdOut, "✅ Service %s://%s:%d is now published on\n\n\t\t%s://%s:%d\n\n\n", localProtocol, localHost, localPort, publicProtocol, publicHost, publicPort)
-}
+	for _, upstream := range addedUpstreams {
+		s.notify(notifications.NewEvent(notifications.EventServicePublished, map[string]string{
+			"public": fmt.Sprintf("%s://%s:%d", publicProtocol, publicHost, publicPort),
+			"local":  fmt.Sprintf("%s://%s:%d", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort),
+		}, "%s://%s:%d is published on %s://%s:%d", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort, publicProtocol, publicHost, publicPort))
+	}
 
-func (s *LocalCommandsService) ServiceUnpublish(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) {
-	serviceDeleted := s.PublicServicesRepository.Delete(publicProtocol, publicHost, publicPort)
+	for _, upstream := range upstreams {
+		if !slices.ContainsFunc(addedUpstreams, func(added publicservices.PublicServiceUpstream) bool {
+			return added.Matches(upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort)
+		}) {
+			fmt.Fprintf(stdOut, "✅ Service %s://%s:%d is already published on %s://%s:%d, settings updated\n", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort, publicProtocol, publicHost, publicPort)
+		}
+	}
 
-	if serviceDeleted {
-		gatewayNode, err := s.NodesRepository.GetGatewayNode()
+	if len(addedUpstreams) == 0 {
+		return nil
+	}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error getting gateway node: %v\n", err)
-			return
-		}
+	for _, upstream := range addedUpstreams {
+		fmt.Fprintf(stdOut, "✅ Service %s://%s:%d is now published on\n\n\t\t%s://%s:%d\n\n", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort, publicProtocol, publicHost, publicPort)
+	}
 
-		publicServices, err := s.PublicServicesRepository.GetAll()
+	if options.Route != nil {
+		fmt.Fprintf(stdOut, "Route: %s\n", options.Route.String())
+	} else if upstreamsCount := len(service.GetUpstreams()); upstreamsCount > 1 {
+		fmt.Fprintf(stdOut, "Traffic is load balanced across %d upstreams\n", upstreamsCount)
+	}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
-			return
-		}
+	fmt.Fprintf(stdOut, "\n")
+
+	return nil
+}
 
-		err = gatewayNode.SaveConfigs(publicServices, false)
+// ServiceUnpublish removes the whole service, or a single upstream of it if localHost is set
+func (s *LocalCommandsService) ServiceUnpublish(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16,
+	localProtocol string, localHost string, localPort uint16) error {
+	if localHost != "" {
+		service, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort)
 
 		if err != nil {
-			fmt.Fprintf(errOut, "Error saving gateway node configs: %v\n", err)
-			return
+			return fmt.Errorf("service %s://%s:%d was not found or was already unpublished earlier: %w", publicProtocol, publicHost, publicPort, err)
 		}
 
-		err = networkapps.RestartNetworkApps(false, false, true)
+		if !service.RemoveUpstream(localProtocol, localHost, localPort) {
+			return fmt.Errorf("upstream %s://%s:%d was not found in service %s://%s:%d: %w", localProtocol, localHost, localPort, publicProtocol, publicHost, publicPort, publicservices.ErrUpstreamNotFound)
+		}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error restarting services: %v\n", err)
-			return
+		if service.HasUpstreams() {
+			err = s.PublicServicesRepository.Save(service)
+
+			if err != nil {
+				return fmt.Errorf("error saving public service: %w", err)
+			}
+
+			err = s.applyGatewayConfigs()
+
+			if err != nil {
+				return err
+			}
+
+			s.notify(notifications.NewEvent(notifications.EventServiceUnpublished, map[string]string{
+				"public": fmt.Sprintf("%s://%s:%d", publicProtocol, publicHost, publicPort),
+				"local":  fmt.Sprintf("%s://%s:%d", localProtocol, localHost, localPort),
+			}, "upstream %s://%s:%d is removed from %s://%s:%d", localProtocol, localHost, localPort, publicProtocol, publicHost, publicPort))
+
+			fmt.Fprintf(stdOut, "✅ Upstream %s://%s:%d is removed from service %s://%s:%d\n", localProtocol, localHost, localPort, publicProtocol, publicHost, publicPort)
+			return nil
 		}
 
-		fmt.Fprintf(stdOut, "✅ Service %s://%s:%d is now unpublished\n", publicProtocol, publicHost, publicPort)
-	} else {
-		fmt.Fprintf(stdOut, "❌ Service %s://%s:%d was not found or was already unpublished earliner\n", publicProtocol, publicHost, publicPort)
+		// the last upstream was removed - unpublish the whole service
 	}
+
+	serviceDeleted := s.PublicServicesRepository.Delete(publicProtocol, publicHost, publicPort)
+
+	if !serviceDeleted {
+		return fmt.Errorf("service %s://%s:%d was not found or was already unpublished earlier: %w", publicProtocol, publicHost, publicPort, publicservices.ErrServiceNotFound)
+	}
+
+	err := s.applyGatewayConfigs()
+
+	if err != nil {
+		return err
+	}
+
+	s.notify(notifications.NewEvent(notifications.EventServiceUnpublished, map[string]string{
+		"public": fmt.Sprintf("%s://%s:%d", publicProtocol, publicHost, publicPort),
+	}, "%s://%s:%d is unpublished", publicProtocol, publicHost, publicPort))
+
+	fmt.Fprintf(stdOut, "✅ Service %s://%s:%d is now unpublished\n", publicProtocol, publicHost, publicPort)
+
+	return nil
 }
 
-func (s *LocalCommandsService) ServiceList(stdOut io.Writer, errOut io.Writer) {
+// applyGatewayConfigs regenerates gateway configs (published services, ACME settings) and restarts caddy
+func (s *LocalCommandsService) applyGatewayConfigs() error {
+	gatewayNode, err := s.NodesRepository.GetGatewayNode()
+
+	if err != nil {
+		return fmt.Errorf("error getting gateway node: %w", err)
+	}
+
+	gatewayConfigs, err := s.loadGatewayConfigs()
+
+	if err != nil {
+		return fmt.Errorf("failed to load gateway configs: %w", err)
+	}
+
+	err = gatewayNode.SaveConfigs(gatewayConfigs, false)
+
+	if err != nil {
+		return fmt.Errorf("error saving gateway node configs: %w", err)
+	}
+
+	err = networkapps.RestartNetworkApps(false, false, true)
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToRestartServices, err)
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) ServiceList(stdOut io.Writer, _ io.Writer) error {
 	services, err := s.PublicServicesRepository.GetAll()
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
-		return
+		return fmt.Errorf("%w: %w", ErrFailedToListServices, err)
+	}
+
+	health, err := s.PublicServicesRepository.GetAllHealth()
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrFailedToListServices, err)
+	}
+
+	formatUpstream := func(service *publicservices.PublicService, upstream publicservices.PublicServiceUpstream) string {
+		address := fmt.Sprintf("%s://%s:%d", upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort)
+
+		if upstreamHealth := publicservices.FindUpstreamHealth(health, service, upstream); upstreamHealth != nil {
+			return fmt.Sprintf("%s (%s)", address, upstreamHealth.String())
+		}
+
+		return address
 	}
 
 	fmt.Fprintf(stdOut, "PUBLIC\t->\tLOCAL\n")
@@ -107,95 +238,96 @@ func (s *LocalCommandsService) ServiceList(stdOut io.Writer, errOut io.Writer) {
 
 	if len(services) > 0 {
 		for _, service := range services {
-			fmt.Fprintf(stdOut, "%s://%s:%d\t->\t%s://%s:%d\n", service.PublicProtocol, service.PublicHost, service.PublicPort, service.LocalProtocol, service.LocalHost, service.LocalPort)
-		}
-	} else {
-		fmt.Fprintf(stdOut, "No services are published on the gateway.\nUse 'wireport service publish' to publish a new service.\n")
-	}
-}
+			upstreams := []string{}
 
-func (s *LocalCommandsService) ServiceParamNew(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) {
-	added := s.PublicServicesRepository.AddParam(publicProtocol, publicHost, publicPort, paramType, paramValue)
+			for _, upstream := range service.GetUpstreams() {
+				upstreams = append(upstreams, formatUpstream(service, upstream))
+			}
 
-	if added {
-		gatewayNode, err := s.NodesRepository.GetGatewayNode()
+			lbPolicy := ""
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error getting gateway node: %v\n", err)
-			return
-		}
+			if len(upstreams) > 1 && service.LBPolicy != publicservices.PublicServiceLBPolicyDefault {
+				lbPolicy = fmt.Sprintf(" [%s]", service.LBPolicy)
+			}
 
-		publicServices, err := s.PublicServicesRepository.GetAll()
+			if len(upstreams) == 0 {
+				upstreams = append(upstreams, "(no default upstream)")
+			}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
-			return
-		}
+			clientAuth := ""
 
-		err = gatewayNode.SaveConfigs(publicServices, false)
+			if service.ClientAuth != nil {
+				clientAuth = " 🔒 client certificate required"
+			}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error saving gateway node configs: %v\n", err)
-			return
-		}
+			fmt.Fprintf(stdOut, "%s://%s:%d\t->\t%s%s%s\n", service.PublicProtocol, service.PublicHost, service.PublicPort, strings.Join(upstreams, ", "), lbPolicy, clientAuth)
 
-		err = networkapps.RestartNetworkApps(false, false, true)
+			for _, route := range service.Routes {
+				routeUpstreams := []string{}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error restarting services: %v\n", err)
-			return
-		}
+				for _, upstream := range route.Upstreams {
+					routeUpstreams = append(routeUpstreams, formatUpstream(service, upstream))
+				}
 
-		fmt.Fprintf(stdOut, "✅ Parameter '%s' successfully added to service %s://%s:%d\n", paramValue, publicProtocol, publicHost, publicPort)
+				fmt.Fprintf(stdOut, "\t[%s]\t->\t%s\n", route.PublicServiceRouteMatcher.String(), strings.Join(routeUpstreams, ", "))
+			}
+		}
 	} else {
-		fmt.Fprintf(stdOut, "❌ Parameter '%s' was not added to service %s://%s:%d (probably already exists)\n", paramValue, publicProtocol, publicHost, publicPort)
+		fmt.Fprintf(stdOut, "No services are published on the gateway.\nUse 'wireport service publish' to publish a new service.\n")
 	}
+
+	return nil
 }
 
-func (s *LocalCommandsService) ServiceParamRemove(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) {
-	removed := s.PublicServicesRepository.RemoveParam(publicProtocol, publicHost, publicPort, paramType, paramValue)
+func (s *LocalCommandsService) ServiceParamNew(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) error {
+	if _, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort); err != nil {
+		return fmt.Errorf("error getting service %s://%s:%d: %w", publicProtocol, publicHost, publicPort, err)
+	}
 
-	if removed {
-		gatewayNode, err := s.NodesRepository.GetGatewayNode()
+	added := s.PublicServicesRepository.AddParam(publicProtocol, publicHost, publicPort, paramType, paramValue)
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error getting gateway node: %v\n", err)
-			return
-		}
+	if !added {
+		return fmt.Errorf("parameter '%s' was not added to service %s://%s:%d: %w", paramValue, publicProtocol, publicHost, publicPort, publicservices.ErrParamExists)
+	}
 
-		publicServices, err := s.PublicServicesRepository.GetAll()
+	err := s.applyGatewayConfigs()
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Failed to list services: %v\n", err)
-			return
-		}
+	if err != nil {
+		return err
+	}
 
-		err = gatewayNode.SaveConfigs(publicServices, false)
+	fmt.Fprintf(stdOut, "✅ Parameter '%s' successfully added to service %s://%s:%d\n", paramValue, publicProtocol, publicHost, publicPort)
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error saving gateway node configs: %v\n", err)
-			return
-		}
+	return nil
+}
 
-		err = networkapps.RestartNetworkApps(false, false, true)
+func (s *LocalCommandsService) ServiceParamRemove(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) error {
+	if _, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort); err != nil {
+		return fmt.Errorf("error getting service %s://%s:%d: %w", publicProtocol, publicHost, publicPort, err)
+	}
 
-		if err != nil {
-			fmt.Fprintf(errOut, "Error restarting services: %v\n", err)
-			return
-		}
+	removed := s.PublicServicesRepository.RemoveParam(publicProtocol, publicHost, publicPort, paramType, paramValue)
 
-		fmt.Fprintf(stdOut, "✅ Parameter '%s' successfully removed from service %s://%s:%d\n", paramValue, publicProtocol, publicHost, publicPort)
-	} else {
-		fmt.Fprintf(stdOut, "❌ Parameter '%s' was not removed from service %s://%s:%d (probably not found)\n", paramValue, publicProtocol, publicHost, publicPort)
+	if !removed {
+		return fmt.Errorf("parameter '%s' was not removed from service %s://%s:%d: %w", paramValue, publicProtocol, publicHost, publicPort, publicservices.ErrParamNotFound)
+	}
+
+	err := s.applyGatewayConfigs()
+
+	if err != nil {
+		return err
 	}
+
+	fmt.Fprintf(stdOut, "✅ Parameter '%s' successfully removed from service %s://%s:%d\n", paramValue, publicProtocol, publicHost, publicPort)
+
+	return nil
 }
 
-func (s *LocalCommandsService) ServiceParamList(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) {
+func (s *LocalCommandsService) ServiceParamList(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16) error {
 	service, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort)
 
 	if err != nil {
-		fmt.Fprintf(errOut, "Error getting service: %v\n", err)
-		return
+		return fmt.Errorf("error getting service: %w", err)
 	}
 
 	fmt.Fprintf(stdOut, "SERVICE PARAMS: %s://%s:%d\n", service.PublicProtocol, service.PublicHost, service.PublicPort)
@@ -210,4 +342,68 @@ func (s *LocalCommandsService) ServiceParamList(stdOut io.Writer, errOut io.Writ
 	}
 
 	fmt.Fprintf(stdOut, "\n")
+
+	return nil
+}
+
+func (s *LocalCommandsService) ServiceClientAuthEnable(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16, trustedCAPEM string) error {
+	service, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort)
+
+	if err != nil {
+		return fmt.Errorf("failed to get service %s://%s:%d: %w", publicProtocol, publicHost, publicPort, err)
+	}
+
+	service.ClientAuth = &publicservices.PublicServiceClientAuth{TrustedCAPEM: trustedCAPEM}
+
+	if err = service.Validate(); err != nil {
+		return fmt.Errorf("failed to enable client authentication: %w", err)
+	}
+
+	if service.ClientAuth.UsesWireportCA() {
+		if err = s.ensureServicesClientCA(); err != nil {
+			return fmt.Errorf("failed to generate public services client CA: %w", err)
+		}
+	}
+
+	if err = s.PublicServicesRepository.Save(service); err != nil {
+		return fmt.Errorf("failed to save service: %w", err)
+	}
+
+	if err = s.applyGatewayConfigs(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Client certificates are now required for %s://%s:%d\n", publicProtocol, publicHost, publicPort)
+
+	if service.ClientAuth.UsesWireportCA() {
+		fmt.Fprintf(stdOut, "Use 'wireport client cert issue <name>' to issue a certificate for a browser or device\n")
+	}
+
+	return nil
+}
+
+func (s *LocalCommandsService) ServiceClientAuthDisable(stdOut io.Writer, _ io.Writer, publicProtocol string, publicHost string, publicPort uint16) error {
+	service, err := s.PublicServicesRepository.Get(publicProtocol, publicHost, publicPort)
+
+	if err != nil {
+		return fmt.Errorf("failed to get service %s://%s:%d: %w", publicProtocol, publicHost, publicPort, err)
+	}
+
+	if service.ClientAuth == nil {
+		return fmt.Errorf("%w: client authentication is not enabled for %s://%s:%d", publicservices.ErrInvalidClientAuth, publicProtocol, publicHost, publicPort)
+	}
+
+	service.ClientAuth = nil
+
+	if err = s.PublicServicesRepository.Save(service); err != nil {
+		return fmt.Errorf("failed to save service: %w", err)
+	}
+
+	if err = s.applyGatewayConfigs(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdOut, "✅ Client certificates are no longer required for %s://%s:%d\n", publicProtocol, publicHost, publicPort)
+
+	return nil
 }
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/logger"
+	"wireport/internal/metrics"
+	"wireport/internal/nodes/types"
+	"wireport/internal/wg"
+)
+
+// startMetrics serves /metrics on the WireGuard address of the node, if enabled with METRICS_PORT
+func (s *LocalCommandsService) startMetrics(node *types.Node) {
+	if config.Config.MetricsPort == "" {
+		return
+	}
+
+	metrics.RegisterCollector(collectWireguardMetrics)
+
+	if node.Role == types.NodeRoleGateway {
+		metrics.RegisterCollector(s.collectGatewayMetrics)
+	}
+
+	go metrics.Serve(types.IPToString(node.WGConfig.Interface.Address.IP), config.Config.MetricsPort)
+}
+
+func collectWireguardMetrics() []metrics.Family {
+	peers, err := wg.GetPeerStats()
+
+	if err != nil {
+		logger.Error("Failed to get WireGuard peer stats: %v", err)
+		return nil
+	}
+
+	handshake := metrics.Family{Name: "wireport_wireguard_peer_last_handshake_seconds", Help: "Unix time of the last handshake with the WireGuard peer (0 if none)", Type: metrics.TypeGauge}
+	received := metrics.Family{Name: "wireport_wireguard_peer_receive_bytes_total", Help: "Bytes received from the WireGuard peer", Type: metrics.TypeCounter}
+	transmitted := metrics.Family{Name: "wireport_wireguard_peer_transmit_bytes_total", Help: "Bytes sent to the WireGuard peer", Type: metrics.TypeCounter}
+
+	for _, peer := range peers {
+		labels := []metrics.Label{
+			{Name: "interface", Value: peer.Interface},
+			{Name: "public_key", Value: peer.PublicKey},
+			{Name: "allowed_ips", Value: peer.AllowedIPs},
+		}
+
+		lastHandshake := 0.0
+
+		if !peer.LatestHandshake.IsZero() {
+			lastHandshake = float64(peer.LatestHandshake.Unix())
+		}
+
+		handshake.Samples = append(handshake.Samples, metrics.Sample{Labels: labels, Value: lastHandshake})
+		received.Samples = append(received.Samples, metrics.Sample{Labels: labels, Value: float64(peer.ReceiveBytes)})
+		transmitted.Samples = append(transmitted.Samples, metrics.Sample{Labels: labels, Value: float64(peer.TransmitBytes)})
+	}
+
+	return []metrics.Family{handshake, received, transmitted}
+}
+
+func (s *LocalCommandsService) collectGatewayMetrics() []metrics.Family {
+	nodesCount := metrics.Family{Name: "wireport_nodes", Help: "Nodes of the wireport network by role", Type: metrics.TypeGauge}
+
+	for _, role := range []types.NodeRole{types.NodeRoleGateway, types.NodeRoleServer, types.NodeRoleClient} {
+		count, err := s.NodesRepository.CountNodesByRole(role)
+
+		if err != nil {
+			logger.Error("Failed to count %s nodes: %v", role, err)
+			continue
+		}
+
+		nodesCount.Samples = append(nodesCount.Samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "role", Value: string(role)}},
+			Value:  float64(count),
+		})
+	}
+
+	families := []metrics.Family{nodesCount}
+
+	publicServices, err := s.PublicServicesRepository.GetAll()
+
+	if err != nil {
+		logger.Error("Failed to list public services: %v", err)
+	} else {
+		families = append(families, metrics.Family{
+			Name:    "wireport_public_services",
+			Help:    "Public services published on the gateway",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Value: float64(len(publicServices))}},
+		})
+	}
+
+	families = append(families, metrics.Family{
+		Name:    "wireport_join_requests",
+		Help:    "Pending join requests",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Value: float64(s.JoinRequestsRepository.CountAll())}},
+	})
+
+	return families
+}
+
+// observeReconcileStep records the duration and the outcome of a step of the server reconcile loop
+func observeReconcileStep(step string, startedAt time.Time, ok bool) {
+	metrics.ReconcileDuration.Observe(time.Since(startedAt).Seconds(), step)
+
+	if !ok {
+		metrics.ReconcileFailures.Inc(step)
+	}
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"net/http"
+	"time"
+	"wireport/internal/logger"
+	"wireport/internal/middleware"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a control API request; it is set by the client and generated by the gateway if missing
+const RequestIDHeader = "X-Request-ID"
+
+// LogRequests assigns a request ID to every control API request, passes a logger with the request attributes
+// to the handlers through the request context (see requestLogger) and logs every handled request
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+
+		if _, err := uuid.Parse(requestID); err != nil {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+
+		requestLog := logger.Default().With(logger.KeyRequestID, requestID, logger.KeyMethod, r.Method, logger.KeyPath, r.URL.Path)
+
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			// mTLS: the common name of the client certificate is the ID of the requesting node
+			requestLog = requestLog.With(logger.KeyNodeID, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+
+		recorder := middleware.NewStatusRecorder(w)
+		startedAt := time.Now()
+
+		next.ServeHTTP(recorder, r.WithContext(logger.NewContext(r.Context(), requestLog)))
+
+		requestLog.Info("Request handled",
+			logger.KeyComponent, logger.ComponentControlAPI,
+			logger.KeyStatus, recorder.Status,
+			logger.KeyDurationMs, time.Since(startedAt).Milliseconds(),
+			logger.KeyRemoteAddr, r.RemoteAddr,
+		)
+	})
+}
+
+// requestLogger returns the logger of the component for the request, with the request ID and the requesting node
+func requestLogger(r *http.Request, component string) *logger.Logger {
+	return logger.FromContext(r.Context()).With(logger.KeyComponent, component)
+}
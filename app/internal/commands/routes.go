@@ -3,17 +3,26 @@ package commands
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
 	"wireport/internal/commands/types"
+	"wireport/internal/controlapi"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/encryption/mtls"
 	"wireport/internal/joinrequests"
 	joinrequeststypes "wireport/internal/joinrequests/types"
 	"wireport/internal/logger"
 	"wireport/internal/networkapps"
 	nodes "wireport/internal/nodes"
 	node_types "wireport/internal/nodes/types"
+	"wireport/internal/notifications"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
 
 	"gorm.io/gorm"
 )
@@ -31,43 +40,130 @@ type Services struct {
 type CommandHandler func(stdOut, errOut *bytes.Buffer) error
 
 // common request validation
-func validateRequest(w http.ResponseWriter, r *http.Request, operation string, requestFromNodeID string) bool {
+func validateRequest(w http.ResponseWriter, r *http.Request, log *logger.Logger, requestFromNodeID string) bool {
 	if r.TLS == nil {
-		logger.Error("[%s] %s request is not over TLS; dropping request", r.Method, operation)
-		http.Error(w, "", http.StatusBadRequest)
+		log.Error("Request is not over TLS, dropping it")
+		writeErrorResponse(w, fmt.Errorf("%w: request is not over TLS", ErrInvalidRequest))
 		return false
 	}
 
 	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
-		logger.Error("[%s] Invalid method or content type: %v", r.Method, r.Method)
-		http.Error(w, "", http.StatusBadRequest)
+		log.Error("Invalid method or content type", "content_type", r.Header.Get("Content-Type"))
+		writeErrorResponse(w, fmt.Errorf("%w: POST with application/json content type is expected", ErrInvalidRequest))
 		return false
 	}
 
 	if requestFromNodeID == "" {
-		logger.Error("[%s] Can not identify request source node ID for %s", r.Method, operation)
-		http.Error(w, "", http.StatusBadRequest)
+		log.Error("Can not identify the requesting node")
+		writeErrorResponse(w, fmt.Errorf("%w: can not identify the requesting node", ErrForbidden))
 		return false
 	}
 
 	return true
 }
 
+// requireNodeRole returns ErrForbidden unless the requesting node has one of the roles
+func requireNodeRole(services *Services, requestFromNodeID string, roles ...node_types.NodeRole) (*node_types.Node, error) {
+	node, err := services.NodesRepository.GetByID(requestFromNodeID)
+
+	if err != nil || node == nil {
+		return nil, fmt.Errorf("%w: unknown node %s", ErrForbidden, requestFromNodeID)
+	}
+
+	if !slices.Contains(roles, node.Role) {
+		return nil, fmt.Errorf("%w: %s nodes can not run this command", ErrForbidden, node.Role)
+	}
+
+	return node, nil
+}
+
+// authorizeUnpublish lets clients unpublish anything, servers only remove the upstreams they published
+func authorizeUnpublish(services *Services, requestFromNodeID string, req *types.ServiceUnpublishRequestDTO) error {
+	node, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient, node_types.NodeRoleServer)
+
+	if err != nil || node.Role == node_types.NodeRoleClient {
+		return err
+	}
+
+	if req.LocalHost == "" {
+		return fmt.Errorf("%w: server can only remove its own upstreams, not the whole service", ErrForbidden)
+	}
+
+	service, err := services.PublicServicesRepository.Get(req.PublicProtocol, req.PublicHost, req.PublicPort)
+
+	if err != nil {
+		// reported by the command itself
+		return nil
+	}
+
+	for _, upstream := range service.GetAllUpstreams() {
+		if upstream.Matches(req.LocalProtocol, req.LocalHost, req.LocalPort) && !upstream.IsPublishedByNode(requestFromNodeID) {
+			return fmt.Errorf("%w: server can only remove its own upstreams", ErrForbidden)
+		}
+	}
+
+	return nil
+}
+
+// authorizePublish lets clients publish anything. Servers do not touch services with upstreams of clients (or published
+// manually) and change the settings only of the services whose upstreams they all published; they may still add their
+// upstreams to a service backed by other servers, see the wireport.service.* labels
+func authorizePublish(services *Services, requestFromNodeID string, req *types.ServicePublishRequestDTO) error {
+	node, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient, node_types.NodeRoleServer)
+
+	if err != nil || node.Role == node_types.NodeRoleClient {
+		return err
+	}
+
+	service, err := services.PublicServicesRepository.Get(req.PublicProtocol, req.PublicHost, req.PublicPort)
+
+	if err != nil {
+		// not published yet, other errors are reported by the command itself
+		return nil
+	}
+
+	ownsAllUpstreams := true
+
+	for _, upstream := range service.GetAllUpstreams() {
+		if upstream.IsPublishedByNode(requestFromNodeID) {
+			continue
+		}
+
+		ownsAllUpstreams = false
+
+		if upstream.PublishedByNodeID == nil {
+			return fmt.Errorf("%w: server can not publish to a service published manually", ErrForbidden)
+		}
+
+		publisher, err := services.NodesRepository.GetByID(*upstream.PublishedByNodeID)
+
+		if err == nil && publisher.Role == node_types.NodeRoleClient {
+			return fmt.Errorf("%w: server can not publish to a service published by a client", ErrForbidden)
+		}
+	}
+
+	if !ownsAllUpstreams && req.Options.Changes(service) {
+		return fmt.Errorf("%w: server can only change the settings of a service whose upstreams it published", ErrForbidden)
+	}
+
+	return nil
+}
+
 // a generic handler for requests (request body validation and parsing)
 func handleRequestWithBody[T any](w http.ResponseWriter, r *http.Request, handler func(string, *T, *bytes.Buffer, *bytes.Buffer) error,
 	customResponsePacker func(requestFromNodeID string, stdOut, errOut *bytes.Buffer) (any, error)) {
-	operation := r.URL.Path
+	log := requestLogger(r, logger.ComponentControlAPI)
 	requestFromNodeID := r.TLS.PeerCertificates[0].Subject.CommonName
 
-	if !validateRequest(w, r, operation, requestFromNodeID) {
+	if !validateRequest(w, r, log, requestFromNodeID) {
 		return
 	}
 
 	var requestDTO T
 	err := json.NewDecoder(r.Body).Decode(&requestDTO)
 	if err != nil {
-		logger.Error("[%s] [from node: %s] Failed to parse %s request: %v", r.Method, requestFromNodeID, operation, err)
-		http.Error(w, "", http.StatusBadRequest)
+		log.Error("Failed to parse request", logger.Err(err))
+		writeErrorResponse(w, requestBodyError(err))
 		return
 	}
 
@@ -77,8 +173,8 @@ func handleRequestWithBody[T any](w http.ResponseWriter, r *http.Request, handle
 	err = handler(requestFromNodeID, &requestDTO, stdOut, errOut)
 
 	if err != nil {
-		logger.Error("[%s] [from node: %s] Failed to execute %s: %v", r.Method, requestFromNodeID, operation, err)
-		http.Error(w, "", http.StatusBadRequest)
+		log.Error("Failed to execute command", logger.Err(err))
+		writeErrorResponse(w, err)
 		return
 	}
 
@@ -88,8 +184,8 @@ func handleRequestWithBody[T any](w http.ResponseWriter, r *http.Request, handle
 		response, err = customResponsePacker(requestFromNodeID, stdOut, errOut)
 
 		if err != nil {
-			logger.Error("[%s] [from node: %s] Failed to pack %s response: %v", r.Method, requestFromNodeID, operation, err)
-			http.Error(w, "", http.StatusInternalServerError)
+			log.Error("Failed to pack response", logger.Err(err))
+			writeErrorResponse(w, err)
 			return
 		}
 	} else {
@@ -101,15 +197,13 @@ func handleRequestWithBody[T any](w http.ResponseWriter, r *http.Request, handle
 
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		logger.Error("[%s] [from node: %s] Failed to encode %s response: %v", r.Method, requestFromNodeID, operation, err)
+		log.Error("Failed to encode response", logger.Err(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	if execResp, ok := response.(types.ExecResponseDTO); ok {
-		logger.Info("[%s] [from node: %s] %s response: stdout: %v, stderr: %v", r.Method, requestFromNodeID, operation, execResp.Stdout[:min(len(execResp.Stdout), 20)], execResp.Stderr[:min(len(execResp.Stderr), 20)])
-	} else {
-		logger.Info("[%s] [from node: %s] %s response: custom response type", r.Method, requestFromNodeID, operation)
+		log.Debug("Command executed", "stdout", execResp.Stdout[:min(len(execResp.Stdout), 20)], "stderr", execResp.Stderr[:min(len(execResp.Stderr), 20)])
 	}
 }
 
@@ -117,6 +211,12 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 	nodesRepository := nodes.NewRepository(db)
 	publicServicesRepository := publicservices.NewRepository(db)
 	joinRequestsRepository := joinrequests.NewRepository(db)
+	acmeRepository := acme.NewRepository(db)
+	certificatesRepository := certificates.NewRepository(db)
+	notificationsRepository := notifications.NewRepository(db)
+	dnsRecordsRepository := dnsrecords.NewRepository(db)
+	controlAPIRepository := controlapi.NewRepository(db)
+	rolloutsRepository := rollouts.NewRepository(db)
 
 	services := &Services{
 		NodesRepository:          nodesRepository,
@@ -127,6 +227,12 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 				NodesRepository:          nodesRepository,
 				PublicServicesRepository: publicServicesRepository,
 				JoinRequestsRepository:   joinRequestsRepository,
+				ACMERepository:           acmeRepository,
+				CertificatesRepository:   certificatesRepository,
+				NotificationsRepository:  notificationsRepository,
+				DNSRecordsRepository:     dnsRecordsRepository,
+				ControlAPIRepository:     controlAPIRepository,
+				RolloutsRepository:       rolloutsRepository,
 			},
 			NodesRepository:          nodesRepository,
 			PublicServicesRepository: publicServicesRepository,
@@ -137,26 +243,23 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 	// Server routes
 	mux.HandleFunc("/commands/server/new", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.ServerNewRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServerNew(stdOut, errOut, req.Force, req.Quiet, req.DockerSubnet)
-			return nil
+			return services.CommandsService.ServerNew(stdOut, errOut, req.Force, req.Quiet, req.DockerSubnet)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/server/remove", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ServerRemoveRequestDTO, stdOut, errOut *bytes.Buffer) error {
 			if req.NodeID != requestFromNodeID {
-				logger.Error("[%s] Server can only remove itself; node removal request came from a different node: requested node ID: %s, current node ID: %s", r.Method, req.NodeID, r.TLS.PeerCertificates[0].Subject.CommonName)
-				return ErrFailedToCreateServerNode
+				requestLogger(r, logger.ComponentControlAPI).Error("Server can only remove itself", "removed_node_id", req.NodeID)
+				return fmt.Errorf("%w: server can only remove itself", ErrForbidden)
 			}
-			services.CommandsService.ServerRemove(stdOut, errOut, req.NodeID)
-			return nil
+			return services.CommandsService.ServerRemove(stdOut, errOut, req.NodeID)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/server/list", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.ServerListRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServerList(&requestFromNodeID, stdOut, errOut)
-			return nil
+			return services.CommandsService.ServerList(&requestFromNodeID, stdOut, errOut)
 		}, func(requestFromNodeID string, stdOut, errOut *bytes.Buffer) (any, error) {
 			serversCount, err := services.NodesRepository.CountNodesByRole(node_types.NodeRoleServer)
 
@@ -174,56 +277,310 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 		})
 	})
 
+	// server upgrade routes, see 'server upgrade --all'
+	mux.HandleFunc("/commands/server/upgrade/rollout", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ServerUpgradeRolloutRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			// servers take part in rollouts, only clients start them
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			return services.CommandsService.LocalCommandsService.ServerUpgradeRollout(req.Image, req.Label, stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/server/upgrade/cancel", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.ServerUpgradeCancelRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			return services.CommandsService.LocalCommandsService.ServerUpgradeCancel(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/server/upgrade/status", func(w http.ResponseWriter, r *http.Request) {
+		var rollout *rollouts.Rollout
+
+		handleRequestWithBody(w, r, func(_ string, _ *types.ServerUpgradeStatusRequestDTO, _, _ *bytes.Buffer) error {
+			var err error
+			rollout, err = services.CommandsService.LocalCommandsService.ServerUpgradeStatus()
+			return err
+		}, func(_ string, _, _ *bytes.Buffer) (any, error) {
+			return types.ServerUpgradeStatusResponseDTO{Rollout: rollout}, nil
+		})
+	})
+
+	mux.HandleFunc("/commands/server/upgrade/assignment", func(w http.ResponseWriter, r *http.Request) {
+		var assignment *rollouts.Assignment
+
+		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.ServerUpgradeAssignmentRequestDTO, _, _ *bytes.Buffer) error {
+			var err error
+			assignment, err = services.CommandsService.LocalCommandsService.ServerUpgradeAssignment(requestFromNodeID)
+			return err
+		}, func(_ string, _, _ *bytes.Buffer) (any, error) {
+			return types.ServerUpgradeAssignmentResponseDTO{Assignment: assignment}, nil
+		})
+	})
+
+	mux.HandleFunc("/commands/server/upgrade/report", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ServerUpgradeReportRequestDTO, _, _ *bytes.Buffer) error {
+			return services.CommandsService.LocalCommandsService.ServerUpgradeReport(requestFromNodeID, req.RolloutID, req.Status, req.Message)
+		}, nil)
+	})
+
+	// ACME routes
+	mux.HandleFunc("/commands/acme/set", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ACMESetRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			return services.CommandsService.ACMESet(stdOut, errOut, req.Update)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/acme/show", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.ACMEShowRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ACMEShow(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/acme/reset", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.ACMEResetRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ACMEReset(stdOut, errOut)
+		}, nil)
+	})
+
+	// Certificate routes
+	mux.HandleFunc("/commands/cert/add", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.CertAddRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.CertAdd(stdOut, errOut, req.CertPEM, req.KeyPEM, req.Hosts)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/cert/list", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.CertListRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.CertList(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/cert/remove", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.CertRemoveRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.CertRemove(stdOut, errOut, req.ID)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/cert/status", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.CertStatusRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.CertStatus(stdOut, errOut)
+		}, nil)
+	})
+
+	// Notification routes
+	mux.HandleFunc("/commands/notify/add", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.NotifyAddRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			return services.CommandsService.NotifyAdd(stdOut, errOut, req.URL, req.Secret, req.Events)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/notify/list", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.NotifyListRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.NotifyList(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/notify/remove", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.NotifyRemoveRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.NotifyRemove(stdOut, errOut, req.ID)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/notify/test", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.NotifyTestRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.NotifyTest(stdOut, errOut, req.ID)
+		}, nil)
+	})
+
+	// DNS routes
+	mux.HandleFunc("/commands/dns/record/add", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.DNSRecordAddRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.DNSRecordAdd(stdOut, errOut, req.Name, req.Type, req.Value)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/dns/record/list", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.DNSRecordListRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.DNSRecordList(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/dns/record/remove", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.DNSRecordRemoveRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.DNSRecordRemove(stdOut, errOut, req.Name)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/gateway/api/set", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ControlAPISetRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			return services.CommandsService.ControlAPISet(stdOut, errOut, req.Update)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/gateway/api/show", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.ControlAPIShowRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ControlAPIShow(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/gateway/api/reset", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.ControlAPIResetRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ControlAPIReset(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/dns/config/set", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.DNSConfigSetRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			return services.CommandsService.DNSConfigSet(stdOut, errOut, req.Update)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/dns/config/show", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.DNSConfigShowRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.DNSConfigShow(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/dns/config/reset", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, _ *types.DNSConfigResetRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.DNSConfigReset(stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/node/rename", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.NodeRenameRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.NodeRename(stdOut, errOut, req.NodeIP, req.Name)
+		}, nil)
+	})
+
 	mux.HandleFunc("/commands/node/label/add", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.NodeLabelAddRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.NodeLabelAdd(stdOut, errOut, req.NodeIP, req.Label)
-			return nil
+			return services.CommandsService.NodeLabelAdd(stdOut, errOut, req.NodeIP, req.Label)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/node/label/remove", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.NodeLabelRemoveRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.NodeLabelRemove(stdOut, errOut, req.NodeIP, req.Label)
-			return nil
+			return services.CommandsService.NodeLabelRemove(stdOut, errOut, req.NodeIP, req.Label)
 		}, nil)
 	})
 
+	// Node routes
+	mux.HandleFunc("/commands/node/status", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.NodeStatusRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.NodeStatus(&requestFromNodeID, stdOut, errOut)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/doctor", func(w http.ResponseWriter, r *http.Request) {
+		var doctorResponse *types.DoctorResponseDTO
+
+		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.DoctorRequestDTO, _, _ *bytes.Buffer) error {
+			var err error
+			doctorResponse, err = services.CommandsService.LocalCommandsService.DoctorGateway(requestFromNodeID)
+			return err
+		}, func(_ string, _, _ *bytes.Buffer) (any, error) {
+			return doctorResponse, nil
+		})
+	})
+
 	// Client routes
 	mux.HandleFunc("/commands/client/new", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.ClientNewRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ClientNew(stdOut, errOut, req.JoinRequest, req.Quiet, req.Wait)
-			return nil
+			return services.CommandsService.ClientNew(stdOut, errOut, req.JoinRequest, req.Quiet, req.Wait)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/client/list", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.ClientListRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ClientList(&requestFromNodeID, stdOut, errOut)
-			return nil
+			return services.CommandsService.ClientList(&requestFromNodeID, stdOut, errOut)
 		}, nil)
 	})
 
+	mux.HandleFunc("/commands/client/cert/issue", func(w http.ResponseWriter, r *http.Request) {
+		var bundle []byte
+
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ClientCertIssueRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if _, err := requireNodeRole(services, requestFromNodeID, node_types.NodeRoleClient); err != nil {
+				return err
+			}
+
+			var err error
+			bundle, err = services.CommandsService.LocalCommandsService.ClientCertIssue(stdOut, errOut, req.Name, req.Password)
+			return err
+		}, func(_ string, stdOut, errOut *bytes.Buffer) (any, error) {
+			return types.ClientCertIssueResponseDTO{
+				ExecResponseDTO: types.ExecResponseDTO{
+					Stdout: strings.TrimSpace(stdOut.String()),
+					Stderr: strings.TrimSpace(errOut.String()),
+				},
+				Bundle: bundle,
+			}, nil
+		})
+	})
+
 	// Service routes
 	mux.HandleFunc("/commands/service/publish", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ServicePublishRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServicePublish(stdOut, errOut, &requestFromNodeID, req.LocalProtocol, req.LocalHost, req.LocalPort, req.PublicProtocol, req.PublicHost, req.PublicPort)
-			return nil
+			if err := authorizePublish(services, requestFromNodeID, req); err != nil {
+				requestLogger(r, logger.ComponentControlAPI).Error("Publish is not allowed", logger.Err(err))
+				return err
+			}
+
+			upstreams := req.Upstreams
+
+			if len(upstreams) == 0 {
+				upstreams = []publicservices.PublicServiceUpstream{{LocalProtocol: req.LocalProtocol, LocalHost: req.LocalHost, LocalPort: req.LocalPort}}
+			}
+
+			return services.CommandsService.ServicePublish(stdOut, errOut, &requestFromNodeID, upstreams, req.PublicProtocol, req.PublicHost, req.PublicPort, req.Options)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/service/unpublish", func(w http.ResponseWriter, r *http.Request) {
-		handleRequestWithBody(w, r, func(_ string, req *types.ServiceUnpublishRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServiceUnpublish(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort)
-			return nil
+		handleRequestWithBody(w, r, func(requestFromNodeID string, req *types.ServiceUnpublishRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			if err := authorizeUnpublish(services, requestFromNodeID, req); err != nil {
+				requestLogger(r, logger.ComponentControlAPI).Error("Unpublish is not allowed", logger.Err(err))
+				return err
+			}
+
+			return services.CommandsService.ServiceUnpublish(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort, req.LocalProtocol, req.LocalHost, req.LocalPort)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/service/list", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, _ *types.ServiceListRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServiceList(stdOut, errOut)
-			return nil
+			return services.CommandsService.ServiceList(stdOut, errOut)
 		}, func(_ string, stdOut, errOut *bytes.Buffer) (any, error) {
-			services, err := services.PublicServicesRepository.GetAll()
+			publicServices, err := services.PublicServicesRepository.GetAll()
+
+			if err != nil {
+				return nil, err
+			}
+
+			health, err := services.PublicServicesRepository.GetAllHealth()
 
 			if err != nil {
 				return nil, err
@@ -234,30 +591,58 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 					Stdout: strings.TrimSpace(stdOut.String()),
 					Stderr: strings.TrimSpace(errOut.String()),
 				},
-				Services: services,
+				Services: publicServices,
+				Health:   health,
 			}, nil
 		})
 	})
 
+	mux.HandleFunc("/commands/service/client-auth/enable", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.ServiceClientAuthEnableRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ServiceClientAuthEnable(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort, req.TrustedCAPEM)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/service/client-auth/disable", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.ServiceClientAuthDisableRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ServiceClientAuthDisable(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/service/stats", func(w http.ResponseWriter, r *http.Request) {
+		handleRequestWithBody(w, r, func(_ string, req *types.ServiceStatsRequestDTO, stdOut, errOut *bytes.Buffer) error {
+			return services.CommandsService.ServiceStats(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort)
+		}, nil)
+	})
+
+	mux.HandleFunc("/commands/service/logs", func(w http.ResponseWriter, r *http.Request) {
+		var serviceLogsResponse *types.ServiceLogsResponseDTO
+
+		handleRequestWithBody(w, r, func(_ string, req *types.ServiceLogsRequestDTO, _, _ *bytes.Buffer) error {
+			var err error
+			serviceLogsResponse, err = services.CommandsService.LocalCommandsService.ServiceLogs(req.PublicProtocol, req.PublicHost, req.PublicPort, req.Lines, req.Cursor)
+			return err
+		}, func(_ string, _, _ *bytes.Buffer) (any, error) {
+			return serviceLogsResponse, nil
+		})
+	})
+
 	// Service parameter routes
 	mux.HandleFunc("/commands/service/params/new", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.ServiceParamNewRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServiceParamNew(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort, req.ParamType, req.ParamValue)
-			return nil
+			return services.CommandsService.ServiceParamNew(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort, req.ParamType, req.ParamValue)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/service/params/remove", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.ServiceParamRemoveRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServiceParamRemove(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort, req.ParamType, req.ParamValue)
-			return nil
+			return services.CommandsService.ServiceParamRemove(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort, req.ParamType, req.ParamValue)
 		}, nil)
 	})
 
 	mux.HandleFunc("/commands/service/params/list", func(w http.ResponseWriter, r *http.Request) {
 		handleRequestWithBody(w, r, func(_ string, req *types.ServiceParamListRequestDTO, stdOut, errOut *bytes.Buffer) error {
-			services.CommandsService.ServiceParamList(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort)
-			return nil
+			return services.CommandsService.ServiceParamList(stdOut, errOut, req.PublicProtocol, req.PublicHost, req.PublicPort)
 		}, nil)
 	})
 
@@ -283,15 +668,32 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 		})
 	})
 
+	// renews the certificate of the requesting node, e.g. after the intermediate that signed it was revoked
+	mux.HandleFunc(NodeCertRenewPath, func(w http.ResponseWriter, r *http.Request) {
+		var bundle *mtls.FullClientBundle
+
+		handleRequestWithBody(w, r, func(requestFromNodeID string, _ *types.NodeCertRenewRequestDTO, _, _ *bytes.Buffer) error {
+			var err error
+
+			bundle, err = services.CommandsService.LocalCommandsService.NodeCertRenew(requestFromNodeID)
+
+			return err
+		}, func(_ string, _, _ *bytes.Buffer) (any, error) {
+			return types.NodeCertRenewResponseDTO{Bundle: bundle}, nil
+		})
+	})
+
 	// special case with different response format
 	mux.HandleFunc("/commands/join", func(w http.ResponseWriter, r *http.Request) {
+		log := requestLogger(r, logger.ComponentJoin)
+
 		if r.TLS == nil {
-			logger.Error("[%s] Join request is not over TLS; dropping request", r.Method)
-			http.Error(w, "", http.StatusBadRequest)
+			log.Error("Join request is not over TLS, dropping it")
+			writeErrorResponse(w, fmt.Errorf("%w: request is not over TLS", ErrInvalidRequest))
 			return
 		}
 
-		logger.Info("[%s] Join request from %s", r.Method, r.RemoteAddr)
+		log.Info("Join request received", logger.KeyRemoteAddr, r.RemoteAddr)
 
 		switch r.Method {
 		case http.MethodPost:
@@ -299,8 +701,8 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 			var joinRequestDto = types.JoinRequestDTO{}
 
 			if err := json.NewDecoder(r.Body).Decode(&joinRequestDto); err != nil {
-				logger.Error("[%s] Failed to parse request: %v", r.Method, err)
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				log.Error("Failed to parse join request", logger.Err(err))
+				writeErrorResponse(w, requestBodyError(err))
 				return
 			}
 
@@ -310,31 +712,37 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 			err := decryptedJoinRequest.FromBase64(joinRequestDto.JoinToken)
 
 			if err != nil {
-				logger.Error("[%s] Failed to decode join token: %v", r.Method, err)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Failed to decode join token", logger.Err(err))
+				writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToParseJoinToken, err))
 				return
 			}
 
 			joinRequestFromDB, err := services.JoinRequestsRepository.Get(decryptedJoinRequest.ID)
 
 			if err != nil {
-				logger.Error("[%s] Failed to get join request from DB: %v", r.Method, err)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Failed to get join request", logger.KeyJoinRequestID, decryptedJoinRequest.ID, logger.Err(err))
+				writeErrorResponse(w, fmt.Errorf("%w: join request not found or already used", ErrInvalidJoinRequest))
 				return
 			}
 
 			joinRequestFromDBBase64, err := joinRequestFromDB.ToBase64()
 
 			if err != nil {
-				logger.Error("[%s] Failed to encode join request from DB: %v", r.Method, err)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Failed to encode join request", logger.KeyJoinRequestID, decryptedJoinRequest.ID, logger.Err(err))
+				writeErrorResponse(w, fmt.Errorf("failed to encode join request: %v", err))
 				return
 			}
 
 			if joinRequestDto.JoinToken != *joinRequestFromDBBase64 {
 				// must be identical, otherwise it's a man-in-the-middle attack
-				logger.Error("[%s] Join request is invalid: %v", r.Method, err)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Join token does not match the join request", logger.KeyJoinRequestID, decryptedJoinRequest.ID)
+				writeErrorResponse(w, ErrInvalidJoinRequest)
+				return
+			}
+
+			if window := publicJoinWindow(r); window > 0 && time.Since(joinRequestFromDB.CreatedAt) > window {
+				log.Warn("Join token issued too long ago", logger.KeyJoinRequestID, decryptedJoinRequest.ID, "issued_at", joinRequestFromDB.CreatedAt)
+				writeErrorResponse(w, controlapi.ErrJoinWindowClosed)
 				return
 			}
 
@@ -349,49 +757,49 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 				serverNode, err = services.NodesRepository.CreateServer(decryptedJoinRequest.DockerSubnet)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToCreateServerNode, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to create server node", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToCreateServerNode, err))
 					return
 				}
 
 				gatewayNode, err = services.NodesRepository.GetGatewayNode()
 
 				if err != nil || gatewayNode == nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToGetGatewayNode, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to get gateway node", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToGetGatewayNode, err))
 					return
 				}
 
-				var publicServices []*publicservices.PublicService
-				publicServices, err = services.PublicServicesRepository.GetAll()
+				var gatewayConfigs *node_types.GatewayConfigs
+				gatewayConfigs, err = services.CommandsService.LocalCommandsService.loadGatewayConfigs()
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToListServices, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to list services", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToListServices, err))
 					return
 				}
 
-				err = gatewayNode.SaveConfigs(publicServices, false)
+				err = gatewayNode.SaveConfigs(gatewayConfigs, false)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToSaveGatewayConfigs, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to save gateway configs", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToSaveGatewayConfigs, err))
 					return
 				}
 
 				err = gatewayNode.GatewayCertBundle.RemoveClient(joinRequestFromDB.ID)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, "Failed to remove client from gateway cert bundle", err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to remove client from gateway cert bundle", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("failed to remove client from gateway cert bundle: %v", err))
 					return
 				}
 
 				err = services.NodesRepository.SaveNode(gatewayNode)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, "Failed to save gateway node", err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to save gateway node", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("failed to save gateway node: %v", err))
 					return
 				}
 
@@ -405,78 +813,87 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 				clientNode, err = services.NodesRepository.CreateClient()
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToCreateClientNode, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to create client node", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToCreateClientNode, err))
 					return
 				}
 
 				gatewayNode, err = services.NodesRepository.GetGatewayNode()
 
 				if err != nil || gatewayNode == nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToGetGatewayNode, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to get gateway node", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToGetGatewayNode, err))
 					return
 				}
 
-				logger.Info("[%s] Client node created from join request", r.Method)
+				log.Info("Client node created", "created_node_id", clientNode.ID)
 
-				var publicServices []*publicservices.PublicService
-				publicServices, err = services.PublicServicesRepository.GetAll()
+				var gatewayConfigs *node_types.GatewayConfigs
+				gatewayConfigs, err = services.CommandsService.LocalCommandsService.loadGatewayConfigs()
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToListServices, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to list services", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToListServices, err))
 					return
 				}
 
-				err = gatewayNode.SaveConfigs(publicServices, false)
+				err = gatewayNode.SaveConfigs(gatewayConfigs, false)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToSaveGatewayConfigs, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to save gateway configs", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToSaveGatewayConfigs, err))
 					return
 				}
 
 				err = gatewayNode.GatewayCertBundle.RemoveClient(joinRequestFromDB.ID)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, "Failed to remove client from gateway cert bundle", err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to remove client from gateway cert bundle", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("failed to remove client from gateway cert bundle: %v", err))
 					return
 				}
 
 				err = services.NodesRepository.SaveNode(gatewayNode)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, "Failed to save gateway node", err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to save gateway node", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("failed to save gateway node: %v", err))
 					return
 				}
 
 				err = networkapps.RestartNetworkApps(true, false, false)
 
 				if err != nil {
-					logger.Error("[%s] %v: %v", r.Method, ErrFailedToRestartServices, err)
-					http.Error(w, "", http.StatusBadRequest)
+					log.Error("Failed to restart services", logger.Err(err))
+					writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToRestartServices, err))
 					return
 				}
 
 				responsePayload.NodeConfig = clientNode
 			default:
-				logger.Error("[%s] %v: %v", r.Method, ErrInvalidJoinRequestRole, joinRequestFromDB.Role)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Invalid join request role", logger.KeyRole, joinRequestFromDB.Role)
+				writeErrorResponse(w, fmt.Errorf("%w: %v", ErrInvalidJoinRequestRole, joinRequestFromDB.Role))
 				return
 			}
 
 			err = services.JoinRequestsRepository.Delete(decryptedJoinRequest.ID)
 
 			if err != nil {
-				logger.Error("[%s] %v: %v", r.Method, ErrFailedToDeleteJoinRequest, err)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Failed to delete join request", logger.Err(err))
+				writeErrorResponse(w, fmt.Errorf("%w: %v", ErrFailedToDeleteJoinRequest, err))
 				return
 			}
 
-			logger.Info("[%s] Join request processed: %v", r.Method, decryptedJoinRequest.ID)
+			log.Info("Join request processed", logger.KeyJoinRequestID, decryptedJoinRequest.ID, logger.KeyRole, joinRequestFromDB.Role)
+
+			if serverNode != nil {
+				overlayIP := node_types.IPToString(serverNode.WGConfig.Interface.Address.IP)
+
+				services.CommandsService.LocalCommandsService.notify(notifications.NewEvent(notifications.EventServerJoined, map[string]string{
+					"nodeId":    serverNode.ID,
+					"overlayIp": overlayIP,
+				}, "server %s joined the network", overlayIP))
+			}
 
 			// 3. Send the response directly (no encryption)
 			w.Header().Set("Content-Type", "application/json")
@@ -484,13 +901,12 @@ func RegisterRoutes(mux *http.ServeMux, db *gorm.DB) {
 			err = json.NewEncoder(w).Encode(responsePayload)
 
 			if err != nil {
-				logger.Error("[%s] %v: %v", r.Method, "Failed to encode response", err)
-				http.Error(w, "", http.StatusBadRequest)
+				log.Error("Failed to encode response", logger.Err(err))
 				return
 			}
 		default:
-			logger.Error("[%s] Invalid method: %v", r.Method, r.Method)
-			http.Error(w, "", http.StatusBadRequest)
+			log.Error("Invalid method")
+			writeErrorResponse(w, fmt.Errorf("%w: POST is expected", ErrInvalidRequest))
 		}
 	})
 }
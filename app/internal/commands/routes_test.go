@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+	"wireport/internal/commands/types"
+	node_types "wireport/internal/nodes/types"
+	"wireport/internal/publicservices"
+)
+
+func TestAuthorizePublish(t *testing.T) {
+	local := newTestGateway(t)
+	services := &Services{NodesRepository: local.NodesRepository, PublicServicesRepository: local.PublicServicesRepository}
+
+	clientNode := saveTestNode(t, local, node_types.NodeRoleClient, "10.0.0.2/24", "laptop")
+	serverA := saveTestNode(t, local, node_types.NodeRoleServer, "10.0.0.3/24", "srv-a")
+	serverB := saveTestNode(t, local, node_types.NodeRoleServer, "10.0.0.4/24", "srv-b")
+
+	publish := func(nodeID string, publicHost string, localHost string, options publicservices.PublishOptions) {
+		t.Helper()
+
+		service, err := local.PublicServicesRepository.Get("https", publicHost, 443)
+
+		if err != nil {
+			service = &publicservices.PublicService{PublicProtocol: "https", PublicHost: publicHost, PublicPort: 443}
+		}
+
+		service.AddUpstream(publicservices.PublicServiceUpstream{PublishedByNodeID: &nodeID, LocalProtocol: "http", LocalHost: localHost, LocalPort: 80})
+		options.Apply(service)
+
+		if err = local.PublicServicesRepository.Save(service); err != nil {
+			t.Fatalf("failed to save service: %v", err)
+		}
+	}
+
+	publish(clientNode.ID, "app.example.com", "10.0.0.2", publicservices.PublishOptions{})
+	publish(serverA.ID, "shop.example.com", "shop", publicservices.PublishOptions{LBPolicy: publicservices.PublicServiceLBPolicyLeastConn})
+
+	tests := []struct {
+		name       string
+		nodeID     string
+		publicHost string
+		options    publicservices.PublishOptions
+		forbidden  bool
+	}{
+		{"client publishes to any service", clientNode.ID, "shop.example.com", publicservices.PublishOptions{ResetLBPolicy: true}, false},
+		{"server publishes a new service", serverB.ID, "new.example.com", publicservices.PublishOptions{}, false},
+		{"server changes its own service", serverA.ID, "shop.example.com", publicservices.PublishOptions{ResetLBPolicy: true}, false},
+		{"server joins a service of servers", serverB.ID, "shop.example.com", publicservices.PublishOptions{LBPolicy: publicservices.PublicServiceLBPolicyLeastConn}, false},
+		{"server changes a service of another server", serverB.ID, "shop.example.com", publicservices.PublishOptions{RemoveHealthCheck: true, LBPolicy: publicservices.PublicServiceLBPolicyIPHash}, true},
+		{"server publishes to a service of a client", serverB.ID, "app.example.com", publicservices.PublishOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizePublish(services, tt.nodeID, &types.ServicePublishRequestDTO{PublicProtocol: "https", PublicHost: tt.publicHost, PublicPort: 443, Options: tt.options})
+
+			if tt.forbidden != errors.Is(err, ErrForbidden) || (!tt.forbidden && err != nil) {
+				t.Errorf("expected forbidden %v, got %v", tt.forbidden, err)
+			}
+		})
+	}
+}
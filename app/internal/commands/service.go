@@ -1,18 +1,26 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"slices"
 	"strings"
 	"time"
+	"wireport/internal/accesslogs"
+	"wireport/internal/acme"
 	commandstypes "wireport/internal/commands/types"
+	"wireport/internal/controlapi"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/encryption/mtls"
 	"wireport/internal/joinrequests"
 	"wireport/internal/nodes"
 	"wireport/internal/nodes/types"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
 	"wireport/internal/ssh"
 
 	"gorm.io/gorm"
@@ -34,12 +42,13 @@ type RoleGroup struct {
 	Handler RoleHandler
 }
 
-// handles the common pattern of command execution with role validation
+// handles the common pattern of command execution with role validation;
+// the returned error is already printed to errOut, see ExitCode for mapping it to the CLI exit code
 func (s *Service) executeCommand(
 	stdOut io.Writer,
 	errOut io.Writer,
 	roleGroups []RoleGroup,
-) {
+) error {
 	currentNode, err := s.NodesRepository.GetCurrentNode()
 
 	if err != nil {
@@ -47,7 +56,7 @@ func (s *Service) executeCommand(
 		// otherwise, return the error
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
 			fmt.Fprintf(errOut, "Error: %v\n", err)
-			return
+			return err
 		}
 	}
 
@@ -68,16 +77,15 @@ func (s *Service) executeCommand(
 	// Check if current node is allowed to execute this command
 	if !s.isRoleAllowed(currentNode, allowedRoles) {
 		s.printRoleError(roleToExecute, errOut, allowedRoles)
-		return
+		return ErrRoleNotAllowed
 	}
 
 	var apiService *APICommandsService
 
 	if currentNode != nil {
-		apiService = &APICommandsService{
-			Host:             currentNode.GatewayPublicIP,
-			Port:             currentNode.GatewayPublicPort,
-			ClientCertBundle: currentNode.ClientCertBundle,
+		apiService = newAPICommandsService(currentNode)
+		apiService.SaveClientCertBundle = func(bundle *mtls.FullClientBundle) error {
+			return s.NodesRepository.UpdateClientCertBundle(currentNode.ID, bundle)
 		}
 	}
 
@@ -87,8 +95,8 @@ func (s *Service) executeCommand(
 			execResponseDTO, err := group.Handler(currentNode, apiService, &s.LocalCommandsService)
 
 			if err != nil {
-				fmt.Fprintf(errOut, "%v\n", err)
-				return
+				printError(errOut, err)
+				return err
 			}
 
 			if execResponseDTO != nil {
@@ -98,12 +106,27 @@ func (s *Service) executeCommand(
 
 				fmt.Fprintf(stdOut, "%s\n", execResponseDTO.Stdout)
 			}
-			return
+			return nil
 		}
 	}
 
 	// If no handler found, show error
 	s.printRoleError(roleToExecute, errOut, allowedRoles)
+
+	return ErrRoleNotAllowed
+}
+
+// printError prints the error of a command with its details, if any
+func printError(errOut io.Writer, err error) {
+	fmt.Fprintf(errOut, "❌ %v\n", err)
+
+	var commandError *CommandError
+
+	if errors.As(err, &commandError) {
+		for _, detail := range commandError.Details {
+			fmt.Fprintf(errOut, "   - %s\n", detail)
+		}
+	}
 }
 
 // if the current node role is allowed for the command
@@ -132,15 +155,15 @@ func (s *Service) printRoleError(currentNodeRole types.NodeRole, errOut io.Write
 
 // gateway commands
 
-func (s *Service) GatewayStart(gatewayPublicIP string, stdOut io.Writer, errOut io.Writer, gatewayStartConfigureOnly bool, router http.Handler) {
-	s.executeCommand(
+func (s *Service) GatewayStart(ctx context.Context, gatewayPublicIP string, stdOut io.Writer, errOut io.Writer, gatewayStartConfigureOnly bool, router http.Handler) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway, types.NodeRoleEmpty},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.GatewayStart(gatewayPublicIP, stdOut, errOut, gatewayStartConfigureOnly, router)
+					local.GatewayStart(ctx, gatewayPublicIP, stdOut, errOut, gatewayStartConfigureOnly, router)
 					return nil, nil
 				},
 			},
@@ -148,19 +171,32 @@ func (s *Service) GatewayStart(gatewayPublicIP string, stdOut io.Writer, errOut
 	)
 }
 
-func (s *Service) GatewayStatus(creds *ssh.Credentials, stdOut io.Writer) {
-	s.LocalCommandsService.GatewayStatus(creds, stdOut)
+func (s *Service) GatewayStatus(creds *ssh.Credentials, stdOut io.Writer) error {
+	statusErr := s.LocalCommandsService.GatewayStatus(creds, stdOut)
+
+	// certificate expiry is known to the gateway only: shown when running on the gateway itself or on a connected client
+	currentNode, err := s.NodesRepository.GetCurrentNode()
+
+	if err != nil || currentNode == nil || (currentNode.Role != types.NodeRoleGateway && currentNode.Role != types.NodeRoleClient) {
+		return statusErr
+	}
+
+	fmt.Fprintf(stdOut, "\n")
+
+	s.CertStatus(stdOut, stdOut)
+
+	return statusErr
 }
 
-func (s *Service) GatewayUp(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) GatewayUp(creds *ssh.Credentials, image string, imageTag string, caDir string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleEmpty},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.GatewayUp(creds, image, imageTag, stdOut, errOut)
+					local.GatewayUp(creds, image, imageTag, caDir, stdOut, errOut)
 					return nil, nil
 				},
 			},
@@ -168,8 +204,8 @@ func (s *Service) GatewayUp(creds *ssh.Credentials, image string, imageTag strin
 	)
 }
 
-func (s *Service) GatewayDown(creds *ssh.Credentials, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) GatewayDown(creds *ssh.Credentials, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
@@ -200,16 +236,21 @@ func (s *Service) GatewayDown(creds *ssh.Credentials, stdOut io.Writer, errOut i
 	)
 }
 
-func (s *Service) GatewayUpgrade(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) GatewayUpgrade(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleClient},
-				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.GatewayUpgrade(creds, image, imageTag, stdOut, errOut)
-					return nil, nil
+				Handler: func(_ *types.Node, api *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					// a request of this client through the new gateway proves that the mTLS certificates are still accepted
+					verify := func() error {
+						_, err := api.NodeStatus()
+						return err
+					}
+
+					return nil, local.GatewayUpgrade(creds, image, imageTag, verify, stdOut, errOut)
 				},
 			},
 		},
@@ -218,16 +259,15 @@ func (s *Service) GatewayUpgrade(creds *ssh.Credentials, image string, imageTag
 
 // server commands
 
-func (s *Service) ServerNew(stdOut io.Writer, errOut io.Writer, forceServerCreation bool, quietServerCreation bool, dockerSubnet string) {
-	s.executeCommand(
+func (s *Service) ServerNew(stdOut io.Writer, errOut io.Writer, forceServerCreation bool, quietServerCreation bool, dockerSubnet string) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServerNew(forceServerCreation, quietServerCreation, dockerSubnet, stdOut, errOut)
-					return nil, nil
+					return nil, local.ServerNew(forceServerCreation, quietServerCreation, dockerSubnet, stdOut, errOut)
 				},
 			},
 			{
@@ -241,8 +281,8 @@ func (s *Service) ServerNew(stdOut io.Writer, errOut io.Writer, forceServerCreat
 	)
 }
 
-func (s *Service) ServerRemove(stdOut io.Writer, errOut io.Writer, serverNodeID string) {
-	s.executeCommand(
+func (s *Service) ServerRemove(stdOut io.Writer, errOut io.Writer, serverNodeID string) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
@@ -256,16 +296,15 @@ func (s *Service) ServerRemove(stdOut io.Writer, errOut io.Writer, serverNodeID
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServerRemove(stdOut, errOut, serverNodeID)
-					return nil, nil
+					return nil, local.ServerRemove(stdOut, errOut, serverNodeID)
 				},
 			},
 		},
 	)
 }
 
-func (s *Service) ServerStart(stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) ServerStart(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
@@ -326,12 +365,12 @@ func (s *Service) ServerStart(stdOut io.Writer, errOut io.Writer) {
 	)
 }
 
-func (s *Service) ServerStatus(creds *ssh.Credentials, stdOut io.Writer) {
-	s.LocalCommandsService.ServerStatus(creds, stdOut)
+func (s *Service) ServerStatus(creds *ssh.Credentials, stdOut io.Writer) error {
+	return s.LocalCommandsService.ServerStatus(creds, stdOut)
 }
 
-func (s *Service) ServerUp(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer, dockerSubnet string) {
-	s.executeCommand(
+func (s *Service) ServerUp(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer, dockerSubnet string) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
@@ -346,8 +385,8 @@ func (s *Service) ServerUp(creds *ssh.Credentials, image string, imageTag string
 	)
 }
 
-func (s *Service) ServerDown(creds *ssh.Credentials, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) ServerDown(creds *ssh.Credentials, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
@@ -377,16 +416,15 @@ func (s *Service) ServerDown(creds *ssh.Credentials, stdOut io.Writer, errOut io
 	)
 }
 
-func (s *Service) ServerList(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) ServerList(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServerList(requestFromNodeID, stdOut, errOut)
-					return nil, nil
+					return nil, local.ServerList(requestFromNodeID, stdOut, errOut)
 				},
 			},
 			{
@@ -400,16 +438,15 @@ func (s *Service) ServerList(requestFromNodeID *string, stdOut io.Writer, errOut
 	)
 }
 
-func (s *Service) NodeLabelAdd(stdOut io.Writer, errOut io.Writer, nodeIP string, label string) {
-	s.executeCommand(
+func (s *Service) NodeLabelAdd(stdOut io.Writer, errOut io.Writer, nodeIP string, label string) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.NodeLabelAdd(nodeIP, label, stdOut, errOut)
-					return nil, nil
+					return nil, local.NodeLabelAdd(nodeIP, label, stdOut, errOut)
 				},
 			},
 			{
@@ -423,16 +460,15 @@ func (s *Service) NodeLabelAdd(stdOut io.Writer, errOut io.Writer, nodeIP string
 	)
 }
 
-func (s *Service) NodeLabelRemove(stdOut io.Writer, errOut io.Writer, nodeIP string, label string) {
-	s.executeCommand(
+func (s *Service) NodeLabelRemove(stdOut io.Writer, errOut io.Writer, nodeIP string, label string) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.NodeLabelRemove(nodeIP, label, stdOut, errOut)
-					return nil, nil
+					return nil, local.NodeLabelRemove(nodeIP, label, stdOut, errOut)
 				},
 			},
 			{
@@ -446,8 +482,8 @@ func (s *Service) NodeLabelRemove(stdOut io.Writer, errOut io.Writer, nodeIP str
 	)
 }
 
-func (s *Service) ServerUpgrade(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) ServerUpgrade(creds *ssh.Credentials, image string, imageTag string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
@@ -462,18 +498,131 @@ func (s *Service) ServerUpgrade(creds *ssh.Credentials, image string, imageTag s
 	)
 }
 
+// ServerUpgradeAll starts a rolling upgrade of the servers (all of them, or the ones with the label) orchestrated by the
+// gateway and follows its progress unless detached
+func (s *Service) ServerUpgradeAll(stdOut io.Writer, errOut io.Writer, image string, imageTag string, label string, detach bool) error {
+	imageRef := fmt.Sprintf("%s:%s", image, imageTag)
+
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					if err := local.ServerUpgradeRollout(imageRef, label, stdOut, errOut); err != nil || detach {
+						return nil, err
+					}
+
+					fmt.Fprintf(stdOut, "\n")
+
+					return nil, followRollout(stdOut, local.ServerUpgradeStatus)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServerUpgradeRollout(imageRef, label)
+
+					if err != nil || detach {
+						return &execResponseDTO, err
+					}
+
+					fmt.Fprintf(stdOut, "%s\n\n", execResponseDTO.Stdout)
+
+					return nil, followRollout(stdOut, func() (*rollouts.Rollout, error) {
+						statusResponseDTO, err := api.ServerUpgradeStatus()
+						return statusResponseDTO.Rollout, err
+					})
+				},
+			},
+		},
+	)
+}
+
+// ServerUpgradeCancel stops the running rolling upgrade of the servers
+func (s *Service) ServerUpgradeCancel(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServerUpgradeCancel(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServerUpgradeCancel()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ServerUpgradeStatus(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					rollout, err := local.ServerUpgradeStatus()
+
+					if err == nil {
+						printRollout(stdOut, rollout)
+					}
+
+					return nil, err
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					statusResponseDTO, err := api.ServerUpgradeStatus()
+
+					if err == nil {
+						printRollout(stdOut, statusResponseDTO.Rollout)
+					}
+
+					return nil, err
+				},
+			},
+		},
+	)
+}
+
+// ServerReplace is executed by the one-off replacement container, which has no wireport node of its own
+func (s *Service) ServerReplace(stdOut io.Writer, errOut io.Writer, containerName string, image string, rolloutID string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleEmpty},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServerReplace(containerName, image, rolloutID, stdOut, errOut)
+				},
+			},
+		},
+	)
+}
+
 // client commands
 
-func (s *Service) ClientNew(stdOut io.Writer, errOut io.Writer, joinRequestClientCreation bool, quietClientCreation bool, waitClientCreation bool) {
-	s.executeCommand(
+func (s *Service) ClientNew(stdOut io.Writer, errOut io.Writer, joinRequestClientCreation bool, quietClientCreation bool, waitClientCreation bool) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway, types.NodeRoleEmpty},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ClientNew(stdOut, errOut, joinRequestClientCreation, quietClientCreation, waitClientCreation)
-					return nil, nil
+					return nil, local.ClientNew(stdOut, errOut, joinRequestClientCreation, quietClientCreation, waitClientCreation)
 				},
 			},
 			{
@@ -487,16 +636,15 @@ func (s *Service) ClientNew(stdOut io.Writer, errOut io.Writer, joinRequestClien
 	)
 }
 
-func (s *Service) ClientList(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) ClientList(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ClientList(requestFromNodeID, stdOut, errOut)
-					return nil, nil
+					return nil, local.ClientList(requestFromNodeID, stdOut, errOut)
 				},
 			},
 			{
@@ -510,25 +658,21 @@ func (s *Service) ClientList(requestFromNodeID *string, stdOut io.Writer, errOut
 	)
 }
 
-// service commands
-
-func (s *Service) ServicePublish(stdOut io.Writer, errOut io.Writer, requestFromNodeID *string,
-	localProtocol string, localHost string, localPort uint16, publicProtocol string, publicHost string, publicPort uint16) {
-	s.executeCommand(
+func (s *Service) NodeStatus(requestFromNodeID *string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServicePublish(stdOut, errOut, requestFromNodeID, localProtocol, localHost, localPort, publicProtocol, publicHost, publicPort)
-					return nil, nil
+					return nil, local.NodeStatus(requestFromNodeID, stdOut, errOut)
 				},
 			},
 			{
 				Roles: []types.NodeRole{types.NodeRoleClient},
 				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					execResponseDTO, err := api.ServicePublish(localProtocol, localHost, localPort, publicProtocol, publicHost, publicPort)
+					execResponseDTO, err := api.NodeStatus()
 					return &execResponseDTO, err
 				},
 			},
@@ -536,70 +680,242 @@ func (s *Service) ServicePublish(stdOut io.Writer, errOut io.Writer, requestFrom
 	)
 }
 
-func (s *Service) ServiceUnpublish(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) {
-	s.executeCommand(
+// DBMigrateStatus runs without the database opened on start, on every node against its own database: the database is
+// not migrated, a newer schema is reported instead of refused
+func (s *Service) DBMigrateStatus(stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.DBMigrateStatus(stdOut, errOut)
+	})
+}
+
+// DBRekey is executed on every node against its own database
+func (s *Service) DBRekey(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway, types.NodeRoleServer, types.NodeRoleClient, types.NodeRoleEmpty},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DBRekey(stdOut, errOut)
+				},
+			},
+		},
+	)
+}
+
+// offline CA commands, run where the root CA is kept
+
+func (s *Service) CAInit(dir string, commonName string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway, types.NodeRoleServer, types.NodeRoleClient, types.NodeRoleEmpty},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CAInit(dir, commonName, stdOut, errOut)
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) CAIntermediate(dir string, outDir string, commonName string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway, types.NodeRoleServer, types.NodeRoleClient, types.NodeRoleEmpty},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CAIntermediate(dir, outDir, commonName, stdOut, errOut)
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) CARevoke(dir string, certPath string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway, types.NodeRoleServer, types.NodeRoleClient, types.NodeRoleEmpty},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CARevoke(dir, certPath, stdOut, errOut)
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) GatewayCAImport(dir string, stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServiceUnpublish(stdOut, errOut, publicProtocol, publicHost, publicPort)
-					return nil, nil
+					return nil, local.GatewayCAImport(dir, stdOut, errOut)
 				},
 			},
+		},
+	)
+}
+
+func (s *Service) GatewayCAStatus(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
 			{
-				Roles: []types.NodeRole{types.NodeRoleClient},
-				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					execResponseDTO, err := api.ServiceUnpublish(publicProtocol, publicHost, publicPort)
-					return &execResponseDTO, err
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.GatewayCAStatus(stdOut, errOut)
+				},
+			},
+		},
+	)
+}
+
+// profile and config commands run without a database, so without the role of a node: they manage the profiles of this
+// machine and show the configuration the other commands would run with
+
+func (s *Service) ProfileList(stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.ProfileList(stdOut)
+	})
+}
+
+func (s *Service) ProfileShow(name string, stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.ProfileShow(name, stdOut)
+	})
+}
+
+func (s *Service) ProfileUse(name string, stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.ProfileUse(name, stdOut)
+	})
+}
+
+func (s *Service) ProfileRename(from string, to string, stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.ProfileRename(from, to, stdOut)
+	})
+}
+
+func (s *Service) ProfileDelete(name string, stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.ProfileDelete(name, stdOut)
+	})
+}
+
+func (s *Service) ConfigShow(changedFlags []string, stdOut io.Writer, errOut io.Writer) error {
+	return executeWithoutDatabase(errOut, func() error {
+		return s.LocalCommandsService.ConfigShow(changedFlags, stdOut, errOut)
+	})
+}
+
+func executeWithoutDatabase(errOut io.Writer, handler func() error) error {
+	err := handler()
+
+	if err != nil {
+		printError(errOut, err)
+	}
+
+	return err
+}
+
+func (s *Service) Doctor(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient, types.NodeRoleServer},
+				Handler: func(currentNode *types.Node, api *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.Doctor(currentNode, api, stdOut, errOut)
 				},
 			},
 		},
 	)
 }
 
-func (s *Service) ServiceList(stdOut io.Writer, errOut io.Writer) {
-	s.executeCommand(
+func (s *Service) ClientCertIssue(stdOut io.Writer, errOut io.Writer, name string, password string, outputPath string) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServiceList(stdOut, errOut)
-					return nil, nil
+					bundle, err := local.ClientCertIssue(stdOut, errOut, name, password)
+
+					if err != nil {
+						return nil, err
+					}
+
+					return nil, writeClientCertBundle(stdOut, outputPath, bundle)
 				},
 			},
 			{
 				Roles: []types.NodeRole{types.NodeRoleClient},
 				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					serviceListResponseDTO, err := api.ServiceList()
-					return &serviceListResponseDTO.ExecResponseDTO, err
+					clientCertIssueResponseDTO, err := api.ClientCertIssue(name, password)
+
+					if err != nil {
+						return nil, err
+					}
+
+					if len(clientCertIssueResponseDTO.Bundle) > 0 {
+						// printed before the bundle is saved
+						fmt.Fprintf(stdOut, "%s\n", clientCertIssueResponseDTO.Stdout)
+						clientCertIssueResponseDTO.Stdout = ""
+
+						if err = writeClientCertBundle(stdOut, outputPath, clientCertIssueResponseDTO.Bundle); err != nil {
+							return nil, err
+						}
+					}
+
+					return &clientCertIssueResponseDTO.ExecResponseDTO, nil
 				},
 			},
 		},
 	)
 }
 
-// service params commands
+func writeClientCertBundle(stdOut io.Writer, outputPath string, bundle []byte) error {
+	if err := os.WriteFile(outputPath, bundle, 0600); err != nil {
+		return fmt.Errorf("failed to save client certificate bundle: %w", err)
+	}
+
+	fmt.Fprintf(stdOut, "📦 PKCS#12 bundle saved to %s, import it into the browser or keychain of the device\n", outputPath)
 
-func (s *Service) ServiceParamNew(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) {
-	s.executeCommand(
+	return nil
+}
+
+// service commands
+
+func (s *Service) ServicePublish(stdOut io.Writer, errOut io.Writer, requestFromNodeID *string,
+	upstreams []publicservices.PublicServiceUpstream, publicProtocol string, publicHost string, publicPort uint16, options publicservices.PublishOptions) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServiceParamNew(stdOut, errOut, publicProtocol, publicHost, publicPort, paramType, paramValue)
-					return nil, nil
+					return nil, local.ServicePublish(stdOut, errOut, requestFromNodeID, upstreams, publicProtocol, publicHost, publicPort, options)
 				},
 			},
 			{
 				Roles: []types.NodeRole{types.NodeRoleClient},
 				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					execResponseDTO, err := api.ServiceParamNew(publicProtocol, publicHost, publicPort, paramType, paramValue)
+					execResponseDTO, err := api.ServicePublish(upstreams, publicProtocol, publicHost, publicPort, options)
 					return &execResponseDTO, err
 				},
 			},
@@ -607,22 +923,21 @@ func (s *Service) ServiceParamNew(stdOut io.Writer, errOut io.Writer, publicProt
 	)
 }
 
-func (s *Service) ServiceParamRemove(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) {
-	s.executeCommand(
+func (s *Service) ServiceUnpublish(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, localProtocol string, localHost string, localPort uint16) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServiceParamRemove(stdOut, errOut, publicProtocol, publicHost, publicPort, paramType, paramValue)
-					return nil, nil
+					return nil, local.ServiceUnpublish(stdOut, errOut, publicProtocol, publicHost, publicPort, localProtocol, localHost, localPort)
 				},
 			},
 			{
 				Roles: []types.NodeRole{types.NodeRoleClient},
 				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					execResponseDTO, err := api.ServiceParamRemove(publicProtocol, publicHost, publicPort, paramType, paramValue)
+					execResponseDTO, err := api.ServiceUnpublish(publicProtocol, publicHost, publicPort, localProtocol, localHost, localPort)
 					return &execResponseDTO, err
 				},
 			},
@@ -630,41 +945,669 @@ func (s *Service) ServiceParamRemove(stdOut io.Writer, errOut io.Writer, publicP
 	)
 }
 
-func (s *Service) ServiceParamList(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) {
-	s.executeCommand(
+func (s *Service) ServiceList(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
 				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.ServiceParamList(stdOut, errOut, publicProtocol, publicHost, publicPort)
-					return nil, nil
+					return nil, local.ServiceList(stdOut, errOut)
 				},
 			},
 			{
 				Roles: []types.NodeRole{types.NodeRoleClient},
 				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					execResponseDTO, err := api.ServiceParamList(publicProtocol, publicHost, publicPort)
-					return &execResponseDTO, err
+					serviceListResponseDTO, err := api.ServiceList()
+					return &serviceListResponseDTO.ExecResponseDTO, err
 				},
 			},
 		},
 	)
 }
 
-// join command
+func (s *Service) ServiceStats(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServiceStats(stdOut, errOut, publicProtocol, publicHost, publicPort)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServiceStats(publicProtocol, publicHost, publicPort)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
 
-func (s *Service) Join(stdOut io.Writer, errOut io.Writer, joinToken string) {
-	s.executeCommand(
+func (s *Service) ServiceLogs(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, lines int, follow bool) error {
+	return s.executeCommand(
 		stdOut,
 		errOut,
 		[]RoleGroup{
 			{
-				Roles: []types.NodeRole{types.NodeRoleEmpty},
+				Roles: []types.NodeRole{types.NodeRoleGateway},
 				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
-					local.Join(stdOut, errOut, joinToken)
-					return nil, nil
+					return nil, printServiceLogs(stdOut, follow, func(cursor *accesslogs.Cursor) (*commandstypes.ServiceLogsResponseDTO, error) {
+						return local.ServiceLogs(publicProtocol, publicHost, publicPort, lines, cursor)
+					})
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, printServiceLogs(stdOut, follow, func(cursor *accesslogs.Cursor) (*commandstypes.ServiceLogsResponseDTO, error) {
+						serviceLogsResponseDTO, err := api.ServiceLogs(publicProtocol, publicHost, publicPort, lines, cursor)
+						return &serviceLogsResponseDTO, err
+					})
+				},
+			},
+		},
+	)
+}
+
+// service params commands
+
+func (s *Service) ServiceParamNew(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServiceParamNew(stdOut, errOut, publicProtocol, publicHost, publicPort, paramType, paramValue)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServiceParamNew(publicProtocol, publicHost, publicPort, paramType, paramValue)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ServiceParamRemove(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, paramType publicservices.PublicServiceParamType, paramValue string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServiceParamRemove(stdOut, errOut, publicProtocol, publicHost, publicPort, paramType, paramValue)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServiceParamRemove(publicProtocol, publicHost, publicPort, paramType, paramValue)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ServiceParamList(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServiceParamList(stdOut, errOut, publicProtocol, publicHost, publicPort)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServiceParamList(publicProtocol, publicHost, publicPort)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+// join command
+
+func (s *Service) Join(stdOut io.Writer, errOut io.Writer, joinToken string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleEmpty},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					if !local.Join(stdOut, errOut, joinToken) {
+						return nil, ErrFailedToJoinNetwork
+					}
+
+					return nil, nil
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ServiceClientAuthEnable(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16, trustedCAPEM string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServiceClientAuthEnable(stdOut, errOut, publicProtocol, publicHost, publicPort, trustedCAPEM)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServiceClientAuthEnable(publicProtocol, publicHost, publicPort, trustedCAPEM)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ServiceClientAuthDisable(stdOut io.Writer, errOut io.Writer, publicProtocol string, publicHost string, publicPort uint16) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ServiceClientAuthDisable(stdOut, errOut, publicProtocol, publicHost, publicPort)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ServiceClientAuthDisable(publicProtocol, publicHost, publicPort)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+// acme commands
+
+func (s *Service) ACMESet(stdOut io.Writer, errOut io.Writer, update acme.SettingsUpdate) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ACMESet(stdOut, errOut, update)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ACMESet(update)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ACMEShow(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ACMEShow(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ACMEShow()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ACMEReset(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ACMEReset(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ACMEReset()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+// certificate commands
+
+func (s *Service) CertAdd(stdOut io.Writer, errOut io.Writer, certPEM string, keyPEM string, hosts []string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CertAdd(stdOut, errOut, certPEM, keyPEM, hosts)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.CertAdd(certPEM, keyPEM, hosts)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) CertList(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CertList(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.CertList()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) CertRemove(stdOut io.Writer, errOut io.Writer, id string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CertRemove(stdOut, errOut, id)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.CertRemove(id)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) CertStatus(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.CertStatus(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.CertStatus()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) NotifyAdd(stdOut io.Writer, errOut io.Writer, url string, secret string, events []string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.NotifyAdd(stdOut, errOut, url, secret, events)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.NotifyAdd(url, secret, events)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) NotifyList(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.NotifyList(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.NotifyList()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) NotifyRemove(stdOut io.Writer, errOut io.Writer, id string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.NotifyRemove(stdOut, errOut, id)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.NotifyRemove(id)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) NotifyTest(stdOut io.Writer, errOut io.Writer, id string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.NotifyTest(stdOut, errOut, id)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.NotifyTest(id)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) DNSRecordAdd(stdOut io.Writer, errOut io.Writer, name string, recordType string, value string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DNSRecordAdd(stdOut, errOut, name, recordType, value)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.DNSRecordAdd(name, recordType, value)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) DNSRecordList(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DNSRecordList(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.DNSRecordList()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) DNSRecordRemove(stdOut io.Writer, errOut io.Writer, name string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DNSRecordRemove(stdOut, errOut, name)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.DNSRecordRemove(name)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ControlAPISet(stdOut io.Writer, errOut io.Writer, update controlapi.SettingsUpdate) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ControlAPISet(stdOut, errOut, update)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ControlAPISet(update)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ControlAPIShow(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ControlAPIShow(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ControlAPIShow()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) ControlAPIReset(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.ControlAPIReset(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.ControlAPIReset()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) DNSConfigSet(stdOut io.Writer, errOut io.Writer, update dnsrecords.SettingsUpdate) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DNSConfigSet(stdOut, errOut, update)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.DNSConfigSet(update)
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) DNSConfigShow(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DNSConfigShow(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.DNSConfigShow()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) DNSConfigReset(stdOut io.Writer, errOut io.Writer) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.DNSConfigReset(stdOut, errOut)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.DNSConfigReset()
+					return &execResponseDTO, err
+				},
+			},
+		},
+	)
+}
+
+func (s *Service) NodeRename(stdOut io.Writer, errOut io.Writer, nodeIP string, name string) error {
+	return s.executeCommand(
+		stdOut,
+		errOut,
+		[]RoleGroup{
+			{
+				Roles: []types.NodeRole{types.NodeRoleGateway},
+				Handler: func(_ *types.Node, _ *APICommandsService, local *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					return nil, local.NodeRename(stdOut, errOut, nodeIP, name)
+				},
+			},
+			{
+				Roles: []types.NodeRole{types.NodeRoleClient},
+				Handler: func(_ *types.Node, api *APICommandsService, _ *LocalCommandsService) (*commandstypes.ExecResponseDTO, error) {
+					execResponseDTO, err := api.NodeRename(nodeIP, name)
+					return &execResponseDTO, err
 				},
 			},
 		},
@@ -1,8 +1,14 @@
 package types
 
 import (
+	"wireport/internal/accesslogs"
+	"wireport/internal/acme"
+	"wireport/internal/controlapi"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/encryption/mtls"
 	node_types "wireport/internal/nodes/types"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
 )
 
 type ExecRequestDTO struct {
@@ -10,6 +16,13 @@ type ExecRequestDTO struct {
 	Args    []string `json:"args"`
 }
 
+// ErrorResponseDTO is returned by the control API with a non-2xx status when a command fails
+type ErrorResponseDTO struct {
+	Code    string   `json:"code"` // stable machine-readable code, e.g. service_not_found
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
 type ExecResponseDTO struct {
 	Stdout string `json:"stdout"`
 	Stderr string `json:"stderr"`
@@ -25,6 +38,38 @@ type ServerRemoveRequestDTO struct {
 	NodeID string `json:"nodeID"`
 }
 
+// server upgrades
+
+type ServerUpgradeRolloutRequestDTO struct {
+	Image string `json:"image"` // with tag
+	Label string `json:"label"`
+}
+
+type ServerUpgradeStatusRequestDTO struct {
+}
+
+type ServerUpgradeCancelRequestDTO struct {
+}
+
+type ServerUpgradeStatusResponseDTO struct {
+	ExecResponseDTO
+	Rollout *rollouts.Rollout `json:"rollout"`
+}
+
+type ServerUpgradeAssignmentRequestDTO struct {
+}
+
+type ServerUpgradeAssignmentResponseDTO struct {
+	ExecResponseDTO
+	Assignment *rollouts.Assignment `json:"assignment"` // nil unless it is the turn of the requesting server
+}
+
+type ServerUpgradeReportRequestDTO struct {
+	RolloutID string              `json:"rolloutId"`
+	Status    rollouts.StepStatus `json:"status"`
+	Message   string              `json:"message"`
+}
+
 type ClientNewRequestDTO struct {
 	JoinRequest bool `json:"joinRequest"`
 	Quiet       bool `json:"quiet"`
@@ -34,22 +79,72 @@ type ClientNewRequestDTO struct {
 type ClientListRequestDTO struct {
 }
 
+type ClientCertIssueRequestDTO struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type ClientCertIssueResponseDTO struct {
+	ExecResponseDTO
+	Bundle []byte `json:"bundle"` // PKCS#12
+}
+
 type ServerListRequestDTO struct {
 }
 
+type NodeStatusRequestDTO struct {
+}
+
+type DoctorRequestDTO struct {
+}
+
+type DoctorCheckStatus string
+
+const (
+	DoctorCheckStatusOK      DoctorCheckStatus = "ok"
+	DoctorCheckStatusWarning DoctorCheckStatus = "warning"
+	DoctorCheckStatusFailed  DoctorCheckStatus = "failed"
+)
+
+// DoctorCheckDTO is the result of a single connectivity check of 'wireport doctor'
+type DoctorCheckDTO struct {
+	Name   string            `json:"name"`
+	Status DoctorCheckStatus `json:"status"`
+	Fix    string            `json:"fix,omitempty"` // actionable hint, set if the check did not pass
+}
+
+// DoctorResponseDTO holds the checks the gateway runs for the services of the requesting node
+type DoctorResponseDTO struct {
+	Checks []DoctorCheckDTO `json:"checks"`
+	// container names / hostnames of the upstreams of the requesting node, resolved by the node itself through CoreDNS
+	UpstreamHosts []string `json:"upstreamHosts"`
+}
+
 type ServicePublishRequestDTO struct {
-	LocalProtocol  string `json:"localProtocol"`
-	LocalHost      string `json:"localHost"`
-	LocalPort      uint16 `json:"localPort"`
+	// all upstreams are added to the service at once, so that a failure leaves the service as it was
+	Upstreams []publicservices.PublicServiceUpstream `json:"upstreams,omitempty"`
+
+	// single upstream sent by nodes that predate Upstreams
+	LocalProtocol string `json:"localProtocol,omitempty"`
+	LocalHost     string `json:"localHost,omitempty"`
+	LocalPort     uint16 `json:"localPort,omitempty"`
+
 	PublicProtocol string `json:"publicProtocol"`
 	PublicHost     string `json:"publicHost"`
 	PublicPort     uint16 `json:"publicPort"`
+
+	Options publicservices.PublishOptions `json:"options"`
 }
 
 type ServiceUnpublishRequestDTO struct {
 	PublicProtocol string `json:"publicProtocol"`
 	PublicHost     string `json:"publicHost"`
 	PublicPort     uint16 `json:"publicPort"`
+
+	// optional: if set, only the given upstream is removed from the service
+	LocalProtocol string `json:"localProtocol,omitempty"`
+	LocalHost     string `json:"localHost,omitempty"`
+	LocalPort     uint16 `json:"localPort,omitempty"`
 }
 
 type ServiceParamNewRequestDTO struct {
@@ -76,7 +171,131 @@ type ServiceParamListRequestDTO struct {
 
 type ServiceListRequestDTO struct {
 	ExecResponseDTO
-	Services []*publicservices.PublicService `json:"services"`
+	Services []*publicservices.PublicService  `json:"services"`
+	Health   []*publicservices.UpstreamHealth `json:"health"` // last probe results of the upstreams
+}
+
+type ServiceClientAuthEnableRequestDTO struct {
+	PublicProtocol string `json:"publicProtocol"`
+	PublicHost     string `json:"publicHost"`
+	PublicPort     uint16 `json:"publicPort"`
+	TrustedCAPEM   string `json:"trustedCaPem,omitempty"`
+}
+
+type ServiceClientAuthDisableRequestDTO struct {
+	PublicProtocol string `json:"publicProtocol"`
+	PublicHost     string `json:"publicHost"`
+	PublicPort     uint16 `json:"publicPort"`
+}
+
+type ServiceStatsRequestDTO struct {
+	PublicProtocol string `json:"publicProtocol"`
+	PublicHost     string `json:"publicHost"`
+	PublicPort     uint16 `json:"publicPort"`
+}
+
+type ServiceLogsRequestDTO struct {
+	PublicProtocol string             `json:"publicProtocol"`
+	PublicHost     string             `json:"publicHost"`
+	PublicPort     uint16             `json:"publicPort"`
+	Lines          int                `json:"lines"`            // last lines returned without a cursor
+	Cursor         *accesslogs.Cursor `json:"cursor,omitempty"` // returns the lines logged since the previous request
+}
+
+type ServiceLogsResponseDTO struct {
+	Entries []*accesslogs.Entry `json:"entries"`
+	Cursor  *accesslogs.Cursor  `json:"cursor"`
+}
+
+// acme
+
+type ACMESetRequestDTO struct {
+	Update acme.SettingsUpdate `json:"update"`
+}
+
+type ACMEShowRequestDTO struct {
+}
+
+type ACMEResetRequestDTO struct {
+}
+
+// certificates
+
+type CertAddRequestDTO struct {
+	CertPEM string   `json:"certPem"`
+	KeyPEM  string   `json:"keyPem"`
+	Hosts   []string `json:"hosts"`
+}
+
+type CertListRequestDTO struct {
+}
+
+type CertRemoveRequestDTO struct {
+	ID string `json:"id"`
+}
+
+type CertStatusRequestDTO struct {
+}
+
+// notifications
+
+type NotifyAddRequestDTO struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+type NotifyListRequestDTO struct {
+}
+
+type NotifyRemoveRequestDTO struct {
+	ID string `json:"id"`
+}
+
+type NotifyTestRequestDTO struct {
+	ID string `json:"id"`
+}
+
+// control api
+
+type ControlAPISetRequestDTO struct {
+	Update controlapi.SettingsUpdate `json:"update"`
+}
+
+type ControlAPIShowRequestDTO struct {
+}
+
+type ControlAPIResetRequestDTO struct {
+}
+
+// dns
+
+type DNSRecordAddRequestDTO struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type DNSRecordListRequestDTO struct {
+}
+
+type DNSRecordRemoveRequestDTO struct {
+	Name string `json:"name"`
+}
+
+type DNSConfigSetRequestDTO struct {
+	Update dnsrecords.SettingsUpdate `json:"update"`
+}
+
+type DNSConfigShowRequestDTO struct {
+}
+
+type DNSConfigResetRequestDTO struct {
+}
+
+type NodeRenameRequestDTO struct {
+	NodeIP string `json:"nodeIp"`
+	Name   string `json:"name"`
 }
 
 // join requests
@@ -106,6 +325,13 @@ type NodeConfigResponseDTO struct {
 	NodeConfig *node_types.Node `json:"node"`
 }
 
+type NodeCertRenewRequestDTO struct {
+}
+
+type NodeCertRenewResponseDTO struct {
+	Bundle *mtls.FullClientBundle `json:"bundle"`
+}
+
 type NodeLabelAddRequestDTO struct {
 	NodeIP string `json:"nodeIP"`
 	Label  string `json:"label"`
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"net/http"
+	"sync"
+	"wireport/internal/logger"
+	"wireport/internal/nodes"
+	"wireport/version"
+
+	"gorm.io/gorm"
+)
+
+// NegotiateVersions rejects the control API requests of callers speaking a protocol the gateway cannot serve, marks the
+// responses to callers running another build with a warning and records the build every node has reported
+func NegotiateVersions(next http.Handler, db *gorm.DB) http.Handler {
+	nodesRepository := nodes.NewRepository(db)
+
+	var (
+		mu       sync.Mutex
+		reported = map[string]string{} // node ID -> last recorded build, spares a write per request
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := requestLogger(r, logger.ComponentControlAPI)
+		caller := version.PeerFromHeaders(r.Header)
+
+		version.SetHeaders(w.Header())
+
+		warning, err := version.CheckCaller(caller)
+
+		if err != nil {
+			log.Warn("Request refused, incompatible wireport versions", "caller_version", caller.Version, "caller_protocol", caller.Protocol)
+			writeErrorResponse(w, err)
+			return
+		}
+
+		if warning != "" {
+			log.Debug("Request from another wireport build", "caller_version", caller.Version, "caller_protocol", caller.Protocol)
+			w.Header().Set(version.HeaderWarning, warning)
+		}
+
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			nodeID := r.TLS.PeerCertificates[0].Subject.CommonName
+
+			mu.Lock()
+
+			if reported[nodeID] != caller.Version {
+				if err = nodesRepository.UpdateVersion(nodeID, caller.Version); err != nil {
+					log.Error("Failed to record the wireport version of the node", logger.Err(err))
+				} else {
+					log.Info("Node reported its wireport version", "version", caller.Version, "protocol", caller.Protocol)
+					reported[nodeID] = caller.Version
+				}
+			}
+
+			mu.Unlock()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+var gatewayWarnings sync.Map
+
+// warnAboutGatewayVersion logs the version warning of the gateway once per process, see NegotiateVersions
+func warnAboutGatewayVersion(header http.Header) {
+	warning := header.Get(version.HeaderWarning)
+
+	if warning == "" {
+		return
+	}
+
+	if _, warned := gatewayWarnings.LoadOrStore(warning, true); !warned {
+		logger.Warn("%s", warning)
+	}
+}
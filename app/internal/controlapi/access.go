@@ -0,0 +1,96 @@
+package controlapi
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// JoinPath is the only route served on the public address of the gateway in the overlay-only mode
+const JoinPath = "/commands/join"
+
+// JoinRateLimitPeriod is the period the join rate limit is counted over
+const JoinRateLimitPeriod = time.Minute
+
+// IsOverlayConnection reports whether the connection came in through the WireGuard network: it was accepted
+// on the WireGuard address of the gateway from an address of the same network
+func IsOverlayConnection(localAddr net.Addr, remoteAddr string, gatewayWGIP net.IP) bool {
+	localIP := addrIP(localAddr)
+	remoteIP := hostIP(remoteAddr)
+
+	if localIP == nil || remoteIP == nil || gatewayWGIP == nil || !localIP.Equal(gatewayWGIP) {
+		return false
+	}
+
+	// nodes get addresses of the /24 network of the gateway
+	overlay := net.IPNet{IP: gatewayWGIP.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+
+	return overlay.Contains(remoteIP)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case nil:
+		return nil
+	}
+
+	return hostIP(addr.String())
+}
+
+// hostIP returns the IP of a host:port address, e.g. the remote address of a request
+func hostIP(address string) net.IP {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil
+	}
+
+	return net.ParseIP(host)
+}
+
+// JoinLimiter counts join requests per client IP over a sliding JoinRateLimitPeriod
+type JoinLimiter struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+}
+
+func NewJoinLimiter() *JoinLimiter {
+	return &JoinLimiter{requests: map[string][]time.Time{}}
+}
+
+// Allow records a join request from the IP and reports whether it is within the limit; limit 0 allows all requests
+func (l *JoinLimiter) Allow(ip string, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// forget requests of all IPs outside of the period, so the map does not grow with every scanner
+	for key, times := range l.requests {
+		recent := times[:0]
+
+		for _, t := range times {
+			if now.Sub(t) < JoinRateLimitPeriod {
+				recent = append(recent, t)
+			}
+		}
+
+		if len(recent) == 0 {
+			delete(l.requests, key)
+		} else {
+			l.requests[key] = recent
+		}
+	}
+
+	if len(l.requests[ip]) >= limit {
+		return false
+	}
+
+	l.requests[ip] = append(l.requests[ip], now)
+
+	return true
+}
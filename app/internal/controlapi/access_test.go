@@ -0,0 +1,60 @@
+package controlapi
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIsOverlayConnection(t *testing.T) {
+	gatewayWGIP := net.ParseIP("10.0.0.1")
+
+	tests := []struct {
+		name       string
+		localAddr  net.Addr
+		remoteAddr string
+		expected   bool
+	}{
+		{"client over the tunnel", &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4060}, "10.0.0.4:51000", true},
+		{"public address", &net.TCPAddr{IP: net.ParseIP("172.17.0.2"), Port: 4060}, "203.0.113.7:51000", false},
+		{"outside address to the overlay address", &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4060}, "203.0.113.7:51000", false},
+		{"no local address", nil, "10.0.0.4:51000", false},
+	}
+
+	for _, test := range tests {
+		if actual := IsOverlayConnection(test.localAddr, test.remoteAddr, gatewayWGIP); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+
+	if IsOverlayConnection(&net.TCPAddr{IP: net.ParseIP("10.0.0.1")}, "10.0.0.4:51000", nil) {
+		t.Errorf("expected no overlay connection without the WireGuard address of the gateway")
+	}
+}
+
+func TestJoinLimiter_Allow(t *testing.T) {
+	limiter := NewJoinLimiter()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("203.0.113.7", 3, now) {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	if limiter.Allow("203.0.113.7", 3, now) {
+		t.Errorf("expected the 4th request within a minute to be refused")
+	}
+
+	if !limiter.Allow("198.51.100.9", 3, now) {
+		t.Errorf("expected requests from other IPs to be allowed")
+	}
+
+	if !limiter.Allow("203.0.113.7", 3, now.Add(JoinRateLimitPeriod)) {
+		t.Errorf("expected requests to be allowed again after the period")
+	}
+
+	if !limiter.Allow("203.0.113.7", 0, now) {
+		t.Errorf("expected no limit with 0")
+	}
+}
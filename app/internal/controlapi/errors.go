@@ -0,0 +1,10 @@
+package controlapi
+
+import "errors"
+
+var (
+	ErrInvalidSettings  = errors.New("invalid control API settings")
+	ErrOverlayOnly      = errors.New("the control API is only served over the WireGuard network, make sure the tunnel to the gateway is up")
+	ErrJoinRateLimited  = errors.New("too many join requests, try again in a minute")
+	ErrJoinWindowClosed = errors.New("the join token has expired, issue a new one")
+)
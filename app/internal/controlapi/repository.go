@@ -0,0 +1,47 @@
+package controlapi
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Get returns the control API settings, or the default ones if they were never set
+func (r *Repository) Get() (*Settings, error) {
+	var settings Settings
+
+	err := r.db.Where("id = ?", SettingsID).First(&settings).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultSettings(), nil
+		}
+
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (r *Repository) Save(settings *Settings) error {
+	settings.ID = SettingsID
+
+	if settings.CreatedAt.IsZero() {
+		settings.CreatedAt = time.Now()
+	}
+
+	return r.db.Save(settings).Error
+}
+
+func (r *Repository) Reset() error {
+	return r.db.Where("id = ?", SettingsID).Delete(&Settings{}).Error
+}
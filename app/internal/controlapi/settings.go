@@ -0,0 +1,67 @@
+package controlapi
+
+import (
+	"fmt"
+	"time"
+)
+
+// SettingsID is the primary key of the single row of gateway-wide control API settings
+const SettingsID = "default"
+
+// Settings define who may reach the control API of the gateway on its public address
+type Settings struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	// all routes but /commands/join are served on the WireGuard address of the gateway only
+	OverlayOnly bool `gorm:"not null"`
+	// join requests per minute accepted from a single IP on the public address, 0 for no limit
+	JoinRateLimit int `gorm:"not null;default:0"`
+	// join tokens are accepted on the public address for this long after they were issued, 0 for no limit
+	JoinWindow time.Duration `gorm:"not null;default:0"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+// TableName keeps the settings apart from the other gateway-wide settings, all types are named Settings
+func (Settings) TableName() string {
+	return "control_api_settings"
+}
+
+// DefaultSettings are the settings of a gateway that was never configured: all routes on all addresses
+func DefaultSettings() *Settings {
+	return &Settings{ID: SettingsID}
+}
+
+func (s *Settings) Validate() error {
+	if s.JoinRateLimit < 0 {
+		return fmt.Errorf("%w: join rate limit must not be negative", ErrInvalidSettings)
+	}
+
+	if s.JoinWindow < 0 {
+		return fmt.Errorf("%w: join window must not be negative", ErrInvalidSettings)
+	}
+
+	return nil
+}
+
+// SettingsUpdate describes a partial update of the settings, nil fields are left unchanged
+type SettingsUpdate struct {
+	OverlayOnly   *bool          `json:"overlayOnly,omitempty"`
+	JoinRateLimit *int           `json:"joinRateLimit,omitempty"`
+	JoinWindow    *time.Duration `json:"joinWindow,omitempty"`
+}
+
+func (u *SettingsUpdate) Apply(settings *Settings) {
+	if u.OverlayOnly != nil {
+		settings.OverlayOnly = *u.OverlayOnly
+	}
+
+	if u.JoinRateLimit != nil {
+		settings.JoinRateLimit = *u.JoinRateLimit
+	}
+
+	if u.JoinWindow != nil {
+		settings.JoinWindow = *u.JoinWindow
+	}
+}
@@ -0,0 +1,42 @@
+package controlapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSettings_Validate(t *testing.T) {
+	invalid := []Settings{
+		{JoinRateLimit: -1},
+		{JoinWindow: -time.Minute},
+	}
+
+	for _, settings := range invalid {
+		if err := settings.Validate(); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("expected invalid settings error for %+v, got %v", settings, err)
+		}
+	}
+
+	valid := Settings{OverlayOnly: true, JoinRateLimit: 10, JoinWindow: 30 * time.Minute}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestSettingsUpdate_Apply(t *testing.T) {
+	settings := Settings{OverlayOnly: true, JoinRateLimit: 10}
+
+	window := time.Hour
+	update := SettingsUpdate{JoinWindow: &window}
+	update.Apply(&settings)
+
+	if !settings.OverlayOnly || settings.JoinRateLimit != 10 {
+		t.Errorf("expected overlay-only and rate limit to be unchanged, got %+v", settings)
+	}
+
+	if settings.JoinWindow != time.Hour {
+		t.Errorf("expected join window to be 1h, got %s", settings.JoinWindow)
+	}
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"wireport/cmd/server/config"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/encryption/atrest"
+	join_requests_types "wireport/internal/joinrequests/types"
+	"wireport/internal/nodes/types"
+	"wireport/internal/notifications"
+
+	"gorm.io/gorm"
+)
+
+// RekeyResult is the outcome of re-encrypting the database with a new master key
+type RekeyResult struct {
+	PreviousKeyID string
+	Key           *atrest.Key
+	BackupPath    string // copy of the database encrypted with the previous key
+	ArchivePath   string // where the previous key has been saved, empty for a key from the environment
+}
+
+// masterKeyOptions tell where the master key of the database is kept, see atrest.LoadKey
+func masterKeyOptions() atrest.KeyOptions {
+	return atrest.KeyOptions{
+		EnvValue:       config.Config.MasterKey,
+		EnvName:        config.Config.MasterKeyEnvName,
+		FilePath:       config.Config.MasterKeyFile,
+		KeyringAccount: config.Config.DatabasePath,
+	}
+}
+
+// Rekey re-encrypts the secrets of the database with a new master key and saves the key where the current one is kept,
+// the current key is archived next to it (see atrest.ArchiveKey). A key from the environment cannot be replaced, the new
+// key is returned to be set there before the next start. Refused with ErrDatabaseInUse while a gateway or a server runs
+// on the database, see HoldDatabase
+func (r *SchemaRepository) Rekey() (*RekeyResult, error) {
+	lock, err := lockFile(databaseLockPath(), true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer lock.Close()
+
+	current := atrest.CurrentKey()
+
+	if current == nil {
+		return nil, atrest.ErrNoKey
+	}
+
+	next, err := atrest.GenerateKey(current.Source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := schemaVersion(r.db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	backupPath, err := backupDatabase(r.db, config.Config.DatabaseBackupsDir, config.Config.DatabaseBackupsKeep, version)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var archivePath string
+
+	if current.Source != atrest.KeySourceEnv {
+		// archived before anything is re-encrypted, the backup and the values are never left without their key
+		if archivePath, err = atrest.ArchiveKey(current, masterKeyOptions()); err != nil {
+			return nil, fmt.Errorf("failed to archive the previous master key: %w", err)
+		}
+	}
+
+	if err = reencrypt(r.db, next, current); err != nil {
+		atrest.SetKeys(current)
+		return nil, fmt.Errorf("failed to re-encrypt the database: %w", err)
+	}
+
+	if next.Source != atrest.KeySourceEnv {
+		if err = atrest.StoreKey(next, masterKeyOptions()); err != nil {
+			if restoreErr := reencrypt(r.db, current, next); restoreErr != nil {
+				return nil, errors.Join(err, fmt.Errorf("failed to re-encrypt the database with the previous key, restore %s: %w", backupPath, restoreErr))
+			}
+
+			return nil, err
+		}
+	}
+
+	return &RekeyResult{PreviousKeyID: current.ID, Key: next, BackupPath: backupPath, ArchivePath: archivePath}, nil
+}
+
+// reencrypt writes every encrypted value again with the key, the previous key is used to read them
+func reencrypt(db *gorm.DB, key *atrest.Key, previous *atrest.Key) error {
+	atrest.SetKeys(key, previous)
+
+	err := db.Transaction(encryptSecrets)
+
+	atrest.SetKeys(key)
+
+	return err
+}
+
+// encryptSecrets writes the rows holding private keys and secrets again, their values are encrypted with the current
+// master key
+func encryptSecrets(tx *gorm.DB) error {
+	if err := encryptNodeSecrets(tx); err != nil {
+		return err
+	}
+
+	if err := encryptCertificateKeys(tx); err != nil {
+		return err
+	}
+
+	if err := encryptWebhookSecrets(tx); err != nil {
+		return err
+	}
+
+	return encryptACMECredentials(tx)
+}
+
+// encryptNodeSecrets writes the nodes and the join requests again
+func encryptNodeSecrets(tx *gorm.DB) error {
+	var nodes []types.Node
+
+	if err := tx.Find(&nodes).Error; err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		if err := tx.Save(&nodes[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	var joinRequests []join_requests_types.JoinRequest
+
+	if err := tx.Find(&joinRequests).Error; err != nil {
+		return err
+	}
+
+	for i := range joinRequests {
+		if err := tx.Save(&joinRequests[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encryptPrivateKeys encrypts the private keys stored in plaintext by earlier builds, the unique index of the WireGuard
+// config is dropped as it is meaningless on encrypted values
+func encryptPrivateKeys(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex(&types.Node{}, "idx_nodes_wg_config") {
+		if err := tx.Migrator().DropIndex(&types.Node{}, "idx_nodes_wg_config"); err != nil {
+			return err
+		}
+	}
+
+	return encryptNodeSecrets(tx)
+}
+
+// encryptCertificateKeys writes the user-provided TLS certificates again, their private keys were stored in plaintext by
+// earlier builds
+func encryptCertificateKeys(tx *gorm.DB) error {
+	var gatewayCertificates []certificates.Certificate
+
+	if err := tx.Find(&gatewayCertificates).Error; err != nil {
+		return err
+	}
+
+	for i := range gatewayCertificates {
+		if err := tx.Save(&gatewayCertificates[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encryptWebhookSecrets writes the webhooks again, their signing secrets were stored in plaintext by earlier builds
+func encryptWebhookSecrets(tx *gorm.DB) error {
+	var webhooks []notifications.Webhook
+
+	if err := tx.Find(&webhooks).Error; err != nil {
+		return err
+	}
+
+	for i := range webhooks {
+		if err := tx.Save(&webhooks[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encryptACMECredentials writes the ACME settings again, their DNS credentials were stored in plaintext by earlier builds
+func encryptACMECredentials(tx *gorm.DB) error {
+	var settings []acme.Settings
+
+	if err := tx.Find(&settings).Error; err != nil {
+		return err
+	}
+
+	for i := range settings {
+		if err := tx.Save(&settings[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
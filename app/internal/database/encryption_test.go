@@ -0,0 +1,365 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wireport/cmd/server/config"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/encryption/atrest"
+	"wireport/internal/nodes/types"
+	"wireport/internal/notifications"
+)
+
+func TestMigrate_EncryptsPrivateKeys(t *testing.T) {
+	db := newTestDB(t)
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+
+	t.Cleanup(func() {
+		atrest.SetKeys(nil)
+	})
+
+	// a node written by a build without the encryption
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	err = db.Exec(`INSERT INTO nodes (id, role, wg_private_key, wg_public_key, wg_config, gateway_public_ip, gateway_public_port, client_cert_bundle)
+		VALUES ('node-1', 'client', 'wg-private-key', 'wg-public-key', '{"interface":{"private_key":"wg-private-key"}}', '140.120.110.10', 4060,
+		'{"client":{"key":"client-key"}}')`).Error
+
+	if err != nil {
+		t.Fatalf("failed to insert plaintext node: %v", err)
+	}
+
+	if err = migrate(db, models, migrations, t.TempDir(), 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var raw struct {
+		WGPrivateKey     string
+		WGConfig         string
+		ClientCertBundle string
+	}
+
+	if err = db.Table("nodes").Select("wg_private_key", "wg_config", "client_cert_bundle").Where("id = ?", "node-1").Scan(&raw).Error; err != nil {
+		t.Fatalf("failed to read the node: %v", err)
+	}
+
+	for _, value := range []string{raw.WGPrivateKey, raw.WGConfig, raw.ClientCertBundle} {
+		if !strings.HasPrefix(value, "wpenc:v1:"+key.ID+":") {
+			t.Errorf("expected an encrypted value, got %q", value)
+		}
+	}
+
+	var node types.Node
+
+	if err = db.First(&node, "id = ?", "node-1").Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if node.WGPrivateKey != "wg-private-key" || node.WGConfig.Interface.PrivateKey != "wg-private-key" || node.ClientCertBundle == nil {
+		t.Errorf("expected the decrypted node, got %+v", node)
+	}
+}
+
+func TestMigrate_EncryptsCertificateKeys(t *testing.T) {
+	db := newTestDB(t)
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+
+	t.Cleanup(func() {
+		atrest.SetKeys(nil)
+	})
+
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	// migrations 1 and 2 were applied by an earlier build, the certificate was stored in plaintext
+	for _, version := range []int{1, 2} {
+		if err = db.Create(&SchemaMigration{Version: version, Description: "applied earlier"}).Error; err != nil {
+			t.Fatalf("failed to record migration: %v", err)
+		}
+	}
+
+	err = db.Exec(`INSERT INTO certificates (id, hosts, cert_pem, key_pem, subject, issuer, not_after, created_at, updated_at)
+		VALUES ('cert-1', '["app.example.com"]', 'cert', 'plaintext-key', 'CN=app', 'CN=ca', '2030-01-01 00:00:00', '2026-01-01 00:00:00', '2026-01-01 00:00:00')`).Error
+
+	if err != nil {
+		t.Fatalf("failed to insert plaintext certificate: %v", err)
+	}
+
+	if err = migrate(db, models, migrations, t.TempDir(), 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var raw string
+
+	if err = db.Table("certificates").Select("key_pem").Where("id = ?", "cert-1").Scan(&raw).Error; err != nil {
+		t.Fatalf("failed to read the certificate: %v", err)
+	}
+
+	if !strings.HasPrefix(raw, "wpenc:v1:"+key.ID+":") {
+		t.Errorf("expected an encrypted key, got %q", raw)
+	}
+
+	var certificate certificates.Certificate
+
+	if err = db.First(&certificate, "id = ?", "cert-1").Error; err != nil || certificate.KeyPEM != "plaintext-key" {
+		t.Errorf("expected the decrypted key, got %q, %v", certificate.KeyPEM, err)
+	}
+}
+
+func TestMigrate_EncryptsWebhookSecrets(t *testing.T) {
+	db := newTestDB(t)
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+
+	t.Cleanup(func() {
+		atrest.SetKeys(nil)
+	})
+
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	// migrations 1 to 3 were applied by an earlier build, the secret was stored in plaintext
+	for _, version := range []int{1, 2, 3} {
+		if err = db.Create(&SchemaMigration{Version: version, Description: "applied earlier"}).Error; err != nil {
+			t.Fatalf("failed to record migration: %v", err)
+		}
+	}
+
+	err = db.Exec(`INSERT INTO webhooks (id, url, secret, events, created_at, updated_at)
+		VALUES ('webhook-1', 'https://hooks.example.com', 's3cr3t', '[]', '2026-01-01 00:00:00', '2026-01-01 00:00:00')`).Error
+
+	if err != nil {
+		t.Fatalf("failed to insert plaintext webhook: %v", err)
+	}
+
+	if err = migrate(db, models, migrations, t.TempDir(), 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var raw string
+
+	if err = db.Table("webhooks").Select("secret").Where("id = ?", "webhook-1").Scan(&raw).Error; err != nil {
+		t.Fatalf("failed to read the webhook: %v", err)
+	}
+
+	if !strings.HasPrefix(raw, "wpenc:v1:"+key.ID+":") {
+		t.Errorf("expected an encrypted secret, got %q", raw)
+	}
+
+	var webhook notifications.Webhook
+
+	if err = db.First(&webhook, "id = ?", "webhook-1").Error; err != nil || webhook.Secret != "s3cr3t" {
+		t.Errorf("expected the decrypted secret, got %q, %v", webhook.Secret, err)
+	}
+}
+
+func TestMigrate_EncryptsACMECredentials(t *testing.T) {
+	db := newTestDB(t)
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+
+	t.Cleanup(func() {
+		atrest.SetKeys(nil)
+	})
+
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	// migrations 1 to 4 were applied by an earlier build, the credentials were stored in plaintext
+	for _, version := range []int{1, 2, 3, 4} {
+		if err = db.Create(&SchemaMigration{Version: version, Description: "applied earlier"}).Error; err != nil {
+			t.Fatalf("failed to record migration: %v", err)
+		}
+	}
+
+	err = db.Exec(`INSERT INTO settings (id, dns_provider, dns_credentials, created_at, updated_at)
+		VALUES ('default', 'cloudflare', '{"api_token":"cf-token"}', '2026-01-01 00:00:00', '2026-01-01 00:00:00')`).Error
+
+	if err != nil {
+		t.Fatalf("failed to insert plaintext ACME settings: %v", err)
+	}
+
+	if err = migrate(db, models, migrations, t.TempDir(), 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var raw string
+
+	if err = db.Table("settings").Select("dns_credentials").Where("id = ?", acme.SettingsID).Scan(&raw).Error; err != nil {
+		t.Fatalf("failed to read the ACME settings: %v", err)
+	}
+
+	if !strings.HasPrefix(raw, "wpenc:v1:"+key.ID+":") {
+		t.Errorf("expected encrypted credentials, got %q", raw)
+	}
+
+	settings, err := acme.NewRepository(db).Get()
+
+	if err != nil || settings.DNSCredentials["api_token"] != "cf-token" {
+		t.Errorf("expected the decrypted credentials, got %v, %v", settings, err)
+	}
+}
+
+func TestRekey_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	previousConfig := config.Config
+
+	config.Config.DatabasePath = filepath.Join(dir, "wireport.db")
+	config.Config.DatabaseBackupsDir = filepath.Join(dir, "backups")
+	config.Config.MasterKey = ""
+	config.Config.MasterKeyFile = filepath.Join(dir, "master.key")
+
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	if err = atrest.StoreKey(key, masterKeyOptions()); err != nil {
+		t.Fatalf("failed to store key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+	atrest.SetArchivedKeyLoader(func(keyID string) (*atrest.Key, error) {
+		return atrest.ReadArchivedKey(keyID, masterKeyOptions())
+	})
+
+	t.Cleanup(func() {
+		config.Config = previousConfig
+		atrest.SetKeys(nil)
+		atrest.SetArchivedKeyLoader(nil)
+	})
+
+	db, err := openDB(config.Config.DatabasePath)
+
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = CloseDB(db)
+	})
+
+	if err = migrate(db, models, migrations, config.Config.DatabaseBackupsDir, 5); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	if err = db.Create(&notifications.Webhook{ID: "webhook-1", URL: "https://hooks.example.com", Secret: "s3cr3t"}).Error; err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	repository := NewSchemaRepository(db)
+
+	release, err := HoldDatabase()
+
+	if err != nil {
+		t.Fatalf("failed to hold the database: %v", err)
+	}
+
+	if _, err = repository.Rekey(); !errors.Is(err, ErrDatabaseInUse) {
+		t.Errorf("expected ErrDatabaseInUse while the database is held, got %v", err)
+	}
+
+	release()
+
+	result, err := repository.Rekey()
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if result.PreviousKeyID != key.ID || result.Key.ID == key.ID {
+		t.Errorf("expected a new key replacing %s, got %+v", key.ID, result)
+	}
+
+	if stored, err := atrest.ReadKey(masterKeyOptions()); err != nil || stored.ID != result.Key.ID {
+		t.Errorf("expected the new key in the key file, got %v %v", stored, err)
+	}
+
+	if result.ArchivePath != config.Config.MasterKeyFile+"."+key.ID {
+		t.Errorf("expected the previous key to be archived next to the key file, got %q", result.ArchivePath)
+	}
+
+	var raw string
+
+	if err = db.Table("webhooks").Select("secret").Where("id = ?", "webhook-1").Scan(&raw).Error; err != nil {
+		t.Fatalf("failed to read the webhook: %v", err)
+	}
+
+	if !strings.HasPrefix(raw, "wpenc:v1:"+result.Key.ID+":") {
+		t.Errorf("expected the secret encrypted with the new key, got %q", raw)
+	}
+
+	var webhook notifications.Webhook
+
+	if err = db.First(&webhook, "id = ?", "webhook-1").Error; err != nil || webhook.Secret != "s3cr3t" {
+		t.Errorf("expected the decrypted secret, got %q, %v", webhook.Secret, err)
+	}
+
+	// the backup is read with the archived key
+	backup, err := openDB(result.BackupPath)
+
+	if err != nil {
+		t.Fatalf("failed to open the backup: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = CloseDB(backup)
+	})
+
+	webhook = notifications.Webhook{}
+
+	if err = backup.First(&webhook, "id = ?", "webhook-1").Error; err != nil || webhook.Secret != "s3cr3t" {
+		t.Errorf("expected the backup to be decrypted with the archived key, got %q, %v", webhook.Secret, err)
+	}
+}
+
+func TestIsInsideDir(t *testing.T) {
+	tests := []struct {
+		path   string
+		dir    string
+		inside bool
+	}{
+		{"/app/wireport/master.key", "/app/wireport", true},
+		{"/app/wireport/keys/master.key", "/app/wireport", true},
+		{"/app/wireport/../master.key", "/app/wireport", false},
+		{"/run/secrets/master.key", "/app/wireport", false},
+		{"/app/wireport-keys/master.key", "/app/wireport", false},
+	}
+
+	for _, tt := range tests {
+		if got := isInsideDir(tt.path, tt.dir); got != tt.inside {
+			t.Errorf("expected %v for %s in %s, got %v", tt.inside, tt.path, tt.dir, got)
+		}
+	}
+}
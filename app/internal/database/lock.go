@@ -0,0 +1,27 @@
+package database
+
+import (
+	"errors"
+	"wireport/cmd/server/config"
+)
+
+var ErrDatabaseInUse = errors.New("the database is in use by a running wireport gateway or server, stop it first")
+
+// HoldDatabase marks the database as in use by a gateway or a server running on it until release is called. 'db rekey'
+// is refused meanwhile: the running process would keep writing the secrets with the previous master key
+func HoldDatabase() (release func(), err error) {
+	file, err := lockFile(databaseLockPath(), false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = file.Close()
+	}, nil
+}
+
+// databaseLockPath is the file the running processes and 'db rekey' lock, next to the database
+func databaseLockPath() string {
+	return config.Config.DatabasePath + ".lock"
+}
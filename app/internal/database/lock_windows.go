@@ -0,0 +1,17 @@
+package database
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile only opens the file: the gateway and the server run on Linux, nothing holds the database on Windows
+func lockFile(path string, _ bool) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+
+	"gorm.io/gorm"
+)
+
+var ErrSchemaTooNew = errors.New("the database schema is newer than this wireport build")
+
+// Migration changes the schema or the data of the database in a way AutoMigrate cannot: renames, drops and data
+// transformations. AutoMigrate creates the tables and adds new columns before the migrations run
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+}
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	Version     int       `gorm:"primaryKey;autoIncrement:false"`
+	Description string    `gorm:"type:text;not null"`
+	AppliedAt   time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrations are applied in the order of their versions, each one once, in a transaction. A released migration is never
+// changed or removed, mistakes are fixed by a new one
+var migrations = []Migration{
+	{1, "reset invalid node labels to empty JSON arrays", ensureNodeLabelsAreValidJSON},
+	{2, "encrypt the private keys of nodes and join requests", encryptPrivateKeys},
+	{3, "encrypt the private keys of TLS certificates", encryptCertificateKeys},
+	{4, "encrypt the signing secrets of webhooks", encryptWebhookSecrets},
+	{5, "encrypt the DNS credentials of the ACME settings", encryptACMECredentials},
+}
+
+// MigrationStatus is a known or recorded migration, AppliedAt is nil for a pending one
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+	Unknown     bool // applied by a newer wireport build
+}
+
+// SchemaRepository reports the migrations of the database and re-encrypts it with a new master key
+type SchemaRepository struct {
+	db *gorm.DB
+}
+
+func NewSchemaRepository(db *gorm.DB) *SchemaRepository {
+	return &SchemaRepository{db: db}
+}
+
+// Status returns the known migrations and the ones recorded by newer builds, in the order of their versions
+func (r *SchemaRepository) Status() ([]MigrationStatus, error) {
+	return migrationStatus(r.db, migrations)
+}
+
+// Check returns ErrSchemaTooNew if the database has been migrated by a newer build
+func (r *SchemaRepository) Check() error {
+	return checkSchemaVersion(r.db, migrations)
+}
+
+// LatestVersion returns the schema version of this build
+func (r *SchemaRepository) LatestVersion() int {
+	return latestVersion(migrations)
+}
+
+func latestVersion(known []Migration) int {
+	latest := 0
+
+	for _, migration := range known {
+		latest = max(latest, migration.Version)
+	}
+
+	return latest
+}
+
+func appliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	applied := []SchemaMigration{}
+
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	if err := db.Order("version ASC").Find(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to get the applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+func migrationStatus(db *gorm.DB, known []Migration) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+
+	for _, migration := range known {
+		status := MigrationStatus{Version: migration.Version, Description: migration.Description}
+
+		if index := slices.IndexFunc(applied, func(m SchemaMigration) bool { return m.Version == migration.Version }); index >= 0 {
+			status.AppliedAt = &applied[index].AppliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	for _, migration := range applied {
+		if !slices.ContainsFunc(known, func(m Migration) bool { return m.Version == migration.Version }) {
+			statuses = append(statuses, MigrationStatus{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   &migration.AppliedAt,
+				Unknown:     true,
+			})
+		}
+	}
+
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int {
+		return a.Version - b.Version
+	})
+
+	return statuses, nil
+}
+
+// schemaVersion returns the version of the last applied migration, 0 if none has been applied
+func schemaVersion(db *gorm.DB) (int, error) {
+	applied, err := appliedMigrations(db)
+
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+
+	return applied[len(applied)-1].Version, nil
+}
+
+// checkSchemaVersion refuses a database migrated by a newer build, its schema or data may not be understood by this one
+func checkSchemaVersion(db *gorm.DB, known []Migration) error {
+	applied, err := appliedMigrations(db)
+
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		return nil
+	}
+
+	if schemaVersion := applied[len(applied)-1].Version; schemaVersion > latestVersion(known) {
+		return fmt.Errorf("%w: the schema is at version %d, this build supports up to version %d; upgrade wireport or restore a backup from %s",
+			ErrSchemaTooNew, schemaVersion, latestVersion(known), config.Config.DatabaseBackupsDir)
+	}
+
+	return nil
+}
+
+// pendingMigrations returns the known migrations that have not been applied yet, in order
+func pendingMigrations(db *gorm.DB, known []Migration) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+
+	for _, migration := range known {
+		if !slices.ContainsFunc(applied, func(m SchemaMigration) bool { return m.Version == migration.Version }) {
+			pending = append(pending, migration)
+		}
+	}
+
+	slices.SortFunc(pending, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+
+	return pending, nil
+}
+
+// modelsChanged reports whether AutoMigrate would change the database: a table, column or index of the models is missing.
+// Changed column types are not detected, they need a migration
+func modelsChanged(db *gorm.DB, models []any) (bool, error) {
+	migrator := db.Migrator()
+
+	for _, model := range models {
+		if !migrator.HasTable(model) {
+			return true, nil
+		}
+
+		statement := &gorm.Statement{DB: db}
+
+		if err := statement.Parse(model); err != nil {
+			return false, err
+		}
+
+		for _, field := range statement.Schema.Fields {
+			if field.DBName != "" && !field.IgnoreMigration && !migrator.HasColumn(model, field.DBName) {
+				return true, nil
+			}
+		}
+
+		for _, index := range statement.Schema.ParseIndexes() {
+			if !migrator.HasIndex(model, index.Name) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// applyMigrations runs every migration in its own transaction together with its record, the first failure stops
+func applyMigrations(db *gorm.DB, pending []Migration) error {
+	for _, migration := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaMigration{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+	}
+
+	return nil
+}
+
+// backupDatabase writes a consistent copy of the database before migrating it from the schema version, only the newest
+// backups are kept
+func backupDatabase(db *gorm.DB, backupsDir string, keep int, schemaVersion int) (string, error) {
+	if err := os.MkdirAll(backupsDir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(backupsDir, fmt.Sprintf("wireport-%s-v%d.db", time.Now().UTC().Format("20060102T150405.000Z"), schemaVersion))
+
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return "", fmt.Errorf("failed to back up the database to %s: %w", path, err)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(backupsDir, "wireport-*.db"))
+
+	if err != nil {
+		return path, nil
+	}
+
+	// the names sort by their timestamps
+	slices.Sort(backups)
+
+	for len(backups) > keep {
+		_ = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+
+	return path, nil
+}
+
+// ensureNodeLabelsAreValidJSON sets labels to [] when NULL/empty/invalid — JSON serializer always expects valid JSON
+// (not a regular string/null)
+func ensureNodeLabelsAreValidJSON(db *gorm.DB) error {
+	type nodeLabelRow struct {
+		ID     string  `gorm:"column:id"`
+		Labels *string `gorm:"column:labels"`
+	}
+
+	var rows []nodeLabelRow
+
+	if err := db.Table("nodes").Select("id", "labels").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		target := []string{}
+		needsUpdate := false
+
+		if row.Labels == nil {
+			needsUpdate = true
+		} else {
+			raw := strings.TrimSpace(*row.Labels)
+			switch {
+			case raw == "":
+				needsUpdate = true
+			case json.Valid([]byte(raw)):
+				needsUpdate = false
+			default:
+				// Invalid stored value: reset to an empty JSON array.
+				needsUpdate = true
+			}
+		}
+
+		if !needsUpdate {
+			continue
+		}
+
+		encoded, err := json.Marshal(target)
+
+		if err != nil {
+			return err
+		}
+
+		if err := db.Table("nodes").Where("id = ?", row.ID).Update("labels", string(encoded)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"wireport/internal/nodes/types"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "wireport.db")), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = CloseDB(db)
+	})
+
+	return db
+}
+
+func testMigrations(applied *[]int) []Migration {
+	record := func(version int) func(tx *gorm.DB) error {
+		return func(_ *gorm.DB) error {
+			*applied = append(*applied, version)
+			return nil
+		}
+	}
+
+	return []Migration{
+		{1, "first", record(1)},
+		{2, "second", record(2)},
+	}
+}
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	db := newTestDB(t)
+	backupsDir := filepath.Join(t.TempDir(), "backups")
+	applied := []int{}
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, testMigrations(&applied), backupsDir, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Errorf("expected migrations 1 and 2 to be applied in order, got %v", applied)
+	}
+
+	if _, err := os.Stat(backupsDir); !os.IsNotExist(err) {
+		t.Errorf("expected no backup of a fresh database, got %v", err)
+	}
+
+	applied = []int{}
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, testMigrations(&applied), backupsDir, 5); err != nil || len(applied) != 0 {
+		t.Errorf("expected the migrations to be applied once, got %v %v", applied, err)
+	}
+}
+
+func TestMigrate_BacksUpBeforePendingMigrations(t *testing.T) {
+	db := newTestDB(t)
+	backupsDir := filepath.Join(t.TempDir(), "backups")
+	applied := []int{}
+	known := testMigrations(&applied)
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, known[:1], backupsDir, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, known, backupsDir, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(backupsDir, "wireport-*-v1.db"))
+
+	if len(backups) != 1 {
+		t.Errorf("expected a backup of the schema version 1, got %v", backups)
+	}
+
+	if len(applied) != 2 || applied[1] != 2 {
+		t.Errorf("expected migration 2 to be applied after the backup, got %v", applied)
+	}
+}
+
+func TestMigrate_BacksUpBeforeModelChanges(t *testing.T) {
+	db := newTestDB(t)
+	backupsDir := filepath.Join(t.TempDir(), "backups")
+	applied := []int{}
+	known := testMigrations(&applied)
+
+	if err := migrate(db, models, known, backupsDir, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the tables of this build are up to date, nothing to back up
+	if err := migrate(db, models, known, backupsDir, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if backups, _ := filepath.Glob(filepath.Join(backupsDir, "wireport-*.db")); len(backups) != 0 {
+		t.Fatalf("expected no backup without changes, got %v", backups)
+	}
+
+	// a column added by a newer build, without a migration
+	if err := db.Migrator().DropColumn(&types.Node{}, "labels"); err != nil {
+		t.Fatalf("failed to drop column: %v", err)
+	}
+
+	if err := migrate(db, models, known, backupsDir, 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if backups, _ := filepath.Glob(filepath.Join(backupsDir, "wireport-*-v2.db")); len(backups) != 1 {
+		t.Errorf("expected a backup before the tables are changed, got %v", backups)
+	}
+
+	if !db.Migrator().HasColumn(&types.Node{}, "labels") {
+		t.Errorf("expected the column to be added")
+	}
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	db := newTestDB(t)
+	applied := []int{}
+	known := testMigrations(&applied)
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, known, t.TempDir(), 5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, known[:1], t.TempDir(), 5); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+
+	statuses, err := migrationStatus(db, known[:1])
+
+	if err != nil || len(statuses) != 2 || statuses[0].Unknown || !statuses[1].Unknown || statuses[1].AppliedAt == nil {
+		t.Errorf("expected migration 2 to be reported as applied by a newer build, got %+v %v", statuses, err)
+	}
+}
+
+func TestMigrate_FailedMigrationIsNotRecorded(t *testing.T) {
+	db := newTestDB(t)
+	failing := []Migration{
+		{1, "failing", func(tx *gorm.DB) error {
+			if err := tx.Exec("UPDATE nodes SET name = 'changed'").Error; err != nil {
+				return err
+			}
+
+			return errors.New("boom")
+		}},
+	}
+
+	if err := migrate(db, []any{&types.Node{}, &SchemaMigration{}}, failing, t.TempDir(), 5); err == nil {
+		t.Fatal("expected the failure of the migration")
+	}
+
+	pending, err := pendingMigrations(db, failing)
+
+	if err != nil || len(pending) != 1 {
+		t.Errorf("expected the failed migration to stay pending, got %v %v", pending, err)
+	}
+}
+
+func TestBackupDatabase_KeepsNewest(t *testing.T) {
+	db := newTestDB(t)
+	backupsDir := t.TempDir()
+
+	if err := db.AutoMigrate(&types.Node{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	for range 3 {
+		if _, err := backupDatabase(db, backupsDir, 2, 1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(backupsDir, "wireport-*.db"))
+
+	if len(backups) != 2 {
+		t.Errorf("expected the 2 newest backups to be kept, got %v", backups)
+	}
+}
@@ -1,16 +1,24 @@
 package database
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"wireport/cmd/server/config"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/controlapi"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/encryption/atrest"
 	join_requests_types "wireport/internal/joinrequests/types"
 	"wireport/internal/jointokens"
+	"wireport/internal/logger"
 	"wireport/internal/nodes/types"
+	"wireport/internal/notifications"
 	"wireport/internal/publicservices"
+	"wireport/internal/rollouts"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
@@ -35,6 +43,14 @@ func sqliteDSNImmediate(path string) string {
 	return u.String()
 }
 
+// models are the tables created and extended by AutoMigrate, see Migration
+var models = []any{
+	&types.Node{}, &join_requests_types.JoinRequest{}, &publicservices.PublicService{}, &jointokens.JoinToken{}, &acme.Settings{},
+	&certificates.Certificate{}, &publicservices.UpstreamHealth{}, &notifications.Webhook{}, &notifications.OutboxMessage{},
+	&dnsrecords.Record{}, &dnsrecords.Settings{}, &controlapi.Settings{}, &rollouts.Rollout{}, &rollouts.Step{},
+	&SchemaMigration{},
+}
+
 func InitDB() (*gorm.DB, error) {
 	var err error
 
@@ -45,83 +61,124 @@ func InitDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	db, err := gorm.Open(sqlite.Open(sqliteDSNImmediate(config.Config.DatabasePath)), &gorm.Config{
-		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
-	})
+	key, err := atrest.LoadKey(masterKeyOptions())
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&types.Node{}, &join_requests_types.JoinRequest{}, &publicservices.PublicService{}, &jointokens.JoinToken{})
+	// whoever copies the data directory (e.g. a backup of the volume) can decrypt the database with a key kept there
+	if key.Source == atrest.KeySourceFile && isInsideDir(config.Config.MasterKeyFile, dbDir) {
+		logger.Warn("The master key is kept in %s, next to the database: set %s or point WIREPORT_MASTER_KEY_FILE outside of %s to keep it apart from the data",
+			config.Config.MasterKeyFile, config.Config.MasterKeyEnvName, dbDir)
+	}
+
+	atrest.SetKeys(key)
+	atrest.SetKeyLoader(func() (*atrest.Key, error) {
+		return atrest.ReadKey(masterKeyOptions())
+	})
+	atrest.SetArchivedKeyLoader(func(keyID string) (*atrest.Key, error) {
+		return atrest.ReadArchivedKey(keyID, masterKeyOptions())
+	})
+
+	db, err := openDB(config.Config.DatabasePath)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if err := ensureNodeLabelsAreValidJSON(db); err != nil {
+	if err = migrate(db, models, migrations, config.Config.DatabaseBackupsDir, config.Config.DatabaseBackupsKeep); err != nil {
+		_ = CloseDB(db)
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func CloseDB(db *gorm.DB) error {
-	sqlDB, err := db.DB()
+// isInsideDir reports whether the path is in the directory or one of its subdirectories
+func isInsideDir(path string, dir string) bool {
+	absolutePath, err := filepath.Abs(path)
 
 	if err != nil {
-		return err
+		return false
 	}
 
-	return sqlDB.Close()
+	absoluteDir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return false
+	}
+
+	relativePath, err := filepath.Rel(absoluteDir, absolutePath)
+
+	return err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
 }
 
-// sets labels to [] when NULL/empty/invalid — JSON serializer always expects valid JSON (not a regular string/null)
-func ensureNodeLabelsAreValidJSON(db *gorm.DB) error {
-	type nodeLabelRow struct {
-		ID     string  `gorm:"column:id"`
-		Labels *string `gorm:"column:labels"`
+// OpenDBWithoutMigrating opens the existing database as it is, without the master key: only its migrations can be read,
+// e.g. for 'db migrate status' on a database a newer build has migrated
+func OpenDBWithoutMigrating() (*gorm.DB, error) {
+	if _, err := os.Stat(config.Config.DatabasePath); err != nil {
+		return nil, fmt.Errorf("failed to open database at %s: %w", config.Config.DatabasePath, err)
 	}
 
-	var rows []nodeLabelRow
+	return openDB(config.Config.DatabasePath)
+}
+
+func openDB(path string) (*gorm.DB, error) {
+	return gorm.Open(sqlite.Open(sqliteDSNImmediate(path)), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+}
 
-	if err := db.Table("nodes").Select("id", "labels").Scan(&rows).Error; err != nil {
+// migrate brings the database to the schema of this build: a database migrated by a newer build is refused, an existing
+// one is backed up before pending migrations are applied or the tables of the models are changed
+func migrate(db *gorm.DB, models []any, known []Migration, backupsDir string, backupsKeep int) error {
+	if err := checkSchemaVersion(db, known); err != nil {
 		return err
 	}
 
-	for _, row := range rows {
-		target := []string{}
-		needsUpdate := false
-
-		if row.Labels == nil {
-			needsUpdate = true
-		} else {
-			raw := strings.TrimSpace(*row.Labels)
-			switch {
-			case raw == "":
-				needsUpdate = true
-			case json.Valid([]byte(raw)):
-				needsUpdate = false
-			default:
-				// Invalid stored value: reset to an empty JSON array.
-				needsUpdate = true
-			}
-		}
+	pending, err := pendingMigrations(db, known)
 
-		if !needsUpdate {
-			continue
-		}
+	if err != nil {
+		return err
+	}
+
+	changed, err := modelsChanged(db, models)
+
+	if err != nil {
+		return err
+	}
 
-		encoded, err := json.Marshal(target)
+	// a fresh database has nothing to lose
+	if (len(pending) > 0 || changed) && db.Migrator().HasTable(&types.Node{}) {
+		version, err := schemaVersion(db)
 
 		if err != nil {
 			return err
 		}
 
-		if err := db.Table("nodes").Where("id = ?", row.ID).Update("labels", string(encoded)).Error; err != nil {
+		path, err := backupDatabase(db, backupsDir, backupsKeep, version)
+
+		if err != nil {
 			return err
 		}
+
+		logger.Info("Database backed up to %s before applying %d migration(s) and updating its tables", path, len(pending))
+	}
+
+	if err = db.AutoMigrate(models...); err != nil {
+		return err
+	}
+
+	return applyMigrations(db, pending)
+}
+
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return sqlDB.Close()
 }
@@ -0,0 +1,10 @@
+package dnsrecords
+
+import "errors"
+
+var (
+	ErrRecordNotFound  = errors.New("dns record not found")
+	ErrInvalidRecord   = errors.New("invalid dns record")
+	ErrNameInUse       = errors.New("dns name is already in use")
+	ErrInvalidSettings = errors.New("invalid dns settings")
+)
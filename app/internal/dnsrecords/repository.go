@@ -0,0 +1,104 @@
+package dnsrecords
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create stores the record, refusing names that already have a record
+func (r *Repository) Create(record *Record) error {
+	if _, err := r.Get(record.Name); err == nil {
+		return fmt.Errorf("%w: %s", ErrNameInUse, record.Name)
+	} else if !errors.Is(err, ErrRecordNotFound) {
+		return err
+	}
+
+	return r.db.Create(record).Error
+}
+
+func (r *Repository) GetAll() ([]*Record, error) {
+	var records []*Record
+
+	if err := r.db.Order("name ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (r *Repository) Get(name string) (*Record, error) {
+	var record Record
+
+	err := r.db.Where("name = ?", name).First(&record).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
+
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// Delete removes the record, ErrRecordNotFound if the name has none; other errors are returned as they are
+func (r *Repository) Delete(name string) error {
+	result := r.db.Delete(&Record{}, "name = ?", name)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrRecordNotFound, name)
+	}
+
+	return nil
+}
+
+// GetSettings returns the DNS settings, or the default settings if they were never set
+func (r *Repository) GetSettings() (*Settings, error) {
+	var settings Settings
+
+	err := r.db.Where("id = ?", SettingsID).First(&settings).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultSettings(), nil
+		}
+
+		return nil, err
+	}
+
+	if settings.Upstreams == nil {
+		settings.Upstreams = []string{}
+	}
+
+	return &settings, nil
+}
+
+func (r *Repository) SaveSettings(settings *Settings) error {
+	settings.ID = SettingsID
+
+	if settings.CreatedAt.IsZero() {
+		settings.CreatedAt = time.Now()
+	}
+
+	return r.db.Save(settings).Error
+}
+
+func (r *Repository) ResetSettings() error {
+	return r.db.Where("id = ?", SettingsID).Delete(&Settings{}).Error
+}
@@ -0,0 +1,37 @@
+package dnsrecords
+
+import (
+	"errors"
+	"testing"
+	"wireport/internal/database/testutil"
+)
+
+func TestRepository_Delete(t *testing.T) {
+	db := testutil.NewInMemoryDB(t, &Record{})
+
+	repository := NewRepository(db)
+
+	sqlDB, err := db.DB()
+
+	if err != nil {
+		t.Fatalf("failed to get database: %v", err)
+	}
+
+	if err = repository.Create(&Record{Name: "db", Type: RecordTypeA, Value: "10.0.0.2"}); err != nil {
+		t.Fatalf("failed to create record: %v", err)
+	}
+
+	if err = repository.Delete("db"); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if err = repository.Delete("db"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	_ = sqlDB.Close()
+
+	if err = repository.Delete("db"); err == nil || errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expected the database error, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package dnsrecords
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// SettingsID is the primary key of the single row of gateway-wide DNS settings
+const SettingsID = "default"
+
+const tlsUpstreamPrefix = "tls://"
+
+// DefaultUpstreams are the resolvers of the names outside of the network if none are configured
+var DefaultUpstreams = []string{"8.8.8.8", "1.1.1.1"}
+
+// Settings are gateway-wide settings of the name resolution in the network
+type Settings struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	// resolvers of the names outside of the network: IPv4/IPv6 addresses with an optional port,
+	// tls://<address> for DNS-over-TLS (port 853 by default)
+	Upstreams     []string `gorm:"type:text;serializer:json;not null;default:'[]'"`
+	TLSServerName string   `gorm:"type:text;not null;default:''"` // name the certificates of the DNS-over-TLS upstreams are verified against
+
+	// names without a zone (e.g. docker container names) are asked from the docker DNS of all servers one after another
+	ServerFanout bool `gorm:"not null"` // no default: gorm would insert it instead of false
+	// <name>.<server>.wp.internal is resolved by the docker DNS of that server only
+	ServerZones bool `gorm:"not null"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+// TableName keeps the settings apart from the ACME ones, both types are named Settings
+func (Settings) TableName() string {
+	return "dns_settings"
+}
+
+// DefaultSettings are the settings of a gateway that was never configured
+func DefaultSettings() *Settings {
+	return &Settings{ID: SettingsID, Upstreams: []string{}, ServerFanout: true}
+}
+
+// EffectiveUpstreams returns the configured upstreams or the default ones
+func (s *Settings) EffectiveUpstreams() []string {
+	if len(s.Upstreams) == 0 {
+		return DefaultUpstreams
+	}
+
+	return s.Upstreams
+}
+
+func (s *Settings) Validate() error {
+	hasTLSUpstream := false
+
+	for _, upstream := range s.Upstreams {
+		address, isTLS := strings.CutPrefix(upstream, tlsUpstreamPrefix)
+		hasTLSUpstream = hasTLSUpstream || isTLS
+
+		host := address
+
+		if h, port, err := net.SplitHostPort(address); err == nil {
+			if port == "" {
+				return fmt.Errorf("%w: upstream %s has an empty port", ErrInvalidSettings, upstream)
+			}
+
+			host = h
+		}
+
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("%w: upstream %s must be an IP address, optionally with a port and the tls:// prefix", ErrInvalidSettings, upstream)
+		}
+	}
+
+	if s.TLSServerName != "" {
+		if !hasTLSUpstream {
+			return fmt.Errorf("%w: TLS server name is set but no tls:// upstream is configured", ErrInvalidSettings)
+		}
+
+		if !IsValidHostname(s.TLSServerName) {
+			return fmt.Errorf("%w: invalid TLS server name %q", ErrInvalidSettings, s.TLSServerName)
+		}
+	}
+
+	return nil
+}
+
+// SettingsUpdate describes a partial update of the settings, nil fields are left unchanged
+type SettingsUpdate struct {
+	Upstreams     []string `json:"upstreams"` // replaces all upstreams if not nil, empty restores the default ones (no omitempty: empty and nil differ)
+	TLSServerName *string  `json:"tlsServerName,omitempty"`
+	ServerFanout  *bool    `json:"serverFanout,omitempty"`
+	ServerZones   *bool    `json:"serverZones,omitempty"`
+}
+
+func (u *SettingsUpdate) Apply(settings *Settings) {
+	if u.Upstreams != nil {
+		settings.Upstreams = u.Upstreams
+	}
+
+	if u.TLSServerName != nil {
+		settings.TLSServerName = strings.ToLower(*u.TLSServerName)
+	}
+
+	if u.ServerFanout != nil {
+		settings.ServerFanout = *u.ServerFanout
+	}
+
+	if u.ServerZones != nil {
+		settings.ServerZones = *u.ServerZones
+	}
+}
@@ -0,0 +1,82 @@
+package dnsrecords
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSettings_Validate(t *testing.T) {
+	invalid := []Settings{
+		{Upstreams: []string{"dns.google"}},
+		{Upstreams: []string{"8.8.8.8:"}},
+		{Upstreams: []string{"tls://dns.quad9.net"}},
+		{Upstreams: []string{"8.8.8.8"}, TLSServerName: "dns.google"},
+		{Upstreams: []string{"tls://9.9.9.9"}, TLSServerName: "dns quad9"},
+	}
+
+	for _, settings := range invalid {
+		if err := settings.Validate(); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("expected invalid settings error for %+v, got %v", settings, err)
+		}
+	}
+
+	valid := []Settings{
+		*DefaultSettings(),
+		{Upstreams: []string{"8.8.8.8", "1.1.1.1:53", "[2606:4700:4700::1111]:53"}},
+		{Upstreams: []string{"tls://9.9.9.9", "tls://149.112.112.112:853"}, TLSServerName: "dns.quad9.net"},
+	}
+
+	for _, settings := range valid {
+		if err := settings.Validate(); err != nil {
+			t.Errorf("expected no error for %+v, got %v", settings, err)
+		}
+	}
+}
+
+func TestSettings_EffectiveUpstreams(t *testing.T) {
+	if upstreams := DefaultSettings().EffectiveUpstreams(); len(upstreams) != len(DefaultUpstreams) {
+		t.Errorf("expected default upstreams, got %v", upstreams)
+	}
+
+	settings := Settings{Upstreams: []string{"9.9.9.9"}}
+
+	if upstreams := settings.EffectiveUpstreams(); len(upstreams) != 1 || upstreams[0] != "9.9.9.9" {
+		t.Errorf("expected configured upstreams, got %v", upstreams)
+	}
+}
+
+func TestSettingsUpdate_Apply(t *testing.T) {
+	settings := Settings{Upstreams: []string{"tls://9.9.9.9"}, TLSServerName: "dns.quad9.net", ServerFanout: true}
+
+	disabled := false
+	update := SettingsUpdate{ServerFanout: &disabled}
+	update.Apply(&settings)
+
+	if len(settings.Upstreams) != 1 || settings.TLSServerName != "dns.quad9.net" {
+		t.Errorf("expected upstreams to be unchanged, got %v %s", settings.Upstreams, settings.TLSServerName)
+	}
+
+	if settings.ServerFanout {
+		t.Errorf("expected server fanout to be disabled")
+	}
+
+	// an empty list of upstreams survives the API round trip and restores the defaults
+	payload, err := json.Marshal(SettingsUpdate{Upstreams: []string{}})
+
+	if err != nil {
+		t.Fatalf("failed to marshal update: %v", err)
+	}
+
+	decoded := SettingsUpdate{}
+
+	if err = json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal update: %v", err)
+	}
+
+	decoded.Apply(&settings)
+
+	if len(settings.Upstreams) != 0 {
+		t.Errorf("expected upstreams to be cleared, got %v", settings.Upstreams)
+	}
+}
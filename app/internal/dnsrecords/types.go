@@ -0,0 +1,131 @@
+package dnsrecords
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecordType string
+
+const (
+	RecordTypeA     RecordType = "A"
+	RecordTypeCNAME RecordType = "CNAME"
+)
+
+var labelRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Record is a user-defined record of the wireport DNS zone, served by the gateway to all nodes
+type Record struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	Name  string     `gorm:"type:text;not null;uniqueIndex"` // relative to the zone, e.g. db or db.team
+	Type  RecordType `gorm:"type:text;not null"`
+	Value string     `gorm:"type:text;not null"` // IPv4 address (A) or host name (CNAME), names without dots are relative to the zone
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (r *Record) BeforeCreate(_ *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+
+	return nil
+}
+
+// NewRecord validates the record; the name may be given relative to the zone (db) or fully qualified (db.wp.internal)
+func NewRecord(zone string, name string, recordType string, value string) (*Record, error) {
+	normalizedName, err := NormalizeName(zone, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	record := &Record{
+		Name: normalizedName,
+		Type: RecordType(strings.ToUpper(recordType)),
+	}
+
+	switch record.Type {
+	case RecordTypeA:
+		ip := net.ParseIP(value)
+
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("%w: %q is not an IPv4 address", ErrInvalidRecord, value)
+		}
+
+		record.Value = ip.String()
+	case RecordTypeCNAME:
+		target := strings.TrimSuffix(strings.ToLower(value), ".")
+
+		if !IsValidHostname(target) {
+			return nil, fmt.Errorf("%w: %q is not a valid host name", ErrInvalidRecord, value)
+		}
+
+		if target == record.FQDN(zone) || target == record.Name {
+			return nil, fmt.Errorf("%w: %s can't point to itself", ErrInvalidRecord, record.Name)
+		}
+
+		record.Value = target
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q, expected A or CNAME", ErrInvalidRecord, recordType)
+	}
+
+	return record, nil
+}
+
+// NormalizeName lowercases the name, strips the zone suffix and validates the labels
+func NormalizeName(zone string, name string) (string, error) {
+	normalized := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	normalized = strings.TrimSuffix(normalized, "."+zone)
+
+	if normalized == "" || normalized == zone || !IsValidHostname(normalized) {
+		return "", fmt.Errorf("%w: %q is not a valid name in the %s zone", ErrInvalidRecord, name, zone)
+	}
+
+	return normalized, nil
+}
+
+// IsValidHostname reports whether all labels of the name are valid DNS labels
+func IsValidHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+
+	for label := range strings.SplitSeq(name, ".") {
+		if !labelRegexp.MatchString(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FQDN returns the fully qualified name of the record, e.g. db.wp.internal
+func (r *Record) FQDN(zone string) string {
+	return r.Name + "." + zone
+}
+
+// zoneValue returns the value as written to the zone file: targets in the zone are relative, others absolute
+func (r *Record) zoneValue(zone string) string {
+	if r.Type != RecordTypeCNAME {
+		return r.Value
+	}
+
+	if relative, ok := strings.CutSuffix(r.Value, "."+zone); ok {
+		return relative
+	}
+
+	if !strings.Contains(r.Value, ".") {
+		return r.Value
+	}
+
+	return r.Value + "."
+}
@@ -0,0 +1,89 @@
+package dnsrecords
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType string
+		value      string
+		expected   Record
+	}{
+		{"nas", "A", "10.0.0.4", Record{Name: "nas", Type: RecordTypeA, Value: "10.0.0.4"}},
+		{"DB.Team.wp.internal.", "a", "172.20.0.5", Record{Name: "db.team", Type: RecordTypeA, Value: "172.20.0.5"}},
+		{"grafana", "CNAME", "server-3.wp.internal", Record{Name: "grafana", Type: RecordTypeCNAME, Value: "server-3.wp.internal"}},
+		{"docs", "cname", "Docs.Example.com.", Record{Name: "docs", Type: RecordTypeCNAME, Value: "docs.example.com"}},
+	}
+
+	for _, tt := range tests {
+		record, err := NewRecord("wp.internal", tt.name, tt.recordType, tt.value)
+
+		if err != nil {
+			t.Errorf("NewRecord(%s) error = %v", tt.name, err)
+			continue
+		}
+
+		if *record != tt.expected {
+			t.Errorf("expected %+v, got %+v", tt.expected, *record)
+		}
+	}
+}
+
+func TestNewRecord_Invalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType string
+		value      string
+	}{
+		{"", "A", "10.0.0.4"},
+		{"wp.internal", "A", "10.0.0.4"},
+		{"-nas", "A", "10.0.0.4"},
+		{"nas_1", "A", "10.0.0.4"},
+		{"nas", "A", "fd00::1"},
+		{"nas", "A", "nas.example.com"},
+		{"nas", "CNAME", "10.0.0.4/24"},
+		{"nas", "CNAME", "nas.wp.internal"},
+		{"nas", "MX", "mail.example.com"},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewRecord("wp.internal", tt.name, tt.recordType, tt.value); !errors.Is(err, ErrInvalidRecord) {
+			t.Errorf("expected ErrInvalidRecord for %s %s %s, got %v", tt.name, tt.recordType, tt.value, err)
+		}
+	}
+}
+
+func TestRenderZoneFile(t *testing.T) {
+	records := []*Record{
+		{Name: "gateway", Type: RecordTypeA, Value: "10.0.0.1"},
+		{Name: "grafana", Type: RecordTypeCNAME, Value: "server-3"},
+		{Name: "db", Type: RecordTypeCNAME, Value: "server-3.wp.internal"},
+		{Name: "docs", Type: RecordTypeCNAME, Value: "docs.example.com"},
+	}
+
+	zone := RenderZoneFile("wp.internal", "gateway", records)
+
+	for _, expected := range []string{
+		"$ORIGIN wp.internal.\n",
+		"gateway IN A 10.0.0.1\n",
+		"grafana IN CNAME server-3\n",
+		"db IN CNAME server-3\n",
+		"docs IN CNAME docs.example.com.\n",
+	} {
+		if !strings.Contains(zone, expected) {
+			t.Errorf("expected %q in the zone, got %s", expected, zone)
+		}
+	}
+
+	if RenderZoneFile("wp.internal", "gateway", records) != zone {
+		t.Errorf("expected the same zone (and serial) for the same records")
+	}
+
+	if RenderZoneFile("wp.internal", "gateway", records[:1]) == zone {
+		t.Errorf("expected another serial for other records")
+	}
+}
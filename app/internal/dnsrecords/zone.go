@@ -0,0 +1,33 @@
+package dnsrecords
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// zone records are short-lived in resolver caches, so that renamed nodes and changed records are picked up quickly
+const zoneTTL = 30
+
+// RenderZoneFile renders the zone file served by the CoreDNS file plugin; nameServer is the name (relative to the zone)
+// of the gateway. The serial is derived from the records, so the file plugin reloads the zone whenever they change
+func RenderZoneFile(zone string, nameServer string, records []*Record) string {
+	var body strings.Builder
+
+	for _, record := range records {
+		fmt.Fprintf(&body, "%s IN %s %s\n", record.Name, record.Type, record.zoneValue(zone))
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(nameServer + "\n" + body.String()))
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "$ORIGIN %s.\n", zone)
+	fmt.Fprintf(&sb, "$TTL %d\n", zoneTTL)
+	fmt.Fprintf(&sb, "@ IN SOA %s hostmaster %d 3600 600 86400 %d\n", nameServer, hash.Sum32(), zoneTTL)
+	fmt.Fprintf(&sb, "@ IN NS %s\n", nameServer)
+	sb.WriteString(body.String())
+
+	return sb.String()
+}
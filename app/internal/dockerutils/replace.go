@@ -0,0 +1,317 @@
+package dockerutils
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/logger"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+)
+
+// GetRunningContainerImage returns the image reference the current container has been created from
+func GetRunningContainerImage() (string, error) {
+	containerID, err := getRunningContainerID()
+
+	if err != nil {
+		return "", err
+	}
+
+	cli, err := client.New(client.FromEnv)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(context.Background(), *containerID, client.ContainerInspectOptions{})
+
+	if err != nil {
+		return "", err
+	}
+
+	if inspect.Container.Config == nil {
+		return "", fmt.Errorf("container %s has no config", *containerID)
+	}
+
+	return inspect.Container.Config.Image, nil
+}
+
+// PullImage pulls the image to the docker host and waits until the pull is finished
+func PullImage(image string) error {
+	cli, err := client.New(client.FromEnv)
+
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	pull, err := cli.ImagePull(ctx, image, client.ImagePullOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	return pull.Wait(ctx)
+}
+
+// StartServerReplacement starts a one-off container of the image that replaces the current container with a container of
+// the same image, see ReplaceServerContainer. The data directory of the current container is mounted into it at
+// config.Config.ServerUpgradeDataDir, the replacement writes its result there
+func StartServerReplacement(image string, rolloutID string) error {
+	containerName, err := getRunningContainerName()
+
+	if err != nil {
+		return err
+	}
+
+	cli, err := client.New(client.FromEnv)
+
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	inspect, err := cli.ContainerInspect(ctx, *containerName, client.ContainerInspectOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	dataDir := filepath.Dir(config.Config.DatabasePath)
+	dataDirSource := ""
+
+	for _, mountPoint := range inspect.Container.Mounts {
+		if mountPoint.Destination == dataDir {
+			dataDirSource = mountPoint.Source
+		}
+	}
+
+	if dataDirSource == "" {
+		return fmt.Errorf("%s is not mounted from the docker host into container %s", dataDir, *containerName)
+	}
+
+	replacementName := *containerName + "-upgrade"
+
+	// leftover of an interrupted upgrade
+	if _, err = cli.ContainerRemove(ctx, replacementName, client.ContainerRemoveOptions{Force: true}); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+
+	created, err := cli.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Name: replacementName,
+		Config: &container.Config{
+			Image: image,
+			Cmd:   []string{"server", "replace", "--container", *containerName, "--image", image, "--rollout", rolloutID},
+		},
+		HostConfig: &container.HostConfig{
+			AutoRemove: true,
+			Binds: []string{
+				fmt.Sprintf("%s:%s", config.Config.DockerSocketUnixPath, config.Config.DockerSocketUnixPath),
+				fmt.Sprintf("%s:%s", dataDirSource, config.Config.ServerUpgradeDataDir),
+			},
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = cli.ContainerStart(ctx, created.ID, client.ContainerStartOptions{})
+
+	return err
+}
+
+// ReplaceServerContainer recreates the server container from the image with the same settings, volumes and networks: the
+// config (labels and env vars other than the defaults of the current image), the host config and the endpoint settings
+// of the networks are copied. The previous container is kept until the new one has been running for config.Config.ServerUpgradeProbation without restarts,
+// otherwise it is started again
+func ReplaceServerContainer(containerName string, image string) error {
+	cli, err := client.New(client.FromEnv)
+
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	current, err := cli.ContainerInspect(ctx, containerName, client.ContainerInspectOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	if current.Container.Config == nil || current.Container.HostConfig == nil {
+		return fmt.Errorf("container %s has no config", containerName)
+	}
+
+	currentImage, err := cli.ImageInspect(ctx, current.Container.Image)
+
+	if err != nil {
+		return err
+	}
+
+	containerConfig := *current.Container.Config
+	containerConfig.Image = image
+	containerConfig.Cmd = []string{"server", "start"}
+	// the defaults of the new image apply, the hostname is the ID of the new container (see getRunningContainerID)
+	containerConfig.Hostname = ""
+	containerConfig.Entrypoint = nil
+	containerConfig.WorkingDir = ""
+
+	if currentImage.Config != nil {
+		containerConfig.Env = slices.DeleteFunc(slices.Clone(containerConfig.Env), func(env string) bool {
+			return slices.Contains(currentImage.Config.Env, env)
+		})
+
+		labels := map[string]string{}
+
+		for key, value := range containerConfig.Labels {
+			if imageValue, ok := currentImage.Config.Labels[key]; !ok || imageValue != value {
+				labels[key] = value
+			}
+		}
+
+		containerConfig.Labels = labels
+	}
+
+	previousName := containerName + "-previous"
+
+	if _, err = cli.ContainerRemove(ctx, previousName, client.ContainerRemoveOptions{Force: true}); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+
+	stopTimeout := 30
+
+	if _, err = cli.ContainerStop(ctx, current.Container.ID, client.ContainerStopOptions{Timeout: &stopTimeout}); err != nil {
+		return err
+	}
+
+	if _, err = cli.ContainerRename(ctx, current.Container.ID, client.ContainerRenameOptions{NewName: previousName}); err != nil {
+		return restoreServerContainer(ctx, cli, containerName, current.Container.ID, err)
+	}
+
+	created, err := cli.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Name:             containerName,
+		Config:           &containerConfig,
+		HostConfig:       current.Container.HostConfig,
+		NetworkingConfig: &network.NetworkingConfig{EndpointsConfig: endpointsConfig(current.Container)},
+	})
+
+	if err != nil {
+		return restoreServerContainer(ctx, cli, containerName, current.Container.ID, err)
+	}
+
+	if _, err = cli.ContainerStart(ctx, created.ID, client.ContainerStartOptions{}); err != nil {
+		return restoreServerContainer(ctx, cli, containerName, current.Container.ID, err)
+	}
+
+	if err = waitForStableContainer(ctx, cli, created.ID, config.Config.ServerUpgradeProbation); err != nil {
+		return restoreServerContainer(ctx, cli, containerName, current.Container.ID, err)
+	}
+
+	if _, err = cli.ContainerRemove(ctx, current.Container.ID, client.ContainerRemoveOptions{}); err != nil {
+		logger.Warn("Failed to remove the previous server container %s: %v", previousName, err)
+	}
+
+	return nil
+}
+
+// endpointsConfig returns the settings of the networks of the container to create its replacement with: the ones given
+// by the user (aliases, static IPs, links, driver options), not the ones docker assigned to the running container
+func endpointsConfig(inspect container.InspectResponse) map[string]*network.EndpointSettings {
+	endpoints := map[string]*network.EndpointSettings{}
+
+	if inspect.NetworkSettings == nil {
+		return endpoints
+	}
+
+	for name, endpoint := range inspect.NetworkSettings.Networks {
+		if endpoint == nil {
+			continue
+		}
+
+		endpoints[name] = &network.EndpointSettings{
+			IPAMConfig: endpoint.IPAMConfig.Copy(),
+			Links:      slices.Clone(endpoint.Links),
+			Aliases:    slices.Clone(endpoint.Aliases),
+			DriverOpts: maps.Clone(endpoint.DriverOpts),
+			GwPriority: endpoint.GwPriority,
+		}
+	}
+
+	return endpoints
+}
+
+// waitForStableContainer fails as soon as the container stops or gets restarted within the probation period
+func waitForStableContainer(ctx context.Context, cli *client.Client, containerID string, probation time.Duration) error {
+	deadline := time.Now().Add(probation)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(2 * time.Second)
+
+		inspect, err := cli.ContainerInspect(ctx, containerID, client.ContainerInspectOptions{})
+
+		if err != nil {
+			return err
+		}
+
+		if inspect.Container.State == nil || !inspect.Container.State.Running || inspect.Container.RestartCount > 0 {
+			return fmt.Errorf("the upgraded server container has stopped (%s)", strings.TrimSpace(describeContainerState(inspect.Container)))
+		}
+	}
+
+	return nil
+}
+
+func describeContainerState(inspect container.InspectResponse) string {
+	if inspect.State == nil {
+		return "unknown state"
+	}
+
+	return fmt.Sprintf("%s, exit code %d, %d restarts %s", inspect.State.Status, inspect.State.ExitCode, inspect.RestartCount, inspect.State.Error)
+}
+
+// restoreServerContainer removes the new container and starts the previous one under its name again
+func restoreServerContainer(ctx context.Context, cli *client.Client, containerName string, previousID string, cause error) error {
+	logger.Error("Failed to replace the server container, restoring the previous one: %v", cause)
+
+	previous, err := cli.ContainerInspect(ctx, previousID, client.ContainerInspectOptions{})
+
+	if err != nil {
+		return fmt.Errorf("%w (restoring the previous container failed: %v)", cause, err)
+	}
+
+	if strings.TrimPrefix(previous.Container.Name, "/") != containerName {
+		if _, err = cli.ContainerRemove(ctx, containerName, client.ContainerRemoveOptions{Force: true}); err != nil && !cerrdefs.IsNotFound(err) {
+			return fmt.Errorf("%w (restoring the previous container failed: %v)", cause, err)
+		}
+
+		if _, err = cli.ContainerRename(ctx, previousID, client.ContainerRenameOptions{NewName: containerName}); err != nil {
+			return fmt.Errorf("%w (restoring the previous container failed: %v)", cause, err)
+		}
+	}
+
+	if _, err = cli.ContainerStart(ctx, previousID, client.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("%w (restoring the previous container failed: %v)", cause, err)
+	}
+
+	return cause
+}
@@ -0,0 +1,46 @@
+package dockerutils
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+)
+
+func TestEndpointsConfig(t *testing.T) {
+	inspect := container.InspectResponse{
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"wireport-net": {
+					IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: netip.MustParseAddr("172.20.0.10")},
+					Aliases:    []string{"wireport"},
+					DriverOpts: map[string]string{"com.example.option": "1"},
+					NetworkID:  "3f2a",
+					EndpointID: "9c1b",
+					IPAddress:  netip.MustParseAddr("172.20.0.10"),
+				},
+			},
+		},
+	}
+
+	endpoints := endpointsConfig(inspect)
+	endpoint, ok := endpoints["wireport-net"]
+
+	if !ok || len(endpoints) != 1 {
+		t.Fatalf("expected the settings of wireport-net, got %v", endpoints)
+	}
+
+	if endpoint.IPAMConfig == nil || endpoint.IPAMConfig.IPv4Address != netip.MustParseAddr("172.20.0.10") {
+		t.Errorf("expected the static IP to be kept, got %+v", endpoint.IPAMConfig)
+	}
+
+	if !slices.Equal(endpoint.Aliases, []string{"wireport"}) || endpoint.DriverOpts["com.example.option"] != "1" {
+		t.Errorf("expected the aliases and driver options to be kept, got %+v", endpoint)
+	}
+
+	if endpoint.NetworkID != "" || endpoint.EndpointID != "" || endpoint.IPAddress.IsValid() {
+		t.Errorf("expected the operational data to be dropped, got %+v", endpoint)
+	}
+}
@@ -0,0 +1,11 @@
+package atrest
+
+import "errors"
+
+var (
+	ErrNoKey              = errors.New("no master key is set")
+	ErrInvalidKey         = errors.New("invalid master key")
+	ErrUnknownKey         = errors.New("the value is encrypted with another master key")
+	ErrInvalidCiphertext  = errors.New("invalid encrypted value")
+	ErrKeyringUnavailable = errors.New("no OS keyring is available")
+)
@@ -0,0 +1,227 @@
+package atrest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const keySize = 32 // AES-256
+
+// KeySource is where the master key is kept
+type KeySource string
+
+const (
+	KeySourceEnv     KeySource = "env"
+	KeySourceFile    KeySource = "file"
+	KeySourceKeyring KeySource = "keyring"
+)
+
+// Key is the master key the secrets in the database are encrypted with
+type Key struct {
+	ID     string // fingerprint stored with every encrypted value, tells a wrong key apart from corrupted data
+	Source KeySource
+	bytes  []byte
+}
+
+// KeyOptions tell where the master key is looked up, see LoadKey
+type KeyOptions struct {
+	EnvValue       string // base64 key taken from the environment, takes precedence
+	EnvName        string // name of the environment variable, for messages
+	FilePath       string
+	KeyringAccount string // account of the key in the OS keyring, empty to skip the keyring
+}
+
+func newKey(bytes []byte, source KeySource) (*Key, error) {
+	if len(bytes) != keySize {
+		return nil, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidKey, keySize, len(bytes))
+	}
+
+	fingerprint := sha256.Sum256(bytes)
+
+	return &Key{ID: hex.EncodeToString(fingerprint[:4]), Source: source, bytes: bytes}, nil
+}
+
+// ParseKey decodes a base64 master key
+func ParseKey(value string, source KeySource) (*Key, error) {
+	bytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	return newKey(bytes, source)
+}
+
+// GenerateKey returns a new random master key for the source
+func GenerateKey(source KeySource) (*Key, error) {
+	bytes := make([]byte, keySize)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+
+	return newKey(bytes, source)
+}
+
+// String returns the base64 encoding the key is kept in
+func (k *Key) String() string {
+	return base64.StdEncoding.EncodeToString(k.bytes)
+}
+
+// LoadKey returns the master key, see ReadKey. Without a key, a new one is generated and stored in the OS keyring if there
+// is one (e.g. on CLIENT laptops), in the key file otherwise (e.g. in the gateway and server containers)
+func LoadKey(options KeyOptions) (*Key, error) {
+	key, err := ReadKey(options)
+
+	if err != nil || key != nil {
+		return key, err
+	}
+
+	source := KeySourceFile
+
+	if options.KeyringAccount != "" && keyringAvailable() {
+		source = KeySourceKeyring
+	}
+
+	if key, err = GenerateKey(source); err != nil {
+		return nil, err
+	}
+
+	if err = StoreKey(key, options); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ReadKey returns the master key from the environment, the key file or the OS keyring, in this order; nil if there is none
+func ReadKey(options KeyOptions) (*Key, error) {
+	if options.EnvValue != "" {
+		key, err := ParseKey(options.EnvValue, KeySourceEnv)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the master key from %s: %w", options.EnvName, err)
+		}
+
+		return key, nil
+	}
+
+	if data, err := os.ReadFile(options.FilePath); err == nil {
+		key, err := ParseKey(string(data), KeySourceFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the master key from %s: %w", options.FilePath, err)
+		}
+
+		return key, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read the master key from %s: %w", options.FilePath, err)
+	}
+
+	if options.KeyringAccount == "" || !keyringAvailable() {
+		return nil, nil
+	}
+
+	value, err := keyringGet(options.KeyringAccount)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the master key from the OS keyring: %w", err)
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	key, err := ParseKey(value, KeySourceKeyring)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the master key from the OS keyring: %w", err)
+	}
+
+	return key, nil
+}
+
+// StoreKey saves the key to its source, a key from the environment cannot be saved
+func StoreKey(key *Key, options KeyOptions) error {
+	switch key.Source {
+	case KeySourceFile:
+		if err := os.MkdirAll(filepath.Dir(options.FilePath), 0700); err != nil {
+			return err
+		}
+
+		// written next to the key file and renamed, a failure never leaves a truncated key behind
+		tmpPath := options.FilePath + ".tmp"
+
+		if err := os.WriteFile(tmpPath, []byte(key.String()+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to save the master key to %s: %w", options.FilePath, err)
+		}
+
+		if err := os.Rename(tmpPath, options.FilePath); err != nil {
+			return fmt.Errorf("failed to save the master key to %s: %w", options.FilePath, err)
+		}
+
+		return nil
+	case KeySourceKeyring:
+		if err := keyringSet(options.KeyringAccount, key.String()); err != nil {
+			return fmt.Errorf("failed to save the master key to the OS keyring: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("the master key from %s cannot be saved, set it there", options.EnvName)
+}
+
+// archivedKeyOptions tell where the previous master key with the ID is kept: next to the key file and in the OS keyring,
+// both with the ID as suffix; a key from the environment is never archived
+func archivedKeyOptions(keyID string, options KeyOptions) KeyOptions {
+	archived := KeyOptions{FilePath: options.FilePath + "." + keyID}
+
+	if options.KeyringAccount != "" {
+		archived.KeyringAccount = options.KeyringAccount + "." + keyID
+	}
+
+	return archived
+}
+
+// ArchiveKey saves a master key being replaced where ReadArchivedKey finds it, e.g. to read a backup of the database
+// encrypted with it; returns where it has been saved
+func ArchiveKey(key *Key, options KeyOptions) (string, error) {
+	if key.Source == KeySourceEnv {
+		return "", fmt.Errorf("the master key from %s cannot be archived, keep it to read the values encrypted with it", options.EnvName)
+	}
+
+	archived := archivedKeyOptions(key.ID, options)
+
+	if err := StoreKey(key, archived); err != nil {
+		return "", err
+	}
+
+	if key.Source == KeySourceKeyring {
+		return fmt.Sprintf("the OS keyring (account %s)", archived.KeyringAccount), nil
+	}
+
+	return archived.FilePath, nil
+}
+
+// ReadArchivedKey returns the previous master key with the ID saved by ArchiveKey, nil if there is none
+func ReadArchivedKey(keyID string, options KeyOptions) (*Key, error) {
+	key, err := ReadKey(archivedKeyOptions(keyID, options))
+
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	if key.ID != keyID {
+		return nil, fmt.Errorf("%w: the archived key %s has the ID %s", ErrInvalidKey, keyID, key.ID)
+	}
+
+	return key, nil
+}
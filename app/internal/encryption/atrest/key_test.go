@@ -0,0 +1,55 @@
+package atrest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadKey_GeneratesKeyFile(t *testing.T) {
+	options := KeyOptions{FilePath: filepath.Join(t.TempDir(), "master.key")}
+
+	key, err := LoadKey(options)
+
+	if err != nil || key.Source != KeySourceFile {
+		t.Fatalf("expected a new key in the key file, got %+v %v", key, err)
+	}
+
+	info, err := os.Stat(options.FilePath)
+
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the key file to be readable by the owner only, got %v %v", info, err)
+	}
+
+	loaded, err := LoadKey(options)
+
+	if err != nil || loaded.ID != key.ID {
+		t.Errorf("expected the saved key to be loaded, got %+v %v", loaded, err)
+	}
+}
+
+func TestLoadKey_EnvTakesPrecedence(t *testing.T) {
+	envKey := mustGenerateKey(t)
+	options := KeyOptions{EnvValue: envKey.String(), EnvName: "WIREPORT_MASTER_KEY", FilePath: filepath.Join(t.TempDir(), "master.key")}
+
+	key, err := LoadKey(options)
+
+	if err != nil || key.ID != envKey.ID || key.Source != KeySourceEnv {
+		t.Fatalf("expected the key from the environment, got %+v %v", key, err)
+	}
+
+	if _, err = os.Stat(options.FilePath); !os.IsNotExist(err) {
+		t.Errorf("expected no key file, got %v", err)
+	}
+
+	if err = StoreKey(key, options); err == nil {
+		t.Errorf("expected a key from the environment not to be saved")
+	}
+}
+
+func TestLoadKey_Invalid(t *testing.T) {
+	if _, err := LoadKey(KeyOptions{EnvValue: "c2hvcnQ=", EnvName: "WIREPORT_MASTER_KEY"}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for a short key, got %v", err)
+	}
+}
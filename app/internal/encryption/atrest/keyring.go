@@ -0,0 +1,189 @@
+package atrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// keyringService is the service the master keys are stored under, the account is the database path
+const keyringService = "wireport"
+
+const keyringTimeout = 30 * time.Second // the keyring may ask the user to unlock it
+
+// keyringAvailable reports whether the OS keyring can be used: the macOS keychain (security) or the Secret Service of the
+// desktop session on Linux (secret-tool from libsecret); headless hosts and containers have neither
+func keyringAvailable() bool {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := exec.LookPath("security")
+		return err == nil
+	case "linux":
+		_, err := exec.LookPath("secret-tool")
+		return err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+	}
+
+	return false
+}
+
+// keyringGet returns the value stored for the account, empty if there is none
+func keyringGet(account string) (string, error) {
+	var (
+		stdout []byte
+		err    error
+	)
+
+	switch runtime.GOOS {
+	case "darwin":
+		stdout, err = runKeyringCommand(nil, "security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+
+		var exitErr *exec.ExitError
+
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			// errSecItemNotFound
+			return "", nil
+		}
+	case "linux":
+		stdout, err = runKeyringCommand(nil, "secret-tool", "lookup", "service", keyringService, "account", account)
+
+		var exitErr *exec.ExitError
+
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(stdout) == 0 {
+			return "", nil
+		}
+	default:
+		return "", ErrKeyringUnavailable
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+func keyringSet(account string, value string) error {
+	var err error
+
+	switch runtime.GOOS {
+	case "darwin":
+		// the command is read by 'security -i' from stdin, the key never shows up in the arguments of a process
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -w %s\n",
+			securityQuote(keyringService), securityQuote(account), securityQuote("wireport master key"), securityQuote(value))
+
+		if _, err = runKeyringCommand([]byte(command), "security", "-i"); err != nil {
+			return err
+		}
+
+		// a failed command does not always fail 'security -i', the key is read back
+		var stored string
+
+		if stored, err = keyringGet(account); err == nil && stored != value {
+			err = errors.New("security: the key has not been saved to the keychain")
+		}
+	case "linux":
+		_, err = runKeyringCommand([]byte(value), "secret-tool", "store", "--label=wireport master key", "service", keyringService, "account", account)
+	default:
+		return ErrKeyringUnavailable
+	}
+
+	return err
+}
+
+// securityQuote quotes an argument of a command read by 'security -i'
+func securityQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func keyringDelete(account string) error {
+	var err error
+
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runKeyringCommand(nil, "security", "delete-generic-password", "-s", keyringService, "-a", account)
+	case "linux":
+		_, err = runKeyringCommand(nil, "secret-tool", "clear", "service", keyringService, "account", account)
+	default:
+		return ErrKeyringUnavailable
+	}
+
+	return err
+}
+
+// MoveKeyringKey moves the master key kept in the OS keyring for a database to another database path, e.g. when the
+// profile directory holding the database is renamed; nothing is moved without a keyring or a key in it
+func MoveKeyringKey(fromAccount string, toAccount string) error {
+	if !keyringAvailable() {
+		return nil
+	}
+
+	value, err := keyringGet(fromAccount)
+
+	if err != nil {
+		return fmt.Errorf("failed to read the master key from the OS keyring: %w", err)
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	if err = keyringSet(toAccount, value); err != nil {
+		return fmt.Errorf("failed to save the master key to the OS keyring: %w", err)
+	}
+
+	if err = keyringDelete(fromAccount); err != nil {
+		return fmt.Errorf("failed to remove the previous master key from the OS keyring: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteKeyringKey removes the master key kept in the OS keyring for a database, e.g. when its profile is deleted
+func DeleteKeyringKey(account string) error {
+	if !keyringAvailable() {
+		return nil
+	}
+
+	value, err := keyringGet(account)
+
+	if err != nil || value == "" {
+		return err
+	}
+
+	if err = keyringDelete(account); err != nil {
+		return fmt.Errorf("failed to remove the master key from the OS keyring: %w", err)
+	}
+
+	return nil
+}
+
+func runKeyringCommand(stdin []byte, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return stdout.Bytes(), fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+		}
+
+		return stdout.Bytes(), fmt.Errorf("%s: %w", name, err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,288 @@
+package atrest
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName is the name of the serializer in the gorm tags, e.g. `gorm:"type:text;serializer:encrypted"`
+const SerializerName = "encrypted"
+
+// prefix of the encrypted values: wpenc:v1:<key ID>:<base64 nonce + ciphertext>
+const valuePrefix = "wpenc:v1:"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+var (
+	keysMu    sync.RWMutex
+	keys      []*Key // the first one encrypts, all of them decrypt
+	keyLoader func() (*Key, error)
+
+	archivedKeyLoader func(keyID string) (*Key, error)
+)
+
+// SetKeyLoader sets how the master key is read again when a value needs another key, e.g. after 'db rekey' changed the
+// key in another process
+func SetKeyLoader(loader func() (*Key, error)) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	keyLoader = loader
+}
+
+// SetArchivedKeyLoader sets how a previous master key is read when a value needs a key that is not the current one, e.g.
+// in a database restored from a backup made before 'db rekey', see ArchiveKey
+func SetArchivedKeyLoader(loader func(keyID string) (*Key, error)) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	archivedKeyLoader = loader
+}
+
+// SetKeys sets the master key the values are encrypted with, the other keys are only used to decrypt (e.g. while
+// re-encrypting the database with a new key); nil unsets the keys
+func SetKeys(key *Key, decryptOnly ...*Key) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if key == nil {
+		keys = nil
+		return
+	}
+
+	keys = append([]*Key{key}, decryptOnly...)
+}
+
+// CurrentKey returns the master key the values are encrypted with, nil if none is set
+func CurrentKey() *Key {
+	if activeKeys := currentKeys(); len(activeKeys) > 0 {
+		return activeKeys[0]
+	}
+
+	return nil
+}
+
+func currentKeys() []*Key {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	return keys
+}
+
+// Serializer stores the field as JSON encrypted with AES-256-GCM under the master key, see SetKeys. The value is bound
+// to its table and column. Plaintext values written before the encryption was introduced are read as they are
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	var data []byte
+
+	switch value := dbValue.(type) {
+	case nil:
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("unexpected value of %s.%s: %T", field.Schema.Table, field.DBName, dbValue)
+	}
+
+	if strings.HasPrefix(string(data), valuePrefix) {
+		plaintext, err := decrypt(string(data), associatedData(field))
+
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s.%s: %w", field.Schema.Table, field.DBName, err)
+		}
+
+		data = plaintext
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, fieldValue.Interface()); err != nil {
+			// plaintext string columns (e.g. a WireGuard private key) were not stored as JSON
+			if !setPlainString(fieldValue.Elem(), string(data)) {
+				return fmt.Errorf("failed to decode %s.%s: %w", field.Schema.Table, field.DBName, err)
+			}
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+
+	return nil
+}
+
+func (Serializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue any) (any, error) {
+	plaintext, err := json.Marshal(fieldValue)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if string(plaintext) == "null" {
+		if field.TagSettings["NOT NULL"] != "" {
+			return "", nil
+		}
+
+		return nil, nil
+	}
+
+	return encrypt(plaintext, associatedData(field))
+}
+
+func associatedData(field *schema.Field) []byte {
+	return []byte(field.Schema.Table + "." + field.DBName)
+}
+
+func setPlainString(value reflect.Value, data string) bool {
+	switch {
+	case value.Kind() == reflect.String:
+		value.SetString(data)
+		return true
+	case value.Kind() == reflect.Pointer && value.Type().Elem().Kind() == reflect.String:
+		value.Set(reflect.New(value.Type().Elem()))
+		value.Elem().SetString(data)
+		return true
+	}
+
+	return false
+}
+
+func encrypt(plaintext []byte, additionalData []byte) (string, error) {
+	activeKeys := currentKeys()
+
+	if len(activeKeys) == 0 {
+		return "", ErrNoKey
+	}
+
+	key := activeKeys[0]
+
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err = rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+
+	return valuePrefix + key.ID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(value string, additionalData []byte) ([]byte, error) {
+	keyID, encoded, found := strings.Cut(strings.TrimPrefix(value, valuePrefix), ":")
+
+	if !found {
+		return nil, ErrInvalidCiphertext
+	}
+
+	activeKeys := currentKeys()
+
+	if len(activeKeys) == 0 {
+		return nil, ErrNoKey
+	}
+
+	var key *Key
+
+	for _, candidate := range activeKeys {
+		if candidate.ID == keyID {
+			key = candidate
+		}
+	}
+
+	if key == nil {
+		key = reloadKey(keyID)
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("%w: the value needs the key %s, the master key is %s", ErrUnknownKey, keyID, activeKeys[0].ID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+
+	return plaintext, nil
+}
+
+// reloadKey reads the master key again and makes it the current one if it is the key with the ID; otherwise the archived
+// key with the ID is read and kept to decrypt. nil if there is neither
+func reloadKey(keyID string) *Key {
+	keysMu.RLock()
+	loader, archivedLoader := keyLoader, archivedKeyLoader
+	keysMu.RUnlock()
+
+	if loader != nil {
+		if key, err := loader(); err == nil && key != nil && key.ID == keyID {
+			SetKeys(key)
+			return key
+		}
+	}
+
+	if archivedLoader == nil {
+		return nil
+	}
+
+	key, err := archivedLoader(keyID)
+
+	if err != nil || key == nil || key.ID != keyID {
+		return nil
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	keys = append(slices.Clone(keys), key)
+
+	return key
+}
+
+func newAEAD(key *Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.bytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,219 @@
+package atrest
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wireport/internal/database/testutil"
+
+	"gorm.io/gorm"
+)
+
+type testBundle struct {
+	PrivateKey string `json:"private_key"`
+}
+
+type testSecret struct {
+	ID     uint        `gorm:"primaryKey"`
+	Key    string      `gorm:"type:text;serializer:encrypted;not null"`
+	Token  *string     `gorm:"type:text;serializer:encrypted"`
+	Bundle *testBundle `gorm:"type:text;serializer:encrypted"`
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db := testutil.NewInMemoryDB(t, &testSecret{})
+
+	return db
+}
+
+func withKeys(t *testing.T, key *Key, decryptOnly ...*Key) {
+	SetKeys(key, decryptOnly...)
+
+	t.Cleanup(func() {
+		SetKeys(nil)
+		SetKeyLoader(nil)
+		SetArchivedKeyLoader(nil)
+	})
+}
+
+func mustGenerateKey(t *testing.T) *Key {
+	key, err := GenerateKey(KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+func rawColumn(t *testing.T, db *gorm.DB, column string) string {
+	var value string
+
+	if err := db.Table("test_secrets").Select(column).Where("id = ?", 1).Scan(&value).Error; err != nil {
+		t.Fatalf("failed to read %s: %v", column, err)
+	}
+
+	return value
+}
+
+func TestSerializer_RoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	withKeys(t, mustGenerateKey(t))
+
+	token := "join-token-key"
+
+	if err := db.Create(&testSecret{ID: 1, Key: "wg-private-key", Token: &token, Bundle: &testBundle{PrivateKey: "ca-key"}}).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, column := range []string{"key", "token", "bundle"} {
+		if raw := rawColumn(t, db, column); !strings.HasPrefix(raw, valuePrefix) || strings.Contains(raw, "key") {
+			t.Errorf("expected %s to be encrypted, got %q", column, raw)
+		}
+	}
+
+	var secret testSecret
+
+	if err := db.First(&secret, 1).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if secret.Key != "wg-private-key" || secret.Token == nil || *secret.Token != token || secret.Bundle == nil || secret.Bundle.PrivateKey != "ca-key" {
+		t.Errorf("expected the decrypted values, got %+v", secret)
+	}
+}
+
+func TestSerializer_ReadsPlaintext(t *testing.T) {
+	db := newTestDB(t)
+	withKeys(t, mustGenerateKey(t))
+
+	if err := db.Exec(`INSERT INTO test_secrets (id, key, token, bundle) VALUES (1, 'wg-private-key', 'join-token-key', '{"private_key":"ca-key"}')`).Error; err != nil {
+		t.Fatalf("failed to insert plaintext row: %v", err)
+	}
+
+	var secret testSecret
+
+	if err := db.First(&secret, 1).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if secret.Key != "wg-private-key" || secret.Token == nil || *secret.Token != "join-token-key" || secret.Bundle == nil || secret.Bundle.PrivateKey != "ca-key" {
+		t.Errorf("expected the plaintext values, got %+v", secret)
+	}
+}
+
+func TestSerializer_KeyRotation(t *testing.T) {
+	db := newTestDB(t)
+	previous := mustGenerateKey(t)
+	withKeys(t, previous)
+
+	if err := db.Create(&testSecret{ID: 1, Key: "wg-private-key"}).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	next := mustGenerateKey(t)
+	SetKeys(next)
+
+	var secret testSecret
+
+	if err := db.First(&secret, 1).Error; !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+
+	SetKeys(next, previous)
+
+	if err := db.First(&secret, 1).Error; err != nil || secret.Key != "wg-private-key" {
+		t.Fatalf("expected the value to be read with the previous key, got %+v %v", secret, err)
+	}
+
+	if err := db.Save(&secret).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if raw := rawColumn(t, db, "key"); !strings.HasPrefix(raw, valuePrefix+next.ID+":") {
+		t.Errorf("expected the value to be encrypted with the new key, got %q", raw)
+	}
+}
+
+func TestSerializer_ReloadsKey(t *testing.T) {
+	db := newTestDB(t)
+	next := mustGenerateKey(t)
+	withKeys(t, next)
+
+	if err := db.Create(&testSecret{ID: 1, Key: "wg-private-key"}).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// another process replaced the key
+	SetKeys(mustGenerateKey(t))
+	SetKeyLoader(func() (*Key, error) {
+		return next, nil
+	})
+
+	var secret testSecret
+
+	if err := db.First(&secret, 1).Error; err != nil || secret.Key != "wg-private-key" {
+		t.Fatalf("expected the value to be read with the reloaded key, got %+v %v", secret, err)
+	}
+
+	if CurrentKey() != next {
+		t.Errorf("expected the reloaded key to become the current one")
+	}
+}
+
+func TestSerializer_ReadsArchivedKey(t *testing.T) {
+	db := newTestDB(t)
+	previous := mustGenerateKey(t)
+	withKeys(t, previous)
+
+	if err := db.Create(&testSecret{ID: 1, Key: "wg-private-key"}).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	options := KeyOptions{FilePath: filepath.Join(t.TempDir(), "master.key")}
+
+	path, err := ArchiveKey(previous, options)
+
+	if err != nil || path != options.FilePath+"."+previous.ID {
+		t.Fatalf("expected the key to be archived next to the key file, got %q %v", path, err)
+	}
+
+	// a database restored from a backup made before the key was replaced
+	next := mustGenerateKey(t)
+	SetKeys(next)
+	SetArchivedKeyLoader(func(keyID string) (*Key, error) {
+		return ReadArchivedKey(keyID, options)
+	})
+
+	var secret testSecret
+
+	if err = db.First(&secret, 1).Error; err != nil || secret.Key != "wg-private-key" {
+		t.Fatalf("expected the value to be read with the archived key, got %+v %v", secret, err)
+	}
+
+	if CurrentKey() != next {
+		t.Errorf("expected the archived key to only decrypt")
+	}
+}
+
+func TestSerializer_BoundToColumn(t *testing.T) {
+	db := newTestDB(t)
+	withKeys(t, mustGenerateKey(t))
+
+	token := "join-token-key"
+
+	if err := db.Create(&testSecret{ID: 1, Key: "wg-private-key", Token: &token}).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := db.Exec("UPDATE test_secrets SET key = token").Error; err != nil {
+		t.Fatalf("failed to swap the values: %v", err)
+	}
+
+	var secret testSecret
+
+	if err := db.First(&secret, 1).Error; !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("expected ErrInvalidCiphertext for a value moved to another column, got %v", err)
+	}
+}
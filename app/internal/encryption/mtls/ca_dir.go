@@ -0,0 +1,136 @@
+package mtls
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// files of an offline CA directory, see 'wireport ca': the root with its revocation list, and a gateway intermediate
+// ready to be imported by the gateway
+const (
+	RootCertFile         = "root-ca.crt"
+	RootKeyFile          = "root-ca.key"
+	RevocationListFile   = "root-ca.crl"
+	IntermediateCertFile = "intermediate.crt"
+	IntermediateKeyFile  = "intermediate.key"
+)
+
+// ReadRootDir returns the root CA and its revocation list (empty if nothing was revoked yet) from the directory
+func ReadRootDir(dir string) (*PEMBundle, string, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, RootCertFile))
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the root CA, run 'wireport ca init' first: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(filepath.Join(dir, RootKeyFile))
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the key of the root CA: %w", err)
+	}
+
+	revocationList, err := readOptionalFile(filepath.Join(dir, RevocationListFile))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &PEMBundle{CertPEM: string(certPEM), KeyPEM: string(keyPEM)}, revocationList, nil
+}
+
+// ReadIntermediateDir returns the intermediate from the directory, nil if there is none
+func ReadIntermediateDir(dir string) (*Intermediate, error) {
+	certPEM, err := readOptionalFile(filepath.Join(dir, IntermediateCertFile))
+
+	if err != nil || certPEM == "" {
+		return nil, err
+	}
+
+	keyPEM, err := os.ReadFile(filepath.Join(dir, IntermediateKeyFile))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key of the intermediate: %w", err)
+	}
+
+	rootCertPEM, err := os.ReadFile(filepath.Join(dir, RootCertFile))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the root CA certificate: %w", err)
+	}
+
+	revocationList, err := readOptionalFile(filepath.Join(dir, RevocationListFile))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Intermediate{
+		RootCertPEM:    string(rootCertPEM),
+		CA:             PEMBundle{CertPEM: certPEM, KeyPEM: string(keyPEM)},
+		RevocationList: revocationList,
+	}, nil
+}
+
+// WriteIntermediateDir writes the intermediate with the root certificate and the revocation list to the directory, to be
+// copied to the gateway
+func WriteIntermediateDir(dir string, intermediate *Intermediate) error {
+	if err := WriteCAFile(dir, IntermediateKeyFile, intermediate.CA.KeyPEM, true); err != nil {
+		return err
+	}
+
+	if err := WriteCAFile(dir, IntermediateCertFile, intermediate.CA.CertPEM, false); err != nil {
+		return err
+	}
+
+	if err := WriteCAFile(dir, RootCertFile, intermediate.RootCertPEM, false); err != nil {
+		return err
+	}
+
+	if intermediate.RevocationList == "" {
+		return nil
+	}
+
+	return WriteCAFile(dir, RevocationListFile, intermediate.RevocationList, false)
+}
+
+// WriteCAFile writes a file of a CA directory, private files (keys) are readable by the owner only
+func WriteCAFile(dir string, name string, data string, private bool) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+
+	if private {
+		mode = 0600
+	}
+
+	path := filepath.Join(dir, name)
+
+	// written next to the file and renamed, a failure never leaves a truncated file behind
+	if err := os.WriteFile(path+".tmp", []byte(data), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func readOptionalFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,570 @@
+package mtls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrInvalidCA          = errors.New("invalid CA")
+	ErrCertificateRevoked = errors.New("certificate is revoked")
+)
+
+// Intermediate is a gateway intermediate CA signed by an offline root, as imported by the gateway: the root
+// certificate comes without its key, the revocation list is signed by the root
+type Intermediate struct {
+	RootCertPEM    string
+	CA             PEMBundle
+	RevocationList string
+}
+
+// GenerateRootCA creates a root CA meant to be kept offline, it signs gateway intermediates and their revocation list
+func GenerateRootCA(commonName string, expiry time.Duration) (*PEMBundle, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := randomSerialNumber()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	tpl := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:             now,
+		NotAfter:              now.Add(expiry),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := encodePrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PEMBundle{
+		CertPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+		KeyPEM:  string(keyPEM),
+	}, nil
+}
+
+// IssueIntermediate signs a gateway intermediate CA with the root; the intermediate cannot sign other CAs and never
+// outlives the root
+func IssueIntermediate(root PEMBundle, commonName string, expiry time.Duration) (*PEMBundle, error) {
+	rootCert, rootKey, err := parseCA(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := randomSerialNumber()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	tpl := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:             now,
+		NotAfter:              capExpiry(now.Add(expiry), rootCert),
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, tpl, rootCert, &key.PublicKey, rootKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := encodePrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PEMBundle{
+		CertPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+		KeyPEM:  string(keyPEM),
+	}, nil
+}
+
+// RevokeCertificate returns the revocation list of the root with the certificate added to it, the certificate must be
+// signed by the root (i.e. a gateway intermediate)
+func RevokeCertificate(root PEMBundle, revocationList string, certPEM string) (string, error) {
+	rootCert, rootKey, err := parseCA(root)
+
+	if err != nil {
+		return "", err
+	}
+
+	cert, err := ParseCertificate(certPEM)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err = cert.CheckSignatureFrom(rootCert); err != nil {
+		return "", fmt.Errorf("%w: the certificate %s is not signed by the root: %v", ErrInvalidCA, cert.Subject.CommonName, err)
+	}
+
+	number := big.NewInt(1)
+	entries := []x509.RevocationListEntry{}
+
+	if revocationList != "" {
+		current, err := parseRevocationList(revocationList, rootCert)
+
+		if err != nil {
+			return "", err
+		}
+
+		number.Add(current.Number, big.NewInt(1))
+		entries = current.RevokedCertificateEntries
+	}
+
+	for _, entry := range entries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return revocationList, nil
+		}
+	}
+
+	now := time.Now()
+
+	entries = append(entries, x509.RevocationListEntry{SerialNumber: cert.SerialNumber, RevocationTime: now})
+
+	// the list is handed out with the certificates instead of being fetched, it is valid as long as the root
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                rootCert.NotAfter,
+		RevokedCertificateEntries: entries,
+	}, rootCert, rootKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})), nil
+}
+
+// Verify checks that the intermediate is a CA signed by the root, not revoked by the root and that its key matches
+func (i *Intermediate) Verify() error {
+	rootCert, err := ParseCertificate(i.RootCertPEM)
+
+	if err != nil {
+		return fmt.Errorf("%w: root certificate: %v", ErrInvalidCA, err)
+	}
+
+	caCert, caKey, err := parseCA(i.CA)
+
+	if err != nil {
+		return err
+	}
+
+	if !caCert.IsCA || caCert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("%w: %s is not a CA certificate", ErrInvalidCA, caCert.Subject.CommonName)
+	}
+
+	if !publicKeysEqual(caCert.PublicKey, caKey.Public()) {
+		return fmt.Errorf("%w: the key does not match the certificate %s", ErrInvalidCA, caCert.Subject.CommonName)
+	}
+
+	if err = caCert.CheckSignatureFrom(rootCert); err != nil {
+		return fmt.Errorf("%w: %s is not signed by the root %s: %v", ErrInvalidCA, caCert.Subject.CommonName, rootCert.Subject.CommonName, err)
+	}
+
+	if time.Now().After(caCert.NotAfter) {
+		return fmt.Errorf("%w: %s expired on %s", ErrInvalidCA, caCert.Subject.CommonName, caCert.NotAfter.Format(time.DateOnly))
+	}
+
+	if i.RevocationList == "" {
+		return nil
+	}
+
+	revoked, err := revokedSerialNumbers(i.RevocationList, rootCert)
+
+	if err != nil {
+		return err
+	}
+
+	if revoked[caCert.SerialNumber.String()] {
+		return fmt.Errorf("%w: %s", ErrCertificateRevoked, caCert.Subject.CommonName)
+	}
+
+	return nil
+}
+
+// GenerateWithIntermediate creates a gateway bundle whose certificates are signed by the intermediate, the root
+// stays offline and only its certificate is kept
+func GenerateWithIntermediate(serverOpt Options, intermediate *Intermediate) (*FullGatewayBundle, error) {
+	bundle := &FullGatewayBundle{
+		Clients:   map[string]PEMBundle{},
+		Generated: time.Now(),
+	}
+
+	if err := bundle.ImportIntermediate(intermediate, serverOpt); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// ImportIntermediate makes the intermediate sign the certificates of the bundle and issues a new server certificate.
+// Client certificates signed by a previous intermediate stay valid until it is revoked, see RenewClient
+func (b *FullGatewayBundle) ImportIntermediate(intermediate *Intermediate, serverOpt Options) error {
+	if err := intermediate.Verify(); err != nil {
+		return err
+	}
+
+	caCert, caKey, err := parseCA(intermediate.CA)
+
+	if err != nil {
+		return err
+	}
+
+	serverCert, serverKey, err := createSignedCert(serverOpt, caCert, caKey, true)
+
+	if err != nil {
+		return err
+	}
+
+	b.RootCA = PEMBundle{CertPEM: intermediate.RootCertPEM}
+	b.Intermediate = &PEMBundle{CertPEM: intermediate.CA.CertPEM, KeyPEM: intermediate.CA.KeyPEM}
+	b.RevocationList = intermediate.RevocationList
+	b.Server = PEMBundle{CertPEM: string(serverCert) + intermediate.CA.CertPEM, KeyPEM: string(serverKey)}
+
+	return nil
+}
+
+// ServerOptions returns the options the current server certificate was issued with, to issue it again
+func (b *FullGatewayBundle) ServerOptions(expiry time.Duration) (Options, error) {
+	cert, err := ParseCertificate(b.Server.CertPEM)
+
+	if err != nil {
+		return Options{}, err
+	}
+
+	options := Options{CommonName: cert.Subject.CommonName, Expiry: expiry, DNSNames: cert.DNSNames}
+
+	for _, ip := range cert.IPAddresses {
+		options.IPAddresses = append(options.IPAddresses, ip.String())
+	}
+
+	return options, nil
+}
+
+// HasRoot tells whether the bundle trusts the root certificate, i.e. its nodes trust certificates the root signs
+func (b *FullGatewayBundle) HasRoot(rootCertPEM string) bool {
+	current, err := ParseCertificate(b.RootCA.CertPEM)
+
+	if err != nil {
+		return false
+	}
+
+	root, err := ParseCertificate(rootCertPEM)
+
+	return err == nil && bytes.Equal(current.Raw, root.Raw)
+}
+
+// IsRootOffline tells whether the key of the root is kept away from the gateway, i.e. an intermediate signs the certificates
+func (b *FullGatewayBundle) IsRootOffline() bool {
+	return b.Intermediate != nil && b.RootCA.KeyPEM == ""
+}
+
+// IsRevoked tells whether every verified chain of a peer goes through a certificate revoked by the root
+func (b *FullGatewayBundle) IsRevoked(chains [][]*x509.Certificate) (bool, error) {
+	revocations, err := b.Revocations()
+
+	if err != nil {
+		return false, err
+	}
+
+	return revocations.IsRevoked(chains), nil
+}
+
+// Revocations returns the intermediates revoked by the root of the bundle, nil if there are none
+func (b *FullGatewayBundle) Revocations() (*Revocations, error) {
+	return NewRevocations(b.RevocationList, b.RootCA.CertPEM)
+}
+
+// IsCurrentClient tells whether the certificate is the last one issued to the client, e.g. to let it renew the
+// certificate after its intermediate was revoked
+func (b *FullGatewayBundle) IsCurrentClient(clientName string, cert *x509.Certificate) bool {
+	clientData, ok := b.Clients[clientName]
+
+	if !ok || cert == nil {
+		return false
+	}
+
+	current, err := ParseCertificate(clientData.CertPEM)
+
+	return err == nil && bytes.Equal(current.Raw, cert.Raw)
+}
+
+// RenewClient issues a new certificate and key for the client, signed by the current intermediate
+func (b *FullGatewayBundle) RenewClient(clientName string, expiry time.Duration) (*FullClientBundle, error) {
+	if _, ok := b.Clients[clientName]; !ok {
+		return nil, errors.New("client not found")
+	}
+
+	if err := b.AddClient(Options{CommonName: clientName, Expiry: expiry}); err != nil {
+		return nil, err
+	}
+
+	return b.GetClientBundlePublic(clientName)
+}
+
+// CAStatus describes the CAs of a gateway bundle
+type CAStatus struct {
+	Root         *x509.Certificate
+	RootOffline  bool
+	Intermediate *x509.Certificate // nil if the root signs the certificates
+	RevokedCount int
+}
+
+// CAStatus returns the CAs the certificates of the bundle are signed by
+func (b *FullGatewayBundle) CAStatus() (*CAStatus, error) {
+	root, err := ParseCertificate(b.RootCA.CertPEM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := &CAStatus{Root: root, RootOffline: b.IsRootOffline()}
+
+	if b.Intermediate != nil {
+		if status.Intermediate, err = ParseCertificate(b.Intermediate.CertPEM); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.RevocationList != "" {
+		revoked, err := revokedSerialNumbers(b.RevocationList, root)
+
+		if err != nil {
+			return nil, err
+		}
+
+		status.RevokedCount = len(revoked)
+	}
+
+	return status, nil
+}
+
+// signer returns the CA the certificates of the bundle are signed by, with the chain appended to the certificates
+func (b *FullGatewayBundle) signer() (*x509.Certificate, crypto.Signer, string, error) {
+	if b.Intermediate != nil {
+		caCert, caKey, err := parseCA(*b.Intermediate)
+
+		return caCert, caKey, b.Intermediate.CertPEM, err
+	}
+
+	caCert, caKey, err := parseCA(b.RootCA)
+
+	return caCert, caKey, "", err
+}
+
+// Revocations are the intermediates revoked by a root, parsed once from its revocation list to check many chains
+type Revocations struct {
+	root    *x509.Certificate
+	revoked map[string]bool
+}
+
+// NewRevocations parses the revocation list signed by the root, nil if the list is empty
+func NewRevocations(revocationList string, rootCertPEM string) (*Revocations, error) {
+	if revocationList == "" {
+		return nil, nil
+	}
+
+	rootCert, err := ParseCertificate(rootCertPEM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := revokedSerialNumbers(revocationList, rootCert)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Revocations{root: rootCert, revoked: revoked}, nil
+}
+
+// IsRevoked tells whether every verified chain goes through an intermediate revoked by the root
+func (r *Revocations) IsRevoked(chains [][]*x509.Certificate) bool {
+	if r == nil || len(chains) == 0 {
+		return false
+	}
+
+	for _, chain := range chains {
+		chainRevoked := false
+
+		for _, cert := range chain {
+			if bytes.Equal(cert.RawIssuer, r.root.RawSubject) && r.revoked[cert.SerialNumber.String()] {
+				chainRevoked = true
+				break
+			}
+		}
+
+		if !chainRevoked {
+			return false
+		}
+	}
+
+	return true
+}
+
+func revokedSerialNumbers(revocationList string, rootCert *x509.Certificate) (map[string]bool, error) {
+	crl, err := parseRevocationList(revocationList, rootCert)
+
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make(map[string]bool, len(crl.RevokedCertificateEntries))
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.String()] = true
+	}
+
+	return revoked, nil
+}
+
+func parseRevocationList(revocationList string, rootCert *x509.Certificate) (*x509.RevocationList, error) {
+	block, _ := pem.Decode([]byte(revocationList))
+
+	if block == nil {
+		return nil, fmt.Errorf("%w: failed to decode the revocation list", ErrInvalidCA)
+	}
+
+	crl, err := x509.ParseRevocationList(block.Bytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: revocation list: %v", ErrInvalidCA, err)
+	}
+
+	if err = crl.CheckSignatureFrom(rootCert); err != nil {
+		return nil, fmt.Errorf("%w: the revocation list is not signed by the root: %v", ErrInvalidCA, err)
+	}
+
+	return crl, nil
+}
+
+// ParseCertificate returns the first certificate of the PEM data, e.g. the leaf of a certificate with its chain
+func ParseCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+
+	if block == nil {
+		return nil, fmt.Errorf("%w: failed to decode the certificate", ErrInvalidCA)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// parseCA returns the certificate and the key of a CA; besides the EC keys wireport generates, PKCS#8 and PKCS#1 keys of
+// an imported root are accepted
+func parseCA(ca PEMBundle) (*x509.Certificate, crypto.Signer, error) {
+	cert, err := ParseCertificate(ca.CertPEM)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode([]byte(ca.KeyPEM))
+
+	if block == nil {
+		return nil, nil, fmt.Errorf("%w: failed to decode the key of %s", ErrInvalidCA, cert.Subject.CommonName)
+	}
+
+	var key any
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: key of %s: %v", ErrInvalidCA, cert.Subject.CommonName, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unsupported key of %s", ErrInvalidCA, cert.Subject.CommonName)
+	}
+
+	return cert, signer, nil
+}
+
+func publicKeysEqual(a crypto.PublicKey, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+
+	return ok && key.Equal(b)
+}
+
+func encodePrivateKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), nil
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// capExpiry keeps a certificate from outliving its CA, the chain would not verify anymore
+func capExpiry(notAfter time.Time, caCert *x509.Certificate) time.Time {
+	if notAfter.After(caCert.NotAfter) {
+		return caCert.NotAfter
+	}
+
+	return notAfter
+}
@@ -0,0 +1,269 @@
+package mtls
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newOfflineCA(t *testing.T) (*PEMBundle, *PEMBundle) {
+	root, err := GenerateRootCA("test offline root", time.Hour)
+
+	if err != nil {
+		t.Fatalf("generate root failed: %v", err)
+	}
+
+	intermediate, err := IssueIntermediate(*root, "test gateway intermediate", time.Hour)
+
+	if err != nil {
+		t.Fatalf("issue intermediate failed: %v", err)
+	}
+
+	return root, intermediate
+}
+
+func newGatewayWithIntermediate(t *testing.T, root *PEMBundle, intermediate *PEMBundle) *FullGatewayBundle {
+	bundle, err := GenerateWithIntermediate(Options{
+		CommonName:  "localhost",
+		Expiry:      time.Hour,
+		IPAddresses: []string{"127.0.0.1"},
+	}, &Intermediate{RootCertPEM: root.CertPEM, CA: *intermediate})
+
+	if err != nil {
+		t.Fatalf("generate with intermediate failed: %v", err)
+	}
+
+	return bundle
+}
+
+func startGateway(t *testing.T, bundle *FullGatewayBundle) *httptest.Server {
+	serverTLS, err := bundle.GetServerTLSConfig()
+
+	if err != nil {
+		t.Fatalf("server TLS config: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revoked, err := bundle.IsRevoked(r.TLS.VerifiedChains)
+
+		if err != nil || revoked {
+			http.Error(w, "revoked", http.StatusForbidden)
+			return
+		}
+
+		fmt.Fprintf(w, "hello, %s", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	ts.TLS = serverTLS
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func requestGateway(ts *httptest.Server, clientBundle *FullClientBundle) (string, error) {
+	clientTLS, err := clientBundle.GetClientTLSConfig()
+
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+	resp, err := client.Get(ts.URL)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+
+	return string(body), err
+}
+
+func TestIntermediate_MTLSCommunication(t *testing.T) {
+	root, intermediate := newOfflineCA(t)
+	bundle := newGatewayWithIntermediate(t, root, intermediate)
+
+	if !bundle.IsRootOffline() || bundle.RootCA.KeyPEM != "" {
+		t.Fatalf("expected the key of the root to stay out of the bundle")
+	}
+
+	if err := bundle.AddClient(Options{CommonName: "client1", Expiry: time.Hour}); err != nil {
+		t.Fatalf("add client failed: %v", err)
+	}
+
+	clientBundle, err := bundle.GetClientBundlePublic("client1")
+
+	if err != nil {
+		t.Fatalf("client bundle: %v", err)
+	}
+
+	body, err := requestGateway(startGateway(t, bundle), clientBundle)
+
+	if err != nil || body != "hello, client1" {
+		t.Fatalf("expected the client to be accepted, got %q %v", body, err)
+	}
+}
+
+func TestIntermediate_RevokedAfterRotation(t *testing.T) {
+	root, previous := newOfflineCA(t)
+	bundle := newGatewayWithIntermediate(t, root, previous)
+
+	if err := bundle.AddClient(Options{CommonName: "client1", Expiry: time.Hour}); err != nil {
+		t.Fatalf("add client failed: %v", err)
+	}
+
+	previousClientBundle, _ := bundle.GetClientBundlePublic("client1")
+	previousGateway := startGateway(t, &FullGatewayBundle{RootCA: bundle.RootCA, Server: bundle.Server})
+
+	revocationList, err := RevokeCertificate(*root, "", previous.CertPEM)
+
+	if err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	next, err := IssueIntermediate(*root, "test gateway intermediate 2", time.Hour)
+
+	if err != nil {
+		t.Fatalf("issue intermediate failed: %v", err)
+	}
+
+	serverOptions, err := bundle.ServerOptions(time.Hour)
+
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+
+	if err = bundle.ImportIntermediate(&Intermediate{RootCertPEM: root.CertPEM, CA: *previous, RevocationList: revocationList}, serverOptions); !errors.Is(err, ErrCertificateRevoked) {
+		t.Fatalf("expected the revoked intermediate to be refused, got %v", err)
+	}
+
+	if err = bundle.ImportIntermediate(&Intermediate{RootCertPEM: root.CertPEM, CA: *next, RevocationList: revocationList}, serverOptions); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	gateway := startGateway(t, bundle)
+
+	if body, err := requestGateway(gateway, previousClientBundle); err != nil || body != "revoked\n" {
+		t.Fatalf("expected the client certificate of the revoked intermediate to be refused, got %q %v", body, err)
+	}
+
+	renewedClientBundle, err := bundle.RenewClient("client1", time.Hour)
+
+	if err != nil {
+		t.Fatalf("renew failed: %v", err)
+	}
+
+	if body, err := requestGateway(gateway, renewedClientBundle); err != nil || body != "hello, client1" {
+		t.Fatalf("expected the renewed client to be accepted, got %q %v", body, err)
+	}
+
+	// a gateway still holding the revoked intermediate, e.g. a compromised host
+	if _, err := requestGateway(previousGateway, renewedClientBundle); !errors.Is(err, ErrCertificateRevoked) {
+		t.Errorf("expected the gateway certificate of the revoked intermediate to be refused, got %v", err)
+	}
+}
+
+func TestIntermediate_RevocationListUpdate(t *testing.T) {
+	root, previous := newOfflineCA(t)
+	bundle := newGatewayWithIntermediate(t, root, previous)
+
+	if err := bundle.AddClient(Options{CommonName: "client1", Expiry: time.Hour}); err != nil {
+		t.Fatalf("add client failed: %v", err)
+	}
+
+	// the client joined before the intermediate was revoked
+	clientBundle, _ := bundle.GetClientBundlePublic("client1")
+	previousGateway := startGateway(t, &FullGatewayBundle{RootCA: bundle.RootCA, Server: bundle.Server})
+
+	if _, err := requestGateway(previousGateway, clientBundle); err != nil {
+		t.Fatalf("expected the gateway to be accepted before the revocation, got %v", err)
+	}
+
+	revocationList, err := RevokeCertificate(*root, "", previous.CertPEM)
+
+	if err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	other, _ := IssueIntermediate(*root, "test gateway intermediate 2", time.Hour)
+	newerRevocationList, err := RevokeCertificate(*root, revocationList, other.CertPEM)
+
+	if err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	if updated, err := clientBundle.UpdateRevocationList(newerRevocationList); err != nil || !updated {
+		t.Fatalf("expected the revocation list to be updated, got %v %v", updated, err)
+	}
+
+	if updated, err := clientBundle.UpdateRevocationList(revocationList); err != nil || updated {
+		t.Errorf("expected an older revocation list to be ignored, got %v %v", updated, err)
+	}
+
+	otherRoot, otherIntermediate := newOfflineCA(t)
+	foreignRevocationList, err := RevokeCertificate(*otherRoot, "", otherIntermediate.CertPEM)
+
+	if err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	if _, err := clientBundle.UpdateRevocationList(foreignRevocationList); !errors.Is(err, ErrInvalidCA) {
+		t.Errorf("expected ErrInvalidCA for a revocation list of another root, got %v", err)
+	}
+
+	if clientBundle.RevocationList != newerRevocationList {
+		t.Errorf("expected the newest revocation list to be kept")
+	}
+
+	if _, err := requestGateway(previousGateway, clientBundle); !errors.Is(err, ErrCertificateRevoked) {
+		t.Errorf("expected the gateway certificate of the revoked intermediate to be refused, got %v", err)
+	}
+}
+
+func TestIntermediate_CurrentClient(t *testing.T) {
+	root, intermediate := newOfflineCA(t)
+	bundle := newGatewayWithIntermediate(t, root, intermediate)
+
+	if err := bundle.AddClient(Options{CommonName: "client1", Expiry: time.Hour}); err != nil {
+		t.Fatalf("add client failed: %v", err)
+	}
+
+	previous, _ := ParseCertificate(bundle.Clients["client1"].CertPEM)
+
+	if !bundle.IsCurrentClient("client1", previous) {
+		t.Fatalf("expected the issued certificate to be the current one")
+	}
+
+	if _, err := bundle.RenewClient("client1", time.Hour); err != nil {
+		t.Fatalf("renew failed: %v", err)
+	}
+
+	if bundle.IsCurrentClient("client1", previous) {
+		t.Errorf("expected a renewed certificate to replace the previous one")
+	}
+}
+
+func TestIntermediate_Verify(t *testing.T) {
+	root, intermediate := newOfflineCA(t)
+	otherRoot, _ := newOfflineCA(t)
+
+	if err := (&Intermediate{RootCertPEM: otherRoot.CertPEM, CA: *intermediate}).Verify(); !errors.Is(err, ErrInvalidCA) {
+		t.Errorf("expected ErrInvalidCA for an intermediate of another root, got %v", err)
+	}
+
+	_, otherIntermediate := newOfflineCA(t)
+
+	if err := (&Intermediate{RootCertPEM: root.CertPEM, CA: PEMBundle{CertPEM: intermediate.CertPEM, KeyPEM: otherIntermediate.KeyPEM}}).Verify(); !errors.Is(err, ErrInvalidCA) {
+		t.Errorf("expected ErrInvalidCA for a key not matching the certificate, got %v", err)
+	}
+
+	if err := (&Intermediate{RootCertPEM: root.CertPEM, CA: *intermediate}).Verify(); err != nil {
+		t.Errorf("expected the intermediate to be valid, got %v", err)
+	}
+}
@@ -1,19 +1,26 @@
 package mtls
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"math/big"
 	"net"
 	"time"
 )
 
+// RevocationListHeader carries the revocation list of the gateway with every control API response, the nodes keep
+// the newest one to refuse the certificates of revoked intermediates
+const RevocationListHeader = "X-Wireport-Revocation-List"
+
 type Options struct {
 	CommonName  string
 	Expiry      time.Duration
@@ -31,82 +38,102 @@ type FullGatewayBundle struct {
 	Server    PEMBundle            `json:"server"`
 	Clients   map[string]PEMBundle `json:"clients"`
 	Generated time.Time            `json:"generated_at"`
+
+	// ServicesClientCA signs client certificates for public services; kept apart from RootCA,
+	// so that these certificates are never accepted by the control API
+	ServicesClientCA *PEMBundle `json:"services_client_ca,omitempty"`
+
+	// Intermediate signs the server and client certificates when the root is kept offline, RootCA has no key then;
+	// RevocationList is signed by the root and lists the intermediates that must not be trusted anymore
+	Intermediate   *PEMBundle `json:"intermediate,omitempty"`
+	RevocationList string     `json:"revocation_list,omitempty"`
 }
 
 type FullClientBundle struct {
-	RootCA    PEMBundle `json:"root_ca"`
-	Client    PEMBundle `json:"client"`
-	Generated time.Time `json:"generated_at"`
+	RootCA         PEMBundle `json:"root_ca"`
+	Client         PEMBundle `json:"client"`
+	Generated      time.Time `json:"generated_at"`
+	RevocationList string    `json:"revocation_list,omitempty"`
 }
 
 // Generate creates a full mTLS bundle with root CA and server cert
 func Generate(serverOpt Options, rootExpiry time.Duration) (*FullGatewayBundle, error) {
 	// Root CA
-	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rootPEM, rootKeyPEM, rootCertParsed, rootKey, err := createCA("wireport gateway Root CA", rootExpiry)
 
 	if err != nil {
 		return nil, err
 	}
 
-	rootTpl := &x509.Certificate{
+	serverCert, serverKey, err := createSignedCert(serverOpt, rootCertParsed, rootKey, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &FullGatewayBundle{
+		RootCA: PEMBundle{
+			CertPEM: string(rootPEM),
+			KeyPEM:  string(rootKeyPEM),
+		},
+		Server: PEMBundle{
+			CertPEM: string(serverCert),
+			KeyPEM:  string(serverKey),
+		},
+		Clients:   map[string]PEMBundle{},
+		Generated: time.Now(),
+	}
+
+	return bundle, nil
+}
+
+func createCA(commonName string, expiry time.Duration) ([]byte, []byte, *x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	tpl := &x509.Certificate{
 		SerialNumber: big.NewInt(1),
 		Subject: pkix.Name{
-			CommonName: "wireport gateway Root CA",
+			CommonName: commonName,
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(rootExpiry),
+		NotAfter:              time.Now().Add(expiry),
 		IsCA:                  true,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		BasicConstraintsValid: true,
 	}
 
-	rootCert, err := x509.CreateCertificate(rand.Reader, rootTpl, rootTpl, &rootKey.PublicKey, rootKey)
+	cert, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	rootCertParsed, err := x509.ParseCertificate(rootCert)
+	certParsed, err := x509.ParseCertificate(cert)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootCert})
-	rootKeyBytes, err := x509.MarshalECPrivateKey(rootKey)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	keyBytes, err := x509.MarshalECPrivateKey(key)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, nil, err
 	}
-	rootKeyPEM := pem.EncodeToMemory(&pem.Block{
+	keyPEM := pem.EncodeToMemory(&pem.Block{
 		Type:  "EC PRIVATE KEY",
-		Bytes: rootKeyBytes,
+		Bytes: keyBytes,
 	})
 
-	serverCert, serverKey, err := createSignedCert(serverOpt, rootCertParsed, rootKey, true)
-
-	if err != nil {
-		return nil, err
-	}
-
-	bundle := &FullGatewayBundle{
-		RootCA: PEMBundle{
-			CertPEM: string(rootPEM),
-			KeyPEM:  string(rootKeyPEM),
-		},
-		Server: PEMBundle{
-			CertPEM: string(serverCert),
-			KeyPEM:  string(serverKey),
-		},
-		Clients:   map[string]PEMBundle{},
-		Generated: time.Now(),
-	}
-
-	return bundle, nil
+	return certPEM, keyPEM, certParsed, key, nil
 }
 
-func createSignedCert(opt Options, caCert *x509.Certificate, caKey *ecdsa.PrivateKey, isServer bool) ([]byte, []byte, error) {
+func createSignedCert(opt Options, caCert *x509.Certificate, caKey crypto.Signer, isServer bool) ([]byte, []byte, error) {
 	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, nil, err
@@ -120,7 +147,7 @@ func createSignedCert(opt Options, caCert *x509.Certificate, caKey *ecdsa.Privat
 			CommonName: opt.CommonName,
 		},
 		NotBefore:   now,
-		NotAfter:    now.Add(opt.Expiry),
+		NotAfter:    capExpiry(now.Add(opt.Expiry), caCert),
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 		KeyUsage:    x509.KeyUsageDigitalSignature,
 	}
@@ -169,15 +196,7 @@ func (b *FullGatewayBundle) AddClient(opt Options) error {
 		b.Clients = make(map[string]PEMBundle)
 	}
 
-	caCertBlock, _ := pem.Decode([]byte(b.RootCA.CertPEM))
-	caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
-
-	if err != nil {
-		return err
-	}
-
-	caKeyBlock, _ := pem.Decode([]byte(b.RootCA.KeyPEM))
-	caKey, err := x509.ParseECPrivateKey(caKeyBlock.Bytes)
+	caCert, caKey, chainPEM, err := b.signer()
 
 	if err != nil {
 		return err
@@ -189,7 +208,8 @@ func (b *FullGatewayBundle) AddClient(opt Options) error {
 		return err
 	}
 
-	b.Clients[opt.CommonName] = PEMBundle{CertPEM: string(certPEM), KeyPEM: string(keyPEM)}
+	// the intermediate is sent along with the certificate, the peers only trust the root
+	b.Clients[opt.CommonName] = PEMBundle{CertPEM: string(certPEM) + chainPEM, KeyPEM: string(keyPEM)}
 
 	return nil
 }
@@ -248,11 +268,26 @@ func (b *FullGatewayBundle) PublicOnly() *FullGatewayBundle {
 		clients[k] = PEMBundle{CertPEM: v.CertPEM}
 	}
 
+	var servicesClientCA *PEMBundle
+
+	if b.ServicesClientCA != nil {
+		servicesClientCA = &PEMBundle{CertPEM: b.ServicesClientCA.CertPEM}
+	}
+
+	var intermediate *PEMBundle
+
+	if b.Intermediate != nil {
+		intermediate = &PEMBundle{CertPEM: b.Intermediate.CertPEM}
+	}
+
 	return &FullGatewayBundle{
-		RootCA:    PEMBundle{CertPEM: b.RootCA.CertPEM},
-		Server:    PEMBundle{CertPEM: b.Server.CertPEM},
-		Clients:   clients,
-		Generated: b.Generated,
+		RootCA:           PEMBundle{CertPEM: b.RootCA.CertPEM},
+		Server:           PEMBundle{CertPEM: b.Server.CertPEM},
+		Clients:          clients,
+		Generated:        b.Generated,
+		ServicesClientCA: servicesClientCA,
+		Intermediate:     intermediate,
+		RevocationList:   b.RevocationList,
 	}
 }
 
@@ -268,11 +303,60 @@ func (b *FullGatewayBundle) GetClientBundlePublic(clientName string) (*FullClien
 		RootCA: PEMBundle{
 			CertPEM: b.RootCA.CertPEM, // Only certificate, no private key
 		},
-		Client:    clientData, // Full client cert + key (client needs this)
-		Generated: b.Generated,
+		Client:         clientData, // Full client cert + key (client needs this)
+		Generated:      b.Generated,
+		RevocationList: b.RevocationList,
 	}, nil
 }
 
+// UpdateRevocationList replaces the revocation list of the bundle with a newer one signed by its root, e.g. the one the
+// gateway sends with every response (see RevocationListHeader); the same or an older list is ignored
+func (b *FullClientBundle) UpdateRevocationList(revocationList string) (bool, error) {
+	if revocationList == "" || revocationList == b.RevocationList {
+		return false, nil
+	}
+
+	rootCert, err := ParseCertificate(b.RootCA.CertPEM)
+
+	if err != nil {
+		return false, err
+	}
+
+	next, err := parseRevocationList(revocationList, rootCert)
+
+	if err != nil {
+		return false, err
+	}
+
+	if b.RevocationList != "" {
+		current, err := parseRevocationList(b.RevocationList, rootCert)
+
+		if err == nil && next.Number.Cmp(current.Number) <= 0 {
+			return false, nil
+		}
+	}
+
+	b.RevocationList = revocationList
+
+	return true, nil
+}
+
+// EncodeRevocationListHeader returns the revocation list as the value of RevocationListHeader
+func EncodeRevocationListHeader(revocationList string) string {
+	return base64.StdEncoding.EncodeToString([]byte(revocationList))
+}
+
+// DecodeRevocationListHeader returns the revocation list sent in RevocationListHeader
+func DecodeRevocationListHeader(value string) (string, error) {
+	revocationList, err := base64.StdEncoding.DecodeString(value)
+
+	if err != nil {
+		return "", fmt.Errorf("%w: revocation list header: %v", ErrInvalidCA, err)
+	}
+
+	return string(revocationList), nil
+}
+
 // GetServerTLSConfig returns tls.Config for server only (for mTLS server setup)
 func (b *FullGatewayBundle) GetServerTLSConfig() (*tls.Config, error) {
 	if b.Server.KeyPEM == "" || b.Server.CertPEM == "" || b.RootCA.CertPEM == "" {
@@ -317,5 +401,20 @@ func (b *FullClientBundle) GetClientTLSConfig() (*tls.Config, error) {
 		RootCAs:      rootCAPool,
 	}
 
+	revocations, err := NewRevocations(b.RevocationList, b.RootCA.CertPEM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a gateway certificate signed by a revoked intermediate is refused, e.g. one issued from a compromised host
+	clientTLS.VerifyConnection = func(state tls.ConnectionState) error {
+		if revocations.IsRevoked(state.VerifiedChains) {
+			return fmt.Errorf("%w: the certificate of the gateway is signed by a revoked intermediate", ErrCertificateRevoked)
+		}
+
+		return nil
+	}
+
 	return clientTLS, nil
 }
@@ -3,6 +3,7 @@ package mtls
 import (
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
 )
 
 func TestSerialization(t *testing.T) {
@@ -230,3 +233,78 @@ func TestServerAndClientTLSConfigs(t *testing.T) {
 		t.Fatal("client TLS config should have root CAs")
 	}
 }
+
+func TestServicesClientCertificate(t *testing.T) {
+	bundle, err := Generate(Options{CommonName: "localhost", Expiry: time.Hour, DNSNames: []string{"localhost"}}, time.Hour)
+
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	if _, err = bundle.IssueServicesClient(Options{CommonName: "alice", Expiry: time.Hour}); err == nil {
+		t.Fatal("expected error when services client CA is not generated")
+	}
+
+	changed, err := bundle.EnsureServicesClientCA(time.Hour)
+
+	if err != nil || !changed {
+		t.Fatalf("expected services client CA to be generated, changed: %v, err: %v", changed, err)
+	}
+
+	if changed, _ = bundle.EnsureServicesClientCA(time.Hour); changed {
+		t.Fatal("expected existing services client CA to be kept")
+	}
+
+	if bundle.PublicOnly().ServicesClientCA.KeyPEM != "" {
+		t.Fatal("expected public only bundle to have no services client CA key")
+	}
+
+	clientCert, err := bundle.IssueServicesClient(Options{CommonName: "alice", Expiry: time.Hour})
+
+	if err != nil {
+		t.Fatalf("issue failed: %v", err)
+	}
+
+	keyPair, err := tls.X509KeyPair([]byte(clientCert.CertPEM), []byte(clientCert.KeyPEM))
+
+	if err != nil {
+		t.Fatalf("key pair failed: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(keyPair.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	servicesPool := x509.NewCertPool()
+	servicesPool.AppendCertsFromPEM([]byte(bundle.ServicesClientCA.CertPEM))
+
+	if _, err = leaf.Verify(x509.VerifyOptions{Roots: servicesPool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("expected certificate to be signed by services client CA: %v", err)
+	}
+
+	// must never be accepted by the control API
+	rootPool := x509.NewCertPool()
+	rootPool.AppendCertsFromPEM([]byte(bundle.RootCA.CertPEM))
+
+	if _, err = leaf.Verify(x509.VerifyOptions{Roots: rootPool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err == nil {
+		t.Fatal("expected certificate not to be signed by the gateway root CA")
+	}
+
+	p12, err := EncodePKCS12(clientCert, bundle.ServicesClientCA.CertPEM, "secret")
+
+	if err != nil {
+		t.Fatalf("pkcs12 encode failed: %v", err)
+	}
+
+	_, decodedCert, caCerts, err := pkcs12.DecodeChain(p12, "secret")
+
+	if err != nil {
+		t.Fatalf("pkcs12 decode failed: %v", err)
+	}
+
+	if decodedCert.Subject.CommonName != "alice" || len(caCerts) != 1 {
+		t.Fatalf("unexpected pkcs12 contents: %s, %d CA certs", decodedCert.Subject.CommonName, len(caCerts))
+	}
+}
@@ -0,0 +1,101 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// EnsureServicesClientCA generates the CA for public services client certificates if the bundle has none yet;
+// returns true if the bundle was changed
+func (b *FullGatewayBundle) EnsureServicesClientCA(expiry time.Duration) (bool, error) {
+	if b.ServicesClientCA != nil {
+		return false, nil
+	}
+
+	certPEM, keyPEM, _, _, err := createCA("wireport public services client CA", expiry)
+
+	if err != nil {
+		return false, err
+	}
+
+	b.ServicesClientCA = &PEMBundle{
+		CertPEM: string(certPEM),
+		KeyPEM:  string(keyPEM),
+	}
+
+	return true, nil
+}
+
+// IssueServicesClient signs a client certificate for public services with client authentication enabled
+func (b *FullGatewayBundle) IssueServicesClient(opt Options) (*PEMBundle, error) {
+	if b.ServicesClientCA == nil || b.ServicesClientCA.KeyPEM == "" {
+		return nil, errors.New("public services client CA is not generated")
+	}
+
+	caCertBlock, _ := pem.Decode([]byte(b.ServicesClientCA.CertPEM))
+
+	if caCertBlock == nil {
+		return nil, errors.New("failed to decode public services client CA certificate")
+	}
+
+	caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	caKeyBlock, _ := pem.Decode([]byte(b.ServicesClientCA.KeyPEM))
+
+	if caKeyBlock == nil {
+		return nil, errors.New("failed to decode public services client CA key")
+	}
+
+	caKey, err := x509.ParseECPrivateKey(caKeyBlock.Bytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, keyPEM, err := createSignedCert(opt, caCert, caKey, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PEMBundle{CertPEM: string(certPEM), KeyPEM: string(keyPEM)}, nil
+}
+
+// EncodePKCS12 packs the client certificate, its key and the CA certificate into a password-protected
+// PKCS#12 bundle that can be imported into browsers and OS keychains
+func EncodePKCS12(client *PEMBundle, caCertPEM string, password string) ([]byte, error) {
+	keyPair, err := tls.X509KeyPair([]byte(client.CertPEM), []byte(client.KeyPEM))
+
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(keyPair.Certificate[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	caCerts := []*x509.Certificate{}
+
+	if caCertBlock, _ := pem.Decode([]byte(caCertPEM)); caCertBlock != nil {
+		caCert, err := x509.ParseCertificate(caCertBlock.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		caCerts = append(caCerts, caCert)
+	}
+
+	return pkcs12.Modern.Encode(keyPair.PrivateKey, leaf, caCerts, password)
+}
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wireport/internal/logger"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Response is the body of the health endpoints
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type check struct {
+	name string
+	fn   func() error
+}
+
+// Checker tells whether the process is alive (/healthz) and ready to serve (/readyz): the process marks itself
+// ready once it started and not ready when it is shutting down, the checks are run on every readiness request
+type Checker struct {
+	ready atomic.Bool
+
+	mu     sync.Mutex
+	checks []check
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddCheck registers a readiness check, e.g. a ping of the database
+func (c *Checker) AddCheck(name string, fn func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+func (c *Checker) SetReady(ready bool) {
+	c.ready.Store(ready)
+}
+
+// Readiness runs the checks, the process is ready if it marked itself ready and all checks pass
+func (c *Checker) Readiness() Response {
+	c.mu.Lock()
+	checks := append([]check{}, c.checks...)
+	c.mu.Unlock()
+
+	response := Response{Status: StatusOK, Checks: map[string]string{}}
+
+	if !c.ready.Load() {
+		response.Status = StatusUnavailable
+		response.Checks["started"] = "starting or shutting down"
+	} else {
+		response.Checks["started"] = StatusOK
+	}
+
+	for _, check := range checks {
+		if err := check.fn(); err != nil {
+			response.Status = StatusUnavailable
+			response.Checks[check.name] = err.Error()
+		} else {
+			response.Checks[check.name] = StatusOK
+		}
+	}
+
+	return response
+}
+
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeResponse(w, Response{Status: StatusOK})
+	})
+
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, _ *http.Request) {
+		writeResponse(w, c.Readiness())
+	})
+
+	return mux
+}
+
+func writeResponse(w http.ResponseWriter, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if response.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// Serve exposes the health endpoints over plain HTTP on the address until the context is done
+func Serve(ctx context.Context, address string, checker *Checker) {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           checker.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Serving health checks on http://%s%s and %s", address, LivenessPath, ReadinessPath)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Health server on %s failed: %v", address, err)
+	}
+}
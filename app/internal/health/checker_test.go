@@ -0,0 +1,65 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, Response) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response Response
+
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response %q: %v", recorder.Body.String(), err)
+	}
+
+	return recorder.Code, response
+}
+
+func TestChecker_Liveness(t *testing.T) {
+	// alive even before it is ready
+	if status, response := get(t, NewChecker().Handler(), LivenessPath); status != http.StatusOK || response.Status != StatusOK {
+		t.Errorf("expected 200 ok, got %d %+v", status, response)
+	}
+}
+
+func TestChecker_Readiness(t *testing.T) {
+	checker := NewChecker()
+
+	var databaseErr error
+	checker.AddCheck("database", func() error { return databaseErr })
+
+	if status, _ := get(t, checker.Handler(), ReadinessPath); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the process is ready, got %d", status)
+	}
+
+	checker.SetReady(true)
+
+	status, response := get(t, checker.Handler(), ReadinessPath)
+
+	if status != http.StatusOK || response.Status != StatusOK || response.Checks["database"] != StatusOK {
+		t.Errorf("expected 200 ok with a passing database check, got %d %+v", status, response)
+	}
+
+	databaseErr = errors.New("database is locked")
+
+	status, response = get(t, checker.Handler(), ReadinessPath)
+
+	if status != http.StatusServiceUnavailable || response.Checks["database"] != "database is locked" {
+		t.Errorf("expected 503 with the failing check, got %d %+v", status, response)
+	}
+
+	databaseErr = nil
+	checker.SetReady(false)
+
+	if status, _ := get(t, checker.Handler(), ReadinessPath); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while shutting down, got %d", status)
+	}
+}
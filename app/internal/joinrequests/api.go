@@ -13,6 +13,8 @@ import (
 	"time"
 	"wireport/internal/commands/types"
 	"wireport/internal/encryption/mtls"
+	"wireport/internal/logger"
+	"wireport/version"
 )
 
 type APIService struct {
@@ -105,11 +107,15 @@ func (s *APIService) Join(joinToken string, gatewayAddress string) (*types.JoinR
 			return nil, fmt.Errorf("failed to marshal payload: %w", err)
 		}
 
-		resp, err := s.client.Post(
-			url,
-			"application/json",
-			bytes.NewBuffer(requestPayloadJSON),
-		)
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestPayloadJSON))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		request.Header.Set("Content-Type", "application/json")
+		version.SetHeaders(request.Header)
+
+		resp, err := s.client.Do(request)
 
 		if err != nil {
 			lastErr = fmt.Errorf("failed to send request: %w", err)
@@ -141,7 +147,16 @@ func (s *APIService) Join(joinToken string, gatewayAddress string) (*types.JoinR
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(responseBody))
+			var errorResponse types.ErrorResponseDTO
+			if json.Unmarshal(responseBody, &errorResponse) == nil && errorResponse.Code != "" {
+				lastErr = fmt.Errorf("gateway rejected the join request with status %d (%s): %s", resp.StatusCode, errorResponse.Code, errorResponse.Message)
+			} else {
+				lastErr = fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(responseBody))
+			}
+			// client errors (e.g. an already used join token) won't succeed on retry
+			if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError {
+				break
+			}
 			if attempt == maxRetries {
 				break
 			}
@@ -162,6 +177,10 @@ func (s *APIService) Join(joinToken string, gatewayAddress string) (*types.JoinR
 			continue
 		}
 
+		if warning := resp.Header.Get(version.HeaderWarning); warning != "" {
+			logger.Warn("%s", warning)
+		}
+
 		return &joinResponse, nil
 	}
 
@@ -21,7 +21,7 @@ func FormatJoinError(err error, gatewayAddress string) string {
 	fmt.Fprintf(&b, "Failed to reach the wireport gateway at %s.\n", gatewayAddress)
 	fmt.Fprintf(&b, "Underlying error: %v\n\n", err)
 
-	b.WriteString(diagnoseJoinError(err))
+	b.WriteString(DiagnoseGatewayConnectionError(err))
 	b.WriteString(ConnectivityRequirementsText())
 	fmt.Fprintf(&b, "Full port and firewall requirements: %s\n\n", config.Config.DocumentationURL)
 	b.WriteString("After fixing firewall rules, the server container will retry joining automatically — a container restart is not required.\n")
@@ -29,7 +29,8 @@ func FormatJoinError(err error, gatewayAddress string) string {
 	return b.String()
 }
 
-func diagnoseJoinError(err error) string {
+// DiagnoseGatewayConnectionError explains the likely cause of a failed connection to the gateway control API
+func DiagnoseGatewayConnectionError(err error) string {
 	lower := strings.ToLower(err.Error())
 
 	switch {
@@ -4,14 +4,15 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"time"
+	_ "wireport/internal/encryption/atrest" // the encrypted serializer of the private keys
 	"wireport/internal/encryption/mtls"
 	nodeTypes "wireport/internal/nodes/types"
 )
 
 type JoinRequest struct {
 	ID                  string                `gorm:"type:text;primary_key" json:"id"`
-	EncryptionKeyBase64 string                `gorm:"type:text" json:"key"`
-	ClientCertBundle    mtls.FullClientBundle `gorm:"type:text;serializer:json" json:"clientCertBundle"`
+	EncryptionKeyBase64 string                `gorm:"type:text;serializer:encrypted" json:"key"`
+	ClientCertBundle    mtls.FullClientBundle `gorm:"type:text;serializer:encrypted" json:"clientCertBundle"`
 	DockerSubnet        *string               `gorm:"type:text" json:"dockerSubnet"`
 	GatewayHost         string                `gorm:"type:text" json:"gatewayHost"`
 	GatewayPort         uint16                `gorm:"type:integer" json:"gatewayPort"`
@@ -1,76 +1,222 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
+	"sync/atomic"
 )
 
-type LogLevel int
+// field names shared by all log records, so that the logs can be queried the same way in every component
+const (
+	KeyComponent     = "component"
+	KeyRequestID     = "request_id"
+	KeyNodeID        = "node_id"
+	KeyJoinRequestID = "join_request_id"
+	KeyRole          = "role"
+	KeyMethod        = "method"
+	KeyPath          = "path"
+	KeyStatus        = "status"
+	KeyDurationMs    = "duration_ms"
+	KeyRemoteAddr    = "remote_addr"
+	KeyStep          = "step"
+	KeyError         = "error"
+)
+
+// components of wireport that have their own logger
+const (
+	ComponentControlAPI  = "control-api"
+	ComponentReconcile   = "reconcile"
+	ComponentJoin        = "join"
+	ComponentNetworkApps = "networkapps"
+)
 
 const (
-	DEBUG LogLevel = iota
-	INFO
-	WARN
-	ERROR
-	FATAL
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
+// LevelFatal is logged by Fatal right before the process exits
+const LevelFatal = slog.Level(12)
+
+// Logger is a structured (key-value) logger, see Component
+type Logger struct {
+	*slog.Logger
+}
+
 var (
-	defaultLogger = log.New(os.Stdout, "", log.LstdFlags)
+	output       io.Writer = os.Stdout
+	minLevel     slog.LevelVar
+	outputFormat = FormatText
+	handler      atomic.Pointer[slog.Handler]
+
+	defaultLogger = &Logger{slog.New(&currentHandler{})}
 )
 
-func SetOutput(w *os.File) {
-	defaultLogger.SetOutput(w)
+func init() {
+	setHandler()
 }
 
-func SetFlags(flag int) {
-	defaultLogger.SetFlags(flag)
+// currentHandler forwards records to the handler configured by Configure at the time of logging,
+// so that loggers created before the configuration (e.g. package-level component loggers) follow it
+type currentHandler struct {
+	wrap []func(slog.Handler) slog.Handler
 }
 
-func formatMessage(level LogLevel, format string, args ...interface{}) string {
-	levelStr := ""
-	switch level {
-	case DEBUG:
-		levelStr = "DEBUG"
-	case INFO:
-		levelStr = "INFO"
-	case WARN:
-		levelStr = "WARN"
-	case ERROR:
-		levelStr = "ERROR"
-	case FATAL:
-		levelStr = "FATAL"
+func (h *currentHandler) resolve() slog.Handler {
+	resolved := *handler.Load()
+
+	for _, wrap := range h.wrap {
+		resolved = wrap(resolved)
 	}
 
-	msg := fmt.Sprintf(format, args...)
-	return fmt.Sprintf("[%s] [WIREPORT] %s", levelStr, msg)
+	return resolved
+}
+
+func (h *currentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*handler.Load()).Enabled(ctx, level)
 }
 
-func Debug(format string, args ...interface{}) {
-	defaultLogger.Println(formatMessage(DEBUG, format, args...))
+func (h *currentHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.resolve().Handle(ctx, record)
 }
 
-func Info(format string, args ...interface{}) {
-	defaultLogger.Println(formatMessage(INFO, format, args...))
+func (h *currentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &currentHandler{wrap: append(h.wrap[:len(h.wrap):len(h.wrap)], func(next slog.Handler) slog.Handler {
+		return next.WithAttrs(attrs)
+	})}
 }
 
-func Warn(format string, args ...interface{}) {
-	defaultLogger.Println(formatMessage(WARN, format, args...))
+func (h *currentHandler) WithGroup(name string) slog.Handler {
+	return &currentHandler{wrap: append(h.wrap[:len(h.wrap):len(h.wrap)], func(next slog.Handler) slog.Handler {
+		return next.WithGroup(name)
+	})}
+}
+
+func setHandler() {
+	options := &slog.HandlerOptions{
+		Level: &minLevel,
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.LevelKey && attr.Value.Any() == LevelFatal {
+				attr.Value = slog.StringValue("FATAL")
+			}
+
+			return attr
+		},
+	}
+
+	var newHandler slog.Handler
+
+	if outputFormat == FormatJSON {
+		newHandler = slog.NewJSONHandler(output, options)
+	} else {
+		newHandler = slog.NewTextHandler(output, options)
+	}
+
+	handler.Store(&newHandler)
+}
+
+// ParseLevel parses debug, info, warn or error (case-insensitive)
+func ParseLevel(value string) (slog.Level, error) {
+	var parsedLevel slog.Level
+
+	if err := parsedLevel.UnmarshalText([]byte(value)); err != nil || parsedLevel >= LevelFatal {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q, expected one of: debug, info, warn, error", value)
+	}
+
+	return parsedLevel, nil
+}
+
+// Configure sets the minimum level and the format (text or json) of all loggers
+func Configure(levelValue string, formatValue string) error {
+	parsedLevel, err := ParseLevel(levelValue)
+
+	if err != nil {
+		return err
+	}
+
+	formatValue = strings.ToLower(formatValue)
+
+	if formatValue != FormatText && formatValue != FormatJSON {
+		return fmt.Errorf("invalid log format %q, expected one of: %s, %s", formatValue, FormatText, FormatJSON)
+	}
+
+	minLevel.Set(parsedLevel)
+	outputFormat = formatValue
+	setHandler()
+
+	return nil
+}
+
+func SetOutput(w io.Writer) {
+	output = w
+	setHandler()
+}
+
+// Default returns the logger of the records without a component
+func Default() *Logger {
+	return defaultLogger
+}
+
+// Component returns the logger of a component of wireport, its records have the component attribute
+func Component(name string) *Logger {
+	return defaultLogger.With(KeyComponent, name)
+}
+
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{l.Logger.With(args...)}
+}
+
+// Fatal logs the message and exits with code 1
+func (l *Logger) Fatal(msg string, args ...any) {
+	l.Log(context.Background(), LevelFatal, msg, args...)
+	os.Exit(1)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the logger (e.g. a request-scoped logger with the request ID)
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+
+	return defaultLogger
+}
+
+// Err is the attribute of the error of a log record
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// printf-style logging without a component
+
+func Debug(format string, args ...any) {
+	if defaultLogger.Enabled(context.Background(), slog.LevelDebug) {
+		defaultLogger.Logger.Debug(fmt.Sprintf(format, args...))
+	}
 }
 
-func Error(format string, args ...interface{}) {
-	defaultLogger.Println(formatMessage(ERROR, format, args...))
+func Info(format string, args ...any) {
+	defaultLogger.Logger.Info(fmt.Sprintf(format, args...))
 }
 
-func Fatal(format string, args ...interface{}) {
-	defaultLogger.Fatal(formatMessage(FATAL, format, args...))
+func Warn(format string, args ...any) {
+	defaultLogger.Logger.Warn(fmt.Sprintf(format, args...))
 }
 
-func Printf(format string, args ...interface{}) {
-	defaultLogger.Printf(format, args...)
+func Error(format string, args ...any) {
+	defaultLogger.Logger.Error(fmt.Sprintf(format, args...))
 }
 
-func Println(args ...interface{}) {
-	defaultLogger.Println(args...)
+func Fatal(format string, args ...any) {
+	defaultLogger.Fatal(fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func configureForTest(t *testing.T, level string, format string) *bytes.Buffer {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+	SetOutput(buffer)
+
+	if err := Configure(level, format); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+
+	t.Cleanup(func() {
+		SetOutput(os.Stdout)
+		_ = Configure("info", FormatText)
+	})
+
+	return buffer
+}
+
+func TestConfigure_Level(t *testing.T) {
+	buffer := configureForTest(t, "info", FormatText)
+
+	Debug("hidden %d", 1)
+	Info("shown %d", 2)
+
+	if strings.Contains(buffer.String(), "hidden") {
+		t.Errorf("debug record is logged at info level: %s", buffer.String())
+	}
+
+	if !strings.Contains(buffer.String(), `msg="shown 2"`) {
+		t.Errorf("info record is missing: %s", buffer.String())
+	}
+
+	buffer = configureForTest(t, "DEBUG", FormatText)
+
+	Debug("shown %d", 1)
+
+	if !strings.Contains(buffer.String(), "level=DEBUG") {
+		t.Errorf("debug record is missing at debug level: %s", buffer.String())
+	}
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	if err := Configure("verbose", FormatText); err == nil {
+		t.Errorf("expected an error for an invalid level")
+	}
+
+	if err := Configure("info", "xml"); err == nil {
+		t.Errorf("expected an error for an invalid format")
+	}
+}
+
+func TestComponent_JSON(t *testing.T) {
+	// created before the configuration, same as package-level component loggers
+	log := Component(ComponentJoin).With(KeyRequestID, "42")
+
+	buffer := configureForTest(t, "warn", FormatJSON)
+
+	log.Info("hidden")
+	log.Warn("Join request failed", KeyRole, "server", Err(errors.New("boom")))
+
+	var record map[string]any
+
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record, got %q: %v", buffer.String(), err)
+	}
+
+	expected := map[string]any{
+		"level":      "WARN",
+		"msg":        "Join request failed",
+		KeyComponent: ComponentJoin,
+		KeyRequestID: "42",
+		KeyRole:      "server",
+		KeyError:     "boom",
+	}
+
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != Default() {
+		t.Errorf("expected the default logger without a logger in the context")
+	}
+
+	log := Component(ComponentControlAPI)
+
+	if FromContext(NewContext(context.Background(), log)) != log {
+		t.Errorf("expected the logger of the context")
+	}
+}
@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric types of the Prometheus text exposition format
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Suffix string // appended to the family name, e.g. _sum and _count of summaries
+	Labels []Label
+	Value  float64
+}
+
+// Family is a set of samples of one metric, e.g. returned by a Collector
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector returns metrics that are computed on scrape (e.g. counts from the database)
+type Collector func() []Family
+
+type vecValue struct {
+	labelValues []string
+	value       float64 // counter value or summary sum
+	count       uint64  // summary count
+}
+
+// Vec is a counter or summary (sum and count, no quantiles) partitioned by labels
+type Vec struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*vecValue
+}
+
+var (
+	registryMutex sync.Mutex
+	vecs          []*Vec
+	collectors    []Collector
+)
+
+func newVec(name string, help string, metricType string, labelNames []string) *Vec {
+	vec := &Vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		values:     map[string]*vecValue{},
+	}
+
+	registryMutex.Lock()
+	vecs = append(vecs, vec)
+	registryMutex.Unlock()
+
+	return vec
+}
+
+func NewCounterVec(name string, help string, labelNames ...string) *Vec {
+	return newVec(name, help, TypeCounter, labelNames)
+}
+
+func NewSummaryVec(name string, help string, labelNames ...string) *Vec {
+	return newVec(name, help, TypeSummary, labelNames)
+}
+
+func (v *Vec) get(labelValues []string) *vecValue {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	value, ok := v.values[key]
+
+	if !ok {
+		value = &vecValue{labelValues: append([]string{}, labelValues...)}
+		v.values[key] = value
+	}
+
+	return value
+}
+
+// Inc increments the counter with the given label values
+func (v *Vec) Inc(labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(labelValues).value++
+}
+
+// Observe records an observation (e.g. a duration in seconds) of the summary with the given label values
+func (v *Vec) Observe(observation float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	value := v.get(labelValues)
+	value.value += observation
+	value.count++
+}
+
+func (v *Vec) families() []Family {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.values))
+
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	labels := func(value *vecValue) []Label {
+		result := make([]Label, 0, len(v.labelNames))
+
+		for i, labelName := range v.labelNames {
+			result = append(result, Label{Name: labelName, Value: value.labelValues[i]})
+		}
+
+		return result
+	}
+
+	if v.metricType != TypeSummary {
+		family := Family{Name: v.name, Help: v.help, Type: v.metricType}
+
+		for _, key := range keys {
+			family.Samples = append(family.Samples, Sample{Labels: labels(v.values[key]), Value: v.values[key].value})
+		}
+
+		return []Family{family}
+	}
+
+	// summaries are exposed as <name>_sum and <name>_count under a single HELP/TYPE header
+	family := Family{Name: v.name, Help: v.help, Type: TypeSummary}
+
+	for _, key := range keys {
+		value := v.values[key]
+
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_sum", Labels: labels(value), Value: value.value},
+			Sample{Suffix: "_count", Labels: labels(value), Value: float64(value.count)},
+		)
+	}
+
+	return []Family{family}
+}
+
+// RegisterCollector adds metrics that are computed on every scrape
+func RegisterCollector(collector Collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	collectors = append(collectors, collector)
+}
+
+// Write renders all the registered metrics in the Prometheus text exposition format
+func Write(w io.Writer) error {
+	registryMutex.Lock()
+	registeredVecs := append([]*Vec{}, vecs...)
+	registeredCollectors := append([]Collector{}, collectors...)
+	registryMutex.Unlock()
+
+	families := []Family{}
+
+	for _, vec := range registeredVecs {
+		families = append(families, vec.families()...)
+	}
+
+	for _, collector := range registeredCollectors {
+		families = append(families, collector()...)
+	}
+
+	buffer := bufio.NewWriter(w)
+
+	for _, family := range families {
+		writeFamily(buffer, family)
+	}
+
+	return buffer.Flush()
+}
+
+func writeFamily(w *bufio.Writer, family Family) {
+	fmt.Fprintf(w, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", family.Name, family.Type)
+
+	for _, sample := range family.Samples {
+		name := family.Name + sample.Suffix
+		labels := []string{}
+
+		for _, label := range sample.Labels {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", label.Name, escapeLabelValue(label.Value)))
+		}
+
+		if len(labels) > 0 {
+			name += "{" + strings.Join(labels, ",") + "}"
+		}
+
+		fmt.Fprintf(w, "%s %s\n", name, formatValue(sample.Value))
+	}
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Handler serves the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		_ = Write(w)
+	})
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Test requests", "route", "code")
+	duration := NewSummaryVec("test_request_duration_seconds", "Test request duration", "route")
+
+	requests.Inc("/a", "200")
+	requests.Inc("/a", "200")
+	requests.Inc("/b", "404")
+	duration.Observe(0.5, "/a")
+	duration.Observe(1.5, "/a")
+
+	RegisterCollector(func() []Family {
+		return []Family{{
+			Name: "test_peers",
+			Help: "Test peers",
+			Type: TypeGauge,
+			Samples: []Sample{
+				{Labels: []Label{{Name: "public_key", Value: `a"b\c`}}, Value: 3},
+			},
+		}}
+	})
+
+	var output bytes.Buffer
+
+	if err := Write(&output); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	expected := []string{
+		"# HELP test_requests_total Test requests\n# TYPE test_requests_total counter\n" +
+			"test_requests_total{route=\"/a\",code=\"200\"} 2\ntest_requests_total{route=\"/b\",code=\"404\"} 1\n",
+		"# TYPE test_request_duration_seconds summary\n" +
+			"test_request_duration_seconds_sum{route=\"/a\"} 2\ntest_request_duration_seconds_count{route=\"/a\"} 2\n",
+		"# TYPE test_peers gauge\ntest_peers{public_key=\"a\\\"b\\\\c\"} 3\n",
+	}
+
+	for _, part := range expected {
+		if !strings.Contains(output.String(), part) {
+			t.Errorf("expected output to contain:\n%s\ngot:\n%s", part, output.String())
+		}
+	}
+}
+
+func TestVec_WrongLabelCount(t *testing.T) {
+	vec := NewCounterVec("test_wrong_labels_total", "Test", "route")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a wrong number of label values")
+		}
+	}()
+
+	vec.Inc("/a", "200")
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"wireport/internal/logger"
+	"wireport/internal/middleware"
+)
+
+var (
+	ControlAPIRequests = NewCounterVec("wireport_control_api_requests_total",
+		"Control API requests handled by the gateway", "route", "code")
+	ControlAPIRequestDuration = NewSummaryVec("wireport_control_api_request_duration_seconds",
+		"Control API request handling time", "route")
+	ReconcileDuration = NewSummaryVec("wireport_reconcile_duration_seconds",
+		"Duration of the server reconcile loop steps", "step")
+	ReconcileFailures = NewCounterVec("wireport_reconcile_failures_total",
+		"Failed server reconcile loop steps", "step")
+	NetworkAppsRestarts = NewCounterVec("wireport_network_apps_restarts_total",
+		"Config reloads of the network apps (wireguard, coredns, caddy)", "app", "result")
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+const metricsPath = "/metrics"
+
+// InstrumentHandler counts the requests of the mux and their duration per route pattern
+// (unknown paths are counted as a single "unmatched" route to keep the label cardinality bounded)
+func InstrumentHandler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+
+		if _, pattern := mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+
+		recorder := middleware.NewStatusRecorder(w)
+		startedAt := time.Now()
+
+		mux.ServeHTTP(recorder, r)
+
+		ControlAPIRequests.Inc(route, strconv.Itoa(recorder.Status))
+		ControlAPIRequestDuration.Observe(time.Since(startedAt).Seconds(), route)
+	})
+}
+
+// Serve exposes /metrics on the given address; the address of the WireGuard interface may not be
+// assigned yet when the node starts, so listening is retried until it succeeds
+func Serve(host string, port string) {
+	address := net.JoinHostPort(host, port)
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, Handler())
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	for {
+		logger.Info("Serving metrics on http://%s%s", address, metricsPath)
+
+		err := server.ListenAndServe()
+
+		logger.Error("Metrics server on %s failed, retrying in 10 seconds: %v", address, err)
+
+		time.Sleep(10 * time.Second)
+	}
+}
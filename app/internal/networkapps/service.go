@@ -9,10 +9,13 @@ import (
 	"time"
 	"wireport/cmd/server/config"
 	"wireport/internal/logger"
+	"wireport/internal/metrics"
 )
 
+var log = logger.Component(logger.ComponentNetworkApps)
+
 func RestartNetworkApps(restartWireguard bool, restartCoreDNS bool, restartCaddy bool) error {
-	logger.Info("Restarting network apps: Wireguard: %v, CoreDNS: %v, Caddy: %v", restartWireguard, restartCoreDNS, restartCaddy)
+	log.Info("Restarting network apps", "wireguard", restartWireguard, "coredns", restartCoreDNS, "caddy", restartCaddy)
 
 	if restartWireguard {
 		if _, err := os.Stat(config.Config.WireguardConfigPath); !os.IsNotExist(err) {
@@ -20,10 +23,13 @@ func RestartNetworkApps(restartWireguard bool, restartCoreDNS bool, restartCaddy
 			err := exec.Command("/bin/sh", "-c", config.Config.WireguardRestartCommand).Run()
 
 			if err != nil {
+				metrics.NetworkAppsRestarts.Inc("wireguard", metrics.ResultFailure)
 				return fmt.Errorf("failed to restart wireguard: %v", err)
 			}
 
-			logger.Info("Wireguard restart command finished")
+			metrics.NetworkAppsRestarts.Inc("wireguard", metrics.ResultSuccess)
+
+			log.Info("Wireguard restart command finished")
 		}
 	}
 
@@ -33,10 +39,13 @@ func RestartNetworkApps(restartWireguard bool, restartCoreDNS bool, restartCaddy
 			err := exec.Command("/bin/sh", "-c", config.Config.CoreDNSRestartCommand).Run()
 
 			if err != nil {
+				metrics.NetworkAppsRestarts.Inc("coredns", metrics.ResultFailure)
 				return fmt.Errorf("failed to restart coredns: %v", err)
 			}
 
-			logger.Info("CoreDNS restart command finished")
+			metrics.NetworkAppsRestarts.Inc("coredns", metrics.ResultSuccess)
+
+			log.Info("CoreDNS restart command finished")
 		}
 	}
 
@@ -45,10 +54,13 @@ func RestartNetworkApps(restartWireguard bool, restartCoreDNS bool, restartCaddy
 			err = exec.Command("/bin/sh", "-c", fmt.Sprintf(config.Config.CaddyRestartCommand, config.Config.CaddyConfigPath)).Run()
 
 			if err != nil {
+				metrics.NetworkAppsRestarts.Inc("caddy", metrics.ResultFailure)
 				return fmt.Errorf("failed to restart caddy: %v", err)
 			}
 
-			logger.Info("Caddy restarted")
+			metrics.NetworkAppsRestarts.Inc("caddy", metrics.ResultSuccess)
+
+			log.Info("Caddy restarted")
 		}
 	}
 
@@ -80,7 +92,7 @@ func ScheduleNetworkAppsRestart(delay time.Duration, restartWireguard bool, rest
 				time.Sleep(delay)
 
 				if err := RestartNetworkApps(restartWireguard, restartCoreDNS, restartCaddy); err != nil {
-					logger.Error("Failed to restart network apps: %v", err)
+					log.Error("Failed to restart network apps", logger.Err(err))
 				}
 
 				isNetworkAppsRestarting.Store(false)
@@ -91,9 +103,9 @@ func ScheduleNetworkAppsRestart(delay time.Duration, restartWireguard bool, rest
 	// Non-blocking send to channel
 	select {
 	case networkAppsRestartChan <- struct{}{}:
-		logger.Info("Network apps restart scheduled in %v", delay)
+		log.Info("Network apps restart scheduled", "delay", delay.String())
 	default:
 		// If there's already a pending restart, we don't need to schedule another one
-		logger.Info("Network apps restart already scheduled")
+		log.Info("Network apps restart already scheduled")
 	}
 }
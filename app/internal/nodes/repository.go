@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"wireport/cmd/server/config"
 	"wireport/internal/encryption/mtls"
@@ -195,11 +198,11 @@ func (r *Repository) CreateGateway(WGPublicIP types.IPMarshable, WGPublicPort ui
 
 	nodeID := uuid.New().String()
 
-	gatewayCertBundle, err := mtls.Generate(mtls.Options{
+	gatewayCertBundle, err := generateGatewayCertBundle(mtls.Options{
 		CommonName:  nodeID,
 		Expiry:      config.Config.CertExpiry,
 		IPAddresses: []string{gatewayPublicIP},
-	}, config.Config.CertExpiry)
+	})
 
 	if err != nil {
 		return nil, err
@@ -272,6 +275,35 @@ func (r *Repository) CreateGateway(WGPublicIP types.IPMarshable, WGPublicPort ui
 	return node, nil
 }
 
+// generateGatewayCertBundle signs the certificates of the gateway with the intermediate put into the CA directory by
+// 'gateway up --ca-dir', with a root CA of its own otherwise
+func generateGatewayCertBundle(serverOpt mtls.Options) (*mtls.FullGatewayBundle, error) {
+	intermediate, err := mtls.ReadIntermediateDir(config.Config.GatewayCADir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if intermediate == nil {
+		return mtls.Generate(serverOpt, config.Config.CertExpiry)
+	}
+
+	logger.Info("Signing the gateway certificates with the intermediate of the offline root from %s", config.Config.GatewayCADir)
+
+	bundle, err := mtls.GenerateWithIntermediate(serverOpt, intermediate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the key is kept encrypted in the database from now on
+	if err = os.Remove(filepath.Join(config.Config.GatewayCADir, mtls.IntermediateKeyFile)); err != nil {
+		logger.Warn("Failed to remove the key of the imported intermediate: %v", err)
+	}
+
+	return bundle, nil
+}
+
 func (r *Repository) CreateServer(forceDockerSubnetStr *string) (*types.Node, error) {
 	var serverInterfaceWGPrivateKey, serverInterfaceWGPublicKey, err = wg.GenerateKeyPair()
 
@@ -528,6 +560,21 @@ func (r *Repository) GetByID(id string) (*types.Node, error) {
 
 // retrieves a server node by its WireGuard private IP address
 func (r *Repository) GetServerByWGPrivateIP(ip string) (*types.Node, error) {
+	node, err := r.GetByWGPrivateIP(ip)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if node.Role != types.NodeRoleServer {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return node, nil
+}
+
+// retrieves a node of any role by its WireGuard private IP address
+func (r *Repository) GetByWGPrivateIP(ip string) (*types.Node, error) {
 	ipnet, err := types.ParseIPNetMarshable(strings.TrimSpace(ip), false)
 
 	if err != nil {
@@ -535,7 +582,7 @@ func (r *Repository) GetServerByWGPrivateIP(ip string) (*types.Node, error) {
 	}
 
 	ipStr := types.IPToString(ipnet.IP)
-	nodes, err := r.GetNodesByRole(types.NodeRoleServer)
+	nodes, err := r.GetAll()
 
 	if err != nil {
 		return nil, err
@@ -577,6 +624,22 @@ func (r *Repository) GetGatewayNode() (*types.Node, error) {
 	return &gatewayNode, nil
 }
 
+// GetGatewayNodeUpdatedAt returns when the gateway node was last saved, e.g. to tell whether a copy of its certificates
+// is still current without reading them; zero if there is no gateway node
+func (r *Repository) GetGatewayNodeUpdatedAt() (time.Time, error) {
+	var updatedAt []time.Time
+
+	if err := r.db.Model(&types.Node{}).Where("role = ?", types.NodeRoleGateway).Limit(1).Pluck("updated_at", &updatedAt).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	if len(updatedAt) == 0 {
+		return time.Time{}, nil
+	}
+
+	return updatedAt[0], nil
+}
+
 func (r *Repository) IsDockerSubnetAvailable(dockerSubnet *types.IPNetMarshable) bool {
 	var nodes []types.Node
 
@@ -746,6 +809,11 @@ func (r *Repository) SaveNode(node *types.Node) error {
 	return nil
 }
 
+// Ping checks that the database answers queries, e.g. for the readiness check of the gateway
+func (r *Repository) Ping() error {
+	return r.db.Exec("SELECT 1").Error
+}
+
 func (r *Repository) IsCurrentNodeGateway() bool {
 	var node types.Node
 
@@ -758,6 +826,44 @@ func (r *Repository) IsCurrentNodeGateway() bool {
 	return node.Role == types.NodeRoleGateway
 }
 
+func (r *Repository) GetAll() ([]types.Node, error) {
+	var nodes []types.Node
+
+	result := r.db.Order("created_at ASC").Find(&nodes)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return nodes, nil
+}
+
+// Rename sets the name of the node in the wireport DNS zone, an empty name restores the default one
+func (r *Repository) Rename(nodeID string, name string) error {
+	result := r.db.Model(&types.Node{}).Where("id = ?", nodeID).Update("name", name)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNodeNotFound
+	}
+
+	return nil
+}
+
+// UpdateVersion records the wireport build the node has reported, requests of unknown nodes (e.g. join requests) are ignored
+func (r *Repository) UpdateVersion(nodeID string, version string) error {
+	return r.db.Model(&types.Node{}).Where("id = ?", nodeID).Update("version", version).Error
+}
+
+// UpdateClientCertBundle replaces the certificate the node authenticates to the gateway with, e.g. after it was renewed
+func (r *Repository) UpdateClientCertBundle(nodeID string, bundle *mtls.FullClientBundle) error {
+	// updated from a struct, map updates skip the serializer of the column
+	return r.db.Model(&types.Node{}).Where("id = ?", nodeID).Select("ClientCertBundle").Updates(&types.Node{ClientCertBundle: bundle}).Error
+}
+
 func (r *Repository) GetNodesByRole(role types.NodeRole) ([]types.Node, error) {
 	var nodes []types.Node
 
@@ -791,7 +897,8 @@ func (r *Repository) DeleteServer(nodeID string) error {
 		return result.Error
 	}
 
-	return nil
+	// the gateway drops the server from its peers and DNS servers
+	return r.updateNodes()
 }
 
 func (r *Repository) DeleteAll() error {
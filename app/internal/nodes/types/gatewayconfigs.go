@@ -0,0 +1,168 @@
+package types
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"wireport/cmd/server/config"
+	"wireport/internal/acme"
+	"wireport/internal/certificates"
+	"wireport/internal/dnsrecords"
+	"wireport/internal/publicservices"
+)
+
+// GatewayConfigs holds the gateway-wide data the gateway configs (e.g. Caddyfile) are rendered from
+type GatewayConfigs struct {
+	PublicServices []*publicservices.PublicService
+	ACME           *acme.Settings
+	Certificates   []*certificates.Certificate
+	AccessLogsDir  string // access logs of the public services are written by caddy to this directory, disabled if empty
+	Nodes          []Node // all nodes, each one gets a name in the wireport DNS zone
+	DNSRecords     []*dnsrecords.Record
+	DNSSettings    *dnsrecords.Settings
+}
+
+func (c *GatewayConfigs) getPublicServices() []*publicservices.PublicService {
+	if c == nil {
+		return nil
+	}
+
+	return c.PublicServices
+}
+
+func (c *GatewayConfigs) getACME() *acme.Settings {
+	if c == nil || c.ACME == nil {
+		return &acme.Settings{}
+	}
+
+	return c.ACME
+}
+
+func (c *GatewayConfigs) getCertificates() []*certificates.Certificate {
+	if c == nil {
+		return nil
+	}
+
+	return c.Certificates
+}
+
+func (c *GatewayConfigs) getDNSSettings() *dnsrecords.Settings {
+	if c == nil || c.DNSSettings == nil {
+		return dnsrecords.DefaultSettings()
+	}
+
+	return c.DNSSettings
+}
+
+// dnsServerZone is the zone of a server, whose names are resolved by the docker DNS of that server only
+type dnsServerZone struct {
+	Zone    string // e.g. server-3.wp.internal
+	Pattern string // regular expression of the names in the zone, the name without the zone is the first group
+	IP      string
+}
+
+func (c *GatewayConfigs) dnsServerZones() []dnsServerZone {
+	zones := []dnsServerZone{}
+
+	if !c.getDNSSettings().ServerZones {
+		return zones
+	}
+
+	for _, node := range c.Nodes {
+		if node.Role != NodeRoleServer {
+			continue
+		}
+
+		zone := node.Hostname() + "." + config.Config.DNSZone
+
+		zones = append(zones, dnsServerZone{
+			Zone:    zone,
+			Pattern: `(.+)\.` + regexp.QuoteMeta(zone) + `\.$`,
+			IP:      IPToString(node.WGConfig.Interface.Address.IP),
+		})
+	}
+
+	return zones
+}
+
+// dnsFanoutTargets returns the DNS servers names outside of the zones are asked one after another: the docker DNS
+// of the servers (the DNS servers of the gateway) followed by the upstreams; empty if the fanout is disabled
+func (c *GatewayConfigs) dnsFanoutTargets(gatewayNode *Node) string {
+	if !c.getDNSSettings().ServerFanout {
+		return ""
+	}
+
+	targets := []string{}
+
+	for _, ip := range MapIPNetMarshablesToStrings(gatewayNode.WGConfig.Interface.DNS, false) {
+		if ip != dockerDNS {
+			targets = append(targets, ip)
+		}
+	}
+
+	if len(targets) == 0 {
+		return ""
+	}
+
+	return strings.Join(append(targets, config.Config.CoreDNSUpstreamsAddress), " ")
+}
+
+// dnsZoneFile renders the wireport DNS zone with the hostnames of the nodes and the user-defined records
+func (c *GatewayConfigs) dnsZoneFile(gatewayNode *Node) string {
+	records := []*dnsrecords.Record{}
+
+	if c != nil {
+		for _, node := range c.Nodes {
+			records = append(records, &dnsrecords.Record{
+				Name:  node.Hostname(),
+				Type:  dnsrecords.RecordTypeA,
+				Value: IPToString(node.WGConfig.Interface.Address.IP),
+			})
+		}
+
+		records = append(records, c.DNSRecords...)
+	}
+
+	return dnsrecords.RenderZoneFile(config.Config.DNSZone, gatewayNode.Hostname(), records)
+}
+
+// caddyGlobalOptions returns the global options of the caddy config
+func (c *GatewayConfigs) caddyGlobalOptions() string {
+	globalOptions := c.getACME().AsCaddyGlobalOptions(config.Config.CaddyACMECARootPath)
+
+	if c == nil || c.AccessLogsDir == "" {
+		return globalOptions
+	}
+
+	layer4AccessLog := publicservices.CaddyLayer4AccessLogGlobalOption(filepath.Join(c.AccessLogsDir, publicservices.Layer4AccessLogFileName))
+
+	if globalOptions == "" {
+		return layer4AccessLog
+	}
+
+	return globalOptions + "\n    " + layer4AccessLog
+}
+
+// caddyConfigEntryOptions returns the options the caddy config entry of the service is rendered with
+func (c *GatewayConfigs) caddyConfigEntryOptions(service *publicservices.PublicService) publicservices.CaddyConfigEntryOptions {
+	options := publicservices.CaddyConfigEntryOptions{}
+
+	if c != nil && c.AccessLogsDir != "" {
+		options.AccessLogFile = filepath.Join(c.AccessLogsDir, service.AccessLogFileName())
+	}
+
+	if service.PublicProtocol != "https" {
+		return options
+	}
+
+	if certificate := certificates.FindForHost(c.getCertificates(), service.PublicHost); certificate != nil {
+		options.TLSCertificateFile = certificate.CertFilePath(config.Config.CaddyCertificatesDir)
+		options.TLSKeyFile = certificate.KeyFilePath(config.Config.CaddyCertificatesDir)
+	}
+
+	if service.ClientAuth != nil {
+		options.ClientCAFile = filepath.Join(config.Config.CaddyClientCAsDir, service.ClientAuth.CAFileName())
+	}
+
+	return options
+}
@@ -4,10 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 	"time"
 	"wireport/cmd/server/config"
+	"wireport/internal/certificates"
+	_ "wireport/internal/encryption/atrest" // the encrypted serializer of the private keys
 	"wireport/internal/encryption/mtls"
 	"wireport/internal/logger"
 	"wireport/internal/publicservices"
@@ -78,25 +82,29 @@ type Node struct {
 
 	IsCurrentNode bool `gorm:"type:boolean;not null;default:false"`
 
-	WGPrivateKey string `gorm:"type:text;not null"`
+	WGPrivateKey string `gorm:"type:text;serializer:encrypted;not null"`
 	WGPublicKey  string `gorm:"type:text;not null"`
 
-	WGConfig WGConfig `gorm:"type:text;serializer:json;uniqueIndex;not null"`
+	WGConfig WGConfig `gorm:"type:text;serializer:encrypted;not null"` // holds the WireGuard private key too
 
 	WGPublicIP   *string `gorm:"type:text"`
 	WGPublicPort *uint16 `gorm:"type:integer"`
 
-	ConnectionEncryptionKey *string `gorm:"type:text"`
+	ConnectionEncryptionKey *string `gorm:"type:text;serializer:encrypted"`
 
 	GatewayPublicIP   string                  `gorm:"type:text;not null"`
 	GatewayPublicPort uint16                  `gorm:"type:integer;not null"`
-	GatewayCertBundle *mtls.FullGatewayBundle `gorm:"type:text;serializer:json"`
-	ClientCertBundle  *mtls.FullClientBundle  `gorm:"type:text;serializer:json"`
+	GatewayCertBundle *mtls.FullGatewayBundle `gorm:"type:text;serializer:encrypted"`
+	ClientCertBundle  *mtls.FullClientBundle  `gorm:"type:text;serializer:encrypted"`
 
 	DockerSubnet *IPNetMarshable `gorm:"type:text;serializer:json"`
 
 	Labels []string `gorm:"type:text;serializer:json;not null;default:'[]'"`
 
+	Name string `gorm:"type:text;not null;default:''"` // name in the wireport DNS zone, see Hostname
+
+	Version string `gorm:"type:text;not null;default:''"` // wireport build reported with the last control API request of the node
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -106,6 +114,33 @@ func (n *Node) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
+var defaultHostnameRegexp = regexp.MustCompile(`^(gateway|server|client)(-[0-9]+)?$`)
+
+// Hostname returns the name of the node in the wireport DNS zone (<name>.wp.internal): the name given by the user,
+// or the role followed by the last octet of the WireGuard address (e.g. server-3)
+func (n *Node) Hostname() string {
+	if n.Name != "" {
+		return n.Name
+	}
+
+	if n.Role == NodeRoleGateway {
+		return string(NodeRoleGateway)
+	}
+
+	ip := n.WGConfig.Interface.Address.IP.To4()
+
+	if ip == nil {
+		return string(n.Role)
+	}
+
+	return fmt.Sprintf("%s-%d", n.Role, ip[3])
+}
+
+// IsDefaultHostname reports whether the name has the form of the default node hostnames, which are reserved
+func IsDefaultHostname(name string) bool {
+	return defaultHostnameRegexp.MatchString(name)
+}
+
 func (c *WGConfig) ToINI() (*string, error) {
 	var sb strings.Builder
 
@@ -197,7 +232,7 @@ func (n *Node) GetFormattedResolvConfig() (*string, error) {
 	return &configContents, nil
 }
 
-func (n *Node) GetFormattedCaddyConfig(publicServices []*publicservices.PublicService) (*string, error) {
+func (n *Node) GetFormattedCaddyConfig(gatewayConfigs *GatewayConfigs) (*string, error) {
 	if n.Role != NodeRoleGateway {
 		return nil, errors.New("only gateway nodes can have a Caddy config")
 	}
@@ -217,7 +252,7 @@ func (n *Node) GetFormattedCaddyConfig(publicServices []*publicservices.PublicSe
 	layer4PublicServices := []string{}
 	layer7PublicServices := []string{}
 
-	for _, service := range publicServices {
+	for _, service := range gatewayConfigs.getPublicServices() {
 		var entry string
 
 		if service.PublicProtocol == "tcp" || service.PublicProtocol == "udp" {
@@ -229,7 +264,7 @@ func (n *Node) GetFormattedCaddyConfig(publicServices []*publicservices.PublicSe
 
 			layer4PublicServices = append(layer4PublicServices, entry)
 		} else {
-			entry, err = service.AsCaddyConfigEntry(n.GatewayPublicIP)
+			entry, err = service.AsCaddyConfigEntryWithOptions(n.GatewayPublicIP, gatewayConfigs.caddyConfigEntryOptions(service))
 
 			if err != nil {
 				return nil, err
@@ -241,6 +276,7 @@ func (n *Node) GetFormattedCaddyConfig(publicServices []*publicservices.PublicSe
 
 	configContents, err := tpl.Exec(map[string]interface{}{
 		"Node":                 n,
+		"GlobalOptions":        gatewayConfigs.caddyGlobalOptions(),
 		"Layer4PublicServices": layer4PublicServices,
 		"Layer7PublicServices": layer7PublicServices,
 	})
@@ -252,7 +288,10 @@ func (n *Node) GetFormattedCaddyConfig(publicServices []*publicservices.PublicSe
 	return &configContents, nil
 }
 
-func (n *Node) GetFormattedCoreDNSConfig() (*string, error) {
+// embedded DNS server of docker, the gateway and the servers resolve container names with it
+const dockerDNS = "127.0.0.11"
+
+func (n *Node) GetFormattedCoreDNSConfig(gatewayConfigs *GatewayConfigs) (*string, error) {
 	if n.Role == NodeRoleClient {
 		return nil, errors.New("client nodes do not need a CoreDNS config")
 	}
@@ -269,7 +308,19 @@ func (n *Node) GetFormattedCoreDNSConfig() (*string, error) {
 		return nil, err
 	}
 
-	configContents, err := tpl.Exec(n)
+	dnsSettings := gatewayConfigs.getDNSSettings()
+
+	configContents, err := tpl.Exec(map[string]interface{}{
+		"Node":             n,
+		"Zone":             config.Config.DNSZone,
+		"ZoneFilePath":     config.Config.CoreDNSZoneFilePath,
+		"GatewayIP":        n.GatewayWGIP(),
+		"ServerZones":      gatewayConfigs.dnsServerZones(),
+		"FanoutTargets":    gatewayConfigs.dnsFanoutTargets(n),
+		"UpstreamsAddress": config.Config.CoreDNSUpstreamsAddress,
+		"Upstreams":        strings.Join(dnsSettings.EffectiveUpstreams(), " "),
+		"TLSServerName":    dnsSettings.TLSServerName,
+	})
 
 	if err != nil {
 		return nil, err
@@ -278,7 +329,16 @@ func (n *Node) GetFormattedCoreDNSConfig() (*string, error) {
 	return &configContents, nil
 }
 
-func (n *Node) SaveConfigs(publicServices []*publicservices.PublicService, configsMustExist bool) error {
+// GatewayWGIP returns the WireGuard address of the gateway as seen by a server or a client, which use it as their first DNS server
+func (n *Node) GatewayWGIP() string {
+	if (n.Role != NodeRoleServer && n.Role != NodeRoleClient) || len(n.WGConfig.Interface.DNS) == 0 {
+		return ""
+	}
+
+	return IPToString(n.WGConfig.Interface.DNS[0].IP)
+}
+
+func (n *Node) SaveConfigs(gatewayConfigs *GatewayConfigs, configsMustExist bool) error {
 	if n.Role != NodeRoleGateway && n.Role != NodeRoleServer {
 		return errors.New("config saving is only relevant to gateway and server nodes")
 	}
@@ -287,9 +347,9 @@ func (n *Node) SaveConfigs(publicServices []*publicservices.PublicService, confi
 
 	resolvConfig, _ := n.GetFormattedResolvConfig()
 
-	caddyConfig, _ := n.GetFormattedCaddyConfig(publicServices)
+	caddyConfig, _ := n.GetFormattedCaddyConfig(gatewayConfigs)
 
-	coreDNSConfig, _ := n.GetFormattedCoreDNSConfig()
+	coreDNSConfig, _ := n.GetFormattedCoreDNSConfig(gatewayConfigs)
 
 	if coreDNSConfig != nil {
 		logger.Info("Writing coreDNS config to %s", config.Config.CoreDNSConfigPath)
@@ -305,6 +365,16 @@ func (n *Node) SaveConfigs(publicServices []*publicservices.PublicService, confi
 		}
 	}
 
+	if n.Role == NodeRoleGateway {
+		logger.Info("Writing DNS zone %s to %s", config.Config.DNSZone, config.Config.CoreDNSZoneFilePath)
+		err := os.WriteFile(config.Config.CoreDNSZoneFilePath, []byte(gatewayConfigs.dnsZoneFile(n)), 0644)
+
+		if err != nil {
+			logger.Error("Failed to write DNS zone: %v", err)
+			return err
+		}
+	}
+
 	if resolvConfig != nil {
 		logger.Info("Writing resolv config to %s", config.Config.ResolvConfigPath)
 		err := os.WriteFile(config.Config.ResolvConfigPath, []byte(*resolvConfig), 0644)
@@ -335,6 +405,29 @@ func (n *Node) SaveConfigs(publicServices []*publicservices.PublicService, confi
 		}
 
 		if n.Role == NodeRoleGateway {
+			if caRootPEM := gatewayConfigs.getACME().CARootPEM; caRootPEM != "" {
+				logger.Info("Writing ACME CA root certificate to %s", config.Config.CaddyACMECARootPath)
+				err := os.WriteFile(config.Config.CaddyACMECARootPath, []byte(caRootPEM), 0644)
+
+				if err != nil {
+					logger.Error("Failed to write ACME CA root certificate: %v", err)
+					return err
+				}
+			} else if err := os.Remove(config.Config.CaddyACMECARootPath); err != nil && !os.IsNotExist(err) {
+				logger.Error("Failed to remove ACME CA root certificate: %v", err)
+				return err
+			}
+
+			if err := saveCertificates(gatewayConfigs.getCertificates()); err != nil {
+				logger.Error("Failed to write TLS certificates: %v", err)
+				return err
+			}
+
+			if err := n.saveClientCAs(gatewayConfigs.getPublicServices()); err != nil {
+				logger.Error("Failed to write client CA certificates: %v", err)
+				return err
+			}
+
 			if caddyConfig != nil {
 				logger.Info("Writing caddy config to %s", config.Config.CaddyConfigPath)
 				err := os.WriteFile(config.Config.CaddyConfigPath, []byte(*caddyConfig), 0644)
@@ -353,3 +446,123 @@ func (n *Node) SaveConfigs(publicServices []*publicservices.PublicService, confi
 
 	return nil
 }
+
+// saveCertificates writes the user-provided TLS certificates to the caddy certificates dir and removes stale ones
+func saveCertificates(gatewayCertificates []*certificates.Certificate) error {
+	dir := config.Config.CaddyCertificatesDir
+
+	if len(gatewayCertificates) == 0 {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	expectedFiles := map[string]bool{}
+
+	for _, certificate := range gatewayCertificates {
+		logger.Info("Writing TLS certificate %s to %s", certificate.ID, dir)
+
+		if err := os.WriteFile(certificate.CertFilePath(dir), []byte(certificate.CertPEM), 0644); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(certificate.KeyFilePath(dir), []byte(certificate.KeyPEM), 0600); err != nil {
+			return err
+		}
+
+		expectedFiles[certificate.CertFilePath(dir)] = true
+		expectedFiles[certificate.KeyFilePath(dir)] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() || expectedFiles[path] {
+			continue
+		}
+
+		if ext := filepath.Ext(path); ext == ".crt" || ext == ".key" {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveClientCAs writes the CA certificates client certificates of public services are verified against
+func (n *Node) saveClientCAs(publicServices []*publicservices.PublicService) error {
+	caFiles := map[string]string{}
+
+	for _, service := range publicServices {
+		if service.ClientAuth == nil {
+			continue
+		}
+
+		caPEM := service.ClientAuth.TrustedCAPEM
+
+		if service.ClientAuth.UsesWireportCA() {
+			if n.GatewayCertBundle == nil || n.GatewayCertBundle.ServicesClientCA == nil {
+				return errors.New("public services client CA is not generated")
+			}
+
+			caPEM = n.GatewayCertBundle.ServicesClientCA.CertPEM
+		}
+
+		caFiles[service.ClientAuth.CAFileName()] = caPEM
+	}
+
+	dir := config.Config.CaddyClientCAsDir
+
+	if len(caFiles) == 0 {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for fileName, caPEM := range caFiles {
+		path := filepath.Join(dir, fileName)
+
+		logger.Info("Writing client CA certificate to %s", path)
+
+		if err := os.WriteFile(path, []byte(caPEM), 0644); err != nil {
+			return err
+		}
+	}
+
+	// CAs of services that no longer require client certificates, or that were replaced, must not stay trusted
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if _, expected := caFiles[entry.Name()]; entry.IsDir() || expected || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		logger.Info("Removing stale client CA certificate %s", entry.Name())
+
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
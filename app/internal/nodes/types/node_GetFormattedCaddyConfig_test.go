@@ -74,7 +74,7 @@ func TestNode_GetFormattedCaddyConfig(t *testing.T) {
 		},
 	}
 
-	caddyConfig, err := node.GetFormattedCaddyConfig(publicServices)
+	caddyConfig, err := node.GetFormattedCaddyConfig(&GatewayConfigs{PublicServices: publicServices})
 
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
@@ -0,0 +1,175 @@
+package types
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"wireport/internal/dnsrecords"
+)
+
+func newTestNode(role NodeRole, address string, dns ...string) Node {
+	node := Node{
+		ID:   address,
+		Role: role,
+		WGConfig: WGConfig{
+			Interface: WGConfigInterface{
+				Address: IPNetMarshable{IPNet: net.IPNet{IP: net.ParseIP(address), Mask: net.CIDRMask(24, 32)}},
+			},
+		},
+	}
+
+	for _, ip := range dns {
+		node.WGConfig.Interface.DNS = append(node.WGConfig.Interface.DNS, IPNetMarshable{IPNet: net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)}})
+	}
+
+	return node
+}
+
+func TestNode_Hostname(t *testing.T) {
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{newTestNode(NodeRoleGateway, "10.0.0.1"), "gateway"},
+		{newTestNode(NodeRoleServer, "10.0.0.3"), "server-3"},
+		{newTestNode(NodeRoleClient, "10.0.0.14"), "client-14"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.node.Hostname(); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+
+		if !IsDefaultHostname(tt.expected) {
+			t.Errorf("expected %s to be a default hostname", tt.expected)
+		}
+	}
+
+	renamed := newTestNode(NodeRoleClient, "10.0.0.14")
+	renamed.Name = "alice-laptop"
+
+	if renamed.Hostname() != "alice-laptop" || IsDefaultHostname(renamed.Name) {
+		t.Errorf("expected the given name, got %s", renamed.Hostname())
+	}
+}
+
+func TestNode_GetFormattedCoreDNSConfig(t *testing.T) {
+	gatewayNode := newTestNode(NodeRoleGateway, "10.0.0.1", "127.0.0.11", "10.0.0.3")
+
+	got, err := gatewayNode.GetFormattedCoreDNSConfig(&GatewayConfigs{})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedZone := `
+wp.internal {
+    file /etc/coredns/wp.internal.db {
+        reload 5s
+    }
+
+    log
+}
+`
+
+	if !strings.Contains(removeSpaces(*got), removeSpaces(expectedZone)) {
+		t.Errorf("expected the gateway to serve the zone, got %s", *got)
+	}
+
+	if !strings.Contains(*got, "fanout . 10.0.0.3 127.0.0.1:5300 {") {
+		t.Errorf("expected the gateway to fan out to the servers and the upstreams, got %s", *got)
+	}
+
+	if !strings.Contains(removeSpaces(*got), removeSpaces(".:5300 { bind 127.0.0.1 forward . 8.8.8.8 1.1.1.1 }")) {
+		t.Errorf("expected the default upstreams, got %s", *got)
+	}
+
+	serverNode := newTestNode(NodeRoleServer, "10.0.0.3", "10.0.0.1", "127.0.0.11")
+
+	got, err = serverNode.GetFormattedCoreDNSConfig(&GatewayConfigs{})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.Contains(removeSpaces(*got), removeSpaces("wp.internal { forward . 10.0.0.1 log }")) {
+		t.Errorf("expected the server to forward the zone to the gateway, got %s", *got)
+	}
+}
+
+func TestNode_GetFormattedCoreDNSConfig_DNSSettings(t *testing.T) {
+	gatewayNode := newTestNode(NodeRoleGateway, "10.0.0.1", "127.0.0.11", "10.0.0.3")
+	serverNode := newTestNode(NodeRoleServer, "10.0.0.3")
+	serverNode.Name = "srv-a"
+
+	gatewayConfigs := &GatewayConfigs{
+		Nodes: []Node{gatewayNode, serverNode},
+		DNSSettings: &dnsrecords.Settings{
+			Upstreams:     []string{"tls://9.9.9.9", "tls://149.112.112.112"},
+			TLSServerName: "dns.quad9.net",
+			ServerFanout:  false,
+			ServerZones:   true,
+		},
+	}
+
+	got, err := gatewayNode.GetFormattedCoreDNSConfig(gatewayConfigs)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedServerZone := `
+srv-a.wp.internal {
+    hosts {
+        10.0.0.3 srv-a.wp.internal
+        fallthrough
+    }
+
+    rewrite stop {
+        name regex (.+)\.srv-a\.wp\.internal\.$ {1}.
+        answer auto
+    }
+
+    forward . 10.0.0.3
+
+    log
+}
+`
+
+	if !strings.Contains(removeSpaces(*got), removeSpaces(expectedServerZone)) {
+		t.Errorf("expected the zone of the server, got %s", *got)
+	}
+
+	if !strings.Contains(removeSpaces(*got), removeSpaces("forward . tls://9.9.9.9 tls://149.112.112.112 { tls_servername dns.quad9.net }")) {
+		t.Errorf("expected the DNS-over-TLS upstreams, got %s", *got)
+	}
+
+	if strings.Contains(*got, "fanout") || !strings.Contains(*got, "forward . 127.0.0.1:5300\n") {
+		t.Errorf("expected the names outside of the zones to be forwarded to the upstreams only, got %s", *got)
+	}
+}
+
+func TestGatewayConfigs_DNSZoneFile(t *testing.T) {
+	gatewayNode := newTestNode(NodeRoleGateway, "10.0.0.1")
+	clientNode := newTestNode(NodeRoleClient, "10.0.0.4")
+	clientNode.Name = "alice-laptop"
+
+	gatewayConfigs := &GatewayConfigs{
+		Nodes:      []Node{gatewayNode, newTestNode(NodeRoleServer, "10.0.0.3"), clientNode},
+		DNSRecords: []*dnsrecords.Record{{Name: "grafana", Type: dnsrecords.RecordTypeCNAME, Value: "server-3"}},
+	}
+
+	zone := gatewayConfigs.dnsZoneFile(&gatewayNode)
+
+	for _, expected := range []string{
+		"@ IN NS gateway\n",
+		"gateway IN A 10.0.0.1\n",
+		"server-3 IN A 10.0.0.3\n",
+		"alice-laptop IN A 10.0.0.4\n",
+		"grafana IN CNAME server-3\n",
+	} {
+		if !strings.Contains(zone, expected) {
+			t.Errorf("expected %q in the zone, got %s", expected, zone)
+		}
+	}
+}
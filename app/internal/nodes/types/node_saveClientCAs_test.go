@@ -0,0 +1,63 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"wireport/cmd/server/config"
+	"wireport/internal/publicservices"
+)
+
+func TestNode_saveClientCAs_RemovesStaleCAs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "client-cas")
+	previousDir := config.Config.CaddyClientCAsDir
+	config.Config.CaddyClientCAsDir = dir
+
+	t.Cleanup(func() {
+		config.Config.CaddyClientCAsDir = previousDir
+	})
+
+	node := Node{Role: NodeRoleGateway}
+	service := &publicservices.PublicService{ClientAuth: &publicservices.PublicServiceClientAuth{TrustedCAPEM: "first CA"}}
+
+	if err := node.saveClientCAs([]*publicservices.PublicService{service}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the CA of the service is replaced
+	service.ClientAuth = &publicservices.PublicServiceClientAuth{TrustedCAPEM: "second CA"}
+
+	if err := node.saveClientCAs([]*publicservices.PublicService{service}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	files := func() []string {
+		entries, err := os.ReadDir(dir)
+
+		if err != nil {
+			t.Fatalf("failed to read the CA directory: %v", err)
+		}
+
+		names := []string{}
+
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+
+		return names
+	}
+
+	if got := files(); !slices.Equal(got, []string{service.ClientAuth.CAFileName()}) {
+		t.Errorf("expected only the CA of the service, got %v", got)
+	}
+
+	// client authentication is disabled
+	if err := node.saveClientCAs(nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if got := files(); len(got) != 0 {
+		t.Errorf("expected no CA to stay trusted, got %v", got)
+	}
+}
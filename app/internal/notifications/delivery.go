@@ -0,0 +1,194 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+	"wireport/internal/logger"
+)
+
+const (
+	EventHeader     = "X-Wireport-Event"
+	SignatureHeader = "X-Wireport-Signature" // sha256=<hex HMAC-SHA256 of the body>
+)
+
+// Sign returns the value of the signature header for the payload
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver posts the payload to the webhook, any non-2xx response is a failure
+func Deliver(client *http.Client, webhook *Webhook, eventType EventType, payload []byte) error {
+	request, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "wireport")
+	request.Header.Set(EventHeader, string(eventType))
+
+	if webhook.Secret != "" {
+		request.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+	}
+
+	response, err := client.Do(request)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s responded with %s", ErrDeliveryFailed, webhook.URL, response.Status)
+	}
+
+	return nil
+}
+
+// Dispatcher delivers the outbox messages, retrying failed deliveries with exponential backoff. The messages are grouped
+// by webhook, the groups are delivered concurrently so an unreachable webhook does not hold back the others
+type Dispatcher struct {
+	Repository  *Repository
+	Client      *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Concurrency int // webhooks delivered at once, 4 if zero
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	backoff := float64(d.BaseBackoff) * math.Pow(2, float64(attempts-1))
+
+	if backoff > float64(d.MaxBackoff) {
+		return d.MaxBackoff
+	}
+
+	return time.Duration(backoff)
+}
+
+// DeliverDue makes one delivery attempt for every due message. The messages of a webhook are delivered in order, once
+// one of them fails the others are postponed to its next attempt without counting an attempt
+func (d *Dispatcher) DeliverDue(now time.Time) error {
+	messages, err := d.Repository.GetDueMessages(now, 100)
+
+	if err != nil {
+		return err
+	}
+
+	var webhookIDs []string
+	groups := map[string][]*OutboxMessage{}
+
+	for _, message := range messages {
+		if _, ok := groups[message.WebhookID]; !ok {
+			webhookIDs = append(webhookIDs, message.WebhookID)
+		}
+
+		groups[message.WebhookID] = append(groups[message.WebhookID], message)
+	}
+
+	concurrency := d.Concurrency
+
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var waitGroup sync.WaitGroup
+	var mutex sync.Mutex
+	var saveErr error
+
+	semaphore := make(chan struct{}, concurrency)
+
+	for _, webhookID := range webhookIDs {
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+
+		go func(group []*OutboxMessage) {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+
+			changed := d.deliverGroup(now, webhookID, group)
+
+			// the database is written by one group at a time
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for _, message := range changed {
+				if err := d.Repository.SaveMessage(message); err != nil && saveErr == nil {
+					saveErr = err
+				}
+			}
+		}(groups[webhookID])
+	}
+
+	waitGroup.Wait()
+
+	return saveErr
+}
+
+// deliverGroup delivers the messages of a webhook, returning the ones to save
+func (d *Dispatcher) deliverGroup(now time.Time, webhookID string, group []*OutboxMessage) []*OutboxMessage {
+	webhook, err := d.Repository.GetWebhook(webhookID)
+
+	if errors.Is(err, ErrWebhookNotFound) {
+		for _, message := range group {
+			message.Status = OutboxStatusFailed
+			message.LastError = err.Error()
+		}
+
+		return group
+	}
+
+	if err != nil {
+		// retried on the next round
+		logger.Error("Failed to get webhook %s: %v", webhookID, err)
+		return nil
+	}
+
+	for i, message := range group {
+		message.Attempts++
+
+		err := Deliver(d.Client, webhook, message.EventType, []byte(message.Payload))
+
+		if err == nil {
+			message.Status = OutboxStatusDelivered
+			message.LastError = ""
+			continue
+		}
+
+		message.LastError = err.Error()
+
+		if message.Attempts >= d.MaxAttempts {
+			message.Status = OutboxStatusFailed
+			logger.Error("Giving up delivering %s event %d to %s after %d attempts: %v", message.EventType, message.ID, webhook.URL, message.Attempts, err)
+		} else {
+			message.NextAttemptAt = now.Add(d.Backoff(message.Attempts))
+		}
+
+		nextAttemptAt := now.Add(d.Backoff(message.Attempts))
+
+		for _, postponed := range group[i+1:] {
+			postponed.NextAttemptAt = nextAttemptAt
+		}
+
+		return group
+	}
+
+	return group
+}
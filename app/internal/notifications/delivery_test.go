@@ -0,0 +1,279 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"wireport/internal/database/testutil"
+	"wireport/internal/encryption/atrest"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	// the secrets of the webhooks are encrypted at rest
+	key, err := atrest.GenerateKey(atrest.KeySourceFile)
+
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	atrest.SetKeys(key)
+
+	t.Cleanup(func() {
+		atrest.SetKeys(nil)
+	})
+
+	db := testutil.NewInMemoryDB(t, &Webhook{}, &OutboxMessage{})
+
+	return NewRepository(db)
+}
+
+// webhookStandIn records the deliveries, failing the first failures ones with 503
+type webhookStandIn struct {
+	mutex      sync.Mutex
+	failures   int
+	deliveries []*http.Request
+	bodies     [][]byte
+}
+
+func (s *webhookStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	s.deliveries = append(s.deliveries, r)
+	s.bodies = append(s.bodies, body)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestDeliver_SignsPayload(t *testing.T) {
+	standIn := &webhookStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	payload := []byte(`{"type":"test"}`)
+	webhook := &Webhook{URL: server.URL, Secret: "s3cr3t"}
+
+	if err := Deliver(server.Client(), webhook, EventTest, payload); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	request := standIn.deliveries[0]
+
+	if request.Header.Get(EventHeader) != string(EventTest) {
+		t.Errorf("expected event header %s, got %s", EventTest, request.Header.Get(EventHeader))
+	}
+
+	if request.Header.Get(SignatureHeader) != Sign("s3cr3t", standIn.bodies[0]) {
+		t.Errorf("signature %s does not match the body", request.Header.Get(SignatureHeader))
+	}
+
+	standIn.failures = 1
+
+	if err := Deliver(server.Client(), webhook, EventTest, payload); !errors.Is(err, ErrDeliveryFailed) {
+		t.Errorf("expected ErrDeliveryFailed for a 503 response, got %v", err)
+	}
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	standIn := &webhookStandIn{failures: 2}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	repository := newTestRepository(t)
+
+	subscribed, _ := NewWebhook(server.URL, "", []string{string(EventServerJoined)})
+	unsubscribed, _ := NewWebhook(server.URL+"/other", "", []string{string(EventServerLeft)})
+
+	for _, webhook := range []*Webhook{subscribed, unsubscribed} {
+		if err := repository.CreateWebhook(webhook); err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+	}
+
+	event := NewEvent(EventServerJoined, map[string]string{"overlayIp": "10.0.0.2"}, "server %s joined the network", "10.0.0.2")
+
+	if err := repository.Enqueue(event); err != nil {
+		t.Fatalf("failed to enqueue event: %v", err)
+	}
+
+	dispatcher := &Dispatcher{Repository: repository, Client: server.Client(), MaxAttempts: 5, BaseBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+	now := event.OccurredAt
+
+	// 1st attempt fails, the 2nd one is not due yet
+	for _, at := range []time.Time{now, now.Add(5 * time.Second)} {
+		if err := dispatcher.DeliverDue(at); err != nil {
+			t.Fatalf("DeliverDue() error = %v", err)
+		}
+	}
+
+	if pending, _ := repository.CountMessages(subscribed.ID, OutboxStatusPending); pending != 1 || standIn.failures != 1 {
+		t.Fatalf("expected 1 pending message after one failed attempt, got %d pending, %d failures left", pending, standIn.failures)
+	}
+
+	// 2nd attempt after 10s fails, 3rd one after 20s more succeeds
+	for _, at := range []time.Time{now.Add(10 * time.Second), now.Add(30 * time.Second)} {
+		if err := dispatcher.DeliverDue(at); err != nil {
+			t.Fatalf("DeliverDue() error = %v", err)
+		}
+	}
+
+	if delivered, _ := repository.CountMessages(subscribed.ID, OutboxStatusDelivered); delivered != 1 {
+		t.Fatalf("expected the message to be delivered, got %d delivered", delivered)
+	}
+
+	if len(standIn.deliveries) != 1 || standIn.deliveries[0].URL.Path != "/" {
+		t.Fatalf("expected a single delivery to the subscribed webhook, got %d", len(standIn.deliveries))
+	}
+
+	var delivered Event
+
+	if err := json.Unmarshal(standIn.bodies[0], &delivered); err != nil || delivered.ID != event.ID || delivered.Data["overlayIp"] != "10.0.0.2" {
+		t.Errorf("unexpected delivered event %+v (%v)", delivered, err)
+	}
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	standIn := &webhookStandIn{failures: 10}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	repository := newTestRepository(t)
+	webhook, _ := NewWebhook(server.URL, "", nil)
+
+	if err := repository.CreateWebhook(webhook); err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	if err := repository.Enqueue(NewEvent(EventPeerStale, nil, "peer is stale")); err != nil {
+		t.Fatalf("failed to enqueue event: %v", err)
+	}
+
+	dispatcher := &Dispatcher{Repository: repository, Client: server.Client(), MaxAttempts: 2, BaseBackoff: time.Second, MaxBackoff: time.Second}
+	now := time.Now().Add(time.Minute)
+
+	for i := range 3 {
+		if err := dispatcher.DeliverDue(now.Add(time.Duration(i) * time.Hour)); err != nil {
+			t.Fatalf("DeliverDue() error = %v", err)
+		}
+	}
+
+	if failed, _ := repository.CountMessages(webhook.ID, OutboxStatusFailed); failed != 1 || standIn.failures != 8 {
+		t.Errorf("expected the message to fail after 2 attempts, got %d failed, %d attempts", failed, 10-standIn.failures)
+	}
+}
+
+func TestDispatcher_UnreachableWebhookDoesNotBlockOthers(t *testing.T) {
+	standIn := &webhookStandIn{}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	repository := newTestRepository(t)
+	dead, _ := NewWebhook(unreachable.URL, "", nil)
+	alive, _ := NewWebhook(server.URL, "", nil)
+
+	for _, webhook := range []*Webhook{dead, alive} {
+		if err := repository.CreateWebhook(webhook); err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+	}
+
+	for range 3 {
+		if err := repository.Enqueue(NewEvent(EventPeerStale, nil, "peer is stale")); err != nil {
+			t.Fatalf("failed to enqueue event: %v", err)
+		}
+	}
+
+	dispatcher := &Dispatcher{Repository: repository, Client: server.Client(), MaxAttempts: 5, BaseBackoff: time.Minute, MaxBackoff: time.Hour}
+	now := time.Now().Add(time.Minute)
+
+	if err := dispatcher.DeliverDue(now); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+
+	if delivered, _ := repository.CountMessages(alive.ID, OutboxStatusDelivered); delivered != 3 {
+		t.Errorf("expected the 3 messages of the reachable webhook to be delivered, got %d", delivered)
+	}
+
+	// the 1st message failed, the others were postponed without an attempt
+	messages, err := repository.GetDueMessages(now.Add(time.Minute), 100)
+
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("expected 3 messages due after the backoff, got %d (%v)", len(messages), err)
+	}
+
+	for i, message := range messages {
+		expected := 0
+
+		if i == 0 {
+			expected = 1
+		}
+
+		if message.Attempts != expected {
+			t.Errorf("message %d: expected %d attempts, got %d", i, expected, message.Attempts)
+		}
+	}
+}
+
+func TestDispatcher_RetriesWhenWebhookLookupFails(t *testing.T) {
+	repository := newTestRepository(t)
+	webhook, _ := NewWebhook("https://hooks.example.com", "s3cr3t", nil)
+
+	if err := repository.CreateWebhook(webhook); err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+
+	if err := repository.Enqueue(NewEvent(EventPeerStale, nil, "peer is stale")); err != nil {
+		t.Fatalf("failed to enqueue event: %v", err)
+	}
+
+	dispatcher := &Dispatcher{Repository: repository, Client: http.DefaultClient, MaxAttempts: 5, BaseBackoff: time.Second, MaxBackoff: time.Second}
+
+	// the secret cannot be decrypted without the key
+	atrest.SetKeys(nil)
+
+	if err := dispatcher.DeliverDue(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+
+	if pending, _ := repository.CountMessages(webhook.ID, OutboxStatusPending); pending != 1 {
+		t.Errorf("expected the message to stay pending, got %d pending", pending)
+	}
+
+	// a webhook removed behind the outbox fails its messages for good
+	if err := repository.db.Exec("DELETE FROM webhooks WHERE id = ?", webhook.ID).Error; err != nil {
+		t.Fatalf("failed to delete webhook: %v", err)
+	}
+
+	if err := dispatcher.DeliverDue(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+
+	if failed, _ := repository.CountMessages(webhook.ID, OutboxStatusFailed); failed != 1 {
+		t.Errorf("expected the message to fail, got %d failed", failed)
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	dispatcher := &Dispatcher{BaseBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+
+	for attempts, expected := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 10: time.Minute} {
+		if backoff := dispatcher.Backoff(attempts); backoff != expected {
+			t.Errorf("Backoff(%d) = %s, expected %s", attempts, backoff, expected)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package notifications
+
+import "errors"
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+	ErrDeliveryFailed  = errors.New("webhook delivery failed")
+)
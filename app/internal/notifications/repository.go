@@ -0,0 +1,133 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) CreateWebhook(webhook *Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *Repository) GetAllWebhooks() ([]*Webhook, error) {
+	var webhooks []*Webhook
+
+	if err := r.db.Order("created_at ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *Repository) GetWebhook(id string) (*Webhook, error) {
+	var webhook Webhook
+
+	err := r.db.Where("id = ?", id).First(&webhook).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook removes the webhook together with its undelivered messages
+func (r *Repository) DeleteWebhook(id string) bool {
+	deleted := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&Webhook{}, "id = ?", id)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		deleted = result.RowsAffected > 0
+
+		return tx.Delete(&OutboxMessage{}, "webhook_id = ?", id).Error
+	})
+
+	return err == nil && deleted
+}
+
+// Enqueue writes the event to the outbox of every webhook subscribed to it
+func (r *Repository) Enqueue(event *Event) error {
+	webhooks, err := r.GetAllWebhooks()
+
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	messages := []*OutboxMessage{}
+
+	for _, webhook := range webhooks {
+		if !webhook.Accepts(event.Type) {
+			continue
+		}
+
+		messages = append(messages, &OutboxMessage{
+			WebhookID:     webhook.ID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        OutboxStatusPending,
+			NextAttemptAt: event.OccurredAt,
+		})
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return r.db.Create(&messages).Error
+}
+
+// GetDueMessages returns the pending messages whose next attempt is due, oldest first
+func (r *Repository) GetDueMessages(now time.Time, limit int) ([]*OutboxMessage, error) {
+	var messages []*OutboxMessage
+
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, now).Order("id ASC").Limit(limit).Find(&messages).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *Repository) SaveMessage(message *OutboxMessage) error {
+	return r.db.Save(message).Error
+}
+
+func (r *Repository) CountMessages(webhookID string, status OutboxStatus) (int64, error) {
+	var count int64
+
+	err := r.db.Model(&OutboxMessage{}).Where("webhook_id = ? AND status = ?", webhookID, status).Count(&count).Error
+
+	return count, err
+}
+
+// PruneMessages removes delivered and failed messages last updated before the given time
+func (r *Repository) PruneMessages(before time.Time) error {
+	return r.db.Where("status <> ? AND updated_at < ?", OutboxStatusPending, before).Delete(&OutboxMessage{}).Error
+}
@@ -0,0 +1,152 @@
+package notifications
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	_ "wireport/internal/encryption/atrest" // the encrypted serializer of the secrets
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EventType string
+
+const (
+	EventServerJoined        EventType = "server.joined"
+	EventServerLeft          EventType = "server.left"
+	EventServicePublished    EventType = "service.published"
+	EventServiceUnpublished  EventType = "service.unpublished"
+	EventPeerStale           EventType = "peer.stale"
+	EventCertificateExpiring EventType = "certificate.expiring"
+	EventTest                EventType = "test" // sent by 'wireport notify test' only
+)
+
+// EventTypes are the event types webhooks can subscribe to
+var EventTypes = []EventType{
+	EventServerJoined,
+	EventServerLeft,
+	EventServicePublished,
+	EventServiceUnpublished,
+	EventPeerStale,
+	EventCertificateExpiring,
+}
+
+// Event is the JSON payload posted to webhooks
+type Event struct {
+	ID         string            `json:"id"`
+	Type       EventType         `json:"type"`
+	Message    string            `json:"message"` // human-readable summary, e.g. for chat channels
+	Data       map[string]string `json:"data,omitempty"`
+	OccurredAt time.Time         `json:"occurredAt"`
+}
+
+func NewEvent(eventType EventType, data map[string]string, format string, args ...any) *Event {
+	return &Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Message:    fmt.Sprintf(format, args...),
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+// Webhook is a notification target of the gateway
+type Webhook struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	URL    string      `gorm:"type:text;not null"`
+	Secret string      `gorm:"type:text;serializer:encrypted;not null;default:''" json:"-"` // HMAC-SHA256 key of the signature header, no signature if empty
+	Events []EventType `gorm:"type:text;serializer:json;not null;default:'[]'"`             // event filter, all events if empty
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (w *Webhook) BeforeCreate(_ *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+
+	return nil
+}
+
+// NewWebhook validates the URL and the event filter of a webhook
+func NewWebhook(webhookURL string, secret string, events []string) (*Webhook, error) {
+	parsedURL, err := url.Parse(webhookURL)
+
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return nil, fmt.Errorf("%w: %s is not an http(s) URL", ErrInvalidWebhook, webhookURL)
+	}
+
+	eventTypes := []EventType{}
+
+	for _, event := range events {
+		if !slices.Contains(EventTypes, EventType(event)) {
+			supported := []string{}
+
+			for _, eventType := range EventTypes {
+				supported = append(supported, string(eventType))
+			}
+
+			return nil, fmt.Errorf("%w: unknown event %s (supported: %s)", ErrInvalidWebhook, event, strings.Join(supported, ", "))
+		}
+
+		if !slices.Contains(eventTypes, EventType(event)) {
+			eventTypes = append(eventTypes, EventType(event))
+		}
+	}
+
+	return &Webhook{
+		URL:    webhookURL,
+		Secret: secret,
+		Events: eventTypes,
+	}, nil
+}
+
+// Accepts reports whether the webhook is subscribed to the event type
+func (w *Webhook) Accepts(eventType EventType) bool {
+	return eventType == EventTest || len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+func (w *Webhook) EventsString() string {
+	if len(w.Events) == 0 {
+		return "all"
+	}
+
+	events := []string{}
+
+	for _, event := range w.Events {
+		events = append(events, string(event))
+	}
+
+	return strings.Join(events, ", ")
+}
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+	OutboxStatusFailed    OutboxStatus = "failed" // gave up after the max number of attempts
+)
+
+// OutboxMessage is an event waiting to be delivered to a webhook; events are written to the outbox by any
+// wireport process on the gateway and delivered by the gateway server, surviving restarts in between
+type OutboxMessage struct {
+	ID uint `gorm:"primaryKey;autoIncrement"`
+
+	WebhookID string    `gorm:"type:text;not null;index"`
+	EventType EventType `gorm:"type:text;not null"`
+	Payload   string    `gorm:"type:text;not null"` // JSON encoded Event
+
+	Status        OutboxStatus `gorm:"type:text;not null;index"`
+	Attempts      int          `gorm:"type:integer;not null;default:0"`
+	NextAttemptAt time.Time    `gorm:"type:timestamp;not null;index"`
+	LastError     string       `gorm:"type:text;not null;default:''"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
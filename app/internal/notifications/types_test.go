@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewWebhook(t *testing.T) {
+	webhook, err := NewWebhook("https://hooks.example.com/wireport", "secret", []string{"server.joined", "peer.stale", "server.joined"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(webhook.Events) != 2 {
+		t.Errorf("expected duplicated events to be dropped, got %v", webhook.Events)
+	}
+
+	if !webhook.Accepts(EventServerJoined) || !webhook.Accepts(EventTest) || webhook.Accepts(EventServicePublished) {
+		t.Errorf("unexpected event filter: %v", webhook.Events)
+	}
+
+	for _, invalid := range []struct {
+		url    string
+		events []string
+	}{
+		{"ftp://hooks.example.com", nil},
+		{"hooks.example.com/wireport", nil},
+		{"https://hooks.example.com", []string{"server.exploded"}},
+	} {
+		if _, err := NewWebhook(invalid.url, "", invalid.events); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("expected ErrInvalidWebhook for %s %v, got %v", invalid.url, invalid.events, err)
+		}
+	}
+}
+
+func TestWebhook_Accepts_AllEvents(t *testing.T) {
+	webhook := Webhook{}
+
+	for _, eventType := range EventTypes {
+		if !webhook.Accepts(eventType) {
+			t.Errorf("expected a webhook without filter to accept %s", eventType)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package profiles
+
+import "errors"
+
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrInvalidProfile  = errors.New("invalid profile name")
+	ErrProfileExists   = errors.New("profile already exists")
+	ErrProfileInUse    = errors.New("profile is in use")
+)
@@ -0,0 +1,260 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"wireport/internal/encryption/atrest"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// every profile is a directory with the database of the node (and its master key file and backups) in the profiles
+// directory, ~/.wireport/<profile>/wireport.db; the profile selected with 'wireport profile use' is kept next to them
+const (
+	DatabaseFileName       = "wireport.db"
+	currentProfileFileName = "current-profile"
+)
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// Profile is a wireport network managed from this machine, with the node of this machine in it
+type Profile struct {
+	Name         string
+	DatabasePath string
+	Role         string // role of the node, empty until it joins a network
+	GatewayIP    string
+	GatewayPort  uint16
+}
+
+// Gateway returns the public address of the control API of the gateway, empty until the node joins a network
+func (p *Profile) Gateway() string {
+	if p.GatewayIP == "" {
+		return ""
+	}
+
+	return net.JoinHostPort(p.GatewayIP, strconv.Itoa(int(p.GatewayPort)))
+}
+
+// ValidateName checks that the name can be used as the directory of a profile
+func ValidateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("%w: %q, use letters, digits, '.', '_' and '-'", ErrInvalidProfile, name)
+	}
+
+	return nil
+}
+
+// DatabasePath returns the database of the profile
+func DatabasePath(dir string, name string) string {
+	return filepath.Join(dir, name, DatabaseFileName)
+}
+
+// ReadCurrent returns the profile selected with 'wireport profile use', empty if none was
+func ReadCurrent(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, currentProfileFileName))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to read the current profile: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// WriteCurrent makes the profile the one used when neither --profile nor WIREPORT_PROFILE select another
+func WriteCurrent(dir string, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, currentProfileFileName)
+
+	// written next to the file and renamed, a failure never leaves a truncated file behind
+	if err := os.WriteFile(path+".tmp", []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save the current profile: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save the current profile: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the names of the profiles with a database, sorted
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the profiles: %w", err)
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		if entry.IsDir() && Exists(dir, entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Exists reports whether the profile has a database
+func Exists(dir string, name string) bool {
+	info, err := os.Stat(DatabasePath(dir, name))
+
+	return err == nil && info.Mode().IsRegular()
+}
+
+// Inspect returns the profile with the role of its node and the gateway the node joined, read from its database
+func Inspect(dir string, name string) (*Profile, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	if !Exists(dir, name) {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	profile := &Profile{Name: name, DatabasePath: DatabasePath(dir, name)}
+
+	if err := readCurrentNode(profile); err != nil {
+		return profile, fmt.Errorf("failed to read the database of profile %s: %w", name, err)
+	}
+
+	return profile, nil
+}
+
+// Rename moves the profile to a new name, together with its master key in the OS keyring (kept under the database path)
+// and the current profile selection
+func Rename(dir string, from string, to string) error {
+	if err := ValidateName(from); err != nil {
+		return err
+	}
+
+	if err := ValidateName(to); err != nil {
+		return err
+	}
+
+	if !Exists(dir, from) {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, from)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, to)); err == nil {
+		return fmt.Errorf("%w: %s", ErrProfileExists, to)
+	}
+
+	if err := atrest.MoveKeyringKey(DatabasePath(dir, from), DatabasePath(dir, to)); err != nil {
+		return err
+	}
+
+	if err := os.Rename(filepath.Join(dir, from), filepath.Join(dir, to)); err != nil {
+		_ = atrest.MoveKeyringKey(DatabasePath(dir, to), DatabasePath(dir, from))
+		return fmt.Errorf("failed to rename profile %s: %w", from, err)
+	}
+
+	current, err := ReadCurrent(dir)
+
+	if err != nil || current != from {
+		return err
+	}
+
+	return WriteCurrent(dir, to)
+}
+
+// Delete removes the profile: its database with the backups, and its master key. The current profile selection falls
+// back to the default profile if it named the deleted one
+func Delete(dir string, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	if !Exists(dir, name) {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	if err := atrest.DeleteKeyringKey(DatabasePath(dir, name)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("failed to delete profile %s: %w", name, err)
+	}
+
+	current, err := ReadCurrent(dir)
+
+	if err != nil || current != name {
+		return err
+	}
+
+	if err = os.Remove(filepath.Join(dir, currentProfileFileName)); err != nil {
+		return fmt.Errorf("failed to reset the current profile: %w", err)
+	}
+
+	return nil
+}
+
+// readCurrentNode fills in the node of the profile. The database is opened read-only and left as it is: it may belong to
+// an older or newer wireport build, the columns read here are in every schema version
+func readCurrentNode(profile *Profile) error {
+	db, err := gorm.Open(sqlite.Open("file:"+profile.DatabasePath+"?mode=ro"), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+
+	if err != nil {
+		return err
+	}
+
+	defer sqlDB.Close()
+
+	if !db.Migrator().HasTable("nodes") {
+		return nil
+	}
+
+	var node struct {
+		Role              string
+		GatewayPublicIP   string
+		GatewayPublicPort uint16
+	}
+
+	result := db.Raw("SELECT role, gateway_public_ip, gateway_public_port FROM nodes WHERE is_current_node = ? LIMIT 1", true).Scan(&node)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	profile.Role = node.Role
+	profile.GatewayIP = node.GatewayPublicIP
+	profile.GatewayPort = node.GatewayPublicPort
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package profiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func createProfile(t *testing.T, dir string, name string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+		t.Fatalf("failed to create profile directory: %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(DatabasePath(dir, name)), &gorm.Config{})
+
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	statements := []string{
+		"CREATE TABLE nodes (id text PRIMARY KEY, role text NOT NULL, is_current_node boolean NOT NULL DEFAULT false, gateway_public_ip text NOT NULL, gateway_public_port integer NOT NULL)",
+		"INSERT INTO nodes VALUES ('gateway', 'gateway', false, '140.120.110.10', 4060)",
+		"INSERT INTO nodes VALUES ('client', 'client', true, '140.120.110.10', 4060)",
+	}
+
+	for _, statement := range statements {
+		if err = db.Exec(statement).Error; err != nil {
+			t.Fatalf("failed to create nodes: %v", err)
+		}
+	}
+
+	sqlDB, _ := db.DB()
+	_ = sqlDB.Close()
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"default", "staging", "prod-eu.1", "lab_2"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", ".", "..", "../etc", "a/b", "-rf", "with space"} {
+		if err := ValidateName(name); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("expected %q to be invalid, got %v", name, err)
+		}
+	}
+}
+
+func TestListAndInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	createProfile(t, dir, "staging")
+	createProfile(t, dir, "production")
+
+	// a directory without a database is not a profile
+	if err := os.MkdirAll(filepath.Join(dir, "leftover"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := List(dir)
+
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"production", "staging"}) {
+		t.Errorf("unexpected profiles %v", names)
+	}
+
+	profile, err := Inspect(dir, "staging")
+
+	if err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+
+	if profile.Role != "client" || profile.Gateway() != "140.120.110.10:4060" {
+		t.Errorf("unexpected profile %+v", profile)
+	}
+
+	if _, err = Inspect(dir, "leftover"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestList_NoProfilesDir(t *testing.T) {
+	names, err := List(filepath.Join(t.TempDir(), "missing"))
+
+	if err != nil || len(names) != 0 {
+		t.Errorf("expected no profiles, got %v %v", names, err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	dir := t.TempDir()
+
+	createProfile(t, dir, "staging")
+	createProfile(t, dir, "production")
+
+	if err := WriteCurrent(dir, "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rename(dir, "staging", "production"); !errors.Is(err, ErrProfileExists) {
+		t.Errorf("expected ErrProfileExists, got %v", err)
+	}
+
+	if err := Rename(dir, "missing", "lab"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+
+	if err := Rename(dir, "staging", "stg"); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+
+	if Exists(dir, "staging") || !Exists(dir, "stg") {
+		t.Errorf("expected the database to move to the new name")
+	}
+
+	if current, _ := ReadCurrent(dir); current != "stg" {
+		t.Errorf("expected the current profile to follow the rename, got %q", current)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dir := t.TempDir()
+
+	createProfile(t, dir, "staging")
+	createProfile(t, dir, "production")
+
+	if err := WriteCurrent(dir, "production"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Delete(dir, "staging"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "staging")); !os.IsNotExist(err) {
+		t.Errorf("expected the profile directory to be removed, got %v", err)
+	}
+
+	if current, _ := ReadCurrent(dir); current != "production" {
+		t.Errorf("expected the current profile to be kept, got %q", current)
+	}
+
+	if err := Delete(dir, "production"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	if current, _ := ReadCurrent(dir); current != "" {
+		t.Errorf("expected the current profile to be reset, got %q", current)
+	}
+
+	if err := Delete(dir, "../staging"); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("expected ErrInvalidProfile, got %v", err)
+	}
+}
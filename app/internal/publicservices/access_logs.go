@@ -0,0 +1,69 @@
+package publicservices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// Layer4AccessLogFileName is the log of all layer 4 services, caddy-l4 has no per-route logs
+const Layer4AccessLogFileName = "layer4.log"
+
+// rotated by caddy, backups are kept next to the log (see accesslogs.Files)
+const accessLogRollSettings = `roll_size 10MiB
+            roll_keep 5`
+
+var accessLogFileNameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+// AccessLogFileName returns the name of the access log file of a layer 7 service, e.g.
+// https_demo.example.com_443_1a2b3c4d.log; the hash of the public address tells apart the hosts that only differ in
+// the replaced characters (e.g. *.example.com and _.example.com)
+func (s *PublicService) AccessLogFileName() string {
+	address := sha256.Sum256(fmt.Appendf(nil, "%s://%s:%d", s.PublicProtocol, s.PublicHost, s.PublicPort))
+
+	return fmt.Sprintf("%s_%s_%d_%s.log", s.PublicProtocol, accessLogFileNameUnsafeChars.ReplaceAllString(s.PublicHost, "_"), s.PublicPort, hex.EncodeToString(address[:4]))
+}
+
+// formatAccessLog renders the log directive of the site (layer 7)
+func formatAccessLog(file string) string {
+	return fmt.Sprintf(`log {
+        output file %s {
+            %s
+        }
+        format json
+    }`, file, accessLogRollSettings)
+}
+
+// CaddyLayer4AccessLogGlobalOption renders the global log option writing the connection logs of caddy-l4 to the file.
+// caddy-l4 logs the connections ("dial upstream", "connection stats") at debug level only, the log would stay empty at
+// the info level of the layer 7 access logs
+func CaddyLayer4AccessLogGlobalOption(file string) string {
+	return fmt.Sprintf(`log layer4 {
+        output file %s {
+            %s
+        }
+        format json
+        level DEBUG
+        include layer4
+    }`, file, accessLogRollSettings)
+}
+
+// CaddyUpstreamAddresses returns the host:port addresses caddy dials the upstreams of the service at
+func (s *PublicService) CaddyUpstreamAddresses(gatewayPublicIP string) []string {
+	addresses := []string{}
+
+	for _, upstream := range s.GetUpstreams() {
+		localHost := upstream.LocalHost
+
+		if localHost == gatewayPublicIP {
+			// same as in the caddy config
+			localHost = "0.0.0.0"
+		}
+
+		addresses = append(addresses, net.JoinHostPort(localHost, fmt.Sprint(upstream.LocalPort)))
+	}
+
+	return addresses
+}
@@ -0,0 +1,107 @@
+package publicservices
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// PublicServiceClientAuth requires visitors of an https service to present a client certificate
+type PublicServiceClientAuth struct {
+	// PEM-encoded CA certificates client certificates must be signed by;
+	// empty means the wireport public services client CA (see `wireport client cert issue`)
+	TrustedCAPEM string `json:"trusted_ca_pem,omitempty"`
+}
+
+func (a *PublicServiceClientAuth) UsesWireportCA() bool {
+	return a.TrustedCAPEM == ""
+}
+
+// CAFileName is the name of the file the trusted CA certificates are written to on the gateway
+func (a *PublicServiceClientAuth) CAFileName() string {
+	if a.UsesWireportCA() {
+		return "wireport-services-client-ca.pem"
+	}
+
+	checksum := sha256.Sum256([]byte(a.TrustedCAPEM))
+
+	return fmt.Sprintf("ca-%x.pem", checksum[:8])
+}
+
+func (a *PublicServiceClientAuth) Validate() error {
+	if a.UsesWireportCA() {
+		return nil
+	}
+
+	rest := []byte(a.TrustedCAPEM)
+	certsCount := 0
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("%w: failed to parse CA certificate: %v", ErrInvalidClientAuth, err)
+		}
+
+		certsCount++
+	}
+
+	if certsCount == 0 {
+		return fmt.Errorf("%w: no CA certificates found in PEM", ErrInvalidClientAuth)
+	}
+
+	return nil
+}
+
+func (s *PublicService) validateClientAuth() error {
+	if s.ClientAuth == nil {
+		return nil
+	}
+
+	if s.PublicProtocol != "https" {
+		return fmt.Errorf("%w: client certificates are supported for https services only", ErrInvalidClientAuth)
+	}
+
+	return s.ClientAuth.Validate()
+}
+
+// formatTLS renders the tls directive of an https site: user-provided certificate and/or client certificate verification
+func (s *PublicService) formatTLS(options CaddyConfigEntryOptions) (string, error) {
+	parts := []string{"tls"}
+
+	if options.TLSCertificateFile != "" {
+		parts = append(parts, options.TLSCertificateFile, options.TLSKeyFile)
+	}
+
+	if s.ClientAuth != nil {
+		if options.ClientCAFile == "" {
+			// never render a service that must be protected without the protection
+			return "", fmt.Errorf("%w: CA file for %s://%s:%d is not set", ErrInvalidClientAuth, s.PublicProtocol, s.PublicHost, s.PublicPort)
+		}
+
+		parts = append(parts, fmt.Sprintf(`{
+        client_auth {
+            mode require_and_verify
+            trust_pool file %s
+        }
+    }`, options.ClientCAFile))
+	}
+
+	if len(parts) == 1 {
+		return "", nil
+	}
+
+	return strings.Join(parts, " "), nil
+}
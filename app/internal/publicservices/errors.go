@@ -3,7 +3,14 @@ package publicservices
 import "errors"
 
 var (
-	ErrServiceNotFound = errors.New("public service not found")
-	ErrParamNotFound   = errors.New("service parameter not found")
-	ErrParamExists     = errors.New("service parameter already exists")
+	ErrServiceNotFound    = errors.New("public service not found")
+	ErrParamNotFound      = errors.New("service parameter not found")
+	ErrParamExists        = errors.New("service parameter already exists")
+	ErrUpstreamNotFound   = errors.New("service upstream not found")
+	ErrInvalidUpstream    = errors.New("invalid service upstream")
+	ErrInvalidLBPolicy    = errors.New("invalid load balancing policy")
+	ErrInvalidHealthCheck = errors.New("invalid health check")
+	ErrInvalidRoute       = errors.New("invalid route")
+	ErrRouteConflict      = errors.New("route conflict")
+	ErrInvalidClientAuth  = errors.New("invalid client authentication")
 )
@@ -0,0 +1,157 @@
+package publicservices
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+type UpstreamHealthStatus string
+
+const (
+	UpstreamHealthStatusHealthy   UpstreamHealthStatus = "healthy"
+	UpstreamHealthStatusUnhealthy UpstreamHealthStatus = "unhealthy"
+)
+
+// UpstreamHealth is the last result of probing an upstream of a public service from the gateway
+type UpstreamHealth struct {
+	PublicProtocol string `gorm:"type:text;primaryKey"`
+	PublicHost     string `gorm:"type:text;primaryKey"`
+	PublicPort     uint16 `gorm:"type:integer;primaryKey"`
+
+	LocalProtocol string `gorm:"type:text;primaryKey"`
+	LocalHost     string `gorm:"type:text;primaryKey"`
+	LocalPort     uint16 `gorm:"type:integer;primaryKey"`
+
+	Status    UpstreamHealthStatus `gorm:"type:text;not null"`
+	LatencyMs int64                `gorm:"type:integer;not null;default:0"`
+	Error     string               `gorm:"type:text;not null;default:''"`
+	CheckedAt time.Time            `gorm:"type:timestamp;not null"`
+}
+
+func (h *UpstreamHealth) Matches(service *PublicService, upstream PublicServiceUpstream) bool {
+	return h.PublicProtocol == service.PublicProtocol && h.PublicHost == service.PublicHost && h.PublicPort == service.PublicPort &&
+		h.LocalProtocol == upstream.LocalProtocol && h.LocalHost == upstream.LocalHost && h.LocalPort == upstream.LocalPort
+}
+
+func (h *UpstreamHealth) String() string {
+	if h.Status == UpstreamHealthStatusHealthy {
+		return fmt.Sprintf("✅ %dms", h.LatencyMs)
+	}
+
+	return fmt.Sprintf("❌ %s", h.Error)
+}
+
+// FindUpstreamHealth returns the health of the upstream of the service or nil if it was not probed yet
+func FindUpstreamHealth(health []*UpstreamHealth, service *PublicService, upstream PublicServiceUpstream) *UpstreamHealth {
+	for _, h := range health {
+		if h.Matches(service, upstream) {
+			return h
+		}
+	}
+
+	return nil
+}
+
+// ProbeUpstream checks the upstream of the service: TCP connect for layer 4 services,
+// HTTP GET of the health check path (/ by default) for layer 7 ones
+func ProbeUpstream(service *PublicService, upstream PublicServiceUpstream, gatewayPublicIP string, defaultTimeout time.Duration) *UpstreamHealth {
+	timeout := defaultTimeout
+
+	if service.HealthCheck != nil && service.HealthCheck.Timeout != "" {
+		if parsedTimeout, err := time.ParseDuration(service.HealthCheck.Timeout); err == nil {
+			timeout = parsedTimeout
+		}
+	}
+
+	localHost := upstream.LocalHost
+
+	if localHost == gatewayPublicIP {
+		// caddy won't see the network interface for the gateway public IP from inside docker containers, so we use 0.0.0.0
+		localHost = "0.0.0.0"
+	}
+
+	address := net.JoinHostPort(localHost, fmt.Sprint(upstream.LocalPort))
+	startedAt := time.Now()
+
+	var err error
+
+	if service.IsLayer4() {
+		err = probeTCP(address, timeout)
+	} else {
+		err = probeHTTP(upstream.LocalProtocol, address, service.HealthCheck, timeout)
+	}
+
+	health := &UpstreamHealth{
+		PublicProtocol: service.PublicProtocol,
+		PublicHost:     service.PublicHost,
+		PublicPort:     service.PublicPort,
+		LocalProtocol:  upstream.LocalProtocol,
+		LocalHost:      upstream.LocalHost,
+		LocalPort:      upstream.LocalPort,
+		Status:         UpstreamHealthStatusHealthy,
+		LatencyMs:      time.Since(startedAt).Milliseconds(),
+		CheckedAt:      startedAt,
+	}
+
+	if err != nil {
+		health.Status = UpstreamHealthStatusUnhealthy
+		health.Error = err.Error()
+	}
+
+	return health
+}
+
+func probeTCP(address string, timeout time.Duration) error {
+	connection, err := net.DialTimeout("tcp", address, timeout)
+
+	if err != nil {
+		return err
+	}
+
+	return connection.Close()
+}
+
+// probeTransport is shared by all HTTP probes; every probe opens a new connection, none is kept idle between the probes
+var probeTransport = &http.Transport{
+	// liveness only: upstreams commonly use self-signed certificates inside the network
+	TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	DisableKeepAlives: true,
+}
+
+func probeHTTP(protocol string, address string, healthCheck *PublicServiceHealthCheck, timeout time.Duration) error {
+	path := "/"
+
+	if healthCheck != nil && healthCheck.Path != "" {
+		path = healthCheck.Path
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: probeTransport,
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	response, err := client.Get(fmt.Sprintf("%s://%s%s", protocol, address, path))
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("GET %s returned %s", path, response.Status)
+	}
+
+	// a dedicated health path must succeed (same as caddy's health_uri), any answer below 500 on / means the upstream is up
+	if path != "/" && response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET %s returned %s", path, response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package publicservices
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func upstreamOf(t *testing.T, protocol string, address string) PublicServiceUpstream {
+	host, portStr, err := net.SplitHostPort(address)
+
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", address, err)
+	}
+
+	port, _ := strconv.ParseUint(portStr, 10, 16)
+
+	return PublicServiceUpstream{LocalProtocol: protocol, LocalHost: host, LocalPort: uint16(port)}
+}
+
+func TestProbeUpstream_Layer7(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.WriteHeader(http.StatusOK)
+		case "/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	upstream := upstreamOf(t, "http", serverURL.Host)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected UpstreamHealthStatus
+	}{
+		{"default path answers", "", UpstreamHealthStatusHealthy},
+		{"health path succeeds", "/healthz", UpstreamHealthStatusHealthy},
+		{"health path not found", "/missing", UpstreamHealthStatusUnhealthy},
+		{"health path fails", "/broken", UpstreamHealthStatusUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &PublicService{PublicProtocol: "https", PublicHost: "demo.example.com", PublicPort: 443}
+
+			if tt.path != "" {
+				service.HealthCheck = &PublicServiceHealthCheck{Path: tt.path}
+			}
+
+			health := ProbeUpstream(service, upstream, "123.123.123.123", time.Second)
+
+			if health.Status != tt.expected {
+				t.Errorf("expected %s, got %s (%s)", tt.expected, health.Status, health.Error)
+			}
+
+			if !health.Matches(service, upstream) {
+				t.Errorf("expected the health to match the probed upstream, got %+v", health)
+			}
+		})
+	}
+}
+
+func TestProbeUpstream_Layer4(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	address := listener.Addr().String()
+	service := &PublicService{PublicProtocol: "tcp", PublicHost: "demo.example.com", PublicPort: 5432}
+
+	if health := ProbeUpstream(service, upstreamOf(t, "tcp", address), "123.123.123.123", time.Second); health.Status != UpstreamHealthStatusHealthy {
+		t.Errorf("expected healthy upstream, got %s (%s)", health.Status, health.Error)
+	}
+
+	listener.Close()
+
+	if health := ProbeUpstream(service, upstreamOf(t, "tcp", address), "123.123.123.123", time.Second); health.Status != UpstreamHealthStatusUnhealthy || health.Error == "" {
+		t.Errorf("expected unhealthy upstream with an error, got %+v", health)
+	}
+}
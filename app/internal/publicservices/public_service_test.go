@@ -1,6 +1,7 @@
 package publicservices
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -753,3 +754,660 @@ func TestPublicService_AsCaddyConfigEntry_Empty_Public_Host_Error(t *testing.T)
 		t.Errorf("expected error '%s', got '%s'", expectedError, err.Error())
 	}
 }
+
+// load balancing
+
+func TestPublicService_AsCaddyConfigEntry_Layer7_With_Multiple_Upstreams(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      8080,
+		PublicProtocol: "https",
+		PublicHost:     "example.com",
+		PublicPort:     443,
+		Upstreams: []PublicServiceUpstream{
+			{LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 8080},
+			{LocalProtocol: "http", LocalHost: "10.0.0.4", LocalPort: 8080},
+		},
+		LBPolicy:    PublicServiceLBPolicyLeastConn,
+		HealthCheck: &PublicServiceHealthCheck{Path: "/healthz", Interval: "10s", Timeout: "2s"},
+		Params:      []PublicServiceParam{{ParamType: PublicServiceParamTypeCaddyFreeText, ParamValue: "header_up Host {host}"}},
+	}
+
+	expected := `
+https://example.com {
+    reverse_proxy http://10.0.0.2:8080 http://10.0.0.3:8080 http://10.0.0.4:8080 {
+        lb_policy least_conn
+        health_uri /healthz
+        health_interval 10s
+        health_timeout 2s
+        header_up Host {host}
+    }
+}
+`
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntry_Layer7_HealthCheck_Without_Path(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      8080,
+		PublicProtocol: "https",
+		PublicHost:     "example.com",
+		PublicPort:     443,
+		Upstreams: []PublicServiceUpstream{
+			{LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 8080},
+		},
+		HealthCheck: &PublicServiceHealthCheck{Interval: "10s"},
+		Params:      []PublicServiceParam{},
+	}
+
+	expected := `
+https://example.com {
+    reverse_proxy http://10.0.0.2:8080 http://10.0.0.3:8080 {
+        health_uri /
+        health_interval 10s
+    }
+}
+`
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntry_Layer4_With_Multiple_Upstreams(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "tcp",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      5432,
+		PublicProtocol: "tcp",
+		PublicHost:     "123.123.123.123",
+		PublicPort:     5432,
+		Upstreams: []PublicServiceUpstream{
+			{LocalProtocol: "tcp", LocalHost: "10.0.0.3", LocalPort: 5432},
+		},
+		LBPolicy:    PublicServiceLBPolicyIPHash,
+		HealthCheck: &PublicServiceHealthCheck{Interval: "5s"},
+		Params:      []PublicServiceParam{},
+	}
+
+	expected := `
+tcp/0.0.0.0:5432 {
+    route {
+        proxy {
+            upstream tcp/10.0.0.2:5432
+            upstream tcp/10.0.0.3:5432
+            lb_policy ip_hash
+            health_interval 5s
+        }
+    }
+}
+`
+
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntry_Mixed_Upstream_Protocols_Error(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      8080,
+		PublicProtocol: "https",
+		PublicHost:     "example.com",
+		PublicPort:     443,
+		Upstreams: []PublicServiceUpstream{
+			{LocalProtocol: "https", LocalHost: "10.0.0.3", LocalPort: 8443},
+		},
+		Params: []PublicServiceParam{},
+	}
+
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err == nil {
+		t.Errorf("expected error, got %v", err)
+	}
+
+	if got != "" {
+		t.Errorf("expected empty string, got %s", got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntry_Layer4_Health_Path_Error(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "tcp",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      5432,
+		PublicProtocol: "tcp",
+		PublicHost:     "123.123.123.123",
+		PublicPort:     5432,
+		HealthCheck:    &PublicServiceHealthCheck{Path: "/healthz"},
+		Params:         []PublicServiceParam{},
+	}
+
+	_, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err == nil {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestPublicService_AddUpstream_And_RemoveUpstream(t *testing.T) {
+	nodeA := "node-a"
+	nodeB := "node-b"
+
+	service := PublicService{
+		PublishedByNodeID: &nodeA,
+		LocalProtocol:     "http",
+		LocalHost:         "10.0.0.2",
+		LocalPort:         8080,
+		PublicProtocol:    "http",
+		PublicHost:        "example.com",
+		PublicPort:        80,
+	}
+
+	if !service.AddUpstream(PublicServiceUpstream{PublishedByNodeID: &nodeB, LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 8080}) {
+		t.Fatalf("expected upstream to be added")
+	}
+
+	if service.AddUpstream(PublicServiceUpstream{PublishedByNodeID: &nodeB, LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 8080}) {
+		t.Errorf("expected duplicate upstream to be rejected")
+	}
+
+	if len(service.GetUpstreams()) != 2 {
+		t.Fatalf("expected 2 upstreams, got %d", len(service.GetUpstreams()))
+	}
+
+	if !service.RemoveUpstream("http", "10.0.0.2", 8080) {
+		t.Fatalf("expected primary upstream to be removed")
+	}
+
+	// the additional upstream is promoted to primary
+	if service.LocalHost != "10.0.0.3" || service.PublishedByNodeID == nil || *service.PublishedByNodeID != nodeB {
+		t.Errorf("expected 10.0.0.3 published by %s to become primary, got %s", nodeB, service.LocalHost)
+	}
+
+	if len(service.Upstreams) != 0 {
+		t.Errorf("expected no additional upstreams, got %d", len(service.Upstreams))
+	}
+
+	if service.RemoveUpstream("http", "10.0.0.2", 8080) {
+		t.Errorf("expected removal of unknown upstream to fail")
+	}
+
+	if len(service.GetUpstreamsPublishedByNode(nodeB)) != 1 || len(service.GetUpstreamsPublishedByNode(nodeA)) != 0 {
+		t.Errorf("unexpected upstream ownership after removal")
+	}
+}
+
+// routes
+
+func TestPublicService_AsCaddyConfigEntry_Layer7_With_Routes(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      3000,
+		PublicProtocol: "https",
+		PublicHost:     "api.example.com",
+		PublicPort:     443,
+		Routes: []PublicServiceRoute{
+			{
+				PublicServiceRouteMatcher: PublicServiceRouteMatcher{PathPrefix: "/v1", StripPrefix: true},
+				Upstreams:                 []PublicServiceUpstream{{LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 4000}},
+			},
+			{
+				PublicServiceRouteMatcher: PublicServiceRouteMatcher{PathPrefix: "/v2", HeaderName: "X-Canary", HeaderValue: "1"},
+				Upstreams:                 []PublicServiceUpstream{{LocalProtocol: "http", LocalHost: "10.0.0.4", LocalPort: 4000}},
+			},
+		},
+		Params: []PublicServiceParam{},
+	}
+
+	expected := `
+https://api.example.com {
+    @route_1 {
+        path /v2 /v2/*
+        header X-Canary 1
+    }
+    handle @route_1 {
+        reverse_proxy http://10.0.0.4:4000
+    }
+
+    @route_2 {
+        path /v1 /v1/*
+    }
+    handle @route_2 {
+        uri strip_prefix /v1
+        reverse_proxy http://10.0.0.3:4000
+    }
+
+    handle {
+        reverse_proxy http://10.0.0.2:3000
+    }
+}
+`
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntry_Layer7_With_Routes_Only(t *testing.T) {
+	service := PublicService{
+		PublicProtocol: "https",
+		PublicHost:     "api.example.com",
+		PublicPort:     443,
+		Routes: []PublicServiceRoute{
+			{
+				PublicServiceRouteMatcher: PublicServiceRouteMatcher{PathPrefix: "/v1/", StripPrefix: true, HeaderName: "X-Tenant", HeaderValue: "acme"},
+				Upstreams:                 []PublicServiceUpstream{{LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 4000}},
+			},
+		},
+		Params: []PublicServiceParam{},
+	}
+
+	expected := `
+https://api.example.com {
+    @route_1 {
+        path /v1 /v1/*
+        header X-Tenant acme
+    }
+    handle @route_1 {
+        uri strip_prefix /v1
+        reverse_proxy http://10.0.0.3:4000
+    }
+}
+`
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntry_Layer7_With_Strip_And_NonStrip_Routes(t *testing.T) {
+	// the shorter strip prefix route is listed first, it must not shadow the longer one
+	service := PublicService{
+		PublicProtocol: "https",
+		PublicHost:     "api.example.com",
+		PublicPort:     443,
+		Routes: []PublicServiceRoute{
+			{
+				PublicServiceRouteMatcher: PublicServiceRouteMatcher{PathPrefix: "/api", StripPrefix: true},
+				Upstreams:                 []PublicServiceUpstream{{LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 4000}},
+			},
+			{
+				PublicServiceRouteMatcher: PublicServiceRouteMatcher{PathPrefix: "/api/admin"},
+				Upstreams:                 []PublicServiceUpstream{{LocalProtocol: "http", LocalHost: "10.0.0.4", LocalPort: 4000}},
+			},
+			{
+				PublicServiceRouteMatcher: PublicServiceRouteMatcher{PathPrefix: "/api/v2", StripPrefix: true, HeaderName: "X-Tenant", HeaderValue: "acme corp"},
+				Upstreams:                 []PublicServiceUpstream{{LocalProtocol: "http", LocalHost: "10.0.0.5", LocalPort: 4000}},
+			},
+		},
+		Params: []PublicServiceParam{},
+	}
+
+	expected := `
+https://api.example.com {
+    @route_1 {
+        path /api/admin /api/admin/*
+    }
+    handle @route_1 {
+        reverse_proxy http://10.0.0.4:4000
+    }
+
+    @route_2 {
+        path /api/v2 /api/v2/*
+        header X-Tenant "acme corp"
+    }
+    handle @route_2 {
+        uri strip_prefix /api/v2
+        reverse_proxy http://10.0.0.5:4000
+    }
+
+    @route_3 {
+        path /api /api/*
+    }
+    handle @route_3 {
+        uri strip_prefix /api
+        reverse_proxy http://10.0.0.3:4000
+    }
+}
+`
+	got, err := service.AsCaddyConfigEntry("123.123.123.123")
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicServiceRouteMatcher_Validate_Injection(t *testing.T) {
+	matchers := []PublicServiceRouteMatcher{
+		{HeaderName: "X-Tenant", HeaderValue: "acme\n}\nrespond 200"},
+		{HeaderName: "X-Tenant", HeaderValue: "acme }"},
+		{HeaderName: "X-Tenant", HeaderValue: "{http.request.host}"},
+		{HeaderName: "X-Tenant", HeaderValue: "acme\" corp"},
+		{HeaderName: "X-Tenant\r", HeaderValue: "acme"},
+		{HeaderName: "X-Tenant}", HeaderValue: "acme"},
+		{PathPrefix: "/v1\nrespond 200"},
+	}
+
+	for _, matcher := range matchers {
+		if err := matcher.Validate(); !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("expected invalid route for %q: %q, got %v", matcher.HeaderName+matcher.PathPrefix, matcher.HeaderValue, err)
+		}
+	}
+
+	valid := PublicServiceRouteMatcher{PathPrefix: "/v1", HeaderName: "X-Tenant", HeaderValue: "acme corp"}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected a header value with spaces to be valid, got %v", err)
+	}
+}
+
+func TestPublicService_AddRouteUpstream_Conflicts(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      3000,
+		PublicProtocol: "https",
+		PublicHost:     "api.example.com",
+		PublicPort:     443,
+	}
+
+	upstream := PublicServiceUpstream{LocalProtocol: "http", LocalHost: "10.0.0.3", LocalPort: 4000}
+
+	if added, err := service.AddRouteUpstream(PublicServiceRouteMatcher{PathPrefix: "/v1", StripPrefix: true}, upstream); !added || err != nil {
+		t.Fatalf("expected route to be added, got %v, %v", added, err)
+	}
+
+	if added, err := service.AddRouteUpstream(PublicServiceRouteMatcher{PathPrefix: "/v1", StripPrefix: true}, upstream); added || err != nil {
+		t.Errorf("expected duplicate upstream to be skipped, got %v, %v", added, err)
+	}
+
+	if _, err := service.AddRouteUpstream(PublicServiceRouteMatcher{PathPrefix: "/v1"}, upstream); !errors.Is(err, ErrRouteConflict) {
+		t.Errorf("expected route conflict, got %v", err)
+	}
+
+	if _, err := service.AddRouteUpstream(PublicServiceRouteMatcher{PathPrefix: "v2"}, upstream); !errors.Is(err, ErrInvalidRoute) {
+		t.Errorf("expected invalid route, got %v", err)
+	}
+
+	if _, err := service.AddRouteUpstream(PublicServiceRouteMatcher{PathPrefix: "/v1", StripPrefix: true}, PublicServiceUpstream{LocalProtocol: "https", LocalHost: "10.0.0.4", LocalPort: 4443}); !errors.Is(err, ErrRouteConflict) {
+		t.Errorf("expected route conflict for mixed protocols, got %v", err)
+	}
+
+	layer4 := PublicService{LocalProtocol: "tcp", LocalHost: "10.0.0.2", LocalPort: 5432, PublicProtocol: "tcp", PublicHost: "123.123.123.123", PublicPort: 5432}
+
+	if _, err := layer4.AddRouteUpstream(PublicServiceRouteMatcher{PathPrefix: "/v1"}, upstream); !errors.Is(err, ErrRouteConflict) {
+		t.Errorf("expected route conflict for layer 4 service, got %v", err)
+	}
+
+	// removing the only upstream of a route drops the route
+	if !service.RemoveUpstream("http", "10.0.0.3", 4000) || len(service.Routes) != 0 {
+		t.Errorf("expected route to be removed together with its last upstream")
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntryWithOptions_TLS_Certificate(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      3000,
+		PublicProtocol: "https",
+		PublicHost:     "app.example.com",
+		PublicPort:     443,
+		Params:         []PublicServiceParam{},
+	}
+
+	expected := `
+https://app.example.com {
+    tls /etc/caddy/certificates/1.crt /etc/caddy/certificates/1.key
+
+    reverse_proxy http://10.0.0.2:3000
+}
+`
+	got, err := service.AsCaddyConfigEntryWithOptions("123.123.123.123", CaddyConfigEntryOptions{
+		TLSCertificateFile: "/etc/caddy/certificates/1.crt",
+		TLSKeyFile:         "/etc/caddy/certificates/1.key",
+	})
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// the certificate is ignored for plain http services
+	service.PublicProtocol = "http"
+	service.PublicPort = 80
+
+	got, err = service.AsCaddyConfigEntryWithOptions("123.123.123.123", CaddyConfigEntryOptions{
+		TLSCertificateFile: "/etc/caddy/certificates/1.crt",
+		TLSKeyFile:         "/etc/caddy/certificates/1.key",
+	})
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if strings.Contains(got, "tls ") {
+		t.Errorf("expected no tls directive for http service, got %s", got)
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntryWithOptions_ClientAuth(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      3000,
+		PublicProtocol: "https",
+		PublicHost:     "grafana.example.com",
+		PublicPort:     443,
+		ClientAuth:     &PublicServiceClientAuth{},
+		Params:         []PublicServiceParam{},
+	}
+
+	if _, err := service.AsCaddyConfigEntry("123.123.123.123"); !errors.Is(err, ErrInvalidClientAuth) {
+		t.Errorf("expected ErrInvalidClientAuth without CA file, got %v", err)
+	}
+
+	expected := `
+https://grafana.example.com {
+    tls /etc/caddy/certificates/1.crt /etc/caddy/certificates/1.key {
+        client_auth {
+            mode require_and_verify
+            trust_pool file /etc/caddy/client-cas/wireport-services-client-ca.pem
+        }
+    }
+
+    reverse_proxy http://10.0.0.2:3000
+}
+`
+	got, err := service.AsCaddyConfigEntryWithOptions("123.123.123.123", CaddyConfigEntryOptions{
+		TLSCertificateFile: "/etc/caddy/certificates/1.crt",
+		TLSKeyFile:         "/etc/caddy/certificates/1.key",
+		ClientCAFile:       "/etc/caddy/client-cas/" + service.ClientAuth.CAFileName(),
+	})
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	service.PublicProtocol = "http"
+	service.PublicPort = 80
+
+	if err := service.Validate(); !errors.Is(err, ErrInvalidClientAuth) {
+		t.Errorf("expected ErrInvalidClientAuth for http service, got %v", err)
+	}
+
+	service.PublicProtocol = "https"
+	service.ClientAuth = &PublicServiceClientAuth{TrustedCAPEM: "not a certificate"}
+
+	if err := service.Validate(); !errors.Is(err, ErrInvalidClientAuth) {
+		t.Errorf("expected ErrInvalidClientAuth for invalid CA, got %v", err)
+	}
+}
+
+func TestPublicService_CaddySiteAddress(t *testing.T) {
+	tests := []struct {
+		protocol string
+		host     string
+		port     uint16
+		expected string
+	}{
+		{"https", "demo.example.com", 443, "https://demo.example.com"},
+		{"https", "demo.example.com", 8443, "https://demo.example.com:8443"},
+		{"http", "demo.example.com", 8080, "http://demo.example.com:8080"},
+		{"http", "123.123.123.123", 8080, ":8080"},
+		{"tcp", "demo.example.com", 5432, "tcp/0.0.0.0:5432"},
+	}
+
+	for _, tt := range tests {
+		service := PublicService{PublicProtocol: tt.protocol, PublicHost: tt.host, PublicPort: tt.port}
+
+		got, err := service.CaddySiteAddress("123.123.123.123")
+
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+
+		if got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestPublicService_AsCaddyConfigEntryWithOptions_AccessLog(t *testing.T) {
+	service := PublicService{
+		LocalProtocol:  "http",
+		LocalHost:      "10.0.0.2",
+		LocalPort:      3000,
+		PublicProtocol: "https",
+		PublicHost:     "*.example.com",
+		PublicPort:     443,
+		Params:         []PublicServiceParam{},
+	}
+
+	if service.AccessLogFileName() != "https__.example.com_443_336bac55.log" {
+		t.Errorf("unexpected access log file name %s", service.AccessLogFileName())
+	}
+
+	underscored := PublicService{PublicProtocol: "https", PublicHost: "_.example.com", PublicPort: 443}
+
+	if underscored.AccessLogFileName() == service.AccessLogFileName() {
+		t.Errorf("expected the access log file names of *.example.com and _.example.com to differ")
+	}
+
+	expected := `
+https://*.example.com {
+    tls /etc/caddy/certificates/1.crt /etc/caddy/certificates/1.key
+
+    log {
+        output file /app/wireport/caddy/logs/https__.example.com_443_336bac55.log {
+            roll_size 10MiB
+            roll_keep 5
+        }
+        format json
+    }
+
+    reverse_proxy http://10.0.0.2:3000
+}
+`
+	got, err := service.AsCaddyConfigEntryWithOptions("123.123.123.123", CaddyConfigEntryOptions{
+		TLSCertificateFile: "/etc/caddy/certificates/1.crt",
+		TLSKeyFile:         "/etc/caddy/certificates/1.key",
+		AccessLogFile:      "/app/wireport/caddy/logs/" + service.AccessLogFileName(),
+	})
+
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if removeSpaces(got) != removeSpaces(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestPublicService_CaddyUpstreamAddresses(t *testing.T) {
+	service := PublicService{LocalProtocol: "tcp", LocalHost: "123.123.123.123", LocalPort: 5432, PublicProtocol: "tcp", PublicHost: "db.example.com", PublicPort: 5432}
+
+	if !service.AddUpstream(PublicServiceUpstream{LocalProtocol: "tcp", LocalHost: "10.0.0.3", LocalPort: 5432}) {
+		t.Fatalf("expected the upstream to be added")
+	}
+
+	got := service.CaddyUpstreamAddresses("123.123.123.123")
+	expected := []string{"0.0.0.0:5432", "10.0.0.3:5432"}
+
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPublishOptions_Apply(t *testing.T) {
+	service := PublicService{LBPolicy: PublicServiceLBPolicyLeastConn, HealthCheck: &PublicServiceHealthCheck{Path: "/healthz"}}
+
+	unchanged := PublishOptions{LBPolicy: PublicServiceLBPolicyLeastConn, HealthCheck: &PublicServiceHealthCheck{Path: "/healthz"}}
+
+	if unchanged.Changes(&service) || (&PublishOptions{}).Changes(&service) {
+		t.Errorf("expected the same or empty options not to change the service")
+	}
+
+	reset := PublishOptions{ResetLBPolicy: true, RemoveHealthCheck: true}
+
+	if !reset.Changes(&service) {
+		t.Errorf("expected the reset to change the service")
+	}
+
+	reset.Apply(&service)
+
+	if service.LBPolicy != PublicServiceLBPolicyDefault || service.HealthCheck != nil {
+		t.Errorf("expected the default LB policy and no health check, got %q %+v", service.LBPolicy, service.HealthCheck)
+	}
+
+	if reset.Changes(&service) {
+		t.Errorf("expected the reset of a service with defaults to change nothing")
+	}
+}
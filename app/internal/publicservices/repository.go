@@ -15,23 +15,26 @@ func NewRepository(db *gorm.DB) *Repository {
 }
 
 func (r *Repository) Save(service *PublicService) error {
-	// For PublicService with composite primary key, we need to handle this carefully
-	// First try to find existing record
-	var existingService PublicService
-	err := r.db.Where("public_protocol = ? AND public_host = ? AND public_port = ?",
-		service.PublicProtocol, service.PublicHost, service.PublicPort).First(&existingService).Error
-
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// No existing record, create new one
-			return r.db.Create(service).Error
+	// the lookup and the write are one transaction, a failed write leaves the service as it was
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// For PublicService with composite primary key, we need to handle this carefully
+		// First try to find existing record
+		var existingService PublicService
+		err := tx.Where("public_protocol = ? AND public_host = ? AND public_port = ?",
+			service.PublicProtocol, service.PublicHost, service.PublicPort).First(&existingService).Error
+
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				// No existing record, create new one
+				return tx.Create(service).Error
+			}
+			return err
 		}
-		return err
-	}
 
-	// Record exists, update it with all fields including PublishedByNodeID
-	service.CreatedAt = existingService.CreatedAt // Preserve original creation time
-	return r.db.Save(service).Error
+		// Record exists, update it with all fields including PublishedByNodeID
+		service.CreatedAt = existingService.CreatedAt // Preserve original creation time
+		return tx.Save(service).Error
+	})
 }
 
 func (r *Repository) GetAll() ([]*PublicService, error) {
@@ -127,3 +130,28 @@ func (r *Repository) RemoveParam(publicProtocol, publicHost string, publicPort u
 
 	return result.Error == nil && result.RowsAffected > 0
 }
+
+// ReplaceHealth replaces the stored upstream health with the results of the latest probe round
+func (r *Repository) ReplaceHealth(health []*UpstreamHealth) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&UpstreamHealth{}).Error; err != nil {
+			return err
+		}
+
+		if len(health) == 0 {
+			return nil
+		}
+
+		return tx.Create(&health).Error
+	})
+}
+
+func (r *Repository) GetAllHealth() ([]*UpstreamHealth, error) {
+	var health []*UpstreamHealth
+
+	if err := r.db.Find(&health).Error; err != nil {
+		return nil, err
+	}
+
+	return health, nil
+}
@@ -0,0 +1,252 @@
+package publicservices
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PublicServiceRouteMatcher selects the requests of a layer 7 service that are sent to a route
+type PublicServiceRouteMatcher struct {
+	PathPrefix  string `json:"path_prefix"`  // e.g. /v1
+	StripPrefix bool   `json:"strip_prefix"` // strip PathPrefix before proxying
+	HeaderName  string `json:"header_name"`  // e.g. X-Api-Version
+	HeaderValue string `json:"header_value"` // e.g. 2
+}
+
+// SameMatch reports whether both matchers select the same requests (strip prefix setting is not compared)
+func (m *PublicServiceRouteMatcher) SameMatch(other *PublicServiceRouteMatcher) bool {
+	return m.PathPrefix == other.PathPrefix && m.HeaderName == other.HeaderName && m.HeaderValue == other.HeaderValue
+}
+
+func (m *PublicServiceRouteMatcher) Validate() error {
+	if m.PathPrefix == "" && m.HeaderName == "" {
+		return fmt.Errorf("%w: path prefix or header must be set", ErrInvalidRoute)
+	}
+
+	if m.PathPrefix != "" && !strings.HasPrefix(m.PathPrefix, "/") {
+		return fmt.Errorf("%w: path prefix must start with /", ErrInvalidRoute)
+	}
+
+	if m.PathPrefix == "/" {
+		return fmt.Errorf("%w: path prefix / matches all requests, publish the service without a route instead", ErrInvalidRoute)
+	}
+
+	if m.StripPrefix && m.PathPrefix == "" {
+		return fmt.Errorf("%w: strip prefix requires a path prefix", ErrInvalidRoute)
+	}
+
+	if (m.HeaderName == "") != (m.HeaderValue == "") {
+		return fmt.Errorf("%w: both header name and header value must be set", ErrInvalidRoute)
+	}
+
+	if strings.ContainsAny(m.PathPrefix+m.HeaderName, " \t{}\"") || strings.ContainsFunc(m.PathPrefix+m.HeaderName, unicode.IsControl) {
+		return fmt.Errorf("%w: path prefix and header name must not contain spaces, quotes, braces or control characters", ErrInvalidRoute)
+	}
+
+	// the value is written into the Caddyfile, quoted if it has spaces, see formatHeaderValue
+	if strings.ContainsAny(m.HeaderValue, "{}\"\\") || strings.ContainsFunc(m.HeaderValue, unicode.IsControl) {
+		return fmt.Errorf("%w: header value must not contain quotes, backslashes, braces or control characters", ErrInvalidRoute)
+	}
+
+	return nil
+}
+
+func (m *PublicServiceRouteMatcher) String() string {
+	parts := []string{}
+
+	if m.PathPrefix != "" {
+		parts = append(parts, fmt.Sprintf("path %s*", strings.TrimSuffix(m.PathPrefix, "/")))
+	}
+
+	if m.HeaderName != "" {
+		parts = append(parts, fmt.Sprintf("header %s: %s", m.HeaderName, m.HeaderValue))
+	}
+
+	if m.StripPrefix {
+		parts = append(parts, "strip prefix")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// formatHeaderValue quotes header values with spaces, the other ones are written as is
+func formatHeaderValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+
+	return value
+}
+
+// PublicServiceRoute sends requests selected by the matcher to its own upstream set
+type PublicServiceRoute struct {
+	PublicServiceRouteMatcher
+	Upstreams []PublicServiceUpstream `json:"upstreams"`
+}
+
+// AddRouteUpstream adds the upstream to the route with the given matcher, creating the route if needed;
+// returns false if the upstream is already present in the route
+func (s *PublicService) AddRouteUpstream(matcher PublicServiceRouteMatcher, upstream PublicServiceUpstream) (bool, error) {
+	if s.IsLayer4() {
+		return false, fmt.Errorf("%w: routes are supported for http and https services only", ErrRouteConflict)
+	}
+
+	if err := matcher.Validate(); err != nil {
+		return false, err
+	}
+
+	for i := range s.Routes {
+		route := &s.Routes[i]
+
+		if !route.SameMatch(&matcher) {
+			continue
+		}
+
+		if route.StripPrefix != matcher.StripPrefix {
+			return false, fmt.Errorf("%w: route %s already exists with a different strip prefix setting", ErrRouteConflict, route.PublicServiceRouteMatcher.String())
+		}
+
+		for _, existing := range route.Upstreams {
+			if existing.Matches(upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort) {
+				return false, nil
+			}
+		}
+
+		if len(route.Upstreams) > 0 && route.Upstreams[0].LocalProtocol != upstream.LocalProtocol {
+			return false, fmt.Errorf("%w: all upstreams of route %s must use the same protocol (%s != %s)", ErrRouteConflict, route.PublicServiceRouteMatcher.String(), upstream.LocalProtocol, route.Upstreams[0].LocalProtocol)
+		}
+
+		route.Upstreams = append(route.Upstreams, upstream)
+
+		return true, nil
+	}
+
+	s.Routes = append(s.Routes, PublicServiceRoute{
+		PublicServiceRouteMatcher: matcher,
+		Upstreams:                 []PublicServiceUpstream{upstream},
+	})
+
+	return true, nil
+}
+
+// removeRoutesUpstream removes the upstream from all routes, dropping the routes left without upstreams
+func (s *PublicService) removeRoutesUpstream(localProtocol, localHost string, localPort uint16) bool {
+	removed := false
+	routes := []PublicServiceRoute{}
+
+	for _, route := range s.Routes {
+		upstreams := []PublicServiceUpstream{}
+
+		for _, upstream := range route.Upstreams {
+			if upstream.Matches(localProtocol, localHost, localPort) {
+				removed = true
+				continue
+			}
+
+			upstreams = append(upstreams, upstream)
+		}
+
+		if len(upstreams) == 0 {
+			continue
+		}
+
+		route.Upstreams = upstreams
+		routes = append(routes, route)
+	}
+
+	s.Routes = routes
+
+	return removed
+}
+
+// sortedRoutes returns the routes ordered from the most to the least specific one,
+// so that longer path prefixes and header matches take precedence
+func (s *PublicService) sortedRoutes() []PublicServiceRoute {
+	routes := append([]PublicServiceRoute{}, s.Routes...)
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if len(routes[i].PathPrefix) != len(routes[j].PathPrefix) {
+			return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+		}
+
+		return routes[i].HeaderName != "" && routes[j].HeaderName == ""
+	})
+
+	return routes
+}
+
+func (s *PublicService) validateRoutes() error {
+	if len(s.Routes) > 0 && s.IsLayer4() {
+		return fmt.Errorf("%w: routes are supported for http and https services only", ErrRouteConflict)
+	}
+
+	for i, route := range s.Routes {
+		if err := route.PublicServiceRouteMatcher.Validate(); err != nil {
+			return err
+		}
+
+		if len(route.Upstreams) == 0 {
+			return fmt.Errorf("%w: route %s has no upstreams", ErrInvalidRoute, route.PublicServiceRouteMatcher.String())
+		}
+
+		if err := validateUpstreams(route.Upstreams, s.PublicProtocol); err != nil {
+			return err
+		}
+
+		for _, other := range s.Routes[i+1:] {
+			if route.SameMatch(&other.PublicServiceRouteMatcher) {
+				return fmt.Errorf("%w: route %s is defined more than once", ErrRouteConflict, route.PublicServiceRouteMatcher.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// formatRoutes renders the routes of a layer 7 service as handle blocks of the site. Every route has a named matcher
+// (even a plain path prefix), Caddy keeps such blocks in the order of sortedRoutes; handle_path or inline path matchers
+// would be sorted by Caddy ahead of them and a short prefix could shadow a longer one
+func (s *PublicService) formatRoutes(gatewayPublicIP string) []string {
+	blocks := []string{}
+
+	for i, route := range s.sortedRoutes() {
+		pathPattern := ""
+
+		if route.PathPrefix != "" {
+			prefix := strings.TrimSuffix(route.PathPrefix, "/")
+			pathPattern = fmt.Sprintf("%s %s/*", prefix, prefix)
+		}
+
+		reverseProxy := s.formatReverseProxy(route.Upstreams, gatewayPublicIP, 12, 8)
+
+		matcherName := fmt.Sprintf("@route_%d", i+1)
+		matcherLines := []string{}
+
+		if pathPattern != "" {
+			matcherLines = append(matcherLines, fmt.Sprintf("path %s", pathPattern))
+		}
+
+		if route.HeaderName != "" {
+			matcherLines = append(matcherLines, fmt.Sprintf("header %s %s", route.HeaderName, formatHeaderValue(route.HeaderValue)))
+		}
+
+		handleLines := []string{}
+
+		if route.StripPrefix {
+			handleLines = append(handleLines, fmt.Sprintf("uri strip_prefix %s", strings.TrimSuffix(route.PathPrefix, "/")))
+		}
+
+		handleLines = append(handleLines, reverseProxy)
+
+		blocks = append(blocks, fmt.Sprintf(`%s {
+        %s
+    }
+    handle %s {
+        %s
+    }`, matcherName, strings.Join(matcherLines, "\n        "), matcherName, strings.Join(handleLines, "\n        ")))
+	}
+
+	return blocks
+}
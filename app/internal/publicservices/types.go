@@ -17,6 +17,115 @@ const (
 	PublicServiceParamTypeCaddyFreeText PublicServiceParamType = "caddyFreeTextParam"
 )
 
+type PublicServiceLBPolicy string
+
+const (
+	PublicServiceLBPolicyDefault    PublicServiceLBPolicy = ""
+	PublicServiceLBPolicyRoundRobin PublicServiceLBPolicy = "round_robin"
+	PublicServiceLBPolicyLeastConn  PublicServiceLBPolicy = "least_conn"
+	PublicServiceLBPolicyIPHash     PublicServiceLBPolicy = "ip_hash"
+)
+
+func ParseLBPolicy(policy string) (PublicServiceLBPolicy, error) {
+	switch PublicServiceLBPolicy(policy) {
+	case PublicServiceLBPolicyDefault, PublicServiceLBPolicyRoundRobin, PublicServiceLBPolicyLeastConn, PublicServiceLBPolicyIPHash:
+		return PublicServiceLBPolicy(policy), nil
+	}
+
+	return PublicServiceLBPolicyDefault, fmt.Errorf("%w: %s (supported: %s, %s, %s)", ErrInvalidLBPolicy, policy, PublicServiceLBPolicyRoundRobin, PublicServiceLBPolicyLeastConn, PublicServiceLBPolicyIPHash)
+}
+
+// PublicServiceUpstream is a single local target of a public service
+type PublicServiceUpstream struct {
+	// node id of the node that added the upstream; server nodes are only allowed to remove their own upstreams
+	PublishedByNodeID *string `json:"published_by_node_id"`
+
+	LocalProtocol string `json:"local_protocol"` // http, https, udp, tcp
+	LocalHost     string `json:"local_host"`     // domain, ip
+	LocalPort     uint16 `json:"local_port"`     // port
+}
+
+func (u *PublicServiceUpstream) Matches(localProtocol, localHost string, localPort uint16) bool {
+	return u.LocalProtocol == localProtocol && u.LocalHost == localHost && u.LocalPort == localPort
+}
+
+func (u *PublicServiceUpstream) IsPublishedByNode(nodeID string) bool {
+	return u.PublishedByNodeID != nil && *u.PublishedByNodeID == nodeID
+}
+
+// PublicServiceHealthCheck configures active health checks of the service upstreams
+type PublicServiceHealthCheck struct {
+	Path     string `json:"path"`     // layer 7 only, e.g. /healthz
+	Interval string `json:"interval"` // e.g. 10s
+	Timeout  string `json:"timeout"`  // e.g. 2s
+}
+
+func (h *PublicServiceHealthCheck) Validate(isLayer4 bool) error {
+	if isLayer4 && h.Path != "" {
+		return fmt.Errorf("%w: health check path is not supported for layer 4 services", ErrInvalidHealthCheck)
+	}
+
+	if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+		return fmt.Errorf("%w: health check path must start with /", ErrInvalidHealthCheck)
+	}
+
+	for _, duration := range []string{h.Interval, h.Timeout} {
+		if duration == "" {
+			continue
+		}
+
+		if _, err := time.ParseDuration(duration); err != nil {
+			return fmt.Errorf("%w: invalid duration %s", ErrInvalidHealthCheck, duration)
+		}
+	}
+
+	return nil
+}
+
+// PublishOptions holds the optional settings applied to the service when it is published
+type PublishOptions struct {
+	LBPolicy    PublicServiceLBPolicy     `json:"lbPolicy"`
+	HealthCheck *PublicServiceHealthCheck `json:"healthCheck"`
+
+	// restore Caddy's default LB policy and remove the health check, see 'service publish --lb-policy default --no-health-check'
+	ResetLBPolicy     bool `json:"resetLBPolicy"`
+	RemoveHealthCheck bool `json:"removeHealthCheck"`
+
+	// if set, the upstream is added to the route with this matcher instead of the default upstream set
+	Route *PublicServiceRouteMatcher `json:"route"`
+}
+
+// Apply sets the non-empty options on the service and clears the settings to reset
+func (o *PublishOptions) Apply(service *PublicService) {
+	if o.ResetLBPolicy {
+		service.LBPolicy = PublicServiceLBPolicyDefault
+	} else if o.LBPolicy != PublicServiceLBPolicyDefault {
+		service.LBPolicy = o.LBPolicy
+	}
+
+	if o.RemoveHealthCheck {
+		service.HealthCheck = nil
+	} else if o.HealthCheck != nil {
+		service.HealthCheck = o.HealthCheck
+	}
+}
+
+// Changes reports whether Apply would change the LB policy or the health check of the service
+func (o *PublishOptions) Changes(service *PublicService) bool {
+	changed := *service
+	o.Apply(&changed)
+
+	if changed.LBPolicy != service.LBPolicy {
+		return true
+	}
+
+	if changed.HealthCheck == nil || service.HealthCheck == nil {
+		return changed.HealthCheck != service.HealthCheck
+	}
+
+	return *changed.HealthCheck != *service.HealthCheck
+}
+
 type PublicService struct {
 	/*
 			Node id of the node that published the service
@@ -33,12 +142,235 @@ type PublicService struct {
 	PublicHost     string `gorm:"type:text;primaryKey;uniqueIndex:idx_public_service"`    // domain:port
 	PublicPort     uint16 `gorm:"type:integer;primaryKey;uniqueIndex:idx_public_service"` // port
 
+	// additional upstreams, load balanced together with the primary one (Local* fields above)
+	Upstreams   []PublicServiceUpstream   `gorm:"type:text;serializer:json;not null;default:'[]'"`
+	LBPolicy    PublicServiceLBPolicy     `gorm:"type:text;not null;default:''"`
+	HealthCheck *PublicServiceHealthCheck `gorm:"type:text;serializer:json"`
+
+	// path/header based routes (layer 7 only); requests not matched by any route go to the upstreams above
+	Routes []PublicServiceRoute `gorm:"type:text;serializer:json;not null;default:'[]'"`
+
+	// if set, visitors must present a client certificate (https only)
+	ClientAuth *PublicServiceClientAuth `gorm:"type:text;serializer:json"`
+
 	Params []PublicServiceParam `gorm:"type:text;serializer:json;not null;default:[]"`
 
 	CreatedAt time.Time `gorm:"type:timestamp;not null"`
 	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
 }
 
+func (s *PublicService) IsLayer4() bool {
+	return s.PublicProtocol == "tcp" || s.PublicProtocol == "udp"
+}
+
+// IsWildcardHTTPS reports whether the service needs a wildcard certificate (only obtainable via the DNS-01 challenge)
+func (s *PublicService) IsWildcardHTTPS() bool {
+	return s.PublicProtocol == "https" && strings.HasPrefix(s.PublicHost, "*.")
+}
+
+// GetUpstreams returns the primary upstream followed by the additional ones
+func (s *PublicService) GetUpstreams() []PublicServiceUpstream {
+	upstreams := []PublicServiceUpstream{}
+
+	if s.LocalHost != "" {
+		upstreams = append(upstreams, PublicServiceUpstream{
+			PublishedByNodeID: s.PublishedByNodeID,
+			LocalProtocol:     s.LocalProtocol,
+			LocalHost:         s.LocalHost,
+			LocalPort:         s.LocalPort,
+		})
+	}
+
+	return append(upstreams, s.Upstreams...)
+}
+
+func (s *PublicService) HasUpstream(localProtocol, localHost string, localPort uint16) bool {
+	for _, upstream := range s.GetUpstreams() {
+		if upstream.Matches(localProtocol, localHost, localPort) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddUpstream adds the upstream to the service, returns false if it is already present
+func (s *PublicService) AddUpstream(upstream PublicServiceUpstream) bool {
+	if s.HasUpstream(upstream.LocalProtocol, upstream.LocalHost, upstream.LocalPort) {
+		return false
+	}
+
+	if s.LocalHost == "" {
+		s.PublishedByNodeID = upstream.PublishedByNodeID
+		s.LocalProtocol = upstream.LocalProtocol
+		s.LocalHost = upstream.LocalHost
+		s.LocalPort = upstream.LocalPort
+
+		return true
+	}
+
+	s.Upstreams = append(s.Upstreams, upstream)
+
+	return true
+}
+
+// GetAllUpstreams returns the default upstreams followed by the upstreams of all routes
+func (s *PublicService) GetAllUpstreams() []PublicServiceUpstream {
+	upstreams := s.GetUpstreams()
+
+	for _, route := range s.Routes {
+		upstreams = append(upstreams, route.Upstreams...)
+	}
+
+	return upstreams
+}
+
+// HasUpstreams reports whether the service still has any default or route upstreams
+func (s *PublicService) HasUpstreams() bool {
+	return len(s.GetAllUpstreams()) > 0
+}
+
+// RemoveUpstream removes the upstream from the default set and from all routes,
+// promoting the first additional upstream to primary if needed
+func (s *PublicService) RemoveUpstream(localProtocol, localHost string, localPort uint16) bool {
+	removedFromRoutes := s.removeRoutesUpstream(localProtocol, localHost, localPort)
+
+	upstreams := s.GetUpstreams()
+	remaining := []PublicServiceUpstream{}
+
+	for _, upstream := range upstreams {
+		if upstream.Matches(localProtocol, localHost, localPort) {
+			continue
+		}
+
+		remaining = append(remaining, upstream)
+	}
+
+	if len(remaining) == len(upstreams) {
+		return removedFromRoutes
+	}
+
+	s.LocalProtocol, s.LocalHost, s.LocalPort = "", "", 0
+	s.Upstreams = []PublicServiceUpstream{}
+
+	for _, upstream := range remaining {
+		s.AddUpstream(upstream)
+	}
+
+	return true
+}
+
+// GetUpstreamsPublishedByNode returns the upstreams that were added by the given node
+func (s *PublicService) GetUpstreamsPublishedByNode(nodeID string) []PublicServiceUpstream {
+	upstreams := []PublicServiceUpstream{}
+
+	for _, upstream := range s.GetAllUpstreams() {
+		if upstream.IsPublishedByNode(nodeID) {
+			upstreams = append(upstreams, upstream)
+		}
+	}
+
+	return upstreams
+}
+
+func validateUpstreams(upstreams []PublicServiceUpstream, publicProtocol string) error {
+	for _, upstream := range upstreams {
+		if upstream.LocalHost == "" {
+			return fmt.Errorf("local host cannot be empty")
+		}
+
+		if (upstream.LocalProtocol == "udp" && publicProtocol == "tcp") ||
+			(upstream.LocalProtocol == "tcp" && publicProtocol == "udp") {
+			return fmt.Errorf("for layer 4, local protocol and public protocol must be the same (udp -> udp or tcp -> tcp)")
+		}
+
+		if upstream.LocalProtocol != upstreams[0].LocalProtocol {
+			return fmt.Errorf("all upstreams of a service must use the same protocol (%s != %s)", upstream.LocalProtocol, upstreams[0].LocalProtocol)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the upstream sets, routes and the load balancing settings of the service
+func (s *PublicService) Validate() error {
+	if !s.HasUpstreams() {
+		return fmt.Errorf("local host cannot be empty")
+	}
+
+	if err := validateUpstreams(s.GetUpstreams(), s.PublicProtocol); err != nil {
+		return err
+	}
+
+	if err := s.validateRoutes(); err != nil {
+		return err
+	}
+
+	if _, err := ParseLBPolicy(string(s.LBPolicy)); err != nil {
+		return err
+	}
+
+	if s.HealthCheck != nil {
+		if err := s.HealthCheck.Validate(s.IsLayer4()); err != nil {
+			return err
+		}
+	}
+
+	if err := s.validateClientAuth(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// formatReverseProxy renders the reverse_proxy directive for the given upstream set (layer 7)
+func (s *PublicService) formatReverseProxy(upstreams []PublicServiceUpstream, gatewayPublicIP string, levelSpacesMain int, levelSpacesClosing int) string {
+	upstreamAddresses := []string{}
+
+	for _, upstream := range upstreams {
+		localHost := upstream.LocalHost
+
+		if localHost == gatewayPublicIP {
+			// caddy won't see the network interface for the gateway public IP from inside docker containers, so we use 0.0.0.0
+			localHost = "0.0.0.0"
+		}
+
+		upstreamAddresses = append(upstreamAddresses, fmt.Sprintf("%s://%s:%d", upstream.LocalProtocol, localHost, upstream.LocalPort))
+	}
+
+	blockParams := append(s.loadBalancingParams(), s.Params...)
+
+	return strings.TrimSpace(fmt.Sprintf("reverse_proxy %s %s", strings.Join(upstreamAddresses, " "), formatBlockParams(blockParams, levelSpacesMain, levelSpacesClosing)))
+}
+
+// loadBalancingParams renders lb policy and health check settings as proxy block params
+func (s *PublicService) loadBalancingParams() []PublicServiceParam {
+	params := []PublicServiceParam{}
+
+	if s.LBPolicy != PublicServiceLBPolicyDefault {
+		params = append(params, PublicServiceParam{ParamType: PublicServiceParamTypeCaddyFreeText, ParamValue: fmt.Sprintf("lb_policy %s", s.LBPolicy)})
+	}
+
+	if s.HealthCheck != nil {
+		if s.HealthCheck.Path != "" {
+			params = append(params, PublicServiceParam{ParamType: PublicServiceParamTypeCaddyFreeText, ParamValue: fmt.Sprintf("health_uri %s", s.HealthCheck.Path)})
+		} else if !s.IsLayer4() {
+			// caddy runs no active health checks of http upstreams without a URI
+			params = append(params, PublicServiceParam{ParamType: PublicServiceParamTypeCaddyFreeText, ParamValue: "health_uri /"})
+		}
+
+		if s.HealthCheck.Interval != "" {
+			params = append(params, PublicServiceParam{ParamType: PublicServiceParamTypeCaddyFreeText, ParamValue: fmt.Sprintf("health_interval %s", s.HealthCheck.Interval)})
+		}
+
+		if s.HealthCheck.Timeout != "" {
+			params = append(params, PublicServiceParam{ParamType: PublicServiceParamTypeCaddyFreeText, ParamValue: fmt.Sprintf("health_timeout %s", s.HealthCheck.Timeout)})
+		}
+	}
+
+	return params
+}
+
 func formatBlockParams(blockParams []PublicServiceParam, levelSpacesMain int, levelSpacesClosing int) string {
 	if len(blockParams) == 0 {
 		return ""
@@ -53,18 +385,19 @@ func formatBlockParams(blockParams []PublicServiceParam, levelSpacesMain int, le
 	return fmt.Sprintf("{\n%s\n%s}", strings.Join(blockParamsList, "\n"), strings.Repeat(" ", levelSpacesClosing))
 }
 
-func (s *PublicService) AsCaddyConfigEntry(gatewayPublicIP string) (result string, err error) {
-	if (s.LocalProtocol == "udp" && s.PublicProtocol == "tcp") ||
-		(s.LocalProtocol == "tcp" && s.PublicProtocol == "udp") {
-		return "", fmt.Errorf("for layer 4, local protocol and public protocol must be the same (udp -> udp or tcp -> tcp)")
-	}
-
-	if s.LocalHost == "" {
-		return "", fmt.Errorf("local host cannot be empty")
-	}
+// CaddyConfigEntryOptions holds gateway-wide settings affecting the caddy config entry of a service
+type CaddyConfigEntryOptions struct {
+	TLSCertificateFile string // user-provided certificate served instead of an ACME one (https only)
+	TLSKeyFile         string
+	ClientCAFile       string // CA certificates client certificates are verified against (services with client auth only)
+	AccessLogFile      string // JSON access log of the site, no access log if empty
+}
 
-	if s.PublicHost == "" {
-		return "", fmt.Errorf("public host cannot be empty")
+// CaddySiteAddress returns the address the service is served on in the caddy config, e.g. https://demo.example.com or tcp/0.0.0.0:8080
+func (s *PublicService) CaddySiteAddress(gatewayPublicIP string) (string, error) {
+	if s.IsLayer4() {
+		// layer 4 services always listen on all interfaces, see AsCaddyConfigEntryWithOptions
+		return fmt.Sprintf("%s/0.0.0.0:%d", s.PublicProtocol, s.PublicPort), nil
 	}
 
 	publicHost := s.PublicHost
@@ -74,54 +407,114 @@ func (s *PublicService) AsCaddyConfigEntry(gatewayPublicIP string) (result strin
 		publicHost = "0.0.0.0"
 	}
 
-	localHost := s.LocalHost
+	// fallback option for standard ports (80 and 443)
+	publicHostname := fmt.Sprintf("%s://%s", s.PublicProtocol, publicHost)
+	publicHostnameIsIP := false
 
-	if localHost == gatewayPublicIP {
-		// caddy won't see the network interface for the gateway public IP from inside docker containers, so we use 0.0.0.0
-		localHost = "0.0.0.0"
+	if ip := net.ParseIP(publicHost); ip != nil {
+		publicHostnameIsIP = true
+	}
+
+	if s.PublicProtocol == "https" {
+		if publicHostnameIsIP {
+			return "", fmt.Errorf("https on ip address is not supported")
+		}
+
+		if s.PublicPort != 443 {
+			publicHostname = fmt.Sprintf("%s://%s:%d", s.PublicProtocol, publicHost, s.PublicPort)
+		}
+	} else if s.PublicProtocol == "http" && s.PublicPort != 80 {
+		// if hostname is a dns-name - use full entry, otherwise - use just the port
+
+		if publicHostnameIsIP {
+			// ip
+			publicHostname = fmt.Sprintf(":%d", s.PublicPort)
+		} else {
+			// dns name
+			publicHostname = fmt.Sprintf("%s://%s:%d", s.PublicProtocol, publicHost, s.PublicPort)
+		}
+	}
+
+	return publicHostname, nil
+}
+
+func (s *PublicService) AsCaddyConfigEntry(gatewayPublicIP string) (result string, err error) {
+	return s.AsCaddyConfigEntryWithOptions(gatewayPublicIP, CaddyConfigEntryOptions{})
+}
+
+func (s *PublicService) AsCaddyConfigEntryWithOptions(gatewayPublicIP string, options CaddyConfigEntryOptions) (result string, err error) {
+	if err := s.Validate(); err != nil {
+		return "", err
 	}
 
-	result = fmt.Sprintf("# service publication: %s://%s:%d (public) -> %s://%s:%d (local)", s.PublicProtocol, publicHost, s.PublicPort, s.LocalProtocol, localHost, s.LocalPort)
+	if s.PublicHost == "" {
+		return "", fmt.Errorf("public host cannot be empty")
+	}
 
 	switch s.PublicProtocol {
 	case "https", "http":
-		// fallback option for standard ports (80 and 443)
-		publicHostname := fmt.Sprintf("%s://%s", s.PublicProtocol, publicHost)
-		publicHostnameIsIP := false
+		publicHostname, err := s.CaddySiteAddress(gatewayPublicIP)
 
-		if ip := net.ParseIP(publicHost); ip != nil {
-			publicHostnameIsIP = true
+		if err != nil {
+			return "", err
 		}
 
-		if s.PublicProtocol == "https" {
-			if publicHostnameIsIP {
-				return "", fmt.Errorf("https on ip address is not supported")
+		siteBody := ""
+
+		if len(s.Routes) == 0 {
+			siteBody = s.formatReverseProxy(s.GetUpstreams(), gatewayPublicIP, 8, 4)
+		} else {
+			siteBlocks := s.formatRoutes(gatewayPublicIP)
+
+			if len(s.GetUpstreams()) > 0 {
+				// requests not matched by any route
+				siteBlocks = append(siteBlocks, fmt.Sprintf(`handle {
+        %s
+    }`, s.formatReverseProxy(s.GetUpstreams(), gatewayPublicIP, 12, 8)))
 			}
 
-			if s.PublicPort != 443 {
-				publicHostname = fmt.Sprintf("%s://%s:%d", s.PublicProtocol, publicHost, s.PublicPort)
+			siteBody = strings.Join(siteBlocks, "\n\n    ")
+		}
+
+		if options.AccessLogFile != "" {
+			siteBody = fmt.Sprintf("%s\n\n    %s", formatAccessLog(options.AccessLogFile), siteBody)
+		}
+
+		if s.PublicProtocol == "https" {
+			tlsDirective, err := s.formatTLS(options)
+
+			if err != nil {
+				return "", err
 			}
-		} else if s.PublicProtocol == "http" && s.PublicPort != 80 {
-			// if hostname is a dns-name - use full entry, otherwise - use just the port
-
-			if publicHostnameIsIP {
-				// ip
-				publicHostname = fmt.Sprintf(":%d", s.PublicPort)
-			} else {
-				// dns name
-				publicHostname = fmt.Sprintf("%s://%s:%d", s.PublicProtocol, publicHost, s.PublicPort)
+
+			if tlsDirective != "" {
+				siteBody = fmt.Sprintf("%s\n\n    %s", tlsDirective, siteBody)
 			}
 		}
 
-		reverseProxy := strings.TrimSpace(fmt.Sprintf("reverse_proxy %s://%s:%d %s", s.LocalProtocol, localHost, s.LocalPort, formatBlockParams(s.Params, 8, 4)))
-
 		result = fmt.Sprintf(`
 %s {
     %s
 }
-`, publicHostname, reverseProxy)
+`, publicHostname, siteBody)
 	case "udp", "tcp":
-		upstream := strings.TrimSpace(fmt.Sprintf("upstream %s/%s:%d %s", s.LocalProtocol, localHost, s.LocalPort, formatBlockParams(s.Params, 16, 12)))
+		proxyLines := []string{}
+
+		for _, upstream := range s.GetUpstreams() {
+			localHost := upstream.LocalHost
+
+			if localHost == gatewayPublicIP {
+				// caddy won't see the network interface for the gateway public IP from inside docker containers, so we use 0.0.0.0
+				localHost = "0.0.0.0"
+			}
+
+			proxyLines = append(proxyLines, strings.TrimSpace(fmt.Sprintf("upstream %s/%s:%d %s", upstream.LocalProtocol, localHost, upstream.LocalPort, formatBlockParams(s.Params, 24, 20))))
+		}
+
+		// lb policy and health checks are configured on the proxy level, not per upstream
+		for _, param := range s.loadBalancingParams() {
+			proxyLines = append(proxyLines, param.ParamValue)
+		}
 		/*
 			publicHost is always 0.0.0.0 for layer 4 host:
 			- if publicHost is a DNS name, resolution happens on the client side, not affecting caddy config for this sake
@@ -137,7 +530,7 @@ func (s *PublicService) AsCaddyConfigEntry(gatewayPublicIP string) (result strin
                 }
             }
         }
-`, s.PublicProtocol, publicHost, s.PublicPort, upstream)
+`, s.PublicProtocol, publicHost, s.PublicPort, strings.Join(proxyLines, "\n"+strings.Repeat(" ", 20)))
 	}
 
 	return strings.TrimRight(result, "\t "), nil
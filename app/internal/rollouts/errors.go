@@ -0,0 +1,13 @@
+package rollouts
+
+import "errors"
+
+var (
+	ErrRolloutNotFound    = errors.New("no server upgrade has been started yet")
+	ErrRolloutInProgress  = errors.New("a server upgrade is already in progress")
+	ErrNoServersToUpgrade = errors.New("no servers to upgrade")
+	ErrInvalidReport      = errors.New("invalid upgrade report")
+	ErrRolloutHalted      = errors.New("the server upgrade has been halted")
+	ErrRolloutCancelled   = errors.New("the server upgrade has been cancelled")
+	ErrRolloutNotRunning  = errors.New("no server upgrade is running")
+)
@@ -0,0 +1,71 @@
+package rollouts
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create saves a new rollout unless another one is still running
+func (r *Repository) Create(rollout *Rollout) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var running int64
+
+		if err := tx.Model(&Rollout{}).Where("status = ?", StatusRunning).Count(&running).Error; err != nil {
+			return err
+		}
+
+		if running > 0 {
+			return ErrRolloutInProgress
+		}
+
+		return tx.Create(rollout).Error
+	})
+}
+
+// GetRunning returns the running rollout, nil if there is none
+func (r *Repository) GetRunning() (*Rollout, error) {
+	rollout, err := r.find(r.db.Where("status = ?", StatusRunning))
+
+	if errors.Is(err, ErrRolloutNotFound) {
+		return nil, nil
+	}
+
+	return rollout, err
+}
+
+// GetLatest returns the most recently started rollout
+func (r *Repository) GetLatest() (*Rollout, error) {
+	return r.find(r.db)
+}
+
+// Save updates the rollout together with its steps
+func (r *Repository) Save(rollout *Rollout) error {
+	return r.db.Session(&gorm.Session{FullSaveAssociations: true}).Save(rollout).Error
+}
+
+func (r *Repository) find(query *gorm.DB) (*Rollout, error) {
+	var rollout Rollout
+
+	err := query.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Order("created_at DESC").First(&rollout).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRolloutNotFound
+		}
+
+		return nil, err
+	}
+
+	return &rollout, nil
+}
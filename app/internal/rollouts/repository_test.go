@@ -0,0 +1,61 @@
+package rollouts
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"wireport/internal/database/testutil"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	db := testutil.NewInMemoryDB(t, &Rollout{}, &Step{})
+
+	return NewRepository(db)
+}
+
+func TestRepository_RolloutLifecycle(t *testing.T) {
+	repository := newTestRepository(t)
+
+	if _, err := repository.GetLatest(); !errors.Is(err, ErrRolloutNotFound) {
+		t.Fatalf("expected ErrRolloutNotFound, got %v", err)
+	}
+
+	rollout, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "", testServers())
+
+	if err := repository.Create(rollout); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	another, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.1", "", testServers())
+
+	if err := repository.Create(another); !errors.Is(err, ErrRolloutInProgress) {
+		t.Fatalf("expected ErrRolloutInProgress, got %v", err)
+	}
+
+	running, err := repository.GetRunning()
+
+	if err != nil || running == nil || running.ID != rollout.ID || len(running.Steps) != 3 {
+		t.Fatalf("expected the running rollout with its steps, got %+v %v", running, err)
+	}
+
+	_ = running.Report("server-1", StepStatusUpgrading, "", time.Now())
+	_ = running.Report("server-1", StepStatusFailed, "image not found", time.Now())
+
+	if err = repository.Save(running); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if running, err = repository.GetRunning(); err != nil || running != nil {
+		t.Fatalf("expected no running rollout after the halt, got %+v %v", running, err)
+	}
+
+	latest, err := repository.GetLatest()
+
+	if err != nil || latest.Status != StatusHalted || latest.Steps[0].Status != StepStatusFailed || latest.Steps[0].Message != "image not found" {
+		t.Fatalf("expected the halted rollout with the failed step, got %+v %v", latest, err)
+	}
+
+	if err = repository.Create(another); err != nil {
+		t.Errorf("expected a new rollout to start after the halt, got %v", err)
+	}
+}
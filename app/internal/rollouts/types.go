@@ -0,0 +1,249 @@
+package rollouts
+
+import (
+	"fmt"
+	"slices"
+	"time"
+	"wireport/internal/nodes/types"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusHalted    Status = "halted"    // a server failed to upgrade, the remaining servers are left as they are
+	StatusCancelled Status = "cancelled" // stopped with 'server upgrade --cancel', the remaining servers are left as they are
+)
+
+type StepStatus string
+
+const (
+	StepStatusPending   StepStatus = "pending"
+	StepStatusUpgrading StepStatus = "upgrading" // the server has pulled the image and is replacing its container
+	StepStatusSucceeded StepStatus = "succeeded" // reported by the server running the new image
+	StepStatusFailed    StepStatus = "failed"
+)
+
+// Rollout is a rolling upgrade of SERVER nodes to an image, orchestrated by the gateway: the servers pick up their
+// step one at a time (see Assignment) and report back, the rollout halts on the first failure
+type Rollout struct {
+	ID string `gorm:"type:text;primaryKey"`
+
+	Image  string  `gorm:"type:text;not null"`            // image reference with tag, e.g. ghcr.io/multionlabs/wireport:1.2.0
+	Label  string  `gorm:"type:text;not null;default:''"` // only the servers with the label are upgraded, all servers if empty
+	Status Status  `gorm:"type:text;not null"`
+	Steps  []*Step `gorm:"foreignKey:RolloutID;constraint:OnDelete:CASCADE"` // in the upgrade order
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null"`
+	UpdatedAt time.Time `gorm:"type:timestamp;not null"`
+}
+
+func (r *Rollout) BeforeCreate(_ *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+
+	return nil
+}
+
+// Step is the upgrade of a single server within a rollout
+type Step struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	RolloutID string `gorm:"type:text;not null;index"`
+	Position  int    `gorm:"not null"`
+
+	NodeID   string `gorm:"type:text;not null"`
+	NodeName string `gorm:"type:text;not null;default:''"` // name in the wireport DNS zone at the time of the rollout
+
+	Status  StepStatus `gorm:"type:text;not null"`
+	Message string     `gorm:"type:text;not null;default:''"` // failure reason reported by the server or the gateway
+
+	StartedAt  *time.Time `gorm:"type:timestamp"`
+	FinishedAt *time.Time `gorm:"type:timestamp"`
+}
+
+// Assignment is the step of the requesting server in the running rollout
+type Assignment struct {
+	RolloutID string     `json:"rolloutId"`
+	Image     string     `json:"image"`
+	Status    StepStatus `json:"status"`
+}
+
+// NewRollout plans the upgrade of the given servers (all of them, or the ones with the label) in their order
+func NewRollout(image string, label string, servers []types.Node) (*Rollout, error) {
+	rollout := &Rollout{
+		Image:  image,
+		Label:  label,
+		Status: StatusRunning,
+		Steps:  []*Step{},
+	}
+
+	for _, server := range servers {
+		if server.Role != types.NodeRoleServer {
+			continue
+		}
+
+		if label != "" && !slices.Contains(server.Labels, label) {
+			continue
+		}
+
+		rollout.Steps = append(rollout.Steps, &Step{
+			Position: len(rollout.Steps) + 1,
+			NodeID:   server.ID,
+			NodeName: server.Hostname(),
+			Status:   StepStatusPending,
+		})
+	}
+
+	if len(rollout.Steps) == 0 {
+		if label != "" {
+			return nil, fmt.Errorf("%w: no server has the label %s", ErrNoServersToUpgrade, label)
+		}
+
+		return nil, fmt.Errorf("%w: no servers have joined the network", ErrNoServersToUpgrade)
+	}
+
+	return rollout, nil
+}
+
+// CurrentStep returns the step in progress (pending or upgrading) of a running rollout, nil otherwise
+func (r *Rollout) CurrentStep() *Step {
+	if r.Status != StatusRunning {
+		return nil
+	}
+
+	for _, step := range r.Steps {
+		if step.Status == StepStatusPending || step.Status == StepStatusUpgrading {
+			return step
+		}
+	}
+
+	return nil
+}
+
+// AssignmentFor returns the step of the node if it is the one to upgrade now, nil otherwise
+func (r *Rollout) AssignmentFor(nodeID string) *Assignment {
+	step := r.CurrentStep()
+
+	if step == nil || step.NodeID != nodeID {
+		return nil
+	}
+
+	return &Assignment{
+		RolloutID: r.ID,
+		Image:     r.Image,
+		Status:    step.Status,
+	}
+}
+
+// Report moves the current step of the node forward: pending -> upgrading -> succeeded, or failed at any point, which
+// halts the rollout. The rollout is completed with the last succeeded step
+func (r *Rollout) Report(nodeID string, status StepStatus, message string, now time.Time) error {
+	step := r.CurrentStep()
+
+	if step == nil || step.NodeID != nodeID {
+		return fmt.Errorf("%w: the node has no upgrade in progress in rollout %s", ErrInvalidReport, r.ID)
+	}
+
+	switch status {
+	case StepStatusUpgrading:
+		if step.Status != StepStatusPending {
+			return fmt.Errorf("%w: the upgrade of the node has already started", ErrInvalidReport)
+		}
+
+		step.StartedAt = &now
+	case StepStatusSucceeded, StepStatusFailed:
+		if step.StartedAt == nil {
+			step.StartedAt = &now
+		}
+
+		step.FinishedAt = &now
+	default:
+		return fmt.Errorf("%w: unexpected status %s", ErrInvalidReport, status)
+	}
+
+	step.Status = status
+	step.Message = message
+
+	switch {
+	case status == StepStatusFailed:
+		r.Status = StatusHalted
+	case r.CurrentStep() == nil:
+		r.Status = StatusCompleted
+	}
+
+	return nil
+}
+
+// Expire fails the current step when its server has not finished the upgrade within the timeout, so that the rollout
+// does not wait forever: an upgrading server may never come back (e.g. the upgraded container cannot reach the
+// gateway), a pending one may be offline and never pick up its step. Reports whether the rollout changed
+func (r *Rollout) Expire(timeout time.Duration, now time.Time) bool {
+	step := r.CurrentStep()
+
+	if step == nil {
+		return false
+	}
+
+	turnStartedAt := r.turnStartedAt(step)
+
+	if turnStartedAt.IsZero() || now.Sub(turnStartedAt) < timeout {
+		return false
+	}
+
+	message := fmt.Sprintf("the server has not reported back within %s", timeout)
+
+	if step.Status == StepStatusPending {
+		message = fmt.Sprintf("the server has not started its upgrade within %s, is it offline?", timeout)
+	}
+
+	return r.Report(step.NodeID, StepStatusFailed, message, now) == nil
+}
+
+// turnStartedAt returns when the server of the step started its upgrade, or when its turn came for a pending step: the
+// end of the previous step, the start of the rollout for the first one
+func (r *Rollout) turnStartedAt(step *Step) time.Time {
+	if step.StartedAt != nil {
+		return *step.StartedAt
+	}
+
+	turnStartedAt := r.CreatedAt
+
+	for _, previous := range r.Steps {
+		if previous.Position < step.Position && previous.FinishedAt != nil && previous.FinishedAt.After(turnStartedAt) {
+			turnStartedAt = *previous.FinishedAt
+		}
+	}
+
+	return turnStartedAt
+}
+
+// Cancel stops a running rollout that is stuck, its current step fails; the servers upgraded so far keep the new image
+func (r *Rollout) Cancel(now time.Time) error {
+	if r.Status != StatusRunning {
+		return fmt.Errorf("%w: rollout %s is %s", ErrRolloutNotRunning, r.ID, r.Status)
+	}
+
+	if step := r.CurrentStep(); step != nil {
+		if step.StartedAt == nil {
+			step.StartedAt = &now
+		}
+
+		step.FinishedAt = &now
+		step.Status = StepStatusFailed
+		step.Message = "the upgrade has been cancelled"
+	}
+
+	r.Status = StatusCancelled
+
+	return nil
+}
+
+// Finished reports whether the rollout is over, successfully or not
+func (r *Rollout) Finished() bool {
+	return r.Status != StatusRunning
+}
@@ -0,0 +1,170 @@
+package rollouts
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"wireport/internal/nodes/types"
+)
+
+func testServers() []types.Node {
+	return []types.Node{
+		{ID: "gateway", Role: types.NodeRoleGateway},
+		{ID: "server-1", Role: types.NodeRoleServer, Name: "web-1", Labels: []string{"web"}},
+		{ID: "client-1", Role: types.NodeRoleClient},
+		{ID: "server-2", Role: types.NodeRoleServer, Name: "db-1", Labels: []string{"db"}},
+		{ID: "server-3", Role: types.NodeRoleServer, Name: "web-2", Labels: []string{"web"}},
+	}
+}
+
+func TestNewRollout(t *testing.T) {
+	rollout, err := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "", testServers())
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(rollout.Steps) != 3 || rollout.Steps[0].NodeID != "server-1" || rollout.Steps[2].NodeID != "server-3" || rollout.Steps[2].Position != 3 {
+		t.Errorf("expected all servers in their order, got %+v", rollout.Steps)
+	}
+
+	rollout, err = NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "web", testServers())
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(rollout.Steps) != 2 || rollout.Steps[1].NodeName != "web-2" {
+		t.Errorf("expected the web servers only, got %+v", rollout.Steps)
+	}
+
+	if _, err = NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "cache", testServers()); !errors.Is(err, ErrNoServersToUpgrade) {
+		t.Errorf("expected ErrNoServersToUpgrade, got %v", err)
+	}
+}
+
+func TestRollout_Report_CompletesInOrder(t *testing.T) {
+	rollout, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "web", testServers())
+	now := time.Now()
+
+	if rollout.AssignmentFor("server-3") != nil {
+		t.Fatalf("expected no assignment for the second server before the first one is upgraded")
+	}
+
+	if assignment := rollout.AssignmentFor("server-1"); assignment == nil || assignment.Status != StepStatusPending || assignment.Image != rollout.Image {
+		t.Fatalf("expected a pending assignment for the first server, got %+v", assignment)
+	}
+
+	if err := rollout.Report("server-3", StepStatusUpgrading, "", now); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("expected ErrInvalidReport for a server out of turn, got %v", err)
+	}
+
+	if err := rollout.Report("server-1", StepStatusSucceeded, "", now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := rollout.Report("server-3", StepStatusUpgrading, "", now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := rollout.Report("server-3", StepStatusUpgrading, "", now); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("expected ErrInvalidReport for a repeated start, got %v", err)
+	}
+
+	if err := rollout.Report("server-3", StepStatusSucceeded, "", now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rollout.Status != StatusCompleted || !rollout.Finished() || rollout.CurrentStep() != nil {
+		t.Errorf("expected the rollout to be completed, got %s", rollout.Status)
+	}
+}
+
+func TestRollout_Report_HaltsOnFailure(t *testing.T) {
+	rollout, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "", testServers())
+	now := time.Now()
+
+	_ = rollout.Report("server-1", StepStatusUpgrading, "", now)
+
+	if err := rollout.Report("server-1", StepStatusFailed, "image not found", now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rollout.Status != StatusHalted || rollout.Steps[0].Message != "image not found" {
+		t.Errorf("expected the rollout to be halted, got %s %+v", rollout.Status, rollout.Steps[0])
+	}
+
+	if rollout.AssignmentFor("server-2") != nil || rollout.Steps[1].Status != StepStatusPending {
+		t.Errorf("expected the remaining servers to be left as they are, got %+v", rollout.Steps[1])
+	}
+}
+
+func TestRollout_Expire(t *testing.T) {
+	rollout, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "", testServers())
+	startedAt := time.Now()
+	rollout.CreatedAt = startedAt
+
+	_ = rollout.Report("server-1", StepStatusUpgrading, "", startedAt)
+
+	if rollout.Expire(time.Minute, startedAt.Add(30*time.Second)) {
+		t.Errorf("expected the step not to expire within the timeout")
+	}
+
+	if !rollout.Expire(time.Minute, startedAt.Add(2*time.Minute)) || rollout.Status != StatusHalted || rollout.Steps[0].Status != StepStatusFailed {
+		t.Errorf("expected the step to fail and the rollout to halt, got %s %+v", rollout.Status, rollout.Steps[0])
+	}
+}
+
+func TestRollout_Expire_OfflineServer(t *testing.T) {
+	rollout, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "", testServers())
+	createdAt := time.Now()
+	rollout.CreatedAt = createdAt
+
+	if rollout.Expire(time.Minute, createdAt.Add(30*time.Second)) {
+		t.Errorf("expected the pending step not to expire within the timeout")
+	}
+
+	// server-1 is upgraded late, the turn of server-2 starts when server-1 is finished
+	finishedAt := createdAt.Add(50 * time.Second)
+	_ = rollout.Report("server-1", StepStatusSucceeded, "", finishedAt)
+
+	if rollout.Expire(time.Minute, finishedAt.Add(30*time.Second)) {
+		t.Errorf("expected the step of server-2 not to expire within the timeout of its turn")
+	}
+
+	// server-2 is offline and never picks up its step
+	if !rollout.Expire(time.Minute, finishedAt.Add(2*time.Minute)) || rollout.Status != StatusHalted {
+		t.Fatalf("expected the rollout to halt, got %s", rollout.Status)
+	}
+
+	if step := rollout.Steps[1]; step.NodeID != "server-2" || step.Status != StepStatusFailed || step.Message == "" {
+		t.Errorf("expected the step of server-2 to fail, got %+v", step)
+	}
+
+	if rollout.Steps[2].Status != StepStatusPending {
+		t.Errorf("expected the remaining server not to be touched, got %+v", rollout.Steps[2])
+	}
+}
+
+func TestRollout_Cancel(t *testing.T) {
+	rollout, _ := NewRollout("ghcr.io/multionlabs/wireport:1.2.0", "", testServers())
+	now := time.Now()
+
+	_ = rollout.Report("server-1", StepStatusUpgrading, "", now)
+
+	if err := rollout.Cancel(now); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if rollout.Status != StatusCancelled || !rollout.Finished() || rollout.Steps[0].Status != StepStatusFailed || rollout.Steps[1].Status != StepStatusPending {
+		t.Errorf("expected the rollout to be cancelled with its current step failed, got %s %+v", rollout.Status, rollout.Steps[0])
+	}
+
+	if rollout.AssignmentFor("server-2") != nil {
+		t.Errorf("expected no assignment in a cancelled rollout")
+	}
+
+	if err := rollout.Cancel(now); !errors.Is(err, ErrRolloutNotRunning) {
+		t.Errorf("expected ErrRolloutNotRunning, got %v", err)
+	}
+}
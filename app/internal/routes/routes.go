@@ -2,7 +2,9 @@ package routes
 
 import (
 	"net/http"
+	"wireport/cmd/server/config"
 	"wireport/internal/commands"
+	"wireport/internal/metrics"
 
 	"gorm.io/gorm"
 )
@@ -12,5 +14,5 @@ func Router(db *gorm.DB) http.Handler {
 
 	commands.RegisterRoutes(mux, db)
 
-	return mux
+	return commands.LogRequests(commands.ControlAPIAccess(commands.RefuseRevokedCertificates(commands.NegotiateVersions(commands.LimitRequestBodies(metrics.InstrumentHandler(mux), config.Config.ControlServerMaxBodyBytes), db), db), db))
 }
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 	"wireport/cmd/server/config"
+	"wireport/internal/encryption/mtls"
 	"wireport/internal/templates"
 
 	"github.com/aymerick/raymond"
@@ -236,6 +239,11 @@ func (s *Service) InstallWireportGateway(image string, imageTag string) (bool, *
 	installCmdStr, err := tpl.Exec(map[string]string{
 		"wireportGatewayContainerName":  config.Config.WireportGatewayContainerName,
 		"wireportGatewayContainerImage": fmt.Sprintf("%s:%s", image, imageTag),
+		"wireportGatewayDataDir":        config.Config.WireportGatewayDataDir,
+		"controlServerPort":             strconv.Itoa(int(config.Config.ControlServerPort)),
+		"wgPublicPort":                  strconv.Itoa(int(config.Config.WGPublicPort)),
+		"healthServerAddress":           config.Config.HealthServerAddress,
+		"dockerNetworkName":             config.Config.DockerNetworkName,
 	})
 
 	if err != nil {
@@ -263,6 +271,50 @@ func (s *Service) InstallWireportGateway(image string, imageTag string) (bool, *
 	return true, clientJoinToken, nil
 }
 
+// UploadGatewayCA writes the intermediate into the data directory of the gateway before its first start, the gateway
+// signs its certificates with it instead of a root CA of its own
+func (s *Service) UploadGatewayCA(intermediate *mtls.Intermediate) error {
+	uploadCmdTemplate, err := templates.Scripts.ReadFile(config.Config.UpGatewayCAScriptTemplatePath)
+
+	if err != nil {
+		return err
+	}
+
+	tpl, err := raymond.Parse(string(uploadCmdTemplate))
+
+	if err != nil {
+		return err
+	}
+
+	uploadCmdStr, err := tpl.Exec(map[string]string{
+		"wireportGatewayCADir": path.Join(config.Config.WireportGatewayDataDir, "ca"),
+		"intermediateKeyFile":  mtls.IntermediateKeyFile,
+		"intermediateKeyPEM":   strings.TrimSpace(intermediate.CA.KeyPEM),
+		"intermediateCertFile": mtls.IntermediateCertFile,
+		"intermediateCertPEM":  strings.TrimSpace(intermediate.CA.CertPEM),
+		"rootCertFile":         mtls.RootCertFile,
+		"rootCertPEM":          strings.TrimSpace(intermediate.RootCertPEM),
+		"revocationListFile":   mtls.RevocationListFile,
+		"revocationList":       strings.TrimSpace(intermediate.RevocationList),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	cmdResult, err := s.executeCommand(uploadCmdStr)
+
+	if err != nil {
+		return err
+	}
+
+	if cmdResult.ExitCode != 0 {
+		return fmt.Errorf("failed to upload the gateway intermediate: %s", cmdResult.Stderr)
+	}
+
+	return nil
+}
+
 func (s *Service) createClientJoinToken() (*string, error) {
 	createClientCmdTemplate, err := templates.Scripts.ReadFile(config.Config.NewClientScriptTemplatePath)
 
@@ -489,41 +541,6 @@ func (s *Service) TeardownWireportServer() (bool, error) {
 	return true, nil
 }
 
-func (s *Service) UpgradeWireportGateway(image string, imageTag string) (bool, error) {
-	upgradeCmdTemplate, err := templates.Scripts.ReadFile(config.Config.UpgradeGatewayScriptTemplatePath)
-
-	if err != nil {
-		return false, err
-	}
-
-	tpl, err := raymond.Parse(string(upgradeCmdTemplate))
-
-	if err != nil {
-		return false, err
-	}
-
-	upgradeCmdStr, err := tpl.Exec(map[string]string{
-		"wireportGatewayContainerName":  config.Config.WireportGatewayContainerName,
-		"wireportGatewayContainerImage": fmt.Sprintf("%s:%s", image, imageTag),
-	})
-
-	if err != nil {
-		return false, err
-	}
-
-	cmdResult, err := s.executeCommand(upgradeCmdStr)
-
-	if err != nil {
-		return false, err
-	}
-
-	if cmdResult.ExitCode != 0 {
-		return false, fmt.Errorf("failed to upgrade wireport gateway: %s", cmdResult.Stderr)
-	}
-
-	return true, nil
-}
-
 func (s *Service) UpgradeWireportServer(image string, imageTag string) (bool, error) {
 	upgradeCmdTemplate, err := templates.Scripts.ReadFile(config.Config.UpgradeServerScriptTemplatePath)
 
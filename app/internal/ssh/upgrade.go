@@ -0,0 +1,248 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"wireport/cmd/server/config"
+	"wireport/internal/templates"
+
+	"github.com/aymerick/raymond"
+)
+
+// GatewayUpgradeStep is a step of the gateway upgrade, reported to the caller as soon as it is finished
+type GatewayUpgradeStep string
+
+const (
+	GatewayUpgradeStepPreflight GatewayUpgradeStep = "pre-flight"
+	GatewayUpgradeStepPull      GatewayUpgradeStep = "pull"
+	GatewayUpgradeStepBackup    GatewayUpgradeStep = "backup"
+	GatewayUpgradeStepStart     GatewayUpgradeStep = "start"
+	GatewayUpgradeStepReadiness GatewayUpgradeStep = "readiness"
+	GatewayUpgradeStepVerify    GatewayUpgradeStep = "verify"
+	GatewayUpgradeStepCleanup   GatewayUpgradeStep = "cleanup"
+	GatewayUpgradeStepRollback  GatewayUpgradeStep = "rollback"
+)
+
+// GatewayUpgradeResult describes what UpgradeWireportGateway did on the gateway host
+type GatewayUpgradeResult struct {
+	PreviousImage string
+	Image         string
+	BackupDir     string
+	// the upgrade failed after the gateway was stopped, the previous container and database were restored
+	RolledBack    bool
+	RollbackError error
+}
+
+// UpgradeWireportGateway replaces the gateway container with the given image. The gateway is stopped, its database is
+// snapshotted and the previous container is kept aside until the new one passes the readiness check and verify (the mTLS
+// round trip of the caller, optional). Otherwise the previous container and database are restored. progress is called
+// after every step with its details or error.
+func (s *Service) UpgradeWireportGateway(image string, imageTag string, verify func() error, progress func(step GatewayUpgradeStep, details string, err error)) (*GatewayUpgradeResult, error) {
+	containerName := config.Config.WireportGatewayContainerName
+	previousContainerName := containerName + "-previous"
+
+	result := &GatewayUpgradeResult{
+		Image:     fmt.Sprintf("%s:%s", image, imageTag),
+		BackupDir: fmt.Sprintf("%s/gateway-%s", config.Config.WireportGatewayBackupsDir, time.Now().UTC().Format("20060102-150405")),
+	}
+
+	// 1. pre-flight: nothing is touched until the current gateway is known and the new image is on the host
+
+	previousImage, err := s.runUpgradeCommand(fmt.Sprintf("docker inspect --format '{{.Config.Image}}' %s", containerName))
+
+	if err != nil {
+		err = fmt.Errorf("wireport gateway container not found: %w", err)
+		progress(GatewayUpgradeStepPreflight, "", err)
+		return result, err
+	}
+
+	result.PreviousImage = previousImage
+
+	// leftover of an interrupted upgrade, the gateway container itself exists
+	if _, err = s.runUpgradeCommand(fmt.Sprintf("docker rm -f %s >/dev/null 2>&1 || true", previousContainerName)); err != nil {
+		progress(GatewayUpgradeStepPreflight, "", err)
+		return result, err
+	}
+
+	progress(GatewayUpgradeStepPreflight, previousImage, nil)
+
+	if _, err = s.runUpgradeCommand(fmt.Sprintf("docker pull %s", result.Image)); err != nil {
+		err = fmt.Errorf("failed to pull %s: %w", result.Image, err)
+		progress(GatewayUpgradeStepPull, "", err)
+		return result, err
+	}
+
+	progress(GatewayUpgradeStepPull, result.Image, nil)
+
+	// 2. stop the gateway, snapshot the database and keep the container for the rollback
+
+	backupCmdStr, err := renderScript(config.Config.BackupGatewayScriptTemplatePath, map[string]any{
+		"wireportGatewayContainerName": containerName,
+		"previousContainerName":        previousContainerName,
+		"wireportGatewayDataDir":       config.Config.WireportGatewayDataDir,
+		"databaseName":                 config.Config.WireportGatewayDatabaseName,
+		"backupDir":                    result.BackupDir,
+	})
+
+	if err != nil {
+		progress(GatewayUpgradeStepBackup, "", err)
+		return result, err
+	}
+
+	if _, err = s.runUpgradeCommand(backupCmdStr); err != nil {
+		err = fmt.Errorf("failed to back up the gateway database: %w", err)
+		progress(GatewayUpgradeStepBackup, "", err)
+		return result, s.rollbackWireportGateway(result, previousContainerName, false, err, progress)
+	}
+
+	progress(GatewayUpgradeStepBackup, result.BackupDir, nil)
+
+	// 3. start and verify the new gateway
+
+	upgradeCmdStr, err := renderScript(config.Config.UpgradeGatewayScriptTemplatePath, map[string]any{
+		"wireportGatewayContainerName":  containerName,
+		"wireportGatewayContainerImage": result.Image,
+		"wireportGatewayDataDir":        config.Config.WireportGatewayDataDir,
+		"controlServerPort":             config.Config.ControlServerPort,
+		"wgPublicPort":                  config.Config.WGPublicPort,
+		"healthServerAddress":           config.Config.HealthServerAddress,
+		"dockerNetworkName":             config.Config.DockerNetworkName,
+	})
+
+	if err == nil {
+		_, err = s.runUpgradeCommand(upgradeCmdStr)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to start the upgraded gateway: %w", err)
+		progress(GatewayUpgradeStepStart, "", err)
+		return result, s.rollbackWireportGateway(result, previousContainerName, true, err, progress)
+	}
+
+	progress(GatewayUpgradeStepStart, result.Image, nil)
+
+	if err = s.waitForWireportGatewayReadiness(); err != nil {
+		progress(GatewayUpgradeStepReadiness, "", err)
+		return result, s.rollbackWireportGateway(result, previousContainerName, true, err, progress)
+	}
+
+	progress(GatewayUpgradeStepReadiness, "", nil)
+
+	if verify != nil {
+		if err = verify(); err != nil {
+			err = fmt.Errorf("mTLS round trip with the upgraded gateway failed: %w", err)
+			progress(GatewayUpgradeStepVerify, "", err)
+			return result, s.rollbackWireportGateway(result, previousContainerName, true, err, progress)
+		}
+
+		progress(GatewayUpgradeStepVerify, "", nil)
+	}
+
+	// 4. the previous container is not needed anymore, the newest database snapshots are kept
+
+	_, err = s.runUpgradeCommand(fmt.Sprintf(
+		"docker rm %s >/dev/null && (ls -1dt %s/gateway-* | tail -n +%d | xargs -r rm -rf)",
+		previousContainerName,
+		config.Config.WireportGatewayBackupsDir,
+		config.Config.WireportGatewayBackupsKeep+1,
+	))
+
+	// the upgrade itself has succeeded, a leftover is only reported
+	progress(GatewayUpgradeStepCleanup, "", err)
+
+	return result, nil
+}
+
+// rollbackWireportGateway brings the previous gateway container back, with the database snapshot when the new gateway
+// may have already used the database. The cause of the rollback is returned, joined with the rollback error if any
+func (s *Service) rollbackWireportGateway(result *GatewayUpgradeResult, previousContainerName string, restoreDatabase bool, cause error, progress func(step GatewayUpgradeStep, details string, err error)) error {
+	result.RolledBack = true
+
+	rollbackCmdStr, err := renderScript(config.Config.RollbackGatewayScriptTemplatePath, map[string]any{
+		"wireportGatewayContainerName": config.Config.WireportGatewayContainerName,
+		"previousContainerName":        previousContainerName,
+		"wireportGatewayDataDir":       config.Config.WireportGatewayDataDir,
+		"databaseName":                 config.Config.WireportGatewayDatabaseName,
+		"backupDir":                    result.BackupDir,
+		"restoreDatabase":              restoreDatabase,
+	})
+
+	if err == nil {
+		_, err = s.runUpgradeCommand(rollbackCmdStr)
+	}
+
+	if err != nil {
+		result.RollbackError = fmt.Errorf("failed to roll back the gateway: %w", err)
+		progress(GatewayUpgradeStepRollback, "", result.RollbackError)
+		return errors.Join(cause, result.RollbackError)
+	}
+
+	progress(GatewayUpgradeStepRollback, result.PreviousImage, nil)
+
+	return cause
+}
+
+// waitForWireportGatewayReadiness polls /readyz of the gateway from inside its container (the health server listens on
+// the loopback address of the container only)
+func (s *Service) waitForWireportGatewayReadiness() error {
+	readinessCmdStr := fmt.Sprintf(
+		"docker exec %s wget -q -O /dev/null http://%s/readyz",
+		config.Config.WireportGatewayContainerName,
+		config.Config.HealthServerAddress,
+	)
+
+	deadline := time.Now().Add(config.Config.GatewayUpgradeReadinessTimeout)
+
+	for {
+		cmdResult, err := s.executeCommand(readinessCmdStr)
+
+		if err != nil {
+			return err
+		}
+
+		if cmdResult.ExitCode == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the upgraded gateway is not ready after %s", config.Config.GatewayUpgradeReadinessTimeout)
+		}
+
+		time.Sleep(config.Config.GatewayUpgradeReadinessPoll)
+	}
+}
+
+func (s *Service) runUpgradeCommand(command string) (string, error) {
+	cmdResult, err := s.executeCommand(command)
+
+	if err != nil {
+		return "", err
+	}
+
+	if cmdResult.ExitCode != 0 {
+		if cmdResult.Stderr != "" {
+			return "", errors.New(cmdResult.Stderr)
+		}
+
+		return "", fmt.Errorf("exit code %d", cmdResult.ExitCode)
+	}
+
+	return cmdResult.Stdout, nil
+}
+
+func renderScript(templatePath string, context map[string]any) (string, error) {
+	scriptTemplate, err := templates.Scripts.ReadFile(templatePath)
+
+	if err != nil {
+		return "", err
+	}
+
+	tpl, err := raymond.Parse(string(scriptTemplate))
+
+	if err != nil {
+		return "", err
+	}
+
+	return tpl.Exec(context)
+}
@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+	"wireport/cmd/server/config"
+)
+
+func TestRenderScript_UpgradeGateway(t *testing.T) {
+	got, err := renderScript(config.Config.UpgradeGatewayScriptTemplatePath, map[string]any{
+		"wireportGatewayContainerName":  "wireport-gateway",
+		"wireportGatewayContainerImage": "anybotsllc/wireport:1.2.0",
+		"wireportGatewayDataDir":        "~/.wireport-docker/gateway",
+		"controlServerPort":             4060,
+		"wgPublicPort":                  51820,
+		"healthServerAddress":           "127.0.0.1:4071",
+		"dockerNetworkName":             "wireport-net",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, expected := range []string{
+		"-p 51820:51820/udp",
+		"-p 4060:4060/tcp",
+		"-e WG_PUBLIC_PORT=51820 -e CONTROL_SERVER_PORT=4060",
+		"-e HEALTH_SERVER_ADDRESS='127.0.0.1:4071' -e DOCKER_NETWORK_NAME='wireport-net'",
+		"-e WIREPORT_GATEWAY_CONTAINER_NAME='wireport-gateway'",
+		`--health-cmd "wget -q -O /dev/null http://127.0.0.1:4071/readyz || exit 1"`,
+		"-v ~/.wireport-docker/gateway:/app/wireport",
+		"--name 'wireport-gateway'",
+		"anybotsllc/wireport:1.2.0 gateway",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected the script to contain %q, got %s", expected, got)
+		}
+	}
+}
+
+func TestRenderScript_BackupGateway(t *testing.T) {
+	got, err := renderScript(config.Config.BackupGatewayScriptTemplatePath, map[string]any{
+		"wireportGatewayContainerName": "wireport-gateway",
+		"previousContainerName":        "wireport-gateway-previous",
+		"wireportGatewayDataDir":       "~/.wireport-docker/gateway",
+		"databaseName":                 "wireport.db",
+		"backupDir":                    "~/.wireport-docker/backups/gateway-20261018-120000",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := `docker stop -t 30 wireport-gateway && \
+mkdir -p ~/.wireport-docker/backups/gateway-20261018-120000 && \
+cp -p ~/.wireport-docker/gateway/wireport.db* ~/.wireport-docker/backups/gateway-20261018-120000/ && \
+docker rename wireport-gateway wireport-gateway-previous
+`
+
+	if got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestRenderScript_RollbackGateway(t *testing.T) {
+	context := map[string]any{
+		"wireportGatewayContainerName": "wireport-gateway",
+		"previousContainerName":        "wireport-gateway-previous",
+		"wireportGatewayDataDir":       "~/.wireport-docker/gateway",
+		"databaseName":                 "wireport.db",
+		"backupDir":                    "~/.wireport-docker/backups/gateway-20261018-120000",
+	}
+
+	restoreContainer := `if docker inspect wireport-gateway-previous >/dev/null 2>&1; then \
+	docker rm -f wireport-gateway >/dev/null 2>&1; \
+	docker rename wireport-gateway-previous wireport-gateway || exit 1; \
+fi && \
+`
+	restoreDatabase := `rm -f ~/.wireport-docker/gateway/wireport.db-wal ~/.wireport-docker/gateway/wireport.db-shm && \
+cp -p ~/.wireport-docker/backups/gateway-20261018-120000/wireport.db* ~/.wireport-docker/gateway/ && \
+`
+	start := "docker start wireport-gateway\n"
+
+	for _, restore := range []bool{false, true} {
+		context["restoreDatabase"] = restore
+
+		got, err := renderScript(config.Config.RollbackGatewayScriptTemplatePath, context)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expected := restoreContainer + start
+
+		if restore {
+			expected = restoreContainer + restoreDatabase + start
+		}
+
+		if got != expected {
+			t.Errorf("restoreDatabase=%v: expected %s, got %s", restore, expected, got)
+		}
+	}
+}
@@ -65,6 +65,16 @@ func ParseAddress(addr string) (protocol, host *string, port *uint16, err error)
 		return nil, nil, nil, errors.New("host is required")
 	}
 
+	if strings.Contains(hostname, "*") {
+		if *protocol != "http" && *protocol != "https" {
+			return nil, nil, nil, errors.New("wildcard hosts are supported for http and https only")
+		}
+
+		if !strings.HasPrefix(hostname, "*.") || strings.Count(hostname, "*") != 1 || strings.Count(hostname, ".") < 2 {
+			return nil, nil, nil, errors.New("invalid wildcard host, expected *.example.com")
+		}
+	}
+
 	portString := u.Port()
 
 	host = &hostname
@@ -0,0 +1,25 @@
+package wg
+
+import "net"
+
+// IsAddressAssigned reports whether the IP is assigned to an interface of this machine, e.g. the WireGuard
+// address of the node once its tunnel is up
+func IsAddressAssigned(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	addrs, err := net.InterfaceAddrs()
+
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
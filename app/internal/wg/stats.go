@@ -0,0 +1,118 @@
+package wg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"wireport/internal/terminal"
+)
+
+type PeerState string
+
+const (
+	PeerStateOnline         PeerState = "online"
+	PeerStateStale          PeerState = "stale"
+	PeerStateNeverConnected PeerState = "never connected"
+)
+
+type PeerStats struct {
+	Interface       string
+	PublicKey       string
+	Endpoint        string
+	AllowedIPs      string
+	LatestHandshake time.Time // zero if there was no handshake yet
+	ReceiveBytes    uint64
+	TransmitBytes   uint64
+}
+
+// GetPeerStats returns the stats of the peers of all WireGuard interfaces
+func GetPeerStats() ([]PeerStats, error) {
+	dump, err := terminal.NewCommand("wg", "show", "all", "dump").Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDump(dump, "")
+}
+
+// GetInterfacePeerStats returns the stats of the peers of the WireGuard interface (e.g. wg0)
+func GetInterfacePeerStats(interfaceName string) ([]PeerStats, error) {
+	dump, err := terminal.NewCommand("wg", "show", interfaceName, "dump").Execute()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDump(dump, interfaceName)
+}
+
+// ParseDump parses the output of 'wg show all dump' (interfaceName is empty) or 'wg show <interfaceName> dump':
+// one line per interface followed by one line per peer (interface, public-key, preshared-key, endpoint, allowed-ips,
+// latest-handshake, transfer-rx, transfer-tx, persistent-keepalive), the interface column is omitted for a single interface
+func ParseDump(dump string, interfaceName string) ([]PeerStats, error) {
+	peers := []PeerStats{}
+
+	for _, line := range strings.Split(dump, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+
+		if interfaceName != "" {
+			fields = append([]string{interfaceName}, fields...)
+		}
+
+		if len(fields) != 9 {
+			// interface line or empty line
+			continue
+		}
+
+		latestHandshake, err := strconv.ParseInt(fields[5], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid latest handshake of peer %s: %v", fields[1], err)
+		}
+
+		receiveBytes, err := strconv.ParseUint(fields[6], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid received bytes of peer %s: %v", fields[1], err)
+		}
+
+		transmitBytes, err := strconv.ParseUint(fields[7], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid transmitted bytes of peer %s: %v", fields[1], err)
+		}
+
+		peer := PeerStats{
+			Interface:     fields[0],
+			PublicKey:     fields[1],
+			Endpoint:      fields[3],
+			AllowedIPs:    fields[4],
+			ReceiveBytes:  receiveBytes,
+			TransmitBytes: transmitBytes,
+		}
+
+		if latestHandshake > 0 {
+			peer.LatestHandshake = time.Unix(latestHandshake, 0)
+		}
+
+		peers = append(peers, peer)
+	}
+
+	return peers, nil
+}
+
+// State returns whether the peer is connected: WireGuard renews the handshake every 2 minutes
+// while the peer is up, so a handshake older than staleAfter means the peer is gone
+func (p *PeerStats) State(now time.Time, staleAfter time.Duration) PeerState {
+	if p.LatestHandshake.IsZero() {
+		return PeerStateNeverConnected
+	}
+
+	if now.Sub(p.LatestHandshake) > staleAfter {
+		return PeerStateStale
+	}
+
+	return PeerStateOnline
+}
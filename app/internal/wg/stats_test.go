@@ -0,0 +1,87 @@
+package wg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDump(t *testing.T) {
+	dump := "wg0\tcHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n" +
+		"wg0\tc2VydmVy\t(none)\t203.0.113.10:51820\t10.0.0.2/32\t1700000000\t1024\t2048\t25\n" +
+		"wg0\tY2xpZW50\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n"
+
+	peers, err := ParseDump(dump, "")
+
+	if err != nil {
+		t.Fatalf("ParseDump() error = %v", err)
+	}
+
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(peers))
+	}
+
+	server := peers[0]
+
+	if server.PublicKey != "c2VydmVy" || server.Endpoint != "203.0.113.10:51820" || server.AllowedIPs != "10.0.0.2/32" {
+		t.Errorf("unexpected peer: %+v", server)
+	}
+
+	if !server.LatestHandshake.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("expected latest handshake at 1700000000, got %v", server.LatestHandshake)
+	}
+
+	if server.ReceiveBytes != 1024 || server.TransmitBytes != 2048 {
+		t.Errorf("expected rx 1024 / tx 2048, got rx %d / tx %d", server.ReceiveBytes, server.TransmitBytes)
+	}
+
+	if !peers[1].LatestHandshake.IsZero() {
+		t.Errorf("expected no handshake for a peer that never connected, got %v", peers[1].LatestHandshake)
+	}
+}
+
+func TestParseDump_InvalidTransfer(t *testing.T) {
+	dump := "wg0\tc2VydmVy\t(none)\t203.0.113.10:51820\t10.0.0.2/32\t1700000000\tmany\t2048\t25\n"
+
+	if _, err := ParseDump(dump, ""); err == nil {
+		t.Errorf("expected an error for invalid transfer bytes")
+	}
+}
+
+func TestParseDump_SingleInterface(t *testing.T) {
+	dump := "cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n" +
+		"c2VydmVy\t(none)\t203.0.113.10:51820\t10.0.0.2/32\t1700000000\t1024\t2048\t25\n"
+
+	peers, err := ParseDump(dump, "wg0")
+
+	if err != nil {
+		t.Fatalf("ParseDump() error = %v", err)
+	}
+
+	if len(peers) != 1 || peers[0].Interface != "wg0" || peers[0].PublicKey != "c2VydmVy" || peers[0].TransmitBytes != 2048 {
+		t.Errorf("unexpected peers: %+v", peers)
+	}
+}
+
+func TestPeerStats_State(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name            string
+		latestHandshake time.Time
+		expected        PeerState
+	}{
+		{"never connected", time.Time{}, PeerStateNeverConnected},
+		{"recent handshake", now.Add(-time.Minute), PeerStateOnline},
+		{"old handshake", now.Add(-10 * time.Minute), PeerStateStale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := PeerStats{LatestHandshake: tt.latestHandshake}
+
+			if state := peer.State(now, 3*time.Minute); state != tt.expected {
+				t.Errorf("State() = %s, expected %s", state, tt.expected)
+			}
+		})
+	}
+}
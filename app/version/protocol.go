@@ -0,0 +1,87 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Protocol is the version of the control API spoken by this build: the routes, the DTOs in
+// internal/commands/types and their semantics. It is bumped on every change an older peer cannot handle,
+// e.g. a renamed or removed DTO field, a new required field or a removed route
+const Protocol = 1
+
+// MinProtocol is the oldest control API version the gateway still serves
+const MinProtocol = 1
+
+// Headers of the control API requests and responses
+const (
+	HeaderVersion  = "X-Wireport-Version"  // build of the sender, see Version
+	HeaderProtocol = "X-Wireport-Protocol" // control API version of the sender, see Protocol
+	HeaderWarning  = "X-Wireport-Warning"  // set by the gateway on responses to callers running another build
+)
+
+var ErrIncompatibleVersion = errors.New("incompatible wireport versions")
+
+// Peer is the wireport build on the other side of a control API request
+type Peer struct {
+	Version  string
+	Protocol int // 0 if the peer predates the protocol negotiation
+}
+
+func (p Peer) String() string {
+	if p.Version == "" {
+		return "an unknown wireport version"
+	}
+
+	return "wireport " + p.Version
+}
+
+// SetHeaders marks the request or response as sent by this build
+func SetHeaders(header http.Header) {
+	header.Set(HeaderVersion, Version)
+	header.Set(HeaderProtocol, strconv.Itoa(Protocol))
+}
+
+// PeerFromHeaders returns the build that sent the request or response, see SetHeaders
+func PeerFromHeaders(header http.Header) Peer {
+	protocol, err := strconv.Atoi(header.Get(HeaderProtocol))
+
+	if err != nil {
+		protocol = 0
+	}
+
+	return Peer{
+		Version:  header.Get(HeaderVersion),
+		Protocol: protocol,
+	}
+}
+
+// CheckCaller decides on a request of the caller to the gateway running this build: an error if the gateway cannot
+// serve the caller, a warning if it can but the builds differ, empty otherwise
+func CheckCaller(caller Peer) (string, error) {
+	if caller.Protocol == 0 {
+		return fmt.Sprintf("the caller runs %s, which does not report its protocol version; upgrade it to wireport %s", caller, Version), nil
+	}
+
+	if caller.Protocol < MinProtocol {
+		return "", fmt.Errorf("%w: the caller runs %s (protocol %d), the gateway runs wireport %s and accepts protocols %d-%d; upgrade wireport on the calling node",
+			ErrIncompatibleVersion, caller, caller.Protocol, Version, MinProtocol, Protocol)
+	}
+
+	if caller.Protocol > Protocol {
+		return "", fmt.Errorf("%w: the caller runs %s (protocol %d), the gateway runs wireport %s and accepts protocols %d-%d; upgrade the gateway with 'wireport gateway upgrade'",
+			ErrIncompatibleVersion, caller, caller.Protocol, Version, MinProtocol, Protocol)
+	}
+
+	if caller.Version != Version && !isDevelopment(caller.Version) && !isDevelopment(Version) {
+		return fmt.Sprintf("the gateway runs wireport %s, this node runs %s; upgrade them to the same version", Version, caller), nil
+	}
+
+	return "", nil
+}
+
+func isDevelopment(version string) bool {
+	return version == "" || version == "dev"
+}
@@ -0,0 +1,46 @@
+package version
+
+import (
+	"errors"
+	"testing"
+)
+
+func withVersion(t *testing.T, version string) {
+	previous := Version
+	Version = version
+
+	t.Cleanup(func() {
+		Version = previous
+	})
+}
+
+func TestCheckCaller(t *testing.T) {
+	withVersion(t, "1.4.0")
+
+	tests := []struct {
+		name        string
+		caller      Peer
+		wantWarning bool
+		wantErr     error
+	}{
+		{"same build", Peer{Version: "1.4.0", Protocol: Protocol}, false, nil},
+		{"other build of the same protocol", Peer{Version: "1.3.2", Protocol: Protocol}, true, nil},
+		{"development build", Peer{Version: "dev", Protocol: Protocol}, false, nil},
+		{"no negotiation", Peer{}, true, nil},
+		{"too new", Peer{Version: "2.0.0", Protocol: Protocol + 1}, false, ErrIncompatibleVersion},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warning, err := CheckCaller(test.caller)
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+
+			if (warning != "") != test.wantWarning {
+				t.Errorf("expected a warning: %v, got %q", test.wantWarning, warning)
+			}
+		})
+	}
+}
@@ -42,6 +42,10 @@ elif [ "$1" = "server" ]; then
 
         wireport server down -f
         exit 0
+    elif [ "$2" = "replace" ]; then
+        # one-off container of 'server upgrade --all', replaces the server container with one of this image
+        shift 2
+        exec wireport server replace "$@"
     else
         echo "Invalid command. Use 'start' or 'down'."
         exit 1